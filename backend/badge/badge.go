@@ -0,0 +1,238 @@
+package badge
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strings"
+
+	"backend/qr"
+)
+
+// Millimeter は1mmのポイント数です
+const Millimeter = 72 / 25.4
+
+// MinBadgeSize はバッジの幅・高さの下限です（これより小さいとQRコードを読み取りにくくなります）
+const MinBadgeSize = 25 * Millimeter
+
+// pageSizes は指定できる用紙サイズ（縦向きの幅・高さ、ポイント）です
+var pageSizes = map[string][2]float64{
+	"A3":     {841.89, 1190.55},
+	"A4":     {595.28, 841.89},
+	"A5":     {419.53, 595.28},
+	"A6":     {297.64, 419.53},
+	"B4":     {728.5, 1031.81}, // JIS
+	"B5":     {515.91, 728.5},  // JIS
+	"Letter": {612, 792},
+	"Legal":  {612, 1008},
+}
+
+// PageSizeNames は指定できる用紙サイズの名前です
+var PageSizeNames = []string{"A3", "A4", "A5", "A6", "B4", "B5", "Letter", "Legal"}
+
+// ParsePageSize は用紙サイズの名前（大文字小文字を区別しません）を幅・高さ（ポイント）に変換します
+func ParsePageSize(name string, landscape bool) (width, height float64, err error) {
+	for _, n := range PageSizeNames {
+		if strings.EqualFold(name, n) {
+			size := pageSizes[n]
+			if landscape {
+				return size[1], size[0], nil
+			}
+			return size[0], size[1], nil
+		}
+	}
+	return 0, 0, fmt.Errorf("用紙サイズは%sのいずれかを指定してください", strings.Join(PageSizeNames, "・"))
+}
+
+// Layout は用紙と1ページあたりのバッジの配置です（長さはポイント）
+type Layout struct {
+	PageWidth  float64
+	PageHeight float64
+	Columns    int
+	Rows       int
+	Margin     float64 // 用紙の端の余白
+	Gap        float64 // バッジどうしの間隔
+	CutLines   bool    // バッジの周囲に切り取り線を描画する
+}
+
+// DefaultLayout は既定の配置（A4縦・2列×5行・余白10mm・間隔なし・切り取り線あり）を返します
+// 1枚あたり約95×55mmで、名刺サイズのバッジケースに入ります
+func DefaultLayout() Layout {
+	width, height, _ := ParsePageSize("A4", false)
+	return Layout{
+		PageWidth:  width,
+		PageHeight: height,
+		Columns:    2,
+		Rows:       5,
+		Margin:     10 * Millimeter,
+		CutLines:   true,
+	}
+}
+
+// PerPage は1ページあたりのバッジの数です
+func (l Layout) PerPage() int {
+	return l.Columns * l.Rows
+}
+
+// BadgeSize はバッジ1枚の幅・高さです
+func (l Layout) BadgeSize() (width, height float64) {
+	width = (l.PageWidth - 2*l.Margin - float64(l.Columns-1)*l.Gap) / float64(l.Columns)
+	height = (l.PageHeight - 2*l.Margin - float64(l.Rows-1)*l.Gap) / float64(l.Rows)
+	return width, height
+}
+
+// Validate は配置が用紙に収まり、バッジが小さすぎないことを確認します
+func (l Layout) Validate() error {
+	if l.Columns < 1 || l.Rows < 1 {
+		return fmt.Errorf("列数・行数は1以上を指定してください")
+	}
+	if l.Margin < 0 || l.Gap < 0 {
+		return fmt.Errorf("余白・間隔は0以上を指定してください")
+	}
+	if width, height := l.BadgeSize(); width < MinBadgeSize || height < MinBadgeSize {
+		return fmt.Errorf("バッジが小さすぎます（1枚が縦横%.0fmm以上になるよう、列数・行数・余白・間隔を減らしてください）", MinBadgeSize/Millimeter)
+	}
+	return nil
+}
+
+// Badge はバッジ1枚の内容です
+type Badge struct {
+	DisplayName string
+	AKA         string
+	Icon        image.Image // nilの場合はアイコンを描画しません
+	URL         string      // QRコードにするプロフィールの公開ページのURL
+}
+
+// 文字の大きさの上限・下限（ポイント）
+const (
+	maxNameSize = 28
+	minNameSize = 8
+	minAKASize  = 6
+)
+
+// Render はバッジを並べたPDFを書き出します
+// 1ページにlayout.PerPage()枚ずつ、左上から行ごとに並べます
+func Render(w io.Writer, badges []Badge, layout Layout) error {
+	if err := layout.Validate(); err != nil {
+		return err
+	}
+	if len(badges) == 0 {
+		return fmt.Errorf("バッジがありません")
+	}
+
+	doc := newDocument()
+	width, height := layout.BadgeSize()
+	var p *page
+	for i, b := range badges {
+		slot := i % layout.PerPage()
+		if slot == 0 {
+			if p != nil {
+				p.finish()
+			}
+			p = doc.newPage(layout.PageWidth, layout.PageHeight)
+		}
+		x := layout.Margin + float64(slot%layout.Columns)*(width+layout.Gap)
+		y := layout.Margin + float64(slot/layout.Columns)*(height+layout.Gap)
+		if err := p.badge(b, x, y, width, height, layout.CutLines); err != nil {
+			return err
+		}
+	}
+	p.finish()
+	return doc.write(w)
+}
+
+// badge はバッジ1枚を描画します（x・yは左上）
+// 横長のバッジは左に名前など・右にQRコード、縦長のバッジは上に名前など・下にQRコードを配置します
+func (p *page) badge(b Badge, x, y, width, height float64, cutLines bool) error {
+	if cutLines {
+		p.rect(x, y, width, height)
+		p.stroke(0.7, 0.5, 3)
+	}
+
+	code, err := qr.Encode(b.URL, qr.DefaultOptions())
+	if err != nil {
+		return err
+	}
+
+	pad := math.Min(width, height) * 0.08
+	x, y, width, height = x+pad, y+pad, width-2*pad, height-2*pad
+	var qrX, qrY, qrSide float64
+	var infoX, infoY, infoWidth, infoHeight float64
+	if width >= height {
+		qrSide = math.Min(height, width*0.45)
+		qrX, qrY = x+width-qrSide, y+(height-qrSide)/2
+		infoX, infoY, infoWidth, infoHeight = x, y, width-qrSide-pad/2, height
+	} else {
+		qrSide = math.Min(width*0.75, height*0.5)
+		qrX, qrY = x+(width-qrSide)/2, y+height-qrSide
+		infoX, infoY, infoWidth, infoHeight = x, y, width, height-qrSide-pad/2
+	}
+
+	// QRコードはベクターで描画する（周囲の余白も含めてqrSideに収める）
+	quiet := float64(code.Options.QuietZone)
+	module := qrSide / (float64(len(code.Modules)) + 2*quiet)
+	p.modules(code.Modules, qrX+quiet*module, qrY+quiet*module, module)
+
+	p.profile(b, infoX, infoY, infoWidth, infoHeight)
+	return nil
+}
+
+// profile はアイコン・表示名・肩書きを領域の中央に縦に並べて描画します
+// 名前が長い場合は文字を小さくし、下限でも収まらない場合は末尾を省略します
+func (p *page) profile(b Badge, x, y, width, height float64) {
+	nameSize := math.Min(maxNameSize, height*0.22)
+	name, nameSize := fitText(b.DisplayName, width, nameSize, minNameSize)
+	aka, akaSize := fitText(b.AKA, width, math.Max(nameSize*0.6, minAKASize), minAKASize)
+
+	lines := nameSize * 1.2
+	if aka != "" {
+		lines += akaSize * 1.2
+	}
+	var iconSide, iconGap float64
+	if b.Icon != nil {
+		iconGap = nameSize * 0.4
+		iconSide = math.Min(math.Min(width*0.5, height*0.45), height-lines-iconGap)
+		// 小さすぎるアイコンは描画しない
+		if iconSide < 10 {
+			iconSide, iconGap = 0, 0
+		}
+	}
+
+	top := y + (height-iconSide-iconGap-lines)/2
+	if iconSide > 0 {
+		p.circleImage(b.Icon, x+(width-iconSide)/2, top, iconSide)
+		top += iconSide + iconGap
+	}
+	top = p.centeredLine(name, nameSize, x, width, top)
+	if aka != "" {
+		p.centeredLine(aka, akaSize, x, width, top)
+	}
+}
+
+// centeredLine は1行を中央揃えで描画し、次の行の上端を返します
+func (p *page) centeredLine(s string, size, x, width, top float64) float64 {
+	lineHeight := size * 1.2
+	baseline := top + (lineHeight-size)/2 + size*fontAscent/(fontAscent+fontDescent)
+	p.text(x+(width-textWidth(s, size))/2, baseline, size, s)
+	return top + lineHeight
+}
+
+// fitText は幅に収まるように文字の大きさを小さくし、下限でも収まらない場合は末尾を…で省略します
+func fitText(s string, width, size, minSize float64) (string, float64) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", size
+	}
+	if w := textWidth(s, size); w > width {
+		size = math.Max(size*width/w, minSize)
+	}
+	if textWidth(s, size) <= width {
+		return s, size
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && textWidth(string(runes)+"…", size) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…", size
+}
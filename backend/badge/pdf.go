@@ -0,0 +1,275 @@
+package badge
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// バッジの描画に必要な機能だけを持つPDF（1.4）の書き出しです
+// 座標は左上を原点とするポイント（1/72インチ）で指定し、書き出し時にPDFの座標系（左下が原点）に変換します
+
+// 日本語を表示するため、Adobe-Japan1の標準CIDフォント（埋め込まず、閲覧環境の日本語フォントで表示）を使います
+// UniJIS-UCS2-HW-H では半角の英数字・カタカナが半角幅のグリフ（CID 231〜632）になるので、幅を計算で求められます
+const (
+	fontName     = "HeiseiKakuGo-W5"
+	fontEncoding = "UniJIS-UCS2-HW-H"
+	// fontAscent・fontDescent はフォントの高さ（1000分率）です
+	fontAscent  = 880
+	fontDescent = 120
+)
+
+// maxImageSide は埋め込む画像の一辺の上限ピクセル数です（大きい画像は縮小してファイルサイズを抑えます）
+const maxImageSide = 512
+
+// document はPDFのオブジェクトを組み立てます
+type document struct {
+	objects [][]byte // オブジェクト番号-1の順の本体
+	pages   []int    // ページオブジェクトの番号
+	font    int
+	images  map[image.Image]int // 同じ画像は1度だけ埋め込みます
+}
+
+// 1・2番はカタログとページツリーに予約し、書き出し時に中身を入れます
+const (
+	catalogObject = 1
+	pagesObject   = 2
+)
+
+func newDocument() *document {
+	d := &document{objects: make([][]byte, 2), images: map[image.Image]int{}}
+	descriptor := d.add([]byte(fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 4 /FontBBox [-92 -250 1010 922] /ItalicAngle 0 /Ascent %d /Descent -%d /CapHeight 737 /StemV 114 >>",
+		fontName, fontAscent, fontDescent)))
+	cidFont := d.add([]byte(fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Japan1) /Supplement 2 >> /FontDescriptor %d 0 R /DW 1000 /W [231 632 500] >>",
+		fontName, descriptor)))
+	d.font = d.add([]byte(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s-%s /Encoding /%s /DescendantFonts [%d 0 R] >>",
+		fontName, fontEncoding, fontEncoding, cidFont)))
+	return d
+}
+
+// add はオブジェクトを追加し、その番号を返します
+func (d *document) add(body []byte) int {
+	d.objects = append(d.objects, body)
+	return len(d.objects)
+}
+
+// addStream はFlateDecodeで圧縮したストリームのオブジェクトを追加します（dictはストリームの長さ・圧縮方式以外の項目）
+func (d *document) addStream(dict string, data []byte) int {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(data)
+	zw.Close()
+	body := fmt.Sprintf("<< %s /Length %d /Filter /FlateDecode >>\nstream\n", dict, compressed.Len())
+	return d.add(append(append([]byte(body), compressed.Bytes()...), "\nendstream"...))
+}
+
+// image は画像をXObjectとして埋め込み、その番号を返します（透過部分は白で塗りつぶします）
+func (d *document) image(img image.Image) int {
+	if n, ok := d.images[img]; ok {
+		return n
+	}
+	src := thumbnail(img, maxImageSide)
+	b := src.Bounds()
+	rgb := make([]byte, 0, b.Dx()*b.Dy()*3)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := src.At(x, y).RGBA()
+			// アルファ乗算済みの値に白の背景を合成する
+			white := 0xFFFF - a
+			rgb = append(rgb, byte((r+white)>>8), byte((g+white)>>8), byte((bl+white)>>8))
+		}
+	}
+	n := d.addStream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8", b.Dx(), b.Dy()), rgb)
+	d.images[img] = n
+	return n
+}
+
+// page は描画中のページです
+type page struct {
+	doc           *document
+	width, height float64
+	content       bytes.Buffer
+	images        map[int]bool
+}
+
+func (d *document) newPage(width, height float64) *page {
+	return &page{doc: d, width: width, height: height, images: map[int]bool{}}
+}
+
+// finish はページの内容をドキュメントに追加します
+func (p *page) finish() {
+	content := p.doc.addStream("", p.content.Bytes())
+	var xobjects strings.Builder
+	for n := range p.images {
+		fmt.Fprintf(&xobjects, " /Im%d %d 0 R", n, n)
+	}
+	p.doc.pages = append(p.doc.pages, p.doc.add([]byte(fmt.Sprintf(
+		"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 %d 0 R >> /XObject <<%s >> >> /Contents %d 0 R >>",
+		pagesObject, num(p.width), num(p.height), p.doc.font, xobjects.String(), content))))
+}
+
+// rect は矩形を追加します（続けてfill・strokeで描画します）
+func (p *page) rect(x, y, w, h float64) {
+	fmt.Fprintf(&p.content, "%s %s %s %s re\n", num(x), num(p.height-y-h), num(w), num(h))
+}
+
+// fill は追加した図形をグレー（0が黒、1が白）で塗りつぶします
+func (p *page) fill(gray float64) {
+	fmt.Fprintf(&p.content, "%s g f\n", num(gray))
+}
+
+// stroke は追加した図形の輪郭をグレーの破線（dashが0なら実線）で描画します
+func (p *page) stroke(gray, lineWidth, dash float64) {
+	if dash > 0 {
+		fmt.Fprintf(&p.content, "q [%s] 0 d %s w %s G S Q\n", num(dash), num(lineWidth), num(gray))
+		return
+	}
+	fmt.Fprintf(&p.content, "q %s w %s G S Q\n", num(lineWidth), num(gray))
+}
+
+// text は文字列を描画します（x・yは左端とベースライン）
+func (p *page) text(x, y, size float64, s string) {
+	fmt.Fprintf(&p.content, "BT /F1 %s Tf 0 g %s %s Td <%s> Tj ET\n", num(size), num(x), num(p.height-y), encodeText(s))
+}
+
+// circleImage は画像を中央で正方形に切り抜き、円形にくり抜いて描画します（x・yは左上、dは直径）
+func (p *page) circleImage(img image.Image, x, y, d float64) {
+	n := p.doc.image(img)
+	p.images[n] = true
+
+	b := img.Bounds()
+	// 短辺を直径に合わせ、長辺のはみ出した部分は円の外になる
+	scale := d / float64(min(b.Dx(), b.Dy()))
+	w, h := float64(b.Dx())*scale, float64(b.Dy())*scale
+	// 4つの3次ベジェ曲線で円を近似する（制御点の距離は半径の約0.5523倍）
+	r := d / 2
+	cx, cy := x+r, p.height-y-r
+	k := r * 0.5523
+	fmt.Fprintf(&p.content, "q %s %s m %s %s %s %s %s %s c %s %s %s %s %s %s c %s %s %s %s %s %s c %s %s %s %s %s %s c W n\n",
+		num(cx+r), num(cy),
+		num(cx+r), num(cy+k), num(cx+k), num(cy+r), num(cx), num(cy+r),
+		num(cx-k), num(cy+r), num(cx-r), num(cy+k), num(cx-r), num(cy),
+		num(cx-r), num(cy-k), num(cx-k), num(cy-r), num(cx), num(cy-r),
+		num(cx+k), num(cy-r), num(cx+r), num(cy-k), num(cx+r), num(cy))
+	fmt.Fprintf(&p.content, "%s 0 0 %s %s %s cm /Im%d Do Q\n", num(w), num(h), num(cx-w/2), num(cy-h/2), n)
+}
+
+// modules はQRコードのモジュールを黒で描画します（x・yは左上、sizeは1モジュールの一辺）
+func (p *page) modules(modules [][]bool, x, y, size float64) {
+	for row, line := range modules {
+		for col := 0; col < len(line); col++ {
+			if !line[col] {
+				continue
+			}
+			start := col
+			for col < len(line) && line[col] {
+				col++
+			}
+			p.rect(x+float64(start)*size, y+float64(row)*size, float64(col-start)*size, size)
+		}
+	}
+	p.fill(0)
+}
+
+// write はPDFを書き出します
+func (d *document) write(w io.Writer) error {
+	kids := make([]string, len(d.pages))
+	for i, n := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", n)
+	}
+	d.objects[catalogObject-1] = []byte(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObject))
+	d.objects[pagesObject-1] = []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+
+	var buf bytes.Buffer
+	// 2行目はバイナリを含むファイルであることを示すコメント
+	buf.WriteString("%PDF-1.4\n%\xE2\xE3\xCF\xD3\n")
+	offsets := make([]int, len(d.objects))
+	for i, body := range d.objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n", i+1)
+		buf.Write(body)
+		buf.WriteString("\nendobj\n")
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(d.objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(d.objects)+1, catalogObject, xref)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// encodeText は文字列をUCS-2（ビッグエンディアン）の16進数に変換します
+// UCS-2で表せない文字（絵文字など）は〓に置き換え、制御文字は取り除きます
+func encodeText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r < 0x20 || r == 0x7F {
+			continue
+		}
+		if r > 0xFFFF || utf16.IsSurrogate(r) {
+			r = '〓'
+		}
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	return b.String()
+}
+
+// textWidth は文字列をsizeポイントで描画したときの幅を返します（半角の文字は全角の半分の幅です）
+func textWidth(s string, size float64) float64 {
+	var width float64
+	for _, r := range s {
+		switch {
+		case r < 0x20 || r == 0x7F:
+		case r < 0x80 || (r >= 0xFF61 && r <= 0xFF9F):
+			width += 0.5
+		default:
+			width += 1
+		}
+	}
+	return width * size
+}
+
+// num は座標などの数値を小数点以下2桁までの文字列にします
+func num(v float64) string {
+	s := strings.TrimRight(fmt.Sprintf("%.2f", v), "0")
+	s = strings.TrimSuffix(s, ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// thumbnail は画像の長辺がsideを超える場合に、範囲の平均をとって縮小した画像を返します
+func thumbnail(src image.Image, side int) image.Image {
+	sb := src.Bounds()
+	if sb.Dx() <= side && sb.Dy() <= side {
+		return src
+	}
+	scale := float64(side) / float64(max(sb.Dx(), sb.Dy()))
+	w, h := max(int(float64(sb.Dx())*scale), 1), max(int(float64(sb.Dy())*scale), 1)
+	dst := image.NewRGBA64(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		sy0, sy1 := sb.Min.Y+y*sb.Dy()/h, sb.Min.Y+(y+1)*sb.Dy()/h
+		for x := 0; x < w; x++ {
+			sx0, sx1 := sb.Min.X+x*sb.Dx()/w, sb.Min.X+(x+1)*sb.Dx()/w
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			i := dst.PixOffset(x, y)
+			for j, v := range []uint64{r / n, g / n, b / n, a / n} {
+				dst.Pix[i+2*j], dst.Pix[i+2*j+1] = byte(v>>8), byte(v)
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS connections;
+DROP TABLE IF EXISTS link;
+DROP TABLE IF EXISTS option_profiles;
+DROP TABLE IF EXISTS profiles;
+DROP TABLE IF EXISTS users;
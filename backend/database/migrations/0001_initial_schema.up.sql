@@ -0,0 +1,61 @@
+-- 初期スキーマ: 既存環境に適用しても壊れないよう IF NOT EXISTS を付けています
+
+CREATE TABLE IF NOT EXISTS users (
+    id       SERIAL PRIMARY KEY,
+    name     TEXT NOT NULL,
+    email    TEXT NOT NULL UNIQUE,
+    password TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS profiles (
+    id           SERIAL PRIMARY KEY,
+    user_id      INTEGER NOT NULL REFERENCES users(id),
+    display_name TEXT NOT NULL,
+    icon_path    TEXT NOT NULL DEFAULT '',
+    aka          TEXT NOT NULL DEFAULT '',
+    hometown     TEXT NOT NULL DEFAULT '',
+    birthdate    DATE,
+    hobby        TEXT NOT NULL DEFAULT '',
+    comment      TEXT NOT NULL DEFAULT '',
+    title        TEXT NOT NULL DEFAULT '',
+    description  TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_profiles_user_id ON profiles (user_id);
+
+CREATE TABLE IF NOT EXISTS option_profiles (
+    id         SERIAL PRIMARY KEY,
+    title      TEXT NOT NULL,
+    content    TEXT NOT NULL,
+    profile_id INTEGER NOT NULL REFERENCES profiles(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_option_profiles_profile_id ON option_profiles (profile_id);
+
+CREATE TABLE IF NOT EXISTS link (
+    id          SERIAL PRIMARY KEY,
+    user_id     INTEGER REFERENCES users(id),
+    profile_id  INTEGER REFERENCES profiles(id),
+    image_url   TEXT,
+    title       VARCHAR(100) NOT NULL,
+    description TEXT,
+    url         TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_link_user_id ON link (user_id);
+CREATE INDEX IF NOT EXISTS idx_link_profile_id ON link (profile_id);
+
+CREATE TABLE IF NOT EXISTS connections (
+    id                      SERIAL PRIMARY KEY,
+    profile_id              INTEGER NOT NULL REFERENCES profiles(id),
+    connect_user_profile_id INTEGER NOT NULL REFERENCES profiles(id),
+    connected_at            TIMESTAMPTZ NOT NULL DEFAULT now(),
+    event_name              TEXT NOT NULL DEFAULT '',
+    event_date              TEXT NOT NULL DEFAULT '',
+    memo                    TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_connections_profile_id ON connections (profile_id);
+CREATE INDEX IF NOT EXISTS idx_connections_connect_user_profile_id ON connections (connect_user_profile_id);
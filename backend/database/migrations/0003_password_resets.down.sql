@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS user_token_revocations;
+DROP TABLE IF EXISTS one_time_tokens;
@@ -0,0 +1,18 @@
+-- 使い捨てトークン（パスワードリセットなど。平文は保存せずSHA-256ハッシュのみ保存）
+CREATE TABLE one_time_tokens (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose    TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    used_at    TIMESTAMPTZ
+);
+
+CREATE INDEX idx_one_time_tokens_user_id_purpose ON one_time_tokens (user_id, purpose);
+
+-- ユーザー単位の失効（この時刻より前に発行されたアクセストークンを拒否する）
+CREATE TABLE user_token_revocations (
+    user_id        INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    revoked_before TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,10 @@
+-- メールアドレスの確認状態（NULLは未確認）
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMPTZ;
+
+-- 既存のメールアドレスを正規化（前後の空白除去・小文字化）。正規化後に重複するものはそのまま残す
+UPDATE users SET email = lower(trim(email))
+WHERE email <> lower(trim(email))
+  AND NOT EXISTS (
+      SELECT 1 FROM users other
+      WHERE other.id <> users.id AND lower(trim(other.email)) = lower(trim(users.email))
+  );
@@ -0,0 +1,16 @@
+-- レート制限のトークンバケット（複数インスタンスで共有する場合に使用）
+CREATE TABLE rate_limit_buckets (
+    key        TEXT PRIMARY KEY,
+    tokens     DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_rate_limit_buckets_updated_at ON rate_limit_buckets (updated_at);
+
+-- サインインの連続失敗回数とロック状態（キーは正規化したメールアドレス）
+CREATE TABLE login_attempts (
+    key            TEXT PRIMARY KEY,
+    failures       INTEGER NOT NULL,
+    last_failed_at TIMESTAMPTZ NOT NULL,
+    locked_until   TIMESTAMPTZ
+);
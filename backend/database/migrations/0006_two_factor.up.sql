@@ -0,0 +1,18 @@
+-- TOTPによる二要素認証（confirmed_atがNULLの間は登録手続き中で、サインインには使わない）
+CREATE TABLE user_totp (
+    user_id        INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret         TEXT NOT NULL,
+    confirmed_at   TIMESTAMPTZ,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- リカバリーコード（平文は保存せずSHA-256ハッシュのみ保存）
+CREATE TABLE totp_recovery_codes (
+    id        SERIAL PRIMARY KEY,
+    user_id   INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at   TIMESTAMPTZ
+);
+
+CREATE INDEX idx_totp_recovery_codes_user_id ON totp_recovery_codes (user_id);
@@ -0,0 +1,20 @@
+-- ユーザーのロール（user・moderator・admin）とアカウント停止
+ALTER TABLE users
+    ADD COLUMN role              TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'moderator', 'admin')),
+    ADD COLUMN suspended_at      TIMESTAMPTZ,
+    ADD COLUMN suspension_reason TEXT NOT NULL DEFAULT '';
+
+-- 管理操作の監査ログ（操作したユーザーや対象が削除されても記録は残す）
+CREATE TABLE audit_logs (
+    id             SERIAL PRIMARY KEY,
+    actor_user_id  INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    action         TEXT NOT NULL,
+    target_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    target_type    TEXT NOT NULL,
+    target_id      INTEGER NOT NULL,
+    details        JSONB NOT NULL DEFAULT '{}',
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_audit_logs_actor_user_id ON audit_logs (actor_user_id);
+CREATE INDEX idx_audit_logs_target_user_id ON audit_logs (target_user_id);
@@ -0,0 +1,4 @@
+-- アカウント削除の予約（この日時を過ぎると関連データごと完全に削除する）
+ALTER TABLE users ADD COLUMN deletion_scheduled_at TIMESTAMPTZ;
+
+CREATE INDEX idx_users_deletion_scheduled_at ON users (deletion_scheduled_at) WHERE deletion_scheduled_at IS NOT NULL;
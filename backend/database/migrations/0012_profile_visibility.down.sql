@@ -0,0 +1,7 @@
+ALTER TABLE link DROP COLUMN IF EXISTS connections_only;
+
+ALTER TABLE option_profiles DROP COLUMN IF EXISTS connections_only;
+
+ALTER TABLE profiles
+    DROP COLUMN IF EXISTS restricted_fields,
+    DROP COLUMN IF EXISTS visibility;
@@ -0,0 +1,9 @@
+-- プロフィールの公開範囲と、つながりのある人だけに見せる項目
+ALTER TABLE profiles
+    ADD COLUMN visibility        TEXT NOT NULL DEFAULT 'public'
+        CHECK (visibility IN ('public', 'unlisted', 'connections', 'private')),
+    ADD COLUMN restricted_fields TEXT[] NOT NULL DEFAULT '{}';
+
+ALTER TABLE option_profiles ADD COLUMN connections_only BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE link ADD COLUMN connections_only BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,3 @@
+ALTER TABLE profiles
+    DROP COLUMN IF EXISTS slug,
+    DROP COLUMN IF EXISTS public_id;
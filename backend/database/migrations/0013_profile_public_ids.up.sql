@@ -0,0 +1,15 @@
+-- 公開URL・QRコードで使う推測できないプロフィールID（連番のidは内部でのみ使う）
+ALTER TABLE profiles ADD COLUMN public_id TEXT;
+
+-- 既存のプロフィールにはUUIDの乱数部分からURLセーフな22文字のIDを振る
+UPDATE profiles
+SET public_id = translate(encode(decode(replace(gen_random_uuid()::text, '-', ''), 'hex'), 'base64'), '+/=', '-_');
+
+ALTER TABLE profiles
+    ALTER COLUMN public_id SET NOT NULL,
+    ADD CONSTRAINT profiles_public_id_key UNIQUE (public_id);
+
+-- 任意のカスタムURL（小文字で保存する）
+ALTER TABLE profiles
+    ADD COLUMN slug TEXT,
+    ADD CONSTRAINT profiles_slug_key UNIQUE (slug);
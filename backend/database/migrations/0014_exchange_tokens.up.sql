@@ -0,0 +1,7 @@
+-- 使用済みの使い捨てQR交換トークン（jti）。有効期限を過ぎたものは照合する必要がないので削除して構いません
+CREATE TABLE used_exchange_tokens (
+    jti        TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_used_exchange_tokens_expires_at ON used_exchange_tokens (expires_at);
@@ -0,0 +1 @@
+ALTER TABLE connections DROP CONSTRAINT IF EXISTS connections_profile_id_connect_user_profile_id_key;
@@ -0,0 +1,10 @@
+-- 同じ向きのコネクションが重複している場合は最初に作成した1件だけを残す
+DELETE FROM connections c
+USING connections d
+WHERE c.profile_id = d.profile_id
+  AND c.connect_user_profile_id = d.connect_user_profile_id
+  AND c.id > d.id;
+
+-- 同時に交換しても同じ向きのコネクションが重複しないようにする
+ALTER TABLE connections
+    ADD CONSTRAINT connections_profile_id_connect_user_profile_id_key UNIQUE (profile_id, connect_user_profile_id);
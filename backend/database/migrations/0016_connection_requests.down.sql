@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS connection_requests;
+
+ALTER TABLE profiles DROP COLUMN IF EXISTS auto_accept_connections;
@@ -0,0 +1,22 @@
+-- コネクション申請を自動で承認するプロフィール（対面でQRコードを読んでもらう用）
+ALTER TABLE profiles ADD COLUMN auto_accept_connections BOOLEAN NOT NULL DEFAULT false;
+
+-- 他人のプロフィールへのコネクション申請。承認されるとprofile_idからconnect_user_profile_idへのコネクションを作成する
+CREATE TABLE connection_requests (
+    id                      SERIAL PRIMARY KEY,
+    profile_id              INTEGER NOT NULL REFERENCES profiles(id) ON DELETE CASCADE,
+    connect_user_profile_id INTEGER NOT NULL REFERENCES profiles(id) ON DELETE CASCADE,
+    status                  TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'accepted', 'declined', 'expired')),
+    event_name              TEXT NOT NULL DEFAULT '',
+    event_date              TEXT NOT NULL DEFAULT '',
+    memo                    TEXT NOT NULL DEFAULT '',
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at              TIMESTAMPTZ NOT NULL,
+    responded_at            TIMESTAMPTZ
+);
+
+-- 同じ組み合わせの未対応の申請は1件まで
+CREATE UNIQUE INDEX idx_connection_requests_pending
+    ON connection_requests (profile_id, connect_user_profile_id) WHERE status = 'pending';
+CREATE INDEX idx_connection_requests_connect_user_profile_id ON connection_requests (connect_user_profile_id);
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/models"
+	"backend/store"
+	"backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPersonalAccessTokens は1ユーザーが同時に持てるパーソナルアクセストークンの数です
+const maxPersonalAccessTokens = 50
+
+// ListPersonalAccessTokens は自分のパーソナルアクセストークン一覧を返すハンドラーです（トークン本体は返しません）
+func (app *App) ListPersonalAccessTokens(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tokens, err := app.AccessTokens.ListByUser(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	c.JSON(http.StatusOK, models.PersonalAccessTokenListResponse{Tokens: tokens})
+}
+
+// CreatePersonalAccessToken はパーソナルアクセストークンを発行するハンドラーです
+// 平文のトークンはこのレスポンスでのみ返し、DBにはハッシュだけを保存します
+func (app *App) CreatePersonalAccessToken(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreatePersonalAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストが不正です"})
+		return
+	}
+	scopes, ok := normalizeScopes(req.Scopes)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不明なスコープが含まれています"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	existing, err := app.AccessTokens.ListByUser(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	if len(existing) >= maxPersonalAccessTokens {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "トークンの数が上限に達しています。不要なトークンを削除してください"})
+		return
+	}
+
+	raw, hash, prefix, err := utils.GeneratePersonalAccessToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "トークンの生成に失敗しました"})
+		return
+	}
+	token := models.PersonalAccessToken{
+		UserID:      userID,
+		Name:        req.Name,
+		TokenHash:   hash,
+		TokenPrefix: prefix,
+		Scopes:      scopes,
+	}
+	if req.ExpiresInDays != nil {
+		expiresAt := time.Now().Add(time.Duration(*req.ExpiresInDays) * 24 * time.Hour)
+		token.ExpiresAt = &expiresAt
+	}
+	if err := app.AccessTokens.Create(ctx, &token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "トークンの生成に失敗しました"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.CreatePersonalAccessTokenResponse{PersonalAccessToken: token, Token: raw})
+}
+
+// RevokePersonalAccessToken はパーソナルアクセストークンを失効させるハンドラーです
+func (app *App) RevokePersonalAccessToken(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "トークンIDが不正です"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 他人のトークンも存在しないものとして扱う
+	if err := app.AccessTokens.Revoke(ctx, userID, id); err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "トークンが見つかりません"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "トークンを削除しました"})
+}
+
+// normalizeScopes はスコープを検証し、重複を除いて定義順に並べ替えます
+func normalizeScopes(requested []string) ([]string, bool) {
+	want := map[string]bool{}
+	for _, scope := range requested {
+		want[scope] = true
+	}
+	var scopes []string
+	for _, scope := range models.PersonalAccessTokenScopes {
+		if want[scope] {
+			scopes = append(scopes, scope)
+			delete(want, scope)
+		}
+	}
+	return scopes, len(want) == 0
+}
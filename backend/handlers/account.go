@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"backend/mail"
+	"backend/models"
+	"backend/store"
+	"backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// maxExportIconSize はエクスポートに含めるアイコン画像1件の上限サイズです
+const maxExportIconSize = 10 * 1024 * 1024
+
+// iconHTTPClient はCloudinaryに保存したアイコン画像の取得に使うクライアントです
+var iconHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// LoadAccountDeletionGrace はアカウント削除の猶予期間を環境変数 ACCOUNT_DELETION_GRACE_DAYS（デフォルト30日）から読み込みます
+func LoadAccountDeletionGrace() time.Duration {
+	return time.Duration(envInt("ACCOUNT_DELETION_GRACE_DAYS", 30)) * 24 * time.Hour
+}
+
+// ExportAccountData は自分のデータ一式をZIPで返すハンドラーです
+// user.json・profiles.json（option_profilesを含む）・links.json・connections.json（両方向）とアイコン画像（icons/）を含みます
+func (app *App) ExportAccountData(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	archive, err := app.buildAccountExport(ctx, userID)
+	if err != nil {
+		fmt.Printf("Failed to export account data: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データのエクスポートに失敗しました"})
+		return
+	}
+
+	filename := fmt.Sprintf("qrsona-export-%s.zip", time.Now().Format("20060102"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+// buildAccountExport はユーザーのデータをZIPにまとめます
+func (app *App) buildAccountExport(ctx context.Context, userID int) ([]byte, error) {
+	user, err := app.Users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := app.Identities.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := app.Profiles.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	links, err := app.Links.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	exported := []models.ExportedProfile{}
+	connections := models.ExportedConnections{Outgoing: []models.Connection{}, Incoming: []models.Connection{}}
+	for _, profile := range profiles {
+		options, err := app.OptionProfiles.ListByProfile(ctx, profile.ID)
+		if err != nil {
+			return nil, err
+		}
+		profile.OptionProfiles = options
+
+		outgoing, err := app.Connections.ListByProfile(ctx, profile.ID)
+		if err == nil {
+			err = app.setConnectionTargets(ctx, outgoing)
+		}
+		if err != nil {
+			return nil, err
+		}
+		incoming, err := app.Connections.ListIncoming(ctx, profile.ID)
+		if err != nil {
+			return nil, err
+		}
+		for i := range incoming {
+			incoming[i].ConnectUsersProfilePublicID = profile.PublicID
+		}
+		connections.Outgoing = append(connections.Outgoing, outgoing...)
+		connections.Incoming = append(connections.Incoming, incoming...)
+
+		entry := models.ExportedProfile{Profile: profile}
+		if data, ext, err := app.readProfileIcon(ctx, &profile); err != nil {
+			// アイコンが取得できなくても他のデータはエクスポートする
+			fmt.Printf("Failed to read icon of profile %d: %v\n", profile.ID, err)
+		} else if data != nil {
+			entry.IconFile = fmt.Sprintf("icons/profile-%d%s", profile.ID, ext)
+			if err := writeZipFile(zw, entry.IconFile, data); err != nil {
+				return nil, err
+			}
+		}
+		entry.IconPath = ""
+		exported = append(exported, entry)
+	}
+	if identities == nil {
+		identities = []models.UserIdentity{}
+	}
+	if links == nil {
+		links = []models.Link{}
+	}
+
+	files := []struct {
+		name string
+		v    interface{}
+	}{
+		{"user.json", models.ExportedUser{User: *user, Identities: identities, ExportedAt: time.Now()}},
+		{"profiles.json", exported},
+		{"links.json", links},
+		{"connections.json", connections},
+	}
+	for _, f := range files {
+		data, err := json.MarshalIndent(f.v, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := writeZipFile(zw, f.name, data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readProfileIcon はプロフィールのアイコン画像と拡張子を返します（アイコンがない場合はnil）
+func (app *App) readProfileIcon(ctx context.Context, profile *models.Profile) ([]byte, string, error) {
+	if profile.IconPath == "" {
+		return nil, "", nil
+	}
+	if utils.CloudinaryPublicID(profile.IconPath) == "" {
+		data, err := os.ReadFile(profile.IconPath)
+		if os.IsNotExist(err) {
+			return nil, "", nil
+		}
+		return data, iconExt(filepath.Ext(profile.IconPath)), err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, profile.IconPath, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	res, err := iconHTTPClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("アイコン画像の取得に失敗しました: %s", res.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxExportIconSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxExportIconSize {
+		return nil, "", fmt.Errorf("アイコン画像が大きすぎます")
+	}
+	var ext string
+	if u, err := url.Parse(profile.IconPath); err == nil {
+		ext = path.Ext(u.Path)
+	}
+	return data, iconExt(ext), nil
+}
+
+// iconExt は拡張子がない場合に.pngを補います
+func iconExt(ext string) string {
+	if ext == "" {
+		return ".png"
+	}
+	return ext
+}
+
+func writeZipFile(zw *zip.Writer, name string, data []byte) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// RequestAccountDeletion はアカウントの削除を予約するハンドラーです
+// 猶予期間が過ぎるとデータは完全に削除されます。それまではサインインして予約を取り消せます（予約時にすべてのセッションを失効させます）
+func (app *App) RequestAccountDeletion(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "パスワードを入力してください"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := app.Users.GetByID(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)) != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "パスワードが正しくありません"})
+		return
+	}
+
+	// 予約済みの場合は予定日時を延ばさない
+	if user.DeletionScheduledAt != nil {
+		c.JSON(http.StatusOK, models.AccountDeletionResponse{
+			Message:             "アカウントの削除は予約済みです",
+			DeletionScheduledAt: *user.DeletionScheduledAt,
+		})
+		return
+	}
+
+	scheduledAt := time.Now().Add(app.AccountDeletionGrace)
+	if err := app.Users.ScheduleDeletion(ctx, userID, scheduledAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "アカウント削除の予約に失敗しました"})
+		return
+	}
+	if err := app.revokeUserSessions(ctx, userID); err != nil {
+		fmt.Printf("Failed to revoke sessions after deletion request: %v\n", err)
+	}
+
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "【QRsona】アカウント削除の予約",
+		Body: fmt.Sprintf("%s さん\n\nアカウントの削除を受け付けました。%s にプロフィール・リンク・コネクションを含むすべてのデータを削除します。\n\n"+
+			"削除を取り消す場合は、それまでにサインインして削除の予約を取り消してください。\n%s/login\n",
+			user.Name, scheduledAt.Format("2006-01-02 15:04"), utils.FrontendURL()),
+	}
+	if err := app.Mailer.Send(ctx, msg); err != nil {
+		fmt.Printf("Failed to send account deletion mail: %v\n", err)
+	}
+
+	c.JSON(http.StatusAccepted, models.AccountDeletionResponse{
+		Message:             "アカウントの削除を予約しました",
+		DeletionScheduledAt: scheduledAt,
+	})
+}
+
+// CancelAccountDeletion はアカウント削除の予約を取り消すハンドラーです
+func (app *App) CancelAccountDeletion(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := app.Users.GetByID(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	if user.DeletionScheduledAt == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "アカウントの削除は予約されていません"})
+		return
+	}
+	if err := app.Users.CancelDeletion(ctx, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "削除の取り消しに失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "アカウントの削除を取り消しました"})
+}
+
+// PurgeDeletedAccounts は猶予期間を過ぎたアカウントを関連データ・アイコン画像ごと完全に削除し、削除した件数を返します
+func (app *App) PurgeDeletedAccounts(ctx context.Context) (int, error) {
+	ids, err := app.Users.ListDueForDeletion(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, id := range ids {
+		// 一覧を取得した後に取り消された予約は削除しない
+		user, err := app.Users.GetByID(ctx, id)
+		if err == store.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return purged, err
+		}
+		if user.DeletionScheduledAt == nil || user.DeletionScheduledAt.After(time.Now()) {
+			continue
+		}
+
+		profiles, err := app.Profiles.ListByUser(ctx, id)
+		if err != nil {
+			return purged, err
+		}
+		if err := app.Users.Delete(ctx, id); err == store.ErrNotFound {
+			continue
+		} else if err != nil {
+			return purged, err
+		}
+		// アイコン画像はデータを削除した後で消す（DeleteProfileと同じく失敗してもログのみ）
+		for i := range profiles {
+			app.removeProfileIcon(ctx, &profiles[i])
+		}
+
+		if err := app.AuditLogs.Create(ctx, &models.AuditLog{
+			Action:     models.AuditUserDelete,
+			TargetType: "user",
+			TargetID:   id,
+		}); err != nil {
+			fmt.Printf("Failed to record audit log (%s): %v\n", models.AuditUserDelete, err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// RunAccountPurger はctxがキャンセルされるまでintervalごとにPurgeDeletedAccountsを実行します
+func (app *App) RunAccountPurger(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		runCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+		if n, err := app.PurgeDeletedAccounts(runCtx); err != nil {
+			fmt.Printf("Failed to purge deleted accounts: %v\n", err)
+		} else if n > 0 {
+			fmt.Printf("Purged %d deleted account(s)\n", n)
+		}
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
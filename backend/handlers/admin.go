@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/middleware"
+	"backend/models"
+	"backend/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 管理者向け一覧のページサイズ
+const (
+	defaultAdminPerPage = 20
+	maxAdminPerPage     = 100
+)
+
+// AdminListUsers はユーザーを検索するハンドラーです（モデレーター以上）
+// ?q=名前・メールアドレスの部分一致 &role=ロール &status=active|suspended &page= &per_page=
+func (app *App) AdminListUsers(c *gin.Context) {
+	page, perPage, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+	query := store.UserSearch{
+		Query:  strings.TrimSpace(c.Query("q")),
+		Role:   models.Role(c.Query("role")),
+		Limit:  perPage,
+		Offset: (page - 1) * perPage,
+	}
+	if query.Role != "" && !query.Role.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ロールが不正です"})
+		return
+	}
+	switch c.Query("status") {
+	case "":
+	case "active":
+		suspended := false
+		query.Suspended = &suspended
+	case "suspended":
+		suspended := true
+		query.Suspended = &suspended
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "statusはactiveまたはsuspendedを指定してください"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	users, total, err := app.Users.Search(ctx, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ユーザー一覧の取得に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, models.AdminUserListResponse{Users: users, Total: total, Page: page, PerPage: perPage})
+}
+
+// AdminGetUser はユーザーの情報とプロフィール一覧を返すハンドラーです（モデレーター以上）
+// 限定公開のプロフィールは一覧に含めません（URLで報告されたものは公開IDで削除できます）
+func (app *App) AdminGetUser(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ユーザーIDが不正です"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user := app.loadUser(c, ctx, userID)
+	if user == nil {
+		return
+	}
+	profiles, err := app.Profiles.ListByUser(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "プロフィールの取得に失敗しました"})
+		return
+	}
+	listed := []models.Profile{}
+	for _, profile := range profiles {
+		if !profile.Unlisted() {
+			listed = append(listed, profile)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"user": user, "profiles": listed})
+}
+
+// AdminSuspendUser はアカウントを停止するハンドラーです（モデレーター以上）
+// 停止したアカウントのセッションはすべて失効させ、サインインとAPIの利用を拒否します
+func (app *App) AdminSuspendUser(c *gin.Context) {
+	var req models.SuspendUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "停止理由を入力してください"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	target := app.loadModeratableUser(c, ctx)
+	if target == nil {
+		return
+	}
+	if err := app.Users.Suspend(ctx, target.ID, req.Reason); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "アカウントの停止に失敗しました"})
+		return
+	}
+	if err := app.revokeUserSessions(ctx, target.ID); err != nil {
+		fmt.Printf("Failed to revoke sessions of suspended user: %v\n", err)
+	}
+
+	app.recordAudit(c, ctx, models.AuditLog{
+		Action:       models.AuditUserSuspend,
+		TargetUserID: &target.ID,
+		TargetType:   "user",
+		TargetID:     target.ID,
+		Details:      map[string]string{"reason": req.Reason},
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "アカウントを停止しました"})
+}
+
+// AdminUnsuspendUser はアカウントの停止を解除するハンドラーです（モデレーター以上）
+func (app *App) AdminUnsuspendUser(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	target := app.loadModeratableUser(c, ctx)
+	if target == nil {
+		return
+	}
+	if !target.Suspended() {
+		c.JSON(http.StatusConflict, gin.H{"error": "このアカウントは停止されていません"})
+		return
+	}
+	if err := app.Users.Unsuspend(ctx, target.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "アカウントの停止解除に失敗しました"})
+		return
+	}
+
+	app.recordAudit(c, ctx, models.AuditLog{
+		Action:       models.AuditUserUnsuspend,
+		TargetUserID: &target.ID,
+		TargetType:   "user",
+		TargetID:     target.ID,
+		Details:      map[string]string{"previous_reason": target.SuspensionReason},
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "アカウントの停止を解除しました"})
+}
+
+// AdminUpdateUserRole はユーザーのロールを変更するハンドラーです（管理者のみ）
+// APIで付与できるのはuserとmoderatorだけで、管理者の付与・剥奪はDBで行います
+func (app *App) AdminUpdateUserRole(c *gin.Context) {
+	var req models.UpdateUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストが不正です"})
+		return
+	}
+	if req.Role != models.RoleUser && req.Role != models.RoleModerator {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "付与できるロールはuserまたはmoderatorです"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	target := app.loadModeratableUser(c, ctx)
+	if target == nil {
+		return
+	}
+	if target.Role == req.Role {
+		c.JSON(http.StatusOK, gin.H{"message": "ロールは変更されていません", "role": target.Role})
+		return
+	}
+	if err := app.Users.UpdateRole(ctx, target.ID, req.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ロールの変更に失敗しました"})
+		return
+	}
+
+	app.recordAudit(c, ctx, models.AuditLog{
+		Action:       models.AuditUserRoleChange,
+		TargetUserID: &target.ID,
+		TargetType:   "user",
+		TargetID:     target.ID,
+		Details:      map[string]string{"from": string(target.Role), "to": string(req.Role)},
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "ロールを変更しました", "role": req.Role})
+}
+
+// AdminDeleteProfile はプロフィールをアイコン画像・関連データごと強制的に削除するハンドラーです（モデレーター以上）
+// :idには連番のIDのほか、一覧に表示しない限定公開のプロフィール用に公開IDまたはカスタムURLも指定できます
+func (app *App) AdminDeleteProfile(c *gin.Context) {
+	var req models.AdminDeleteProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "削除理由を入力してください"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var profile *models.Profile
+	var err error
+	if profileID, convErr := strconv.Atoi(c.Param("id")); convErr == nil {
+		profile, err = app.Profiles.Get(ctx, profileID)
+	} else {
+		profile, err = app.Profiles.GetByPublicRef(ctx, c.Param("id"))
+	}
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "プロフィールが見つかりません"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
+		return
+	}
+	owner := app.loadUser(c, ctx, profile.UserID)
+	if owner == nil {
+		return
+	}
+	if !app.canModerate(c, owner) {
+		return
+	}
+
+	if err := app.Profiles.Delete(ctx, profile.ID); err != nil {
+		fmt.Printf("プロフィール削除エラー: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "プロフィールの削除に失敗しました"})
+		return
+	}
+	app.removeProfileIcon(ctx, profile)
+
+	app.recordAudit(c, ctx, models.AuditLog{
+		Action:       models.AuditProfileDelete,
+		TargetUserID: &owner.ID,
+		TargetType:   "profile",
+		TargetID:     profile.ID,
+		Details:      map[string]string{"reason": req.Reason, "display_name": profile.DisplayName},
+	})
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "プロフィールを削除しました",
+		"profile_id": profile.ID,
+	})
+}
+
+// AdminListAuditLogs は監査ログを新しい順に返すハンドラーです（モデレーター以上）
+// ?actor_id= &target_user_id= &action= &page= &per_page=
+func (app *App) AdminListAuditLogs(c *gin.Context) {
+	page, perPage, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+	query := store.AuditLogSearch{
+		Action: c.Query("action"),
+		Limit:  perPage,
+		Offset: (page - 1) * perPage,
+	}
+	for param, dest := range map[string]*int{"actor_id": &query.ActorUserID, "target_user_id": &query.TargetUserID} {
+		if v := c.Query(param); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil || id < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + "が不正です"})
+				return
+			}
+			*dest = id
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	logs, total, err := app.AuditLogs.List(ctx, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "監査ログの取得に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, models.AuditLogListResponse{Logs: logs, Total: total, Page: page, PerPage: perPage})
+}
+
+// loadUser はユーザーを取得します
+// 存在しない場合は404を返してnilを返します
+func (app *App) loadUser(c *gin.Context, ctx context.Context, userID int) *models.User {
+	user, err := app.Users.GetByID(ctx, userID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ユーザーが見つかりません"})
+		return nil
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return nil
+	}
+	user.PasswordHash = ""
+	return user
+}
+
+// loadModeratableUser はURLの:idのユーザーを取得し、呼び出し元が管理操作の対象にできることを確認します
+func (app *App) loadModeratableUser(c *gin.Context, ctx context.Context) *models.User {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ユーザーIDが不正です"})
+		return nil
+	}
+	target := app.loadUser(c, ctx, userID)
+	if target == nil || !app.canModerate(c, target) {
+		return nil
+	}
+	return target
+}
+
+// canModerate は呼び出し元がtargetを管理操作の対象にできるか確認します
+// 自分自身と、自分と同じかより強いロールのユーザーは対象にできません（できない場合は403を返してfalseを返します）
+func (app *App) canModerate(c *gin.Context, target *models.User) bool {
+	actorID, ok := currentUserID(c)
+	if !ok {
+		return false
+	}
+	if actorID == target.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "自分自身は操作できません"})
+		return false
+	}
+	if !middleware.CurrentRole(c).Outranks(target.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "このユーザーを操作する権限がありません"})
+		return false
+	}
+	return true
+}
+
+// recordAudit は呼び出し元を操作者として監査ログを記録します（失敗してもログ出力のみ）
+func (app *App) recordAudit(c *gin.Context, ctx context.Context, log models.AuditLog) {
+	if actorID, ok := c.Get("user_id"); ok {
+		id := actorID.(int)
+		log.ActorUserID = &id
+	}
+	if err := app.AuditLogs.Create(ctx, &log); err != nil {
+		fmt.Printf("Failed to record audit log (%s): %v\n", log.Action, err)
+	}
+}
+
+// parsePagination は ?page= と ?per_page= を解釈します（不正な値の場合は400を返してfalseを返します）
+func parsePagination(c *gin.Context) (page, perPage int, ok bool) {
+	page, perPage = 1, defaultAdminPerPage
+	if v := c.Query("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "pageは1以上の整数で指定してください"})
+			return 0, 0, false
+		}
+		page = n
+	}
+	if v := c.Query("per_page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAdminPerPage {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("per_pageは1から%dの整数で指定してください", maxAdminPerPage)})
+			return 0, 0, false
+		}
+		perPage = n
+	}
+	return page, perPage, true
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"backend/models"
+	"backend/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ForbiddenMessage は他人のリソースを操作しようとした場合の共通エラーメッセージです
+const ForbiddenMessage = "このリソースを操作する権限がありません"
+
+// currentUserID はAuthRequiredミドルウェアでセットされたユーザーIDを返します
+// 取得できない場合は401を返してfalseを返します
+func currentUserID(c *gin.Context) (int, bool) {
+	userIDAny, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証情報がありません"})
+		return 0, false
+	}
+	userID, ok := userIDAny.(int)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証情報が不正です"})
+		return 0, false
+	}
+	return userID, true
+}
+
+// authorizeOwner は呼び出し元がownerIDのユーザーであることを確認します
+// 一致しない場合は403を返してfalseを返します
+func authorizeOwner(c *gin.Context, ownerID int) bool {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return false
+	}
+	if userID != ownerID {
+		c.JSON(http.StatusForbidden, gin.H{"error": ForbiddenMessage})
+		return false
+	}
+	return true
+}
+
+// loadOwnedProfile はプロフィールを取得し、呼び出し元が所有者であることを確認します
+// 存在しない場合は404、所有者でない場合は403を返してnilを返します
+func (app *App) loadOwnedProfile(c *gin.Context, profileID int) *models.Profile {
+	profile, err := app.Profiles.Get(context.Background(), profileID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "プロフィールが見つかりません"})
+		return nil
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
+		return nil
+	}
+	if !authorizeOwner(c, profile.UserID) {
+		return nil
+	}
+	return profile
+}
+
+// loadOwnedLink はリンクを取得し、呼び出し元が所有者であることを確認します
+// 存在しない場合は404、所有者でない場合は403を返してnilを返します
+func (app *App) loadOwnedLink(c *gin.Context, linkID int) *models.Link {
+	link, err := app.Links.Get(context.Background(), linkID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "リンクが見つかりません"})
+		return nil
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "リンクの取得に失敗しました"})
+		return nil
+	}
+
+	ownerID, err := app.linkOwnerID(context.Background(), link)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "リンクの取得に失敗しました"})
+		return nil
+	}
+	if !authorizeOwner(c, ownerID) {
+		return nil
+	}
+	return link
+}
+
+// loadOwnedOptionProfile は任意項目を取得し、呼び出し元が紐づくプロフィールの所有者であることを確認します
+// 存在しない場合は404、所有者でない場合は403を返してnilを返します
+func (app *App) loadOwnedOptionProfile(c *gin.Context, optionID int) *models.OptionProfile {
+	opt, err := app.OptionProfiles.Get(context.Background(), optionID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "任意項目が見つかりません"})
+		return nil
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
+		return nil
+	}
+
+	profile, err := app.Profiles.Get(context.Background(), opt.ProfileID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
+		return nil
+	}
+	if !authorizeOwner(c, profile.UserID) {
+		return nil
+	}
+	return opt
+}
+
+// linkOwnerID はリンクの所有者のユーザーIDを返します
+// プロフィールに紐づくリンクはプロフィールの所有者、それ以外はuser_idのユーザーが所有者です
+func (app *App) linkOwnerID(ctx context.Context, link *models.Link) (int, error) {
+	if link.ProfileID == nil {
+		return link.UsersID, nil
+	}
+	profile, err := app.Profiles.Get(ctx, *link.ProfileID)
+	if err != nil {
+		return 0, err
+	}
+	return profile.UserID, nil
+}
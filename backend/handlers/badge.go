@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"net/http"
+	"time"
+
+	"backend/badge"
+	"backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBadges は1回で作成できるバッジの上限です
+const maxBadges = 500
+
+// GenerateBadges はプロフィールの一覧からイベント用の名札（バッジ）を並べたPDFを返すハンドラーです
+// 各バッジには表示名・肩書き・アイコンと、プロフィールの公開ページのQRコードを印刷します
+// 閲覧者が閲覧できるプロフィールに限り、つながりのある人だけに見せる肩書き・アイコンはつながりがある場合だけ印刷します
+// 限定公開のプロフィールは一括で印刷できるのは所有者とつながりのある人だけです
+func (app *App) GenerateBadges(c *gin.Context) {
+	var req models.BadgeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストが不正です"})
+		return
+	}
+	if len(req.ProfileIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "profile_idsを1件以上指定してください"})
+		return
+	}
+	if len(req.ProfileIDs) > maxBadges {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("一度に作成できるバッジは%d件までです", maxBadges)})
+		return
+	}
+	layout, err := badgeLayout(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	viewer := viewerID(c)
+	// 同じプロフィールを複数枚印刷する場合は、アイコンを1度だけ読み込む
+	icons := map[int]image.Image{}
+	badges := make([]badge.Badge, 0, len(req.ProfileIDs))
+	for _, ref := range req.ProfileIDs {
+		profile, access, err := app.profileAccessByRef(ctx, viewer, ref)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
+			return
+		}
+		if profile == nil || !access.listed {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("プロフィールが見つかりません: %s", ref)})
+			return
+		}
+
+		icon, ok := icons[profile.ID]
+		if !ok && profile.IconPath != "" && (!profile.Restricts(models.ProfileFieldIcon) || access.connected) {
+			icon = app.loadBadgeIcon(ctx, profile)
+			icons[profile.ID] = icon
+		}
+		presentProfile(profile, access)
+		badges = append(badges, badge.Badge{
+			DisplayName: profile.DisplayName,
+			AKA:         profile.AKA,
+			Icon:        icon,
+			URL:         profile.URL,
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := badge.Render(&buf, badges, layout); err != nil {
+		fmt.Printf("Render badges error: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "PDFの作成に失敗しました"})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="qrsona-badges.pdf"`)
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// badgeLayout はリクエストの用紙サイズ・配置（mm単位）を検証し、バッジの配置に変換します
+func badgeLayout(req models.BadgeRequest) (badge.Layout, error) {
+	layout := badge.DefaultLayout()
+	if req.PageSize != "" || req.Orientation != "" {
+		pageSize := req.PageSize
+		if pageSize == "" {
+			pageSize = "A4"
+		}
+		width, height, err := badge.ParsePageSize(pageSize, req.Orientation == "landscape")
+		if err != nil {
+			return layout, err
+		}
+		layout.PageWidth, layout.PageHeight = width, height
+	}
+	if req.Columns != 0 {
+		layout.Columns = req.Columns
+	}
+	if req.Rows != 0 {
+		layout.Rows = req.Rows
+	}
+	if req.Margin != nil {
+		layout.Margin = *req.Margin * badge.Millimeter
+	}
+	if req.Gap != nil {
+		layout.Gap = *req.Gap * badge.Millimeter
+	}
+	if req.CutLines != nil {
+		layout.CutLines = *req.CutLines
+	}
+	return layout, layout.Validate()
+}
+
+// loadBadgeIcon はバッジに印刷するアイコンを読み込みます
+// 取得・読み込みに失敗した場合は、他のバッジを作成できるようアイコンなしにします
+func (app *App) loadBadgeIcon(ctx context.Context, profile *models.Profile) image.Image {
+	data, _, err := app.readProfileIcon(ctx, profile)
+	if err != nil {
+		fmt.Printf("Read profile icon error: %v\n", err)
+		return nil
+	}
+	if data == nil {
+		return nil
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width > maxLogoPixels || config.Height > maxLogoPixels {
+		return nil
+	}
+	icon, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	return icon
+}
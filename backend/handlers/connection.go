@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"backend/models"
+	"backend/store"
+	"backend/utils"
 	"context"
 	"net/http"
 	"strconv"
@@ -11,50 +13,107 @@ import (
 )
 
 // CreateConnectionは新規コネクション（フォロー）を作成します
+// 他人のプロフィールへのコネクションは常に申請として登録し、相手が承認したときに作成します（自分のプロフィール同士はすぐに作成します）
+// 申請を自動で承認する設定は交換トークン（QRコード）を使った交換にだけ適用します
+// 閲覧できないプロフィールは存在しないものとして404を返します
 func (app *App) CreateConnection(c *gin.Context) {
 	var req models.CreateConnectionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエスト形式が不正です"})
 		return
 	}
+	if req.ConnectUsersProfilePublicID == "" && req.ConnectUsersProfileID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエスト形式が不正です"})
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// 既存のコネクション重複防止
-	var exists int
-	err := app.DB.QueryRowContext(ctx,
-		`SELECT 1 FROM connections WHERE profile_id = $1 AND connect_user_profile_id = $2`,
-		req.ProfileID, req.ConnectUsersProfileID,
-	).Scan(&exists)
-	if err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "すでに作成されています"})
+	// 作成元のプロフィールが本人のものか確認
+	profile := app.loadOwnedProfile(c, req.ProfileID)
+	if profile == nil {
 		return
 	}
 
-	// コネクション新規作成
-	var id int
-	now := time.Now()
-	err = app.DB.QueryRowContext(ctx,
-		`INSERT INTO connections (profile_id, connect_user_profile_id, connected_at, event_name, event_date, memo)
-         VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
-		req.ProfileID, req.ConnectUsersProfileID, now, req.EventName, req.EventDate, req.Memo,
-	).Scan(&id)
+	// 接続先のプロフィールの存在確認
+	// 連番のIDは自分のプロフィールだけに使える（他人のプロフィールを連番で辿って申請を送れないようにするため）
+	var target *models.Profile
+	var err error
+	if req.ConnectUsersProfilePublicID != "" {
+		target, err = app.Profiles.GetByPublicRef(ctx, req.ConnectUsersProfilePublicID)
+	} else {
+		target, err = app.Profiles.Get(ctx, req.ConnectUsersProfileID)
+		if err == nil && target.UserID != profile.UserID {
+			err = store.ErrNotFound
+		}
+	}
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "接続先のプロフィールが見つかりません"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
+		return
+	}
+	// 閲覧できないプロフィールは存在しないものとして扱う（非公開のプロフィールの有無を明かさない）
+	access, err := app.profileAccessFor(ctx, profile.UserID, target)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
+		return
+	}
+	if !access.visible {
+		c.JSON(http.StatusNotFound, gin.H{"error": "接続先のプロフィールが見つかりません"})
+		return
+	}
+
+	if target.UserID != profile.UserID {
+		request := models.ConnectionRequest{
+			ProfileID:                   req.ProfileID,
+			ConnectUsersProfileID:       target.ID,
+			ConnectUsersProfilePublicID: target.PublicID,
+			EventName:                   req.EventName,
+			EventDate:                   req.EventDate,
+			Memo:                        req.Memo,
+			ExpiresAt:                   time.Now().Add(utils.ConnectionRequestTTL()),
+		}
+		err := app.ConnectionRequests.Create(ctx, &request)
+		if err == store.ErrConflict {
+			c.JSON(http.StatusConflict, gin.H{"error": "すでに作成されているか、申請中です"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "申請に失敗しました"})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "コネクションを申請しました。相手が承認すると交換済みになります",
+			"request": request,
+		})
+		return
+	}
+
+	// コネクション新規作成（既存のコネクションとの重複はストア側で検出）
+	conn := models.Connection{
+		ProfileID:                   req.ProfileID,
+		ConnectUsersProfileID:       target.ID,
+		ConnectUsersProfilePublicID: target.PublicID,
+		EventName:                   req.EventName,
+		EventDate:                   req.EventDate,
+		Memo:                        req.Memo,
+	}
+	err = app.Connections.Create(ctx, &conn)
+	if err == store.ErrConflict {
+		c.JSON(http.StatusConflict, gin.H{"error": "すでに作成されています"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "登録に失敗しました"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"connection": models.Connection{
-			ID:                    id,
-			ProfileID:             req.ProfileID,
-			ConnectUsersProfileID: req.ConnectUsersProfileID,
-			ConnectedAt:           now,
-			EventName:             req.EventName,
-			EventDate:             req.EventDate,
-			Memo:                  req.Memo,
-		},
+		"connection": conn,
 	})
 }
 
@@ -67,30 +126,22 @@ func (app *App) ListConnections(c *gin.Context) {
 		return
 	}
 
+	// 本人のプロフィールのコネクションのみ参照可能
+	if app.loadOwnedProfile(c, profileID) == nil {
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	rows, err := app.DB.QueryContext(ctx,
-		`SELECT id, profile_id, connect_user_profile_id, connected_at, event_name, event_date, memo
-         FROM connections WHERE profile_id = $1 ORDER BY connected_at DESC`,
-		profileID,
-	)
+	list, err := app.Connections.ListByProfile(ctx, profileID)
+	if err == nil {
+		err = app.setConnectionTargets(ctx, list)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "取得に失敗しました"})
 		return
 	}
-	defer rows.Close()
-
-	var list []models.Connection
-	for rows.Next() {
-		var conn models.Connection
-		if err := rows.Scan(
-			&conn.ID, &conn.ProfileID, &conn.ConnectUsersProfileID, &conn.ConnectedAt,
-			&conn.EventName, &conn.EventDate, &conn.Memo,
-		); err == nil {
-			list = append(list, conn)
-		}
-	}
 
 	c.JSON(http.StatusOK, models.ConnectionListResponse{
 		Connections: list,
@@ -113,16 +164,18 @@ func (app *App) DeleteConnection(c *gin.Context) {
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	res, err := app.DB.ExecContext(ctx, `DELETE FROM connections WHERE id=$1`, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "削除に失敗しました"})
+	if app.loadOwnedConnection(ctx, c, id) == nil {
 		return
 	}
-	num, _ := res.RowsAffected()
-	if num == 0 {
+	err = app.Connections.Delete(ctx, id)
+	if err == store.ErrNotFound {
 		c.JSON(http.StatusNotFound, gin.H{"error": "該当データがありません"})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "削除に失敗しました"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"result": "success"})
 }
 
@@ -136,17 +189,32 @@ func (app *App) GetConnection(c *gin.Context) {
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	var conn models.Connection
-	err = app.DB.QueryRowContext(ctx,
-		`SELECT id, profile_id, connect_user_profile_id, connected_at, event_name, event_date, memo 
-		 FROM connections WHERE id=$1`, id).
-		Scan(&conn.ID, &conn.ProfileID, &conn.ConnectUsersProfileID, &conn.ConnectedAt,
-			&conn.EventName, &conn.EventDate, &conn.Memo)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "該当データがありません"})
+	conn := app.loadOwnedConnection(ctx, c, id)
+	if conn == nil {
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"connection": conn})
+	list := []models.Connection{*conn}
+	if err := app.setConnectionTargets(ctx, list); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"connection": list[0]})
+}
+
+// setConnectionTargets はコネクションに接続先のプロフィールの公開IDを設定します
+// （接続先の連番のIDはレスポンスに含めないため、画面では公開IDで接続先を照合します）
+func (app *App) setConnectionTargets(ctx context.Context, list []models.Connection) error {
+	for i := range list {
+		target, err := app.Profiles.Get(ctx, list[i].ConnectUsersProfileID)
+		if err == store.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		list[i].ConnectUsersProfilePublicID = target.PublicID
+	}
+	return nil
 }
 
 // GetUserConnectionsは指定ユーザーの交換済みプロフィール一覧を返します
@@ -158,49 +226,20 @@ func (app *App) GetUserConnections(c *gin.Context) {
 		return
 	}
 
+	// メモなどを含むため本人の一覧のみ参照可能
+	if !authorizeOwner(c, userID) {
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	// ユーザーの交換済みプロフィール情報を取得
-	query := `
-		SELECT DISTINCT
-			cp.id as connected_profile_id,
-			cp.title as connected_profile_title,
-			cu.name as connected_user_name,
-			c.connected_at,
-			c.event_name,
-			c.event_date,
-			c.memo
-		FROM connections c
-		JOIN profiles p ON c.profile_id = p.id
-		JOIN profiles cp ON c.connect_user_profile_id = cp.id
-		JOIN users cu ON cp.user_id = cu.id
-		WHERE p.user_id = $1
-		ORDER BY c.connected_at DESC
-	`
-
-	rows, err := app.DB.QueryContext(ctx, query, userID)
+	connections, err := app.Connections.ListByUser(ctx, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "データの取得に失敗しました"})
 		return
 	}
-	defer rows.Close()
-
-	var connections []models.UserConnection
-	for rows.Next() {
-		var conn models.UserConnection
-		if err := rows.Scan(
-			&conn.ConnectedProfileID,
-			&conn.ConnectedProfileTitle,
-			&conn.ConnectedUserName,
-			&conn.ConnectedAt,
-			&conn.EventName,
-			&conn.EventDate,
-			&conn.Memo,
-		); err == nil {
-			connections = append(connections, conn)
-		}
-	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"connections": connections,
@@ -231,24 +270,53 @@ func (app *App) UpdateConnection(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// コネクションを更新
-	result, err := app.DB.ExecContext(ctx,
-		`UPDATE connections SET event_name = $1, event_date = $2, memo = $3 WHERE id = $4`,
-		req.EventName, req.EventDate, req.Memo, id,
-	)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新に失敗しました"})
+	if app.loadOwnedConnection(ctx, c, id) == nil {
 		return
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil || rowsAffected == 0 {
+	// コネクションを更新
+	err = app.Connections.Update(ctx, id, req.EventName, req.EventDate, req.Memo)
+	if err == store.ErrNotFound {
 		c.JSON(http.StatusNotFound, gin.H{"error": "該当データがありません"})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新に失敗しました"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"result":  "success",
 		"message": "コネクション情報を更新しました",
 	})
 }
+
+// loadOwnedConnectionは呼び出し元のプロフィールが作成したコネクションを返します
+// 存在しない場合だけでなく他人のコネクションの場合も、存在を明かさないよう404を返してnilを返します
+func (app *App) loadOwnedConnection(ctx context.Context, c *gin.Context, id int) *models.Connection {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return nil
+	}
+
+	conn, err := app.Connections.Get(ctx, id)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "該当データがありません"})
+		return nil
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "取得に失敗しました"})
+		return nil
+	}
+
+	profile, err := app.Profiles.Get(ctx, conn.ProfileID)
+	if err != nil && err != store.ErrNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "取得に失敗しました"})
+		return nil
+	}
+	if err == store.ErrNotFound || profile.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "該当データがありません"})
+		return nil
+	}
+	return conn
+}
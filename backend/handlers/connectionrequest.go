@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/models"
+	"backend/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListIncomingConnectionRequests は自分のプロフィールへのコネクション申請の一覧を返すハンドラーです（?status=pendingなどで絞り込み）
+// 申請者が見せることを選んだプロフィールなので公開範囲に関わらず返しますが、申請者のメモは返しません
+func (app *App) ListIncomingConnectionRequests(c *gin.Context) {
+	app.listConnectionRequests(c, true)
+}
+
+// ListOutgoingConnectionRequests は自分のプロフィールからのコネクション申請の一覧を返すハンドラーです（?status=pendingなどで絞り込み）
+func (app *App) ListOutgoingConnectionRequests(c *gin.Context) {
+	app.listConnectionRequests(c, false)
+}
+
+func (app *App) listConnectionRequests(c *gin.Context, incoming bool) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	status := c.Query("status")
+	switch status {
+	case "", models.ConnectionRequestPending, models.ConnectionRequestAccepted,
+		models.ConnectionRequestDeclined, models.ConnectionRequestExpired:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "statusが不正です"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var requests []models.ConnectionRequest
+	var err error
+	if incoming {
+		requests, err = app.ConnectionRequests.ListIncoming(ctx, userID, status)
+	} else {
+		requests, err = app.ConnectionRequests.ListOutgoing(ctx, userID, status)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "取得に失敗しました"})
+		return
+	}
+
+	for i := range requests {
+		otherID := requests[i].ConnectUsersProfileID
+		if incoming {
+			otherID = requests[i].ProfileID
+			requests[i].ProfileID = 0
+			requests[i].Memo = ""
+		}
+		other, err := app.Profiles.Get(ctx, otherID)
+		if err == store.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
+			return
+		}
+		access, err := app.profileAccessFor(ctx, userID, other)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
+			return
+		}
+		if !incoming && !access.visible {
+			continue
+		}
+		if !incoming {
+			requests[i].ConnectUsersProfilePublicID = other.PublicID
+		}
+		presentProfile(other, access)
+		requests[i].Profile = other
+	}
+
+	c.JSON(http.StatusOK, models.ConnectionRequestListResponse{
+		Requests: requests,
+		Total:    len(requests),
+	})
+}
+
+// AcceptConnectionRequest は自分のプロフィールへのコネクション申請を承認し、申請者のプロフィールからのコネクションを作成するハンドラーです
+func (app *App) AcceptConnectionRequest(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pending := app.loadIncomingConnectionRequest(ctx, c)
+	if pending == nil {
+		return
+	}
+	request, conn, err := app.ConnectionRequests.Accept(ctx, pending.ID)
+	if err == store.ErrConflict {
+		c.JSON(http.StatusConflict, gin.H{"error": "既に対応済みの申請です"})
+		return
+	}
+	if err != nil {
+		fmt.Printf("Accept connection request error: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "承認に失敗しました"})
+		return
+	}
+	// 申請者のプロフィールの連番のIDは返さない
+	request.ProfileID = 0
+	request.Memo = ""
+	request.ConnectUsersProfilePublicID = pending.ConnectUsersProfilePublicID
+	conn.ProfileID = 0
+	conn.ConnectUsersProfilePublicID = pending.ConnectUsersProfilePublicID
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "申請を承認しました",
+		"request":    request,
+		"connection": conn,
+	})
+}
+
+// DeclineConnectionRequest は自分のプロフィールへのコネクション申請を拒否するハンドラーです
+func (app *App) DeclineConnectionRequest(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pending := app.loadIncomingConnectionRequest(ctx, c)
+	if pending == nil {
+		return
+	}
+	request, err := app.ConnectionRequests.Decline(ctx, pending.ID)
+	if err == store.ErrConflict {
+		c.JSON(http.StatusConflict, gin.H{"error": "既に対応済みの申請です"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "拒否に失敗しました"})
+		return
+	}
+	request.ProfileID = 0
+	request.Memo = ""
+	request.ConnectUsersProfilePublicID = pending.ConnectUsersProfilePublicID
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "申請を拒否しました",
+		"request": request,
+	})
+}
+
+// loadIncomingConnectionRequest はパスのIDの申請を取得し、呼び出し元のプロフィールへの承認待ちの申請であることを確認します
+// 他人への申請は存在を明かさないよう404、対応済みは409、期限切れは410を返してnilを返します
+func (app *App) loadIncomingConnectionRequest(ctx context.Context, c *gin.Context) *models.ConnectionRequest {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return nil
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "IDが不正です"})
+		return nil
+	}
+
+	request, err := app.ConnectionRequests.Get(ctx, id)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "申請が見つかりません"})
+		return nil
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "取得に失敗しました"})
+		return nil
+	}
+	target, err := app.Profiles.Get(ctx, request.ConnectUsersProfileID)
+	if err != nil && err != store.ErrNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "取得に失敗しました"})
+		return nil
+	}
+	if err == store.ErrNotFound || target.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "申請が見つかりません"})
+		return nil
+	}
+
+	request.ConnectUsersProfilePublicID = target.PublicID
+
+	switch request.Status {
+	case models.ConnectionRequestPending:
+		return request
+	case models.ConnectionRequestExpired:
+		c.JSON(http.StatusGone, gin.H{"error": "申請の有効期限が切れています"})
+	default:
+		c.JSON(http.StatusConflict, gin.H{"error": "既に対応済みの申請です"})
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"backend/models"
+	"backend/store"
+	"backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skip2/go-qrcode"
+)
+
+// CreateExchangeToken は自分のプロフィールの交換トークンを発行し、それを埋め込んだQRコードを返すハンドラーです
+// QRコードのスクリーンショットを使い回せないよう、トークンには有効期限があり、1回限りにもできます
+func (app *App) CreateExchangeToken(c *gin.Context) {
+	var req models.CreateExchangeTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストが不正です"})
+		return
+	}
+
+	ttl := utils.ExchangeTokenTTL()
+	if req.TTLSeconds != 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+		if ttl < utils.MinExchangeTokenTTL || ttl > utils.MaxExchangeTokenTTL {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf(
+				"有効期間は%d〜%d秒で指定してください", int(utils.MinExchangeTokenTTL.Seconds()), int(utils.MaxExchangeTokenTTL.Seconds()),
+			)})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	profile, err := app.Profiles.Get(ctx, req.ProfileID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "プロフィールが見つかりません"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
+		return
+	}
+	if !authorizeOwner(c, profile.UserID) {
+		return
+	}
+
+	token, claims, err := app.Keys.GenerateExchangeToken(profile.PublicID, req.SingleUse, ttl)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "トークンの生成に失敗しました"})
+		return
+	}
+	exchangeURL := utils.FrontendURL() + "/exchange?token=" + url.QueryEscape(token)
+	qr, err := qrcode.Encode(exchangeURL, qrcode.Medium, 256)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "QRコードの生成に失敗しました"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.ExchangeTokenResponse{
+		Token:     token,
+		URL:       exchangeURL,
+		QRData:    "data:image/png;base64," + base64.StdEncoding.EncodeToString(qr),
+		ExpiresAt: claims.ExpiresAt.Time,
+		SingleUse: claims.SingleUse,
+	})
+}
+
+// PreviewExchangeToken は交換トークンが指すプロフィールを返すハンドラーです（交換前の確認用）
+// トークンを発行した所有者が見せることを選んだプロフィールなので公開範囲に関わらず返しますが、
+// つながりのある人だけに見せる項目は交換済みでなければ取り除きます
+func (app *App) PreviewExchangeToken(c *gin.Context) {
+	var req models.ExchangeTokenPreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストが不正です"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	claims, profile, ok := app.loadExchangeToken(ctx, c, req.Token)
+	if !ok {
+		return
+	}
+	access, err := app.profileAccessFor(ctx, viewerID(c), profile)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
+		return
+	}
+	presentProfile(profile, access)
+
+	c.JSON(http.StatusOK, gin.H{
+		"profile":    profile,
+		"expires_at": claims.ExpiresAt.Time,
+		"single_use": claims.SingleUse,
+	})
+}
+
+// RedeemExchangeToken は交換トークンを検証し、自分のプロフィールから相手のプロフィールへのコネクションを作成するハンドラーです
+// 1回限りのトークンの使用済みの記録とコネクションの作成は1つのトランザクションで行います
+func (app *App) RedeemExchangeToken(c *gin.Context) {
+	var req models.RedeemExchangeTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストが不正です"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	claims, profile, target, ok := app.loadExchangeParties(ctx, c, req)
+	if !ok {
+		return
+	}
+
+	conn := models.Connection{
+		ProfileID:             profile.ID,
+		ConnectUsersProfileID: target.ID,
+		EventName:             req.EventName,
+		EventDate:             req.EventDate,
+		Memo:                  req.Memo,
+	}
+	err := app.ExchangeTokens.Redeem(ctx, claims.ID, claims.ExpiresAt.Time, claims.SingleUse, &conn)
+	if err == store.ErrTokenUsed {
+		c.JSON(http.StatusGone, gin.H{"error": "このQRコードは既に使用されています"})
+		return
+	}
+	if err == store.ErrConflict {
+		c.JSON(http.StatusConflict, gin.H{"error": "既に交換済みです"})
+		return
+	}
+	if err != nil {
+		fmt.Printf("Redeem exchange token error: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "コネクションの作成に失敗しました"})
+		return
+	}
+
+	access, err := app.profileAccessFor(ctx, profile.UserID, target)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
+		return
+	}
+	conn.ConnectUsersProfilePublicID = target.PublicID
+	presentProfile(target, access)
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "コネクションを作成しました",
+		"connection": conn,
+		"profile":    target,
+	})
+}
+
+// CreateExchange は交換トークンを検証し、自分のプロフィールと相手のプロフィールの双方向のコネクションを作成するハンドラーです
+// 相手から自分へのコネクションは、相手が1回限りで有効期間の短いQRコードを見せたこと（トークン）を同意として作成します
+// （申請を自動で承認する設定のプロフィールは、繰り返し使えるトークンでも作成します）
+// 再送しても重複せず、既に双方向とも交換済みの場合は既存のコネクションを200で返します
+func (app *App) CreateExchange(c *gin.Context) {
+	var req models.RedeemExchangeTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストが不正です"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	claims, profile, target, ok := app.loadExchangeParties(ctx, c, req)
+	if !ok {
+		return
+	}
+	// 繰り返し使える・有効期間の長いトークンは、見せた相手以外にも渡りうるので相互交換の同意とみなさない
+	if !claims.AllowsMutualExchange() && !target.AutoAcceptConnections {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf(
+			"相互交換には1回限りで有効期間が%d分以内のQRコードが必要です（自分の一覧への登録は /api/exchange-tokens/redeem を使ってください）",
+			int(utils.MaxMutualExchangeTokenTTL.Minutes()),
+		)})
+		return
+	}
+
+	// イベント名・日付は共通、メモは本人だけのものなので相手側には入れない
+	forward := models.Connection{
+		ProfileID:             profile.ID,
+		ConnectUsersProfileID: target.ID,
+		EventName:             req.EventName,
+		EventDate:             req.EventDate,
+		Memo:                  req.Memo,
+	}
+	reverse := models.Connection{
+		ProfileID:             target.ID,
+		ConnectUsersProfileID: profile.ID,
+		EventName:             req.EventName,
+		EventDate:             req.EventDate,
+	}
+	created, err := app.ExchangeTokens.Exchange(ctx, claims.ID, claims.ExpiresAt.Time, claims.SingleUse, &forward, &reverse)
+	if err == store.ErrTokenUsed {
+		c.JSON(http.StatusGone, gin.H{"error": "このQRコードは既に使用されています"})
+		return
+	}
+	if err != nil {
+		fmt.Printf("Create exchange error: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "プロフィール交換に失敗しました"})
+		return
+	}
+
+	access, err := app.profileAccessFor(ctx, profile.UserID, target)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
+		return
+	}
+	forward.ConnectUsersProfilePublicID = target.PublicID
+	// 相手のプロフィールの連番のIDは返さない
+	reverse.ProfileID = 0
+	reverse.ConnectUsersProfilePublicID = profile.PublicID
+	presentProfile(target, access)
+
+	status := http.StatusCreated
+	if !created {
+		status = http.StatusOK
+	}
+	c.JSON(status, models.ExchangeResponse{
+		Connection:        forward,
+		ReverseConnection: reverse,
+		Profile:           target,
+		AlreadyExisted:    !created,
+	})
+}
+
+// loadExchangeParties は交換トークンが指す相手のプロフィールと、交換に使う自分のプロフィールを取得します
+// 自分のプロフィールでない場合や、自分自身と交換しようとした場合はエラーレスポンスを書き込みfalseを返します
+func (app *App) loadExchangeParties(ctx context.Context, c *gin.Context, req models.RedeemExchangeTokenRequest) (*utils.ExchangeClaims, *models.Profile, *models.Profile, bool) {
+	claims, target, ok := app.loadExchangeToken(ctx, c, req.Token)
+	if !ok {
+		return nil, nil, nil, false
+	}
+
+	profile, err := app.Profiles.Get(ctx, req.ProfileID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "プロフィールが見つかりません"})
+		return nil, nil, nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
+		return nil, nil, nil, false
+	}
+	if !authorizeOwner(c, profile.UserID) {
+		return nil, nil, nil, false
+	}
+	if target.UserID == profile.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "自分のプロフィールとは交換できません"})
+		return nil, nil, nil, false
+	}
+	return claims, profile, target, true
+}
+
+// loadExchangeToken は交換トークンを検証し、指しているプロフィールを取得します
+// 不正・期限切れのトークンと、削除されたプロフィールの場合はエラーレスポンスを書き込みfalseを返します
+func (app *App) loadExchangeToken(ctx context.Context, c *gin.Context, token string) (*utils.ExchangeClaims, *models.Profile, bool) {
+	claims, err := app.Keys.ValidateExchangeToken(token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "QRコードが正しくないか、有効期限が切れています"})
+		return nil, nil, false
+	}
+	profile, err := app.Profiles.GetByPublicRef(ctx, claims.ProfilePublicID)
+	if err == store.ErrNotFound || (err == nil && profile.PublicID != claims.ProfilePublicID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "プロフィールが見つかりません"})
+		return nil, nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
+		return nil, nil, false
+	}
+	return claims, profile, true
+}
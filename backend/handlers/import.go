@@ -0,0 +1,477 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"backend/models"
+	"backend/store"
+	"backend/utils"
+	"backend/vcard"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxImportFileBytes はインポートできるファイルの最大サイズです
+	maxImportFileBytes = 2 << 20
+	// maxImportRecords は1回でインポートできる件数の上限です
+	maxImportRecords = 500
+	// defaultImportTitle はタイトルを指定しなかった場合のプロフィールのタイトルです
+	defaultImportTitle = "インポート"
+	// maxLinkTitleLength はリンクのタイトルの最大文字数です（CreateLinkRequestと同じ）
+	maxLinkTitleLength = 100
+)
+
+// インポートするファイルの形式
+const (
+	importFormatVCard = "vcard"
+	importFormatCSV   = "csv"
+)
+
+// ImportProfiles はvCard（3.0・4.0）またはCSVのファイルからプロフィールをまとめて作成するハンドラーです
+// multipart/form-data で次の項目を受け取ります
+//   - file: インポートするファイル（必須）
+//   - format: vcard または csv（省略時は拡張子・内容から判定）
+//   - title: 作成するプロフィールのタイトル（省略時は「インポート」、CSVのtitle列が優先）
+//   - visibility: 作成するプロフィールの公開範囲（省略時は private）
+//   - profile_id: 指定した自分のプロフィールから、作成した各プロフィールへのコネクションも作成する
+//   - dry_run: true の場合は検証結果だけを返し、何も登録しない（クエリパラメーターでも指定可）
+//
+// 1件でも検証エラーがあれば何も登録せず422を返し、すべて正しければ1つのトランザクションでまとめて登録します
+func (app *App) ImportProfiles(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportFileBytes+64*1024)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "インポートするファイルを指定してください"})
+		return
+	}
+	if fileHeader.Size > maxImportFileBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "ファイルサイズが大きすぎます（2MBまで）"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ファイルを読み込めませんでした"})
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ファイルを読み込めませんでした"})
+		return
+	}
+	if !utf8.Valid(data) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ファイルはUTF-8で保存してください"})
+		return
+	}
+
+	format := strings.ToLower(c.PostForm("format"))
+	if format == "" {
+		format = detectImportFormat(fileHeader.Filename, data)
+	}
+	if format != importFormatVCard && format != importFormatCSV {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "formatはvcardまたはcsvを指定してください"})
+		return
+	}
+
+	visibility := c.DefaultPostForm("visibility", models.ProfileVisibilityPrivate)
+	switch visibility {
+	case models.ProfileVisibilityPublic, models.ProfileVisibilityUnlisted,
+		models.ProfileVisibilityConnections, models.ProfileVisibilityPrivate:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "公開範囲が不正です"})
+		return
+	}
+	title := strings.TrimSpace(c.PostForm("title"))
+	if title == "" {
+		title = defaultImportTitle
+	}
+	dryRun := isTruthy(c.Query("dry_run")) || isTruthy(c.PostForm("dry_run"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// コネクション元のプロフィールは自分のものに限る
+	connectFrom := 0
+	if raw := c.PostForm("profile_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "profile_idが不正です"})
+			return
+		}
+		profile, err := app.Profiles.Get(ctx, id)
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "プロフィールが存在しません"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
+			return
+		}
+		if !authorizeOwner(c, profile.UserID) {
+			return
+		}
+		connectFrom = id
+	}
+
+	var records []models.ImportRecord
+	if format == importFormatVCard {
+		records, err = vcardImportRecords(data)
+	} else {
+		records, err = csvImportRecords(data)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(records) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "インポートするデータがありません"})
+		return
+	}
+	if len(records) > maxImportRecords {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("一度にインポートできるのは%d件までです", maxImportRecords)})
+		return
+	}
+
+	resp := models.ImportResponse{DryRun: dryRun, Format: format, Total: len(records), Records: records}
+	for i := range records {
+		record := &records[i]
+		record.Profile.UserID = userID
+		record.Profile.Visibility = visibility
+		record.Profile.RestrictedFields = []string{}
+		if record.Profile.Title == "" {
+			record.Profile.Title = title
+		}
+		validateImportRecord(record)
+		if len(record.Errors) == 0 {
+			resp.Valid++
+		}
+	}
+
+	if dryRun {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	if resp.Valid != resp.Total {
+		resp.Error = "エラーのあるデータがあるため、インポートしませんでした"
+		c.JSON(http.StatusUnprocessableEntity, resp)
+		return
+	}
+
+	for i := range records {
+		publicID, err := utils.GeneratePublicID()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "公開IDの生成に失敗しました"})
+			return
+		}
+		records[i].Profile.PublicID = publicID
+	}
+	if err := app.Imports.Import(ctx, records, connectFrom); err != nil {
+		fmt.Printf("Import error: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "インポートに失敗しました"})
+		return
+	}
+	resp.Imported = len(records)
+	for i := range records {
+		presentProfile(&records[i].Profile, ownerAccess)
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// detectImportFormat はファイル名の拡張子、なければ内容からファイルの形式を判定します
+func detectImportFormat(filename string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".vcf", ".vcard":
+		return importFormatVCard
+	case ".csv":
+		return importFormatCSV
+	}
+	head := strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(string(data), "\ufeff")))
+	if strings.HasPrefix(head, "BEGIN:VCARD") {
+		return importFormatVCard
+	}
+	return importFormatCSV
+}
+
+func isTruthy(s string) bool {
+	ok, _ := strconv.ParseBool(s)
+	return ok
+}
+
+// vcardImportRecords はvCardの各カードをプロフィール・リンク・任意項目に変換します
+// 対応していない項目（PHOTO・ADRなど）は読み飛ばします
+func vcardImportRecords(data []byte) ([]models.ImportRecord, error) {
+	cards, err := vcard.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("vCardを読み込めませんでした（%v）", err)
+	}
+
+	records := make([]models.ImportRecord, 0, len(cards))
+	for i, card := range cards {
+		record := models.ImportRecord{Index: i + 1, Links: []models.Link{}, OptionProfiles: []models.OptionProfile{}}
+		if card.Version != "3.0" && card.Version != "4.0" {
+			record.Errors = append(record.Errors, fmt.Sprintf("対応していないvCardのバージョンです（%q）", card.Version))
+		}
+
+		profile := &record.Profile
+		if fn, ok := card.Get("FN"); ok {
+			profile.DisplayName = strings.TrimSpace(fn.Value())
+		}
+		if n, ok := card.Get("N"); ok && profile.DisplayName == "" {
+			// N は 姓;名;ミドルネーム;敬称;称号 の順
+			parts := []string{}
+			for _, v := range n.Values[:min(len(n.Values), 2)] {
+				if v = strings.TrimSpace(v); v != "" {
+					parts = append(parts, v)
+				}
+			}
+			profile.DisplayName = strings.Join(parts, " ")
+		}
+		profile.AKA = firstValue(card, "TITLE", "ROLE")
+		profile.Hometown = firstValue(card, "BIRTHPLACE")
+		profile.Hobby = firstValue(card, "HOBBY")
+		profile.Comment = firstValue(card, "NOTE")
+		if bday := firstValue(card, "BDAY"); bday != "" {
+			birthdate, ok, err := parseVCardDate(bday)
+			if err != nil {
+				record.Errors = append(record.Errors, fmt.Sprintf("誕生日の形式が不正です（%s）", bday))
+			} else if ok {
+				profile.Birthdate = birthdate
+			}
+		}
+
+		for _, prop := range card.Properties {
+			value := strings.TrimSpace(prop.Value())
+			if value == "" {
+				continue
+			}
+			switch prop.Name {
+			case "EMAIL":
+				record.Links = append(record.Links, models.Link{Title: "Email", URL: "mailto:" + strings.TrimPrefix(value, "mailto:")})
+			case "TEL":
+				record.Links = append(record.Links, models.Link{Title: "電話", URL: telURI(value)})
+			case "IMPP":
+				scheme, _, _ := strings.Cut(value, ":")
+				record.Links = append(record.Links, models.Link{Title: scheme, URL: value})
+			case "URL", "X-SOCIALPROFILE":
+				// 書き出したvCardに含まれるQRsonaの公開ページは取り込まない
+				if strings.HasPrefix(value, utils.FrontendURL()+"/profile/") {
+					continue
+				}
+				link := models.Link{Title: prop.Param("X-SERVICE-TYPE"), URL: value}
+				if link.Title == "" {
+					link.Title = linkTitle(value, prop.Param("TYPE"))
+				}
+				record.Links = append(record.Links, link)
+			case "ORG":
+				org := strings.TrimSpace(strings.Join(prop.Values, " "))
+				if org != "" {
+					record.OptionProfiles = append(record.OptionProfiles, models.OptionProfile{Title: "所属", Content: org})
+				}
+			case "X-QRSONA-OPTION":
+				record.OptionProfiles = append(record.OptionProfiles, models.OptionProfile{Title: prop.Param("X-LABEL"), Content: value})
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// firstValue は指定した名前のうち最初に見つかった空でない値を返します
+func firstValue(card *vcard.Card, names ...string) string {
+	for _, name := range names {
+		for _, prop := range card.All(name) {
+			if v := strings.TrimSpace(prop.Value()); v != "" {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+// parseVCardDate はBDAYの値（19900401・1990-04-01・1990-04-01T00:00:00Z）を日付に変換します
+// 年のない日付（--0401）は保存できないため、エラーにせずokをfalseで返します
+func parseVCardDate(value string) (time.Time, bool, error) {
+	if strings.HasPrefix(value, "--") {
+		return time.Time{}, false, nil
+	}
+	if i := strings.IndexByte(value, 'T'); i > 0 {
+		value = value[:i]
+	}
+	for _, layout := range []string{"20060102", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("invalid date: %s", value)
+}
+
+// telURI は電話番号をtel: URIに変換します（区切りの空白・括弧は取り除きます）
+func telURI(value string) string {
+	if strings.HasPrefix(strings.ToLower(value), "tel:") {
+		return value
+	}
+	number := strings.NewReplacer(" ", "", "(", "", ")", "", "　", "").Replace(value)
+	return "tel:" + number
+}
+
+// linkTitle はURLに一致するCommonLinkTypesの名前、なければヒント（TYPEパラメーターなど）かホスト名をタイトルにします
+func linkTitle(rawURL, hint string) string {
+	if linkType := commonLinkType(models.Link{URL: rawURL}); linkType != nil {
+		return linkType.Name
+	}
+	if hint != "" {
+		return hint
+	}
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		return strings.TrimPrefix(u.Host, "www.")
+	}
+	return "Link"
+}
+
+// csvImportRecords はヘッダー行のあるCSVの各行をプロフィール・リンク・任意項目に変換します
+// 列名は次のとおりです（大文字小文字は区別しません）
+//   - display_name（name）・title・aka・hometown・birthdate（YYYY-MM-DD）・hobby・comment・description
+//   - email・phone（tel）・url（website）: リンク
+//   - link:<タイトル>: そのタイトルのリンク、option:<タイトル>: そのタイトルの任意項目
+func csvImportRecords(data []byte) ([]models.ImportRecord, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("CSVにヘッダー行がありません")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("CSVを読み込めませんでした（%v）", err)
+	}
+	columns := make([]string, len(header))
+	for i, name := range header {
+		column, ok := csvColumn(name)
+		if !ok {
+			return nil, fmt.Errorf("不明な列があります（%s）", name)
+		}
+		columns[i] = column
+	}
+
+	var records []models.ImportRecord
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("CSVを読み込めませんでした（%v）", err)
+		}
+		record := models.ImportRecord{Index: len(records) + 1, Links: []models.Link{}, OptionProfiles: []models.OptionProfile{}}
+		if len(row) != len(columns) {
+			record.Errors = append(record.Errors, fmt.Sprintf("列の数がヘッダーと一致しません（%d列）", len(row)))
+		}
+
+		profile := &record.Profile
+		for i, column := range columns {
+			if i >= len(row) {
+				break
+			}
+			value := strings.TrimSpace(row[i])
+			if value == "" {
+				continue
+			}
+			switch {
+			case column == "display_name":
+				profile.DisplayName = value
+			case column == "title":
+				profile.Title = value
+			case column == "aka":
+				profile.AKA = value
+			case column == "hometown":
+				profile.Hometown = value
+			case column == "birthdate":
+				birthdate, err := time.Parse("2006-01-02", value)
+				if err != nil {
+					record.Errors = append(record.Errors, fmt.Sprintf("誕生日はYYYY-MM-DD形式で入力してください（%s）", value))
+					continue
+				}
+				profile.Birthdate = birthdate
+			case column == "hobby":
+				profile.Hobby = value
+			case column == "comment":
+				profile.Comment = value
+			case column == "description":
+				profile.Description = value
+			case column == "email":
+				record.Links = append(record.Links, models.Link{Title: "Email", URL: "mailto:" + strings.TrimPrefix(value, "mailto:")})
+			case column == "phone":
+				record.Links = append(record.Links, models.Link{Title: "電話", URL: telURI(value)})
+			case column == "url":
+				record.Links = append(record.Links, models.Link{Title: linkTitle(value, ""), URL: value})
+			case strings.HasPrefix(column, "link:"):
+				record.Links = append(record.Links, models.Link{Title: strings.TrimPrefix(column, "link:"), URL: value})
+			case strings.HasPrefix(column, "option:"):
+				record.OptionProfiles = append(record.OptionProfiles, models.OptionProfile{Title: strings.TrimPrefix(column, "option:"), Content: value})
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// csvColumn はCSVの列名を正規化します（link:・option: のタイトル部分は元の表記のまま残します）
+func csvColumn(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	lower := strings.ToLower(name)
+	for _, prefix := range []string{"link:", "option:"} {
+		if strings.HasPrefix(lower, prefix) {
+			title := strings.TrimSpace(name[len(prefix):])
+			return prefix + title, title != ""
+		}
+	}
+	switch lower {
+	case "display_name", "name":
+		return "display_name", true
+	case "phone", "tel":
+		return "phone", true
+	case "url", "website":
+		return "url", true
+	case "title", "aka", "hometown", "birthdate", "hobby", "comment", "description", "email":
+		return lower, true
+	}
+	return "", false
+}
+
+// validateImportRecord はプロフィール・リンク・任意項目をAPIで作成する場合と同じ基準で検証し、エラーをrecord.Errorsに追加します
+func validateImportRecord(record *models.ImportRecord) {
+	if record.Profile.DisplayName == "" {
+		record.Errors = append(record.Errors, "表示名がありません")
+	}
+	for _, link := range record.Links {
+		if link.Title == "" || utf8.RuneCountInString(link.Title) > maxLinkTitleLength {
+			record.Errors = append(record.Errors, fmt.Sprintf("リンクのタイトルは1〜%d文字で指定してください（%s）", maxLinkTitleLength, link.URL))
+		}
+		if u, err := url.Parse(link.URL); err != nil || u.Scheme == "" || (u.Host == "" && u.Opaque == "") {
+			record.Errors = append(record.Errors, fmt.Sprintf("URLの形式が不正です（%s）", link.URL))
+		}
+	}
+	for _, opt := range record.OptionProfiles {
+		if opt.Title == "" {
+			record.Errors = append(record.Errors, fmt.Sprintf("任意項目のタイトルがありません（%s）", opt.Content))
+		}
+	}
+}
@@ -2,14 +2,13 @@ package handlers
 
 import (
 	"context"
-	"database/sql"
 
 	// "fmt"
 	"net/http"
 	"strconv"
-	"time"
 
 	"backend/models"
+	"backend/store"
 
 	"github.com/gin-gonic/gin"
 )
@@ -28,35 +27,31 @@ func (app *App) CreateLink(c *gin.Context) {
 		return
 	}
 
-	// profile_idが指定されている場合、プロフィールの存在確認
+	// profile_idが指定されている場合、プロフィールの存在確認 & 本人のものか確認
 	if req.ProfileID != nil {
-		var exists bool
-		err := app.DB.QueryRowContext(
-			context.Background(),
-			"SELECT EXISTS(SELECT 1 FROM profiles WHERE id = $1)",
-			*req.ProfileID,
-		).Scan(&exists)
+		profile, err := app.Profiles.Get(context.Background(), *req.ProfileID)
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "プロフィールが存在しません"})
+			return
+		}
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
 			return
 		}
 
-		if !exists {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "プロフィールが存在しません"})
+		if !authorizeOwner(c, profile.UserID) {
 			return
 		}
 	}
 
-	// user_idが指定されている場合、ユーザーの存在確認
+	// user_idが指定されている場合、本人か確認 & ユーザーの存在確認
 	if req.UsersID != nil {
-		var exists bool
-		err := app.DB.QueryRowContext(
-			context.Background(),
-			"SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)",
-			*req.UsersID,
-		).Scan(&exists)
+		if !authorizeOwner(c, *req.UsersID) {
+			return
+		}
 
+		exists, err := app.Users.Exists(context.Background(), *req.UsersID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
 			return
@@ -68,16 +63,20 @@ func (app *App) CreateLink(c *gin.Context) {
 		}
 	}
 
-	var linkID int
-	err := app.DB.QueryRowContext(
-		context.Background(),
-		`INSERT INTO link (user_id, profile_id, image_url, title, description, url, created_at, updated_at) 
-         VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
-		req.UsersID, req.ProfileID, req.ImageURL, req.Title, req.Description, req.URL,
-		time.Now(), time.Now(),
-	).Scan(&linkID)
+	link := models.Link{
+		ProfileID:   req.ProfileID,
+		ImageURL:    req.ImageURL,
+		Title:       req.Title,
+		Description: req.Description,
+		URL:         req.URL,
 
-	if err != nil {
+		ConnectionsOnly: req.ConnectionsOnly,
+	}
+	if req.UsersID != nil {
+		link.UsersID = *req.UsersID
+	}
+
+	if err := app.Links.Create(context.Background(), &link); err != nil {
 		// デバッグログ追加
 		// fmt.Printf("リンク作成エラー: %v\n", err)
 		// fmt.Printf("リクエストデータ: %+v\n", req)
@@ -85,13 +84,6 @@ func (app *App) CreateLink(c *gin.Context) {
 		return
 	}
 
-	// 作成したリンクを取得して返す
-	link, err := app.getLinkByID(linkID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "作成したリンクの取得に失敗しました"})
-		return
-	}
-
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "リンクを作成しました",
 		"link":    link,
@@ -107,52 +99,17 @@ func (app *App) GetLinksByUser(c *gin.Context) {
 		return
 	}
 
-	rows, err := app.DB.QueryContext(
-		context.Background(),
-		`SELECT id, user_id, profile_id, image_url, title, description, url, created_at, updated_at 
-         FROM link 
-         WHERE user_id = $1 OR profile_id IN (SELECT id FROM profiles WHERE user_id = $1)
-         ORDER BY created_at DESC`,
-		userID,
-	)
+	links, err := app.Links.ListByUser(context.Background(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "リンク一覧の取得に失敗しました"})
 		return
 	}
-	defer rows.Close()
-
-	var links []models.Link
-	for rows.Next() {
-		var link models.Link
-		var userIDPtr, profileIDPtr sql.NullInt64
-		var imageURL, description sql.NullString
-
-		err := rows.Scan(
-			&link.ID, &userIDPtr, &profileIDPtr,
-			&imageURL, &link.Title, &description, &link.URL,
-			&link.CreatedAt, &link.UpdatedAt,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースの読み込みに失敗しました"})
-			return
-		}
 
-		// NULL値の処理
-		if userIDPtr.Valid {
-			link.UsersID = int(userIDPtr.Int64)
-		}
-		if profileIDPtr.Valid {
-			profileID := int(profileIDPtr.Int64)
-			link.ProfileID = &profileID
-		}
-		if imageURL.Valid {
-			link.ImageURL = &imageURL.String
-		}
-		if description.Valid {
-			link.Description = &description.String
-		}
-
-		links = append(links, link)
+	// 閲覧者に見せないリンクを除く
+	links, err = app.visibleLinks(context.Background(), viewerID(c), links)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "リンク一覧の取得に失敗しました"})
+		return
 	}
 
 	c.JSON(http.StatusOK, models.LinkListResponse{
@@ -161,61 +118,28 @@ func (app *App) GetLinksByUser(c *gin.Context) {
 	})
 }
 
-// プロフィール別リンク一覧取得
+// プロフィール別リンク一覧取得（:profile_idは公開IDまたはカスタムURL）
+// 閲覧できないプロフィールの場合は空のリストを、つながりのない閲覧者には公開のリンクだけを返す
 func (app *App) GetLinksByProfile(c *gin.Context) {
-	profileIDStr := c.Param("profile_id")
-	profileID, err := strconv.Atoi(profileIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "プロフィールIDが不正です"})
-		return
-	}
-
-	rows, err := app.DB.QueryContext(
-		context.Background(),
-		`SELECT id, user_id, profile_id, image_url, title, description, url, created_at, updated_at 
-         FROM link 
-         WHERE profile_id = $1 
-         ORDER BY created_at DESC`,
-		profileID,
-	)
+	profile, access, err := app.profileAccessByRef(context.Background(), viewerID(c), c.Param("profile_id"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "リンク一覧の取得に失敗しました"})
 		return
 	}
-	defer rows.Close()
-
-	var links []models.Link
-	for rows.Next() {
-		var link models.Link
-		var userIDPtr, profileIDPtr sql.NullInt64
-		var imageURL, description sql.NullString
-
-		err := rows.Scan(
-			&link.ID, &userIDPtr, &profileIDPtr,
-			&imageURL, &link.Title, &description, &link.URL,
-			&link.CreatedAt, &link.UpdatedAt,
-		)
+
+	links := []models.Link{}
+	if access.visible {
+		links, err = app.Links.ListByProfile(context.Background(), profile.ID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースの読み込みに失敗しました"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "リンク一覧の取得に失敗しました"})
 			return
 		}
-
-		// NULL値の処理
-		if userIDPtr.Valid {
-			link.UsersID = int(userIDPtr.Int64)
-		}
-		if profileIDPtr.Valid {
-			profileIDInt := int(profileIDPtr.Int64)
-			link.ProfileID = &profileIDInt
-		}
-		if imageURL.Valid {
-			link.ImageURL = &imageURL.String
+		if !access.connected {
+			links = models.PublicLinks(links)
 		}
-		if description.Valid {
-			link.Description = &description.String
+		if !access.owner {
+			hideLinkOwners(links)
 		}
-
-		links = append(links, link)
 	}
 
 	c.JSON(http.StatusOK, models.LinkListResponse{
@@ -233,9 +157,9 @@ func (app *App) GetLink(c *gin.Context) {
 		return
 	}
 
-	link, err := app.getLinkByID(linkID)
+	link, err := app.Links.Get(context.Background(), linkID)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if err == store.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "リンクが見つかりません"})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "リンクの取得に失敗しました"})
@@ -243,7 +167,18 @@ func (app *App) GetLink(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"link": link})
+	// 閲覧者に見せないリンクは存在しないものとして扱う
+	visible, err := app.visibleLinks(context.Background(), viewerID(c), []models.Link{*link})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "リンクの取得に失敗しました"})
+		return
+	}
+	if len(visible) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "リンクが見つかりません"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"link": visible[0]})
 }
 
 // リンク更新
@@ -261,62 +196,38 @@ func (app *App) UpdateLink(c *gin.Context) {
 		return
 	}
 
-	// 既存のリンクを取得
-	existingLink, err := app.getLinkByID(linkID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "リンクが見つかりません"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "リンクの取得に失敗しました"})
-		}
+	// 既存のリンクを取得 & 本人のものか確認
+	link := app.loadOwnedLink(c, linkID)
+	if link == nil {
 		return
 	}
 
 	// 更新値の設定（nil の場合は既存値を使用）
-	title := existingLink.Title
 	if req.Title != nil {
-		title = *req.Title
+		link.Title = *req.Title
 	}
-
-	url := existingLink.URL
 	if req.URL != nil {
-		url = *req.URL
+		link.URL = *req.URL
 	}
-
-	imageURL := existingLink.ImageURL
 	if req.ImageURL != nil {
-		imageURL = req.ImageURL
+		link.ImageURL = req.ImageURL
 	}
-
-	description := existingLink.Description
 	if req.Description != nil {
-		description = req.Description
+		link.Description = req.Description
+	}
+	if req.ConnectionsOnly != nil {
+		link.ConnectionsOnly = *req.ConnectionsOnly
 	}
 
 	// 更新実行
-	_, err = app.DB.ExecContext(
-		context.Background(),
-		`UPDATE link 
-         SET image_url = $1, title = $2, description = $3, url = $4, updated_at = $5 
-         WHERE id = $6`,
-		imageURL, title, description, url, time.Now(), linkID,
-	)
-
-	if err != nil {
+	if err := app.Links.Update(context.Background(), link); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "リンクの更新に失敗しました"})
 		return
 	}
 
-	// 更新後のリンクを取得
-	updatedLink, err := app.getLinkByID(linkID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新後のリンク取得に失敗しました"})
-		return
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"message": "リンクを更新しました",
-		"link":    updatedLink,
+		"link":    link,
 	})
 }
 
@@ -329,26 +240,19 @@ func (app *App) DeleteLink(c *gin.Context) {
 		return
 	}
 
-	// リンクの存在確認
-	_, err = app.getLinkByID(linkID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "リンクが見つかりません"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "リンクの取得に失敗しました"})
-		}
+	// リンクの存在確認 & 本人のものか確認
+	if app.loadOwnedLink(c, linkID) == nil {
 		return
 	}
 
 	// 削除実行
-	_, err = app.DB.ExecContext(
-		context.Background(),
-		"DELETE FROM link WHERE id = $1",
-		linkID,
-	)
-
+	err = app.Links.Delete(context.Background(), linkID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "リンクの削除に失敗しました"})
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "リンクが見つかりません"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "リンクの削除に失敗しました"})
+		}
 		return
 	}
 
@@ -361,42 +265,3 @@ func (app *App) GetCommonLinkTypes(c *gin.Context) {
 		"link_types": models.CommonLinkTypes,
 	})
 }
-
-// ヘルパー関数: IDでリンクを取得
-func (app *App) getLinkByID(linkID int) (*models.Link, error) {
-	var link models.Link
-	var userIDPtr, profileIDPtr sql.NullInt64
-	var imageURL, description sql.NullString
-
-	err := app.DB.QueryRowContext(
-		context.Background(),
-		`SELECT id, user_id, profile_id, image_url, title, description, url, created_at, updated_at 
-         FROM link WHERE id = $1`,
-		linkID,
-	).Scan(
-		&link.ID, &userIDPtr, &profileIDPtr,
-		&imageURL, &link.Title, &description, &link.URL,
-		&link.CreatedAt, &link.UpdatedAt,
-	)
-
-	if err != nil {
-		return nil, err
-	}
-
-	// NULL値の処理
-	if userIDPtr.Valid {
-		link.UsersID = int(userIDPtr.Int64)
-	}
-	if profileIDPtr.Valid {
-		profileID := int(profileIDPtr.Int64)
-		link.ProfileID = &profileID
-	}
-	if imageURL.Valid {
-		link.ImageURL = &imageURL.String
-	}
-	if description.Valid {
-		link.Description = &description.String
-	}
-
-	return &link, nil
-}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// LockoutPolicy はサインインの連続失敗によるアカウントロックの設定です
+// Threshold回連続で失敗するとBaseDurationだけロックし、以降は失敗するたびにロック時間を倍にします（最大MaxDuration）
+type LockoutPolicy struct {
+	Threshold    int
+	BaseDuration time.Duration
+	MaxDuration  time.Duration
+	// ResetAfter は最後の失敗からこの時間が経過すると失敗回数を数え直します
+	ResetAfter time.Duration
+}
+
+// LoadLockoutPolicy は環境変数からロックの設定を読み込みます
+// LOGIN_LOCKOUT_THRESHOLD（デフォルト5回）、LOGIN_LOCKOUT_BASE_SECONDS（デフォルト60秒）、LOGIN_LOCKOUT_MAX_MINUTES（デフォルト60分）
+func LoadLockoutPolicy() LockoutPolicy {
+	return LockoutPolicy{
+		Threshold:    envInt("LOGIN_LOCKOUT_THRESHOLD", 5),
+		BaseDuration: time.Duration(envInt("LOGIN_LOCKOUT_BASE_SECONDS", 60)) * time.Second,
+		MaxDuration:  time.Duration(envInt("LOGIN_LOCKOUT_MAX_MINUTES", 60)) * time.Minute,
+		ResetAfter:   24 * time.Hour,
+	}
+}
+
+// LockDuration は連続失敗回数に応じたロック時間を返します（ロックしない場合は0）
+func (p LockoutPolicy) LockDuration(failures int) time.Duration {
+	if failures < p.Threshold {
+		return 0
+	}
+	d := p.BaseDuration
+	for i := p.Threshold; i < failures && d < p.MaxDuration; i++ {
+		d *= 2
+	}
+	if d > p.MaxDuration {
+		d = p.MaxDuration
+	}
+	return d
+}
+
+// envInt は正の整数の環境変数を読み込みます（未設定・不正な場合はdefaultValue）
+func envInt(key string, defaultValue int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultValue
+}
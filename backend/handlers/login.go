@@ -2,9 +2,13 @@ package handlers
 
 import (
 	"backend/models"
+	"backend/store"
 	"backend/utils"
 	"context"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -22,43 +26,103 @@ func (app *App) SignIn(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	var (
-		id             int
-		name, email    string
-		hashedPassword string
-	)
-
-	err := app.DB.QueryRowContext(
-		ctx,
-		"SELECT id, name, email, password FROM users WHERE email = $1",
-		req.Email,
-	).Scan(&id, &name, &email, &hashedPassword)
-
+	email, err := utils.NormalizeEmail(req.Email)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "メールアドレスまたはパスワードが正しくありません"})
 		return
 	}
 
-	// パスワード検証
-	if bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(req.Password)) != nil {
+	// ロック中のアカウントはパスワードを検証せずに拒否する
+	attempt, err := app.LoginAttempts.Get(ctx, email)
+	if err != nil && err != store.ErrNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	if err == nil && attempt.LockedUntil != nil && attempt.LockedUntil.After(time.Now()) {
+		respondLocked(c, *attempt.LockedUntil)
+		return
+	}
+
+	user, err := app.Users.GetByEmail(ctx, email)
+	if err != nil && err != store.ErrNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+
+	// パスワード検証（存在しないアカウントも失敗として数え、登録の有無で挙動を変えない）
+	if err == store.ErrNotFound || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)) != nil {
+		if lockedUntil := app.recordSignInFailure(ctx, email); !lockedUntil.IsZero() {
+			respondLocked(c, lockedUntil)
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "メールアドレスまたはパスワードが正しくありません"})
 		return
 	}
 
-	// JWTトークン生成
-	token, err := utils.GenerateJWT(id, email)
+	// 停止中のアカウントはパスワードが正しくてもサインインさせない
+	if user.Suspended() {
+		respondSuspended(c)
+		return
+	}
+
+	// 二要素認証が有効な場合はトークンの代わりにチャレンジトークンを返す（失敗回数は二段階目の成功時にリセット）
+	enrollment, err := app.TwoFactor.Get(ctx, user.ID)
+	if err != nil && err != store.ErrNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	if enrollment.Enabled() {
+		app.respondTwoFactorChallenge(c, user)
+		return
+	}
+
+	if err := app.LoginAttempts.Reset(ctx, email); err != nil {
+		fmt.Printf("Failed to reset login attempts: %v\n", err)
+	}
+
+	// アクセストークン・リフレッシュトークン生成
+	res, err := app.issueTokens(ctx, c, user, "")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "トークンの生成に失敗しました"})
 		return
 	}
 
 	// ログイン成功レスポンス
-	c.JSON(http.StatusOK, gin.H{
-		"user": models.User{
-			ID:    id,
-			Name:  name,
-			Email: email,
-		},
-		"token": token, // トークンを追加
+	c.JSON(http.StatusOK, res)
+}
+
+// recordSignInFailure はサインインの失敗を記録し、ロックした場合はロック解除時刻を返します
+func (app *App) recordSignInFailure(ctx context.Context, email string) time.Time {
+	attempt, err := app.LoginAttempts.RecordFailure(ctx, email, app.Lockout.ResetAfter)
+	if err != nil {
+		fmt.Printf("Failed to record login failure: %v\n", err)
+		return time.Time{}
+	}
+	d := app.Lockout.LockDuration(attempt.Failures)
+	if d == 0 {
+		return time.Time{}
+	}
+	lockedUntil := time.Now().Add(d)
+	if err := app.LoginAttempts.Lock(ctx, email, lockedUntil); err != nil {
+		fmt.Printf("Failed to lock account: %v\n", err)
+		return time.Time{}
+	}
+	return lockedUntil
+}
+
+// respondSuspended はアカウントが停止中であることを403で返します
+func respondSuspended(c *gin.Context) {
+	c.JSON(http.StatusForbidden, gin.H{"error": "このアカウントは停止されています"})
+}
+
+// respondLocked はアカウントロック中であることを429とRetry-Afterヘッダーで返します
+func respondLocked(c *gin.Context, lockedUntil time.Time) {
+	seconds := int(math.Ceil(time.Until(lockedUntil).Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error": "サインインの失敗が続いたため、一時的にロックしています。しばらくしてから再度お試しください",
 	})
 }
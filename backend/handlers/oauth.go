@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"backend/models"
+	"backend/oauth"
+	"backend/store"
+	"backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// oauthStateTTL は認可リクエストを開始してからコールバックまでの有効期間です
+const oauthStateTTL = 10 * time.Minute
+
+// oauthRedirectURI はプロバイダーからのリダイレクト先（フロントエンドのコールバックページ）です
+func oauthRedirectURI(provider string) string {
+	return utils.FrontendURL() + "/auth/callback/" + provider
+}
+
+// GetOAuthProviders は利用できる外部ログインのプロバイダー一覧を返すハンドラーです
+func (app *App) GetOAuthProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, models.OAuthProvidersResponse{Providers: app.OAuth.Names()})
+}
+
+// StartOAuth は外部プロバイダーでのログインを開始し、認可エンドポイントのURLを返すハンドラーです
+// stateとPKCEのcode_verifierはサーバー側に保存し、コールバックで照合します
+func (app *App) StartOAuth(c *gin.Context) {
+	provider, ok := app.OAuth[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "対応していないプロバイダーです"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	state, stateHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	verifier, challenge, err := oauth.NewPKCE()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, challenge, oauthRedirectURI(provider.Name()))
+	if err != nil {
+		fmt.Printf("Failed to build authorization URL: %v\n", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "プロバイダーに接続できませんでした"})
+		return
+	}
+
+	if err := app.OAuthStates.Create(ctx, &models.OAuthState{
+		StateHash:    stateHash,
+		Provider:     provider.Name(),
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(oauthStateTTL),
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.OAuthStartResponse{AuthorizationURL: authURL})
+}
+
+// OAuthCallback は認可コードを検証してサインインするハンドラーです
+// 紐付け済みのアカウントがなければ、プロバイダーで確認済みのメールアドレスで既存ユーザーに紐付けるか新規登録します
+func (app *App) OAuthCallback(c *gin.Context) {
+	provider, ok := app.OAuth[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "対応していないプロバイダーです"})
+		return
+	}
+
+	var req models.OAuthCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストが不正です"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	// stateは一度しか使えない（別のプロバイダー向けに発行したstateも拒否する）
+	state, err := app.OAuthStates.Consume(ctx, utils.HashToken(req.State))
+	if err != nil && err != store.ErrNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	if err == store.ErrNotFound || state.Provider != provider.Name() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ログインの有効期限が切れました。もう一度お試しください"})
+		return
+	}
+
+	identity, err := provider.Exchange(ctx, req.Code, state.CodeVerifier, oauthRedirectURI(provider.Name()))
+	if err != nil {
+		fmt.Printf("OAuth code exchange failed (%s): %v\n", provider.Name(), err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "外部アカウントの認証に失敗しました"})
+		return
+	}
+
+	user, linked, ok := app.resolveOAuthUser(c, ctx, provider.Name(), identity)
+	if !ok {
+		return
+	}
+	if user.Suspended() {
+		respondSuspended(c)
+		return
+	}
+
+	// GitHubアカウントを連携したら最初のプロフィールにGitHubのリンクを追加する
+	if linked && provider.Name() == "github" {
+		if err := app.populateGitHubLink(ctx, user.ID); err != nil {
+			fmt.Printf("Failed to populate GitHub link: %v\n", err)
+		}
+	}
+
+	// 二要素認証はパスワードでのサインインと同様に求める
+	enrollment, err := app.TwoFactor.Get(ctx, user.ID)
+	if err != nil && err != store.ErrNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	if enrollment.Enabled() {
+		app.respondTwoFactorChallenge(c, user)
+		return
+	}
+
+	res, err := app.issueTokens(ctx, c, user, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "トークンの生成に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// resolveOAuthUser は外部アカウントに対応するユーザーを返します（紐付けを新しく作成した場合はlinked=true）
+// 失敗した場合はレスポンスを書き込んでok=falseを返します
+func (app *App) resolveOAuthUser(c *gin.Context, ctx context.Context, provider string, identity *oauth.Identity) (user *models.User, linked bool, ok bool) {
+	existing, err := app.Identities.GetBySubject(ctx, provider, identity.Subject)
+	if err == nil {
+		user, err = app.Users.GetByID(ctx, existing.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+			return nil, false, false
+		}
+		return user, false, true
+	}
+	if err != store.ErrNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return nil, false, false
+	}
+
+	// 確認済みのメールアドレスがなければ既存アカウントとの照合も新規登録もできない
+	email, err := utils.NormalizeEmail(identity.Email)
+	if err != nil || !identity.EmailVerified {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "外部アカウントの確認済みメールアドレスを取得できませんでした"})
+		return nil, false, false
+	}
+
+	record := models.UserIdentity{
+		Provider:   provider,
+		Subject:    identity.Subject,
+		Email:      email,
+		ProfileURL: identity.ProfileURL,
+	}
+
+	user, err = app.Users.GetByEmail(ctx, email)
+	if err == nil {
+		// 第三者が先に同じアドレスで登録したアカウントを乗っ取られないよう、未確認のアカウントには紐付けない
+		if user.EmailVerifiedAt == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "このメールアドレスのアカウントは確認が済んでいません。パスワードでサインインしてメールアドレスを確認してから連携してください"})
+			return nil, false, false
+		}
+		record.UserID = user.ID
+		if err := app.Identities.Create(ctx, &record); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "アカウントの連携に失敗しました"})
+			return nil, false, false
+		}
+		return user, true, true
+	}
+	if err != store.ErrNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return nil, false, false
+	}
+
+	// 新規登録（パスワードは使えない値にしておき、必要ならパスワード再設定で設定してもらう）
+	randomPassword, _, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return nil, false, false
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(randomPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return nil, false, false
+	}
+	name := identity.Name
+	if name == "" {
+		name = strings.SplitN(email, "@", 2)[0]
+	}
+	verifiedAt := time.Now()
+	user = &models.User{
+		Name:            name,
+		Email:           email,
+		PasswordHash:    string(hashedPassword),
+		EmailVerifiedAt: &verifiedAt,
+	}
+	if err := app.Identities.CreateWithUser(ctx, user, &record); err == store.ErrConflict {
+		c.JSON(http.StatusConflict, gin.H{"error": "同時に登録処理が行われました。もう一度お試しください"})
+		return nil, false, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ユーザー登録に失敗しました"})
+		return nil, false, false
+	}
+	return user, true, true
+}
+
+// populateGitHubLink は連携済みのGitHubアカウントのリンクをユーザーの最初のプロフィールに追加します
+// 限定公開のプロフィールはGitHubアカウントと結び付けないよう対象にしません
+// 対象のプロフィールがない場合や、同じURLのリンクがすでにある場合は何もしません
+func (app *App) populateGitHubLink(ctx context.Context, userID int) error {
+	identities, err := app.Identities.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	var profileURL string
+	for _, identity := range identities {
+		if identity.Provider == "github" && identity.ProfileURL != "" {
+			profileURL = identity.ProfileURL
+			break
+		}
+	}
+	if profileURL == "" {
+		return nil
+	}
+
+	profiles, err := app.Profiles.ListByUser(ctx, userID)
+	if err != nil || len(profiles) == 0 {
+		return err
+	}
+	first := profiles[0]
+	for _, p := range profiles {
+		if p.ID < first.ID {
+			first = p
+		}
+	}
+	if first.Unlisted() {
+		return nil
+	}
+
+	links, err := app.Links.ListByProfile(ctx, first.ID)
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		if link.URL == profileURL {
+			return nil
+		}
+	}
+
+	link := models.Link{
+		UsersID:   userID,
+		ProfileID: &first.ID,
+		Title:     "GitHub",
+		URL:       profileURL,
+	}
+	for _, t := range models.CommonLinkTypes {
+		if t.Name == "GitHub" {
+			iconURL := t.IconURL
+			link.ImageURL = &iconURL
+		}
+	}
+	return app.Links.Create(ctx, &link)
+}
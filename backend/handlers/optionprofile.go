@@ -2,12 +2,10 @@ package handlers
 
 import (
 	"backend/models"
+	"backend/store"
 	"context"
-	"database/sql"
-	"fmt"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,37 +18,33 @@ func (app *App) CreateOptionProfile(c *gin.Context) {
 		return
 	}
 
-	// Profileの存在チェック
-	var exists bool
-	err := app.DB.QueryRowContext(
-		context.Background(),
-		"SELECT EXISTS(SELECT 1 FROM profiles WHERE id = $1)", req.ProfileID,
-	).Scan(&exists)
+	// Profileの存在チェック & 本人のものか確認
+	profile, err := app.Profiles.Get(context.Background(), req.ProfileID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "プロフィールが存在しません"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
 		return
 	}
-	if !exists {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "プロフィールが存在しません"})
+	if !authorizeOwner(c, profile.UserID) {
 		return
 	}
 
 	// DBにINSERT
-	var optionID int
-	query := `INSERT INTO option_profiles (title, content, profile_id) VALUES ($1, $2, $3) RETURNING id`
-	err = app.DB.QueryRowContext(context.Background(), query, req.Title, req.Content, req.ProfileID).
-		Scan(&optionID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "任意項目の作成に失敗しました"})
-		return
-	}
-
 	optionProfile := models.OptionProfile{
-		ID:        optionID,
 		Title:     req.Title,
 		Content:   req.Content,
 		ProfileID: req.ProfileID,
+
+		ConnectionsOnly: req.ConnectionsOnly,
 	}
+	if err := app.OptionProfiles.Create(context.Background(), &optionProfile); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "任意項目の作成に失敗しました"})
+		return
+	}
+
 	c.JSON(http.StatusCreated, optionProfile)
 }
 
@@ -69,33 +63,18 @@ func (app *App) UpdateOptionProfile(c *gin.Context) {
 	}
 
 	// 部分更新に対応
-	fields := []string{}
-	params := []interface{}{}
-	paramCnt := 1
-	if req.Title != "" {
-		fields = append(fields, fmt.Sprintf("title = $%d", paramCnt))
-		params = append(params, req.Title)
-		paramCnt++
-	}
-	if req.Content != "" {
-		fields = append(fields, fmt.Sprintf("content = $%d", paramCnt))
-		params = append(params, req.Content)
-		paramCnt++
-	}
-	if len(fields) == 0 {
+	if req.Title == "" && req.Content == "" && req.ConnectionsOnly == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "更新する項目がありません"})
 		return
 	}
 
-	updateQuery := fmt.Sprintf(
-		"UPDATE option_profiles SET %s WHERE id = $%d RETURNING id, title, content, profile_id",
-		strings.Join(fields, ", "), paramCnt,
-	)
-	params = append(params, optionID)
-	var updated models.OptionProfile
-	err = app.DB.QueryRowContext(context.Background(), updateQuery, params...).
-		Scan(&updated.ID, &updated.Title, &updated.Content, &updated.ProfileID)
-	if err == sql.ErrNoRows {
+	// 任意項目の存在確認 & 本人のプロフィールのものか確認
+	if app.loadOwnedOptionProfile(c, optionID) == nil {
+		return
+	}
+
+	updated, err := app.OptionProfiles.Update(context.Background(), optionID, req.Title, req.Content, req.ConnectionsOnly)
+	if err == store.ErrNotFound {
 		c.JSON(http.StatusNotFound, gin.H{"error": "任意項目が見つかりません"})
 		return
 	}
@@ -114,46 +93,42 @@ func (app *App) DeleteOptionProfile(c *gin.Context) {
 		return
 	}
 
-	res, err := app.DB.ExecContext(context.Background(),
-		"DELETE FROM option_profiles WHERE id = $1", optionID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
+	// 任意項目の存在確認 & 本人のプロフィールのものか確認
+	if app.loadOwnedOptionProfile(c, optionID) == nil {
 		return
 	}
-	count, _ := res.RowsAffected()
-	if count == 0 {
+
+	err = app.OptionProfiles.Delete(context.Background(), optionID)
+	if err == store.ErrNotFound {
 		c.JSON(http.StatusNotFound, gin.H{"error": "任意項目が見つかりません"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"result": "削除しました"})
-}
-
-// GetOptionProfilesByProfileID はprofile_idで任意項目のリストを返すハンドラー
-func (app *App) GetOptionProfilesByProfileID(c *gin.Context) {
-	profileID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "プロフィールIDが不正です"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"result": "削除しました"})
+}
 
-	rows, err := app.DB.QueryContext(
-		context.Background(),
-		"SELECT id, title, content, profile_id FROM option_profiles WHERE profile_id = $1 ORDER BY id DESC", profileID)
+// GetOptionProfilesByProfileID はプロフィールの任意項目のリストを返すハンドラー（:idは公開IDまたはカスタムURL）
+// 閲覧できないプロフィールの場合は空のリストを、つながりのない閲覧者には公開の任意項目だけを返します
+func (app *App) GetOptionProfilesByProfileID(c *gin.Context) {
+	profile, access, err := app.profileAccessByRef(context.Background(), viewerID(c), c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
 		return
 	}
-	defer rows.Close()
 
 	options := []models.OptionProfile{}
-	for rows.Next() {
-		var opt models.OptionProfile
-		err := rows.Scan(&opt.ID, &opt.Title, &opt.Content, &opt.ProfileID)
+	if access.visible {
+		options, err = app.OptionProfiles.ListByProfile(context.Background(), profile.ID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースのスキャンエラー"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
 			return
 		}
-		options = append(options, opt)
+		if !access.connected {
+			options = models.PublicOptionProfiles(options)
+		}
 	}
 	resp := models.OptionProfileListResponse{
 		Options: options,
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"backend/mail"
+	"backend/models"
+	"backend/store"
+	"backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordResetMailLimit は同じアドレスにリセットメールを送れる頻度です（連続3通、以降20分に1通）
+var passwordResetMailLimit = store.RateLimit{Burst: 3, Interval: 20 * time.Minute}
+
+// ForgotPassword はパスワードリセット用のメールを送信するハンドラーです
+// メールアドレスが登録済みかどうかを推測されないよう、結果に関わらず同じレスポンスを返します
+func (app *App) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストが不正です"})
+		return
+	}
+
+	email, err := utils.NormalizeEmail(req.Email)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	accepted := gin.H{"message": "登録済みのメールアドレスの場合、パスワード再設定用のメールを送信しました"}
+
+	user, err := app.Users.GetByEmail(ctx, email)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusOK, accepted)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+
+	// 同じアドレスへの大量送信を防ぐ（制限中も登録の有無が分からないよう同じレスポンスを返す）
+	limited, err := app.RateLimits.Take(ctx, "password_reset:"+email, passwordResetMailLimit)
+	if err != nil {
+		fmt.Printf("Rate limit check failed: %v\n", err)
+	} else if !limited.Allowed {
+		c.JSON(http.StatusOK, accepted)
+		return
+	}
+
+	// 有効なリセットトークンは常に最新の1つだけにする
+	if err := app.OneTimeTokens.InvalidateForUser(ctx, user.ID, models.TokenPurposePasswordReset); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+
+	rawToken, tokenHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	ttl := utils.PasswordResetTTL()
+	token := models.OneTimeToken{
+		UserID:    user.ID,
+		Purpose:   models.TokenPurposePasswordReset,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := app.OneTimeTokens.Create(ctx, &token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+
+	resetURL := fmt.Sprintf("%s/reset-password?token=%s", utils.FrontendURL(), url.QueryEscape(rawToken))
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "【QRsona】パスワード再設定のご案内",
+		Body: fmt.Sprintf("%s さん\n\n以下のリンクからパスワードを再設定してください（有効期限: %d分）。\n%s\n\n"+
+			"このメールに心当たりがない場合は破棄してください。パスワードは変更されません。\n",
+			user.Name, int(ttl.Minutes()), resetURL),
+	}
+	if err := app.Mailer.Send(ctx, msg); err != nil {
+		fmt.Printf("Failed to send password reset mail: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "メールの送信に失敗しました"})
+		return
+	}
+
+	c.JSON(http.StatusOK, accepted)
+}
+
+// ResetPassword はリセットトークンを検証して新しいパスワードを設定するハンドラーです
+// パスワード変更後は既存のセッション（リフレッシュトークン・アクセストークン）をすべて失効させます
+func (app *App) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストが不正です（パスワードは8文字以上）"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	invalid := gin.H{"error": "リセット用のリンクが無効か、有効期限が切れています"}
+
+	token, err := app.OneTimeTokens.GetByHash(ctx, models.TokenPurposePasswordReset, utils.HashToken(req.Token))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusBadRequest, invalid)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+
+	// 使用済みにできた場合だけ続行する（期限切れ・使用済み・同時使用を拒否）
+	consumed, err := app.OneTimeTokens.Consume(ctx, token.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	if !consumed {
+		c.JSON(http.StatusBadRequest, invalid)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	if err := app.Users.UpdatePassword(ctx, token.UserID, string(hashedPassword)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "パスワードの更新に失敗しました"})
+		return
+	}
+
+	if err := app.revokeUserSessions(ctx, token.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "セッションの失効に失敗しました"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "パスワードを再設定しました。新しいパスワードでサインインしてください"})
+}
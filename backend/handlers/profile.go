@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	"backend/mail"
 	"backend/models"
+	"backend/oauth"
+	"backend/store"
 	"backend/utils"
 	"context"
-	"database/sql"
 	"encoding/base64"
 	"fmt"
 	"net/http"
@@ -20,22 +22,56 @@ import (
 
 // App はアプリケーションのコンテキストを保持します
 type App struct {
-	DB               *sql.DB
+	*store.Stores
 	CloudinaryClient *utils.CloudinaryClient
+	Keys             *utils.KeyManager
+	Mailer           mail.Mailer
+	UnverifiedPolicy UnverifiedPolicy
+	Lockout          LockoutPolicy
+	OAuth            oauth.Providers
+	// AccountDeletionGrace はアカウント削除を予約してから完全に削除するまでの猶予期間です
+	AccountDeletionGrace time.Duration
 }
 
 // NewApp は新しいAppインスタンスを作成します
-func NewApp(db *sql.DB) (*App, error) {
+func NewApp(stores *store.Stores) (*App, error) {
+	keys, err := utils.NewKeyManagerFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("JWT署名鍵の読み込みに失敗しました: %v", err)
+	}
+	fmt.Printf("JWT signing key loaded (kid: %s)\n", keys.ActiveKID())
+
+	mailer, err := mail.NewFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("メール送信の設定に失敗しました: %v", err)
+	}
+
+	policy, err := LoadUnverifiedPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	app := &App{
+		Stores:               stores,
+		Keys:                 keys,
+		Mailer:               mailer,
+		UnverifiedPolicy:     policy,
+		Lockout:              LoadLockoutPolicy(),
+		OAuth:                oauth.ProvidersFromEnv(),
+		AccountDeletionGrace: LoadAccountDeletionGrace(),
+	}
+
 	fmt.Println("Initializing Cloudinary client...")
 	cloudinaryClient, err := utils.NewCloudinaryClient()
 	if err != nil {
-		// Cloudinaryが設定されていない場合はログを出力してnilを設定
+		// Cloudinaryが設定されていない場合はログを出力してnilのままにする
 		fmt.Printf("Cloudinary設定なし（ローカルファイル保存を使用）: %v\n", err)
-		return &App{DB: db, CloudinaryClient: nil}, nil
+		return app, nil
 	}
 
 	fmt.Println("Cloudinary client initialized successfully")
-	return &App{DB: db, CloudinaryClient: cloudinaryClient}, nil
+	app.CloudinaryClient = cloudinaryClient
+	return app, nil
 }
 
 // CreateProfile は新しいプロフィールを作成するハンドラーです
@@ -52,14 +88,37 @@ func (app *App) CreateProfile(c *gin.Context) {
 		return
 	}
 
-	// ユーザーIDの存在チェック
-	var exists bool
-	err := app.DB.QueryRowContext(
-		context.Background(),
-		"SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)",
-		req.UserID,
-	).Scan(&exists)
+	// 作成者は常にトークンのユーザー（他人のuser_idを指定した場合は拒否）
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	if req.UserID == 0 {
+		req.UserID = userID
+	}
+	if !authorizeOwner(c, req.UserID) {
+		return
+	}
+
+	restrictedFields, ok := normalizeRestrictedFields(req.RestrictedFields)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "公開範囲を制限できない項目が含まれています"})
+		return
+	}
+
+	// カスタムURLの検証（形式・予約語・不適切な語句）
+	slug := req.Slug
+	if slug != "" {
+		normalized, err := utils.NormalizeSlug(slug)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		slug = normalized
+	}
 
+	// ユーザーIDの存在チェック
+	exists, err := app.Users.Exists(context.Background(), req.UserID)
 	if err != nil {
 		fmt.Printf("Database error checking user: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
@@ -109,6 +168,8 @@ func (app *App) CreateProfile(c *gin.Context) {
 				return
 			}
 			fmt.Printf("Cloudinary upload success - URL: %s\n", iconURL)
+			// 削除・エクスポートの際に参照できるようCloudinaryのURLを保存する
+			iconPath = iconURL
 		} else {
 			// ローカルファイル保存（開発環境用）
 			uploadDir := "./uploads"
@@ -134,56 +195,61 @@ func (app *App) CreateProfile(c *gin.Context) {
 		}
 	}
 
+	profile := models.Profile{
+		UserID:      req.UserID,
+		DisplayName: req.DisplayName,
+		IconPath:    iconPath,
+		AKA:         req.AKA,
+		Hometown:    req.Hometown,
+		Hobby:       req.Hobby,
+		Comment:     req.Comment,
+		Title:       req.Title,
+		Description: req.Description,
+
+		Visibility:       req.Visibility,
+		RestrictedFields: restrictedFields,
+		Slug:             slug,
+
+		AutoAcceptConnections: req.AutoAcceptConnections,
+	}
+
+	// 公開URL・QRコード用のIDを発行
+	if profile.PublicID, err = utils.GeneratePublicID(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "プロフィールの作成に失敗しました"})
+		return
+	}
+
 	// 誕生日の処理
-	var birthdate *time.Time
 	if req.Birthdate != "" {
 		parsedDate, err := time.Parse("2006-01-02", req.Birthdate)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "誕生日の形式が不正です。YYYY-MM-DD形式で入力してください"})
 			return
 		}
-		birthdate = &parsedDate
+		profile.Birthdate = parsedDate
 	}
 
 	// プロフィール情報をDBに保存
-	var profileID int
-	query := `INSERT INTO profiles (
-        user_id, display_name, icon_path, aka, hometown, 
-        birthdate, hobby, comment, title, description
-    ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) 
-    RETURNING id`
-
-	err = app.DB.QueryRowContext(
-		context.Background(),
-		query,
-		req.UserID, req.DisplayName, iconPath, req.AKA, req.Hometown,
-		birthdate, req.Hobby, req.Comment, req.Title, req.Description,
-	).Scan(&profileID)
-
-	if err != nil {
+	if err := app.Profiles.Create(context.Background(), &profile); err == store.ErrConflict {
+		c.JSON(http.StatusConflict, gin.H{"error": "このURLはすでに使われています"})
+		return
+	} else if err != nil {
 		fmt.Printf("Database error creating profile: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "プロフィールの作成に失敗しました"})
 		return
 	}
 
-	// 作成したプロフィールを返す
-	profile := models.Profile{
-		ID:          profileID,
-		UserID:      req.UserID,
-		DisplayName: req.DisplayName,
-		IconURL:     iconURL,
-		AKA:         req.AKA,
-		Hometown:    req.Hometown,
-		Hobby:       req.Hobby,
-		Comment:     req.Comment,
-		Title:       req.Title,
-		Description: req.Description,
+	// 最初のプロフィールには連携済みのGitHubアカウントのリンクを追加する
+	if profiles, err := app.Profiles.ListByUser(context.Background(), req.UserID); err == nil && len(profiles) == 1 {
+		if err := app.populateGitHubLink(context.Background(), req.UserID); err != nil {
+			fmt.Printf("Failed to populate GitHub link: %v\n", err)
+		}
 	}
 
-	// 誕生日がある場合は設定
-	if birthdate != nil {
-		profile.Birthdate = *birthdate
-	}
+	// 作成したプロフィールを返す
+	profile.IconPath = ""
+	profile.IconURL = iconURL
+	profile.URL = profileURL(&profile)
 
 	c.JSON(http.StatusCreated, profile)
 }
@@ -202,47 +268,47 @@ func (app *App) UpdateProfile(c *gin.Context) {
 		return
 	}
 
-	// プロフィール存在確認
-	var userID int
-	var currentIconPath sql.NullString
-	err = app.DB.QueryRowContext(
-		context.Background(),
-		"SELECT user_id, icon_path FROM profiles WHERE id = $1",
-		profileID,
-	).Scan(&userID, &currentIconPath)
-
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "プロフィールが見つかりません"})
-		return
-	}
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
+	// プロフィール存在確認 & 本人のものか確認
+	current := app.loadOwnedProfile(c, profileID)
+	if current == nil {
 		return
 	}
 
-	// 更新クエリ構築
-	query := "UPDATE profiles SET "
-	params := []interface{}{}
-	paramCount := 0
-
-	// 各フィールドの更新処理
-	if req.DisplayName != "" {
-		paramCount++
-		query += fmt.Sprintf("display_name = $%d, ", paramCount)
-		params = append(params, req.DisplayName)
-	}
-
-	if req.AKA != "" {
-		paramCount++
-		query += fmt.Sprintf("aka = $%d, ", paramCount)
-		params = append(params, req.AKA)
-	}
-
-	if req.Hometown != "" {
-		paramCount++
-		query += fmt.Sprintf("hometown = $%d, ", paramCount)
-		params = append(params, req.Hometown)
+	// 各フィールドの更新処理（空文字は更新しない）
+	var update store.ProfileUpdate
+	optional := func(v string) *string {
+		if v == "" {
+			return nil
+		}
+		return &v
+	}
+	update.DisplayName = optional(req.DisplayName)
+	update.AKA = optional(req.AKA)
+	update.Hometown = optional(req.Hometown)
+	update.Hobby = optional(req.Hobby)
+	update.Comment = optional(req.Comment)
+	update.Title = optional(req.Title)
+	update.Description = optional(req.Description)
+	update.Visibility = optional(req.Visibility)
+	update.AutoAcceptConnections = req.AutoAcceptConnections
+	if req.RestrictedFields != nil {
+		restrictedFields, ok := normalizeRestrictedFields(*req.RestrictedFields)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "公開範囲を制限できない項目が含まれています"})
+			return
+		}
+		update.RestrictedFields = &restrictedFields
+	}
+	if req.Slug != nil {
+		// 空文字はカスタムURLの解除
+		slug := ""
+		if *req.Slug != "" {
+			if slug, err = utils.NormalizeSlug(*req.Slug); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		update.Slug = &slug
 	}
 
 	if req.Birthdate != "" {
@@ -251,37 +317,9 @@ func (app *App) UpdateProfile(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "誕生日の形式が不正です。YYYY-MM-DD形式で入力してください"})
 			return
 		}
-		paramCount++
-		query += fmt.Sprintf("birthdate = $%d, ", paramCount)
-		params = append(params, birthdate)
-	}
-
-	if req.Hobby != "" {
-		paramCount++
-		query += fmt.Sprintf("hobby = $%d, ", paramCount)
-		params = append(params, req.Hobby)
+		update.Birthdate = &birthdate
 	}
 
-	if req.Comment != "" {
-		paramCount++
-		query += fmt.Sprintf("comment = $%d, ", paramCount)
-		params = append(params, req.Comment)
-	}
-
-	if req.Title != "" {
-		paramCount++
-		query += fmt.Sprintf("title = $%d, ", paramCount)
-		params = append(params, req.Title)
-	}
-
-	if req.Description != "" {
-		paramCount++
-		query += fmt.Sprintf("description = $%d, ", paramCount)
-		params = append(params, req.Description)
-	}
-
-	var newIconPath string
-
 	// アイコン画像の処理（存在する場合）
 	if req.IconBase64 != "" {
 		// Base64をデコード
@@ -300,16 +338,9 @@ func (app *App) UpdateProfile(c *gin.Context) {
 			}
 		}
 
-		// 古いアイコンがあれば削除
-		if currentIconPath.Valid && currentIconPath.String != "" {
-			if err := os.Remove(currentIconPath.String); err != nil && !os.IsNotExist(err) {
-				fmt.Printf("Failed to delete old icon: %v\n", err)
-			}
-		}
-
 		// ユニークなファイル名を生成
 		filename := uuid.New().String() + ".png"
-		newIconPath = filepath.Join(uploadDir, filename)
+		newIconPath := filepath.Join(uploadDir, filename)
 
 		// ファイルに保存
 		if err := os.WriteFile(newIconPath, iconData, 0644); err != nil {
@@ -317,161 +348,102 @@ func (app *App) UpdateProfile(c *gin.Context) {
 			return
 		}
 
-		paramCount++
-		query += fmt.Sprintf("icon_path = $%d, ", paramCount)
-		params = append(params, newIconPath)
+		update.IconPath = &newIconPath
 	}
 
 	// 更新するフィールドがなければエラー
-	if paramCount == 0 {
+	if update.IsEmpty() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "更新する項目がありません"})
 		return
 	}
 
-	// 末尾のカンマとスペースを削除
-	query = query[:len(query)-2]
-
-	// WHERE句とタイムスタンプ更新を追加
-	query += fmt.Sprintf(" WHERE id = $%d RETURNING id", paramCount+1)
-	params = append(params, profileID)
-
 	// タイムアウト付きコンテキスト
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	// 更新実行
-	var updatedID int
-	err = app.DB.QueryRowContext(ctx, query, params...).Scan(&updatedID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "プロフィールの更新に失敗しました"})
+	profile, err := app.Profiles.Update(ctx, profileID, update)
+	if err != nil && update.IconPath != nil {
+		// 更新できなかった場合は保存した新しいアイコンを削除し、古いアイコンはそのまま残す
+		if err := os.Remove(*update.IconPath); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Failed to delete profile icon: %v\n", err)
+		}
+	}
+	if err == store.ErrConflict {
+		c.JSON(http.StatusConflict, gin.H{"error": "このURLはすでに使われています"})
 		return
 	}
-
-	// 更新されたプロフィール情報を取得
-	var profile models.Profile
-	err = app.DB.QueryRowContext(
-		ctx,
-		`SELECT id, user_id, display_name, aka, hometown, birthdate, 
-        hobby, comment, title, description 
-        FROM profiles WHERE id = $1`,
-		updatedID,
-	).Scan(
-		&profile.ID, &profile.UserID, &profile.DisplayName,
-		&profile.AKA, &profile.Hometown, &profile.Birthdate,
-		&profile.Hobby, &profile.Comment, &profile.Title, &profile.Description,
-	)
-
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新後のプロフィール取得に失敗しました"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "プロフィールの更新に失敗しました"})
 		return
 	}
 
-	// アイコンURLを設定
-	if newIconPath != "" || (currentIconPath.Valid && currentIconPath.String != "") {
-		profile.IconURL = fmt.Sprintf("http://localhost:8080/api/profiles/%d/icon", profile.ID)
+	// 新しいアイコンを保存できたので、古いアイコンを削除（Cloudinaryに保存したものも含む）
+	if update.IconPath != nil {
+		app.removeProfileIcon(ctx, current)
 	}
 
+	// アイコンURL・公開URLを設定
+	presentProfile(profile, ownerAccess)
+
 	c.JSON(http.StatusOK, profile)
 }
 
-// GetProfile はプロフィール情報を取得するハンドラーです
+// GetProfile はプロフィール情報を取得するハンドラーです（:idは公開IDまたはカスタムURL）
+// 公開範囲により閲覧できない場合は存在しないものとして404を返し、つながりのない閲覧者には制限された項目を返しません
 func (app *App) GetProfile(c *gin.Context) {
-	profileID, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "プロフィールIDが不正です"})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	profile, access := app.loadVisibleProfile(ctx, c, c.Param("id"))
+	if profile == nil {
 		return
 	}
 
-	var profile models.Profile
-	var iconPath sql.NullString
-	var aka sql.NullString
-	var hometown sql.NullString
-	var birthdate sql.NullTime
-	var hobby sql.NullString
-	var comment sql.NullString
-	var title sql.NullString
-	var description sql.NullString
-
-	err = app.DB.QueryRowContext(
-		context.Background(),
-		`SELECT id, user_id, display_name, icon_path, aka, hometown, 
-        birthdate, hobby, comment, title, description 
-        FROM profiles WHERE id = $1`,
-		profileID,
-	).Scan(
-		&profile.ID, &profile.UserID, &profile.DisplayName, &iconPath,
-		&aka, &hometown, &birthdate, &hobby, &comment, &title, &description,
-	)
-
-	if err == sql.ErrNoRows {
+	// アイコンURLの設定と、閲覧者に見せない項目の除去
+	presentProfile(profile, access)
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// loadVisibleProfile は公開IDまたはカスタムURLでプロフィールを取得し、閲覧者が閲覧できることを確認します
+// 存在しないか閲覧できない場合は404を返してnilを返します
+func (app *App) loadVisibleProfile(ctx context.Context, c *gin.Context, ref string) (*models.Profile, profileAccess) {
+	profile, err := app.profileByRef(ctx, viewerID(c), ref)
+	if err == store.ErrNotFound {
 		c.JSON(http.StatusNotFound, gin.H{"error": "プロフィールが見つかりません"})
-		return
+		return nil, profileAccess{}
 	}
-
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
-		return
+		return nil, profileAccess{}
 	}
 
-	// NULL値の処理
-	if aka.Valid {
-		profile.AKA = aka.String
-	}
-	if hometown.Valid {
-		profile.Hometown = hometown.String
-	}
-	if birthdate.Valid {
-		profile.Birthdate = birthdate.Time
-	}
-	if hobby.Valid {
-		profile.Hobby = hobby.String
-	}
-	if comment.Valid {
-		profile.Comment = comment.String
-	}
-	if title.Valid {
-		profile.Title = title.String
-	}
-	if description.Valid {
-		profile.Description = description.String
+	access, err := app.profileAccessFor(ctx, viewerID(c), profile)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
+		return nil, profileAccess{}
 	}
-
-	// アイコンURLの設定
-	if iconPath.Valid && iconPath.String != "" {
-		profile.IconURL = fmt.Sprintf("http://localhost:8080/api/profiles/%d/icon", profile.ID)
+	if !access.visible {
+		c.JSON(http.StatusNotFound, gin.H{"error": "プロフィールが見つかりません"})
+		return nil, profileAccess{}
 	}
-
-	c.JSON(http.StatusOK, profile)
+	return profile, access
 }
 
 // GetProfileIcon はプロフィールのアイコン画像を返すハンドラーです
 func (app *App) GetProfileIcon(c *gin.Context) {
-	profileID, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "プロフィールIDが不正です"})
-		return
-	}
-
-	// アイコンのパスを取得
-	var iconPath sql.NullString
-	err = app.DB.QueryRowContext(
-		context.Background(),
-		"SELECT icon_path FROM profiles WHERE id = $1",
-		profileID,
-	).Scan(&iconPath)
-
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "プロフィールが見つかりません"})
-		return
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
+	// アイコンのパスを取得（閲覧できないプロフィールは404）
+	profile, access := app.loadVisibleProfile(ctx, c, c.Param("id"))
+	if profile == nil {
 		return
 	}
 
-	// アイコンが設定されていない場合
-	if !iconPath.Valid || iconPath.String == "" {
+	// アイコンが設定されていない場合（つながりのある人だけに見せるアイコンも同様）
+	if profile.IconPath == "" || (profile.Restricts(models.ProfileFieldIcon) && !access.connected) {
 		// デフォルトアイコンを返す
 		defaultIconPath := "./assets/default-icon.png"
 		if _, err := os.Stat(defaultIconPath); os.IsNotExist(err) {
@@ -482,8 +454,14 @@ func (app *App) GetProfileIcon(c *gin.Context) {
 		return
 	}
 
+	// Cloudinaryに保存したアイコンはそのURLへリダイレクト
+	if utils.CloudinaryPublicID(profile.IconPath) != "" {
+		c.Redirect(http.StatusFound, profile.IconPath)
+		return
+	}
+
 	// カスタムアイコンの存在確認
-	if _, err := os.Stat(iconPath.String); os.IsNotExist(err) {
+	if _, err := os.Stat(profile.IconPath); os.IsNotExist(err) {
 		// ファイルが見つからない場合はデフォルトアイコンを返す
 		defaultIconPath := "./assets/default-icon.png"
 		if _, err := os.Stat(defaultIconPath); os.IsNotExist(err) {
@@ -495,86 +473,36 @@ func (app *App) GetProfileIcon(c *gin.Context) {
 	}
 
 	// ユーザーのカスタムアイコンを送信
-	c.File(iconPath.String)
+	c.File(profile.IconPath)
 }
 
 // GetProfilesByUserID はユーザーIDに基づいてプロフィール一覧を取得するハンドラーです
+// 本人の一覧のみ取得できます（他人のユーザーIDからプロフィールを列挙できないようにするため）
 func (app *App) GetProfilesByUserID(c *gin.Context) {
-	// デバッグ用：リクエストの詳細をログ出力
-	fmt.Printf("GetProfilesByUserID called - User ID from params: %s\n", c.Param("userId"))
-	fmt.Printf("Authorization header: %s\n", c.GetHeader("Authorization"))
-
 	// URLからユーザーIDを取得
 	userID, err := strconv.Atoi(c.Param("userId"))
 	if err != nil {
-		fmt.Printf("Error parsing user ID: %v\n", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "ユーザーIDが不正です"})
 		return
 	}
-
-	// ユーザーの存在確認
-	var exists bool
-	err = app.DB.QueryRowContext(
-		context.Background(),
-		"SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)",
-		userID,
-	).Scan(&exists)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
-		return
-	}
-
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "ユーザーが見つかりません"})
+	if !authorizeOwner(c, userID) {
 		return
 	}
 
 	// プロフィール一覧の取得
-	rows, err := app.DB.QueryContext(
-		context.Background(),
-		`SELECT id, user_id, display_name, icon_path, aka, hometown, 
-        birthdate, hobby, comment, title, description 
-        FROM profiles WHERE user_id = $1
-        ORDER BY id DESC`,
-		userID,
-	)
+	profiles, err := app.Profiles.ListByUser(context.Background(), userID)
 	if err != nil {
 		fmt.Printf("Error querying profiles: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
 		return
 	}
-	defer rows.Close()
-
-	profiles := []models.Profile{}
-	for rows.Next() {
-		var profile models.Profile
-		var iconPath sql.NullString
-		var birthdate sql.NullTime
-
-		err := rows.Scan(
-			&profile.ID, &profile.UserID, &profile.DisplayName, &iconPath,
-			&profile.AKA, &profile.Hometown, &birthdate,
-			&profile.Hobby, &profile.Comment, &profile.Title, &profile.Description,
-		)
-		if err != nil {
-			fmt.Printf("Error scanning profile row: %v\n", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "データベース結果のスキャンエラー"})
-			return
-		}
-
-		// Nullableフィールドの処理
-		if birthdate.Valid {
-			profile.Birthdate = birthdate.Time
-		}
-
-		// アイコンURLの設定
-		if iconPath.Valid && iconPath.String != "" {
-			profile.IconPath = iconPath.String
-			profile.IconURL = fmt.Sprintf("http://localhost:8080/api/profiles/%d/icon", profile.ID)
-		}
+	if profiles == nil {
+		profiles = []models.Profile{}
+	}
 
-		profiles = append(profiles, profile)
+	// アイコンURLの設定
+	for i := range profiles {
+		presentProfile(&profiles[i], ownerAccess)
 	}
 
 	// レスポンスを返す
@@ -594,123 +522,24 @@ func (app *App) DeleteProfile(c *gin.Context) {
 		return
 	}
 
-	// ミドルウェアでセットされたユーザーIDを取得
-	userIDAny, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証情報がありません"})
-		return
-	}
-	userID, ok := userIDAny.(int)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証情報が不正です"})
-		return
-	}
-
 	// プロフィールが本人のものか確認 & アイコンパス取得
-	var dbUserID int
-	var iconPath sql.NullString
-	err = app.DB.QueryRowContext(
-		context.Background(),
-		"SELECT user_id, icon_path FROM profiles WHERE id = $1",
-		profileID,
-	).Scan(&dbUserID, &iconPath)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "プロフィールが見つかりません"})
-		return
-	}
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "DBエラー"})
+	profile := app.loadOwnedProfile(c, profileID)
+	if profile == nil {
 		return
 	}
 
-	if dbUserID != userID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "自分のプロフィールのみ削除できます"})
-		return
-	}
-
-	// トランザクションを開始
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	tx, err := app.DB.BeginTx(ctx, nil)
-	if err != nil {
-		fmt.Printf("トランザクション開始エラー: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "トランザクションの開始に失敗しました"})
-		return
-	}
-	defer tx.Rollback() // エラー時に自動ロールバック
-
-	// 関連データを先に削除（外部キー制約のため）
-	// option_profilesを削除
-	_, err = tx.ExecContext(
-		ctx,
-		"DELETE FROM option_profiles WHERE profile_id = $1",
-		profileID,
-	)
-	if err != nil {
-		fmt.Printf("option_profiles削除エラー: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "関連データの削除に失敗しました（option_profiles）"})
-		return
-	}
-
-	// connectionsを削除
-	_, err = tx.ExecContext(
-		ctx,
-		"DELETE FROM connections WHERE profile_id = $1 OR connect_user_profile_id = $1",
-		profileID,
-	)
-	if err != nil {
-		fmt.Printf("connections削除エラー: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "関連データの削除に失敗しました（connections）"})
-		return
-	}
-
-	// リンクを削除
-	_, err = tx.ExecContext(
-		ctx,
-		"DELETE FROM link WHERE profile_id = $1",
-		profileID,
-	)
-	if err != nil {
-		fmt.Printf("link削除エラー: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "関連データの削除に失敗しました（link）"})
-		return
-	}
-
-	// プロファイル自体を削除
-	result, err := tx.ExecContext(
-		ctx,
-		"DELETE FROM profiles WHERE id = $1",
-		profileID,
-	)
-	if err != nil {
-		fmt.Printf("profiles削除エラー: %v\n", err)
+	// 関連データ（option_profiles・connections・link）ごと削除
+	if err := app.Profiles.Delete(ctx, profileID); err != nil {
+		fmt.Printf("プロフィール削除エラー: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "プロフィールの削除に失敗しました"})
 		return
 	}
 
-	// 影響を受けた行数を確認
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		fmt.Printf("RowsAffected取得エラー: %v\n", err)
-	} else if rowsAffected == 0 {
-		fmt.Printf("削除されたプロフィールなし: ID=%d\n", profileID)
-	}
-
-	// トランザクションをコミット
-	if err := tx.Commit(); err != nil {
-		fmt.Printf("トランザクションコミットエラー: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "変更の確定に失敗しました"})
-		return
-	}
-
-	// アイコン画像を削除
-	if iconPath.Valid && iconPath.String != "" {
-		if err := os.Remove(iconPath.String); err != nil && !os.IsNotExist(err) {
-			// ログのみ、エラー応答は返さない
-			fmt.Printf("Failed to delete profile icon: %v\n", err)
-		}
-	}
+	// アイコン画像を削除（失敗してもログのみ、エラー応答は返さない）
+	app.removeProfileIcon(ctx, profile)
 
 	// JSONレスポンスを返す
 	c.JSON(http.StatusOK, gin.H{
@@ -718,3 +547,24 @@ func (app *App) DeleteProfile(c *gin.Context) {
 		"profile_id": profileID,
 	})
 }
+
+// removeProfileIcon はプロフィールのアイコン画像（ローカルファイルまたはCloudinaryの画像）を削除します
+// 削除に失敗してもログを出力するだけで、エラーは返しません
+func (app *App) removeProfileIcon(ctx context.Context, profile *models.Profile) {
+	if profile.IconPath == "" {
+		return
+	}
+	if publicID := utils.CloudinaryPublicID(profile.IconPath); publicID != "" {
+		if app.CloudinaryClient == nil {
+			fmt.Printf("Cloudinary not configured, icon left in place: %s\n", publicID)
+			return
+		}
+		if err := app.CloudinaryClient.DeleteImage(ctx, publicID); err != nil {
+			fmt.Printf("Failed to delete profile icon: %v\n", err)
+		}
+		return
+	}
+	if err := os.Remove(profile.IconPath); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("Failed to delete profile icon: %v\n", err)
+	}
+}
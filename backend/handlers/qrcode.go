@@ -1,17 +1,50 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/gif"  // ロゴ画像の読み込み用
+	_ "image/jpeg" // ロゴ画像の読み込み用
+	_ "image/png"  // ロゴ画像の読み込み用
+	"io"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"backend/models"
+	"backend/qr"
+	"backend/store"
+	"backend/utils"
 
 	"github.com/gin-gonic/gin"
-	"github.com/skip2/go-qrcode"
 )
 
-// QRコード生成ハンドラー
-func GenerateQRCode(c *gin.Context) {
+// QRコードの生成オプションの範囲
+const (
+	minQRSize      = 64
+	maxQRSize      = 2048
+	maxQRQuietZone = 16
+	minLogoScale   = 0.1
+	maxLogoScale   = 0.3
+	// maxLogoSize はロゴ画像（デコード前）の上限サイズです
+	maxLogoSize = 2 * 1024 * 1024
+	// maxLogoPixels はロゴ画像の縦横それぞれの上限ピクセル数です
+	maxLogoPixels = 4096
+	// maxDecodeImageBytes はQRコードを読み取る画像の上限サイズです
+	maxDecodeImageBytes = 10 * 1024 * 1024
+	// maxDecodeImagePixels はQRコードを読み取る画像の上限画素数です（スマートフォンのカメラの1200万画素程度を想定）
+	// 展開後の画像とグレースケールの複製がリクエストごとにメモリに載るため、圧縮後のサイズとは別に制限します
+	maxDecodeImagePixels = 16_000_000
+)
+
+// GenerateQRCode はQRコード生成ハンドラーです
+// 形式（PNG・SVG、JSONのdata URIまたは画像そのもの）・誤り訂正レベル・サイズ・色・余白・中央のロゴを指定できます
+// 生成した画像は読み取れることを確認してから返します（ロゴが大きすぎて読み取れない場合は422）
+func (app *App) GenerateQRCode(c *gin.Context) {
 	var req models.URLRequest
 
 	// リクエストのバリデーション
@@ -21,9 +54,62 @@ func GenerateQRCode(c *gin.Context) {
 		})
 		return
 	}
+	format := strings.ToLower(req.Format)
+	if format == "" {
+		format = "png"
+	}
+	if format != "png" && format != "svg" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "formatはpngまたはsvgを指定してください"})
+		return
+	}
+	opts, err := qrOptions(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	switch {
+	case req.Logo != "" && req.LogoProfileID != "":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "logoとlogo_profile_idはどちらか一方を指定してください"})
+		return
+	case req.Logo != "":
+		data, err := decodeBase64Image(req.Logo)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ロゴ画像のBase64が不正です"})
+			return
+		}
+		if opts.Logo, err = decodeLogo(data); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	case req.LogoProfileID != "":
+		if opts.Logo = app.loadProfileLogo(ctx, c, req.LogoProfileID); opts.Logo == nil {
+			return
+		}
+	}
 
 	// QRコード生成
-	qr, err := qrcode.Encode(req.URL, qrcode.Medium, 256)
+	code, err := qr.Encode(req.URL, opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := code.Verify(); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "生成したQRコードを読み取れません。ロゴを小さくするか、サイズを大きくしてください"})
+		return
+	}
+
+	var data []byte
+	contentType := "image/png"
+	if format == "svg" {
+		data, err = code.SVG()
+		contentType = "image/svg+xml"
+	} else {
+		data, err = code.PNG()
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "QRコードの生成に失敗しました",
@@ -31,15 +117,249 @@ func GenerateQRCode(c *gin.Context) {
 		return
 	}
 
-	// Base64エンコード
-	base64Encoding := base64.StdEncoding.EncodeToString(qr)
-	dataURI := "data:image/png;base64," + base64Encoding
+	if req.Raw {
+		c.Data(http.StatusOK, contentType, data)
+		return
+	}
 
 	// レスポンス作成
 	response := models.QRCodeResponse{
-		QRData: dataURI,
-		URL:    req.URL,
+		QRData:          "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data),
+		URL:             req.URL,
+		Format:          format,
+		ErrorCorrection: code.Options.Level.String(),
 	}
 
 	c.JSON(http.StatusOK, response)
 }
+
+// qrOptions はリクエストの生成オプションを検証し、省略した項目には既定値を使います（ロゴ以外）
+func qrOptions(req models.URLRequest) (qr.Options, error) {
+	opts := qr.DefaultOptions()
+	var err error
+	if req.ErrorCorrection != "" {
+		if opts.Level, err = qr.ParseLevel(req.ErrorCorrection); err != nil {
+			return opts, err
+		}
+	}
+	if req.Size != 0 {
+		if req.Size < minQRSize || req.Size > maxQRSize {
+			return opts, fmt.Errorf("sizeは%d〜%dの範囲で指定してください", minQRSize, maxQRSize)
+		}
+		opts.Size = req.Size
+	}
+	if req.QuietZone != nil {
+		if *req.QuietZone < 0 || *req.QuietZone > maxQRQuietZone {
+			return opts, fmt.Errorf("quiet_zoneは0〜%dの範囲で指定してください", maxQRQuietZone)
+		}
+		opts.QuietZone = *req.QuietZone
+	}
+	if req.Foreground != "" {
+		if opts.Foreground, err = qr.ParseColor(req.Foreground); err != nil {
+			return opts, err
+		}
+	}
+	if req.Background != "" {
+		if opts.Background, err = qr.ParseColor(req.Background); err != nil {
+			return opts, err
+		}
+	}
+	if req.LogoScale != 0 {
+		if req.LogoScale < minLogoScale || req.LogoScale > maxLogoScale {
+			return opts, fmt.Errorf("logo_scaleは%g〜%gの範囲で指定してください", minLogoScale, maxLogoScale)
+		}
+		opts.LogoScale = req.LogoScale
+	}
+	return opts, nil
+}
+
+// decodeLogo はロゴ画像を読み込みます（PNG・JPEG・GIF）
+func decodeLogo(data []byte) (image.Image, error) {
+	if len(data) > maxLogoSize {
+		return nil, fmt.Errorf("ロゴ画像は%dMB以下にしてください", maxLogoSize/1024/1024)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("ロゴ画像はPNG・JPEG・GIFのいずれかを指定してください")
+	}
+	if config.Width > maxLogoPixels || config.Height > maxLogoPixels {
+		return nil, fmt.Errorf("ロゴ画像は縦横%dピクセル以下にしてください", maxLogoPixels)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("ロゴ画像を読み込めません")
+	}
+	return img, nil
+}
+
+// loadProfileLogo は閲覧できるプロフィールのアイコンをロゴとして読み込みます
+// プロフィールを閲覧できない場合は404、アイコンがない（つながりのある人だけに見せるアイコンを含む）場合は400を返してnilを返します
+func (app *App) loadProfileLogo(ctx context.Context, c *gin.Context, ref string) image.Image {
+	profile, access := app.loadVisibleProfile(ctx, c, ref)
+	if profile == nil {
+		return nil
+	}
+	if profile.IconPath == "" || (profile.Restricts(models.ProfileFieldIcon) && !access.connected) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "このプロフィールにはアイコンがありません"})
+		return nil
+	}
+	data, _, err := app.readProfileIcon(ctx, profile)
+	if err != nil {
+		fmt.Printf("Read profile icon error: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "アイコン画像の取得に失敗しました"})
+		return nil
+	}
+	if data == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "このプロフィールにはアイコンがありません"})
+		return nil
+	}
+	logo, err := decodeLogo(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil
+	}
+	return logo
+}
+
+// decodeBase64Image はBase64の画像を読み込みます（data:image/png;base64, などのプレフィックスがあれば取り除きます）
+func decodeBase64Image(s string) ([]byte, error) {
+	if strings.HasPrefix(s, "data:") {
+		if i := strings.Index(s, ","); i >= 0 {
+			s = s[i+1:]
+		}
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
+
+// DecodeQRCode はアップロードされた画像からQRコードを読み取るハンドラーです
+// multipart/form-data の file に画像（PNG・JPEG・GIF）を指定します。画像に写っているQRコードはすべて読み取ります
+// QRsonaのプロフィール・交換用のQRコードは、閲覧者が閲覧できる範囲で指しているプロフィールも返します
+func (app *App) DecodeQRCode(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxDecodeImageBytes+64*1024)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "画像ファイルを指定してください（10MBまで）"})
+		return
+	}
+	if fileHeader.Size > maxDecodeImageBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "画像が大きすぎます（10MBまで）"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "画像を読み込めませんでした"})
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "画像を読み込めませんでした"})
+		return
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "画像はPNG・JPEG・GIFのいずれかを指定してください"})
+		return
+	}
+	// 展開する前に画素数を確認する
+	if config.Width*config.Height > maxDecodeImagePixels {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("画像の解像度が大きすぎます（%d万画素まで）", maxDecodeImagePixels/10_000)})
+		return
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "画像を読み込めませんでした"})
+		return
+	}
+
+	contents, err := qr.DecodeAll(img)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "QRコードが見つかりませんでした。QRコード全体が写るように撮影してください"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	results := make([]models.DecodedQRCode, 0, len(contents))
+	for _, content := range contents {
+		result, err := app.resolveQRPayload(ctx, viewerID(c), content)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
+			return
+		}
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, models.DecodeQRCodeResponse{
+		Results: results,
+		Total:   len(results),
+	})
+}
+
+// resolveQRPayload はQRコードの内容の種類を判定し、QRsonaのプロフィール・交換用のURLであれば指しているプロフィールを解決します
+// 閲覧できないプロフィールは存在を明かさず、見つからないものとして扱います
+func (app *App) resolveQRPayload(ctx context.Context, viewer int, content string) (models.DecodedQRCode, error) {
+	result := models.DecodedQRCode{Content: content, Type: models.QRPayloadText}
+	u, err := url.Parse(content)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return result, nil
+	}
+	result.Type = models.QRPayloadURL
+	frontend, err := url.Parse(utils.FrontendURL())
+	if err != nil || !strings.EqualFold(u.Host, frontend.Host) {
+		return result, nil
+	}
+
+	path := strings.TrimSuffix(u.Path, "/")
+	switch {
+	case path == "/exchange" && u.Query().Get("token") != "":
+		result.Type = models.QRPayloadExchange
+		result.Token = u.Query().Get("token")
+		claims, err := app.Keys.ValidateExchangeToken(result.Token)
+		if err != nil {
+			result.Error = "QRコードが正しくないか、有効期限が切れています"
+			return result, nil
+		}
+		result.ExpiresAt = &claims.ExpiresAt.Time
+		result.SingleUse = claims.SingleUse
+		// 交換トークンのプロフィールの確認（/api/exchange-tokens/preview）と同じく、ログインしている場合だけ返す
+		if viewer == 0 {
+			result.Error = "ログインすると交換相手のプロフィールを確認できます"
+			return result, nil
+		}
+		profile, err := app.Profiles.GetByPublicRef(ctx, claims.ProfilePublicID)
+		if err == store.ErrNotFound || (err == nil && profile.PublicID != claims.ProfilePublicID) {
+			result.Error = "プロフィールが見つかりません"
+			return result, nil
+		}
+		if err != nil {
+			return result, err
+		}
+		access, err := app.profileAccessFor(ctx, viewer, profile)
+		if err != nil {
+			return result, err
+		}
+		presentProfile(profile, access)
+		result.Profile = profile
+
+	case strings.HasPrefix(path, "/profile/"):
+		ref := strings.TrimPrefix(path, "/profile/")
+		if ref == "" || strings.Contains(ref, "/") {
+			return result, nil
+		}
+		result.Type = models.QRPayloadProfile
+		profile, access, err := app.profileAccessByRef(ctx, viewer, ref)
+		if err != nil {
+			return result, err
+		}
+		if profile == nil || !access.visible {
+			result.Error = "プロフィールが見つかりません"
+			return result, nil
+		}
+		presentProfile(profile, access)
+		result.Profile = profile
+	}
+	return result, nil
+}
@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"backend/models"
+	"backend/store"
 	"backend/utils"
 	"context"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -19,6 +21,12 @@ func (app *App) SignUp(c *gin.Context) {
 		return
 	}
 
+	email, err := utils.NormalizeEmail(req.Email)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// パスワードハッシュ化
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -30,32 +38,33 @@ func (app *App) SignUp(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	var id int
-	err = app.DB.QueryRowContext(
-		ctx,
-		"INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id",
-		req.Name, req.Email, string(hashedPassword),
-	).Scan(&id)
-
+	user := models.User{
+		Name:         req.Name,
+		Email:        email,
+		PasswordHash: string(hashedPassword),
+	}
+	err = app.Users.Create(ctx, &user)
+	if err == store.ErrConflict {
+		c.JSON(http.StatusConflict, gin.H{"error": "このメールアドレスは既に登録されています"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "ユーザー登録に失敗しました"})
 		return
 	}
 
-	// JWTトークン生成
-	token, err := utils.GenerateJWT(id, req.Email)
+	// 確認メールの送信に失敗しても登録自体は成功とする（再送できるため）
+	if err := app.sendVerificationEmail(ctx, &user); err != nil {
+		fmt.Printf("Failed to send verification mail: %v\n", err)
+	}
+
+	// アクセストークン・リフレッシュトークン生成
+	res, err := app.issueTokens(ctx, c, &user, "")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "トークンの生成に失敗しました"})
 		return
 	}
 
 	// 登録成功レスポンス
-	c.JSON(http.StatusOK, gin.H{
-		"user": models.User{
-			ID:    id,
-			Name:  req.Name,
-			Email: req.Email,
-		},
-		"token": token,
-	})
+	c.JSON(http.StatusOK, res)
 }
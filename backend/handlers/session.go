@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"backend/models"
+	"backend/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxUserAgentLength はセッションに記録するUser-Agentの最大文字数です
+const maxUserAgentLength = 512
+
+// ListSessions は自分の有効なセッション一覧を返すハンドラーです
+// リクエストに使ったトークンのセッションにはcurrentを付けます
+func (app *App) ListSessions(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sessions, err := app.Sessions.ListActive(ctx, userID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	current := c.GetString("session_id")
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+	c.JSON(http.StatusOK, models.SessionListResponse{Sessions: sessions})
+}
+
+// RevokeSession は指定したセッションを失効させるハンドラーです
+// 失効させたセッションのアクセストークン・リフレッシュトークンはすぐに使えなくなります
+func (app *App) RevokeSession(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 他人のセッションも存在しないものとして扱う
+	if err := app.revokeSession(ctx, userID, c.Param("id")); err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "セッションが見つかりません"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "セッションを失効させました"})
+}
+
+// RevokeOtherSessions は現在のセッション以外をすべて失効させるハンドラーです
+func (app *App) RevokeOtherSessions(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	current := c.GetString("session_id")
+	if current == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "現在のセッションを特定できません。再度サインインしてください"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	revoked, err := app.Sessions.RevokeAllForUser(ctx, userID, current)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	for _, id := range revoked {
+		if err := app.RefreshTokens.RevokeFamily(ctx, id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, models.RevokeSessionsResponse{Revoked: len(revoked)})
+}
+
+// revokeSession はユーザーのセッションとそのリフレッシュトークンを失効させます
+func (app *App) revokeSession(ctx context.Context, userID int, sessionID string) error {
+	if err := app.Sessions.Revoke(ctx, userID, sessionID); err != nil {
+		return err
+	}
+	return app.RefreshTokens.RevokeFamily(ctx, sessionID)
+}
+
+// truncate は文字列を最大max文字（ルーン単位）に切り詰めます
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
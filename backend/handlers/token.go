@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/models"
+	"backend/store"
+	"backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// issueTokens はアクセストークンとリフレッシュトークンを発行します
+// sessionIDが空の場合は新しいセッション（サインイン）を作成し、端末の情報を記録します
+// セッションIDはリフレッシュトークンの系列（FamilyID）としても使います
+func (app *App) issueTokens(ctx context.Context, c *gin.Context, user *models.User, sessionID string) (*models.AuthResponse, error) {
+	now := time.Now()
+	expiresAt := now.Add(utils.RefreshTokenTTL())
+	if sessionID == "" {
+		session := models.Session{
+			ID:        uuid.New().String(),
+			UserID:    user.ID,
+			UserAgent: truncate(c.Request.UserAgent(), maxUserAgentLength),
+			IPAddress: c.ClientIP(),
+			ExpiresAt: expiresAt,
+		}
+		if err := app.Sessions.Create(ctx, &session); err != nil {
+			return nil, fmt.Errorf("セッションの保存に失敗しました: %v", err)
+		}
+		sessionID = session.ID
+	} else if err := app.Sessions.Extend(ctx, sessionID, now, expiresAt); err != nil {
+		return nil, fmt.Errorf("セッションの更新に失敗しました: %v", err)
+	}
+
+	accessToken, err := app.Keys.GenerateJWT(user.ID, user.Email, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	rawRefresh, refreshHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	refresh := models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  sessionID,
+		TokenHash: refreshHash,
+		ExpiresAt: expiresAt,
+	}
+	if err := app.RefreshTokens.Create(ctx, &refresh); err != nil {
+		return nil, fmt.Errorf("リフレッシュトークンの保存に失敗しました: %v", err)
+	}
+
+	return &models.AuthResponse{
+		User:         *user,
+		Token:        accessToken,
+		RefreshToken: rawRefresh,
+		ExpiresIn:    int(utils.AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+// RefreshToken はリフレッシュトークンをローテーションして新しいトークンを発行するハンドラーです
+// 使用済みのトークンが再利用された場合は盗用とみなし、同じ系列のトークンをすべて失効させます
+func (app *App) RefreshToken(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストが不正です"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	token, err := app.RefreshTokens.GetByHash(ctx, utils.HashToken(req.RefreshToken))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "リフレッシュトークンが無効です"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+
+	if token.RevokedAt != nil || time.Now().After(token.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "リフレッシュトークンが無効です"})
+		return
+	}
+
+	// 使用済みトークンの再利用（または同時使用）を検出したらセッションごと失効
+	// （系列のIDはセッションIDなので、既に発行したアクセストークンも使えなくなります）
+	marked, err := app.RefreshTokens.MarkUsed(ctx, token.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	if token.UsedAt != nil || !marked {
+		err := app.revokeSession(ctx, token.UserID, token.FamilyID)
+		if err == store.ErrNotFound {
+			// セッションが既に失効している場合もリフレッシュトークンの系列は失効させる
+			err = app.RefreshTokens.RevokeFamily(ctx, token.FamilyID)
+		}
+		if err != nil {
+			fmt.Printf("Failed to revoke session on refresh token reuse: %v\n", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "リフレッシュトークンの再利用を検出しました。再度サインインしてください"})
+		return
+	}
+
+	user, err := app.Users.GetByID(ctx, token.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "リフレッシュトークンが無効です"})
+		return
+	}
+	if user.Suspended() {
+		respondSuspended(c)
+		return
+	}
+
+	res, err := app.issueTokens(ctx, c, user, token.FamilyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "トークンの生成に失敗しました"})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// SignOut は現在のアクセストークンとそのセッションを失効させるハンドラーです
+// リフレッシュトークンが指定された場合はその系列もまとめて失効させます
+func (app *App) SignOut(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.SignOutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストが不正です"})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := app.revokeCurrentAccessToken(ctx, c); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サインアウトに失敗しました"})
+		return
+	}
+	if sessionID := c.GetString("session_id"); sessionID != "" {
+		if err := app.revokeSession(ctx, userID, sessionID); err != nil && err != store.ErrNotFound {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "サインアウトに失敗しました"})
+			return
+		}
+	}
+
+	if req.RefreshToken != "" {
+		token, err := app.RefreshTokens.GetByHash(ctx, utils.HashToken(req.RefreshToken))
+		if err != nil && err != store.ErrNotFound {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "サインアウトに失敗しました"})
+			return
+		}
+		// 他人のリフレッシュトークンは失効させない
+		if err == nil && token.UserID == userID {
+			if err := app.RefreshTokens.RevokeFamily(ctx, token.FamilyID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "サインアウトに失敗しました"})
+				return
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "サインアウトしました"})
+}
+
+// revokeCurrentAccessToken はAuthRequiredミドルウェアがセットしたjtiを失効リストに追加します
+func (app *App) revokeCurrentAccessToken(ctx context.Context, c *gin.Context) error {
+	jti := c.GetString("token_id")
+	if jti == "" {
+		return nil
+	}
+	expiresAt, ok := c.Get("token_expires_at")
+	if !ok {
+		expiresAt = time.Now().Add(utils.AccessTokenTTL())
+	}
+	return app.RevokedTokens.Revoke(ctx, jti, expiresAt.(time.Time))
+}
+
+// JWKS は他のサービスがアクセストークンを検証するための公開鍵セットを返すハンドラーです
+func (app *App) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, app.Keys.JWKS())
+}
+
+// revokeUserSessions はユーザーのセッション・リフレッシュトークンと発行済みのアクセストークンをすべて失効させます
+func (app *App) revokeUserSessions(ctx context.Context, userID int) error {
+	if _, err := app.Sessions.RevokeAllForUser(ctx, userID, ""); err != nil {
+		return err
+	}
+	if err := app.RefreshTokens.RevokeAllForUser(ctx, userID); err != nil {
+		return err
+	}
+	// iatはミリ秒精度なので、同じミリ秒内に発行される新しいトークンは失効させない
+	return app.RevokedTokens.RevokeUser(ctx, userID, time.Now().Truncate(time.Millisecond))
+}
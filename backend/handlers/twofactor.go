@@ -0,0 +1,383 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/models"
+	"backend/store"
+	"backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// totpIssuer は認証アプリに表示されるサービス名です
+	totpIssuer = "QRsona"
+	// recoveryCodeCount は一度に発行するリカバリーコードの数です
+	recoveryCodeCount = 10
+)
+
+// twoFactorAttemptLimit はチャレンジトークン1つあたりの認証コードの試行回数です（期限内に5回まで）
+var twoFactorAttemptLimit = store.RateLimit{Burst: 5, Interval: utils.TwoFactorChallengeTTL}
+
+// GetTwoFactorStatus は二要素認証の設定状況を返すハンドラーです
+func (app *App) GetTwoFactorStatus(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	enrollment, err := app.TwoFactor.Get(ctx, userID)
+	if err != nil && err != store.ErrNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	res := models.TwoFactorStatusResponse{}
+	if enrollment.Enabled() {
+		res.Enabled = true
+		res.ConfirmedAt = enrollment.ConfirmedAt
+		if res.RecoveryCodesRemaining, err = app.TwoFactor.CountRecoveryCodes(ctx, userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// EnrollTOTP はTOTPの登録を開始し、認証アプリに登録するシークレットとQRコードを返すハンドラーです
+// 確認コードで検証されるまで二要素認証は有効になりません
+func (app *App) EnrollTOTP(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := app.Users.GetByID(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "ユーザーが存在しません"})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	if err := app.TwoFactor.SavePending(ctx, userID, secret); err == store.ErrConflict {
+		c.JSON(http.StatusConflict, gin.H{"error": "二要素認証はすでに有効です"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+
+	uri := utils.TOTPURI(totpIssuer, user.Email, secret)
+	qr, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "QRコードの生成に失敗しました"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TOTPEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: uri,
+		QRData:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(qr),
+	})
+}
+
+// ConfirmTOTP は認証アプリのコードを検証して二要素認証を有効にし、リカバリーコードを返すハンドラーです
+func (app *App) ConfirmTOTP(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストが不正です"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	enrollment, err := app.TwoFactor.Get(ctx, userID)
+	if err != nil && err != store.ErrNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "二要素認証の登録が開始されていません"})
+		return
+	}
+	if enrollment.Enabled() {
+		c.JSON(http.StatusConflict, gin.H{"error": "二要素認証はすでに有効です"})
+		return
+	}
+
+	step, ok := utils.VerifyTOTP(enrollment.Secret, req.Code, time.Now(), 0)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "認証コードが正しくありません"})
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	if err := app.TwoFactor.Confirm(ctx, userID, step, hashes); err == store.ErrNotFound {
+		c.JSON(http.StatusConflict, gin.H{"error": "二要素認証はすでに有効です"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTOTP はパスワードと認証コード（またはリカバリーコード）を確認して二要素認証を解除するハンドラーです
+func (app *App) DisableTOTP(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.DisableTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストが不正です"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := app.Users.GetByID(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "ユーザーが存在しません"})
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)) != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "パスワードが正しくありません"})
+		return
+	}
+
+	enrollment := app.loadEnabledTOTP(c, ctx, userID)
+	if enrollment == nil {
+		return
+	}
+	if !app.verifySecondFactor(c, ctx, enrollment, req.Code) {
+		return
+	}
+
+	if err := app.TwoFactor.Delete(ctx, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "二要素認証を解除しました"})
+}
+
+// RegenerateRecoveryCodes は認証コードを確認してリカバリーコードを再発行するハンドラーです（古いコードは使えなくなります）
+func (app *App) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストが不正です"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	enrollment := app.loadEnabledTOTP(c, ctx, userID)
+	if enrollment == nil {
+		return
+	}
+	if !app.verifySecondFactor(c, ctx, enrollment, req.Code) {
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	if err := app.TwoFactor.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// SignInTwoFactor はサインインの二段階目として、チャレンジトークンと認証コードを検証してトークンを発行するハンドラーです
+func (app *App) SignInTwoFactor(c *gin.Context) {
+	var req models.TwoFactorSignInRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストが不正です"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	invalidChallenge := gin.H{"error": "チャレンジトークンが無効です。もう一度サインインしてください"}
+	claims, err := app.Keys.ValidateChallengeToken(req.ChallengeToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, invalidChallenge)
+		return
+	}
+	revoked, err := app.RevokedTokens.IsRevoked(ctx, claims.ID, claims.UserID, claims.IssuedAt.Time)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	if revoked {
+		c.JSON(http.StatusUnauthorized, invalidChallenge)
+		return
+	}
+
+	// 一段階目の後にロックされたアカウントは拒否する
+	attempt, err := app.LoginAttempts.Get(ctx, claims.Email)
+	if err != nil && err != store.ErrNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	if err == nil && attempt.LockedUntil != nil && attempt.LockedUntil.After(time.Now()) {
+		respondLocked(c, *attempt.LockedUntil)
+		return
+	}
+
+	// 1つのチャレンジで総当たりされないよう試行回数を制限する
+	limited, err := app.RateLimits.Take(ctx, "2fa:"+claims.ID, twoFactorAttemptLimit)
+	if err != nil {
+		fmt.Printf("Rate limit check failed: %v\n", err)
+	} else if !limited.Allowed {
+		c.JSON(http.StatusUnauthorized, invalidChallenge)
+		return
+	}
+
+	user, err := app.Users.GetByID(ctx, claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, invalidChallenge)
+		return
+	}
+	if user.Suspended() {
+		respondSuspended(c)
+		return
+	}
+	enrollment := app.loadEnabledTOTP(c, ctx, user.ID)
+	if enrollment == nil {
+		return
+	}
+
+	ok, err := app.checkSecondFactor(ctx, enrollment, req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	if !ok {
+		if lockedUntil := app.recordSignInFailure(ctx, claims.Email); !lockedUntil.IsZero() {
+			respondLocked(c, lockedUntil)
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証コードが正しくありません"})
+		return
+	}
+
+	// チャレンジトークンは一度だけ使える
+	if err := app.RevokedTokens.Revoke(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	if err := app.LoginAttempts.Reset(ctx, claims.Email); err != nil {
+		fmt.Printf("Failed to reset login attempts: %v\n", err)
+	}
+
+	res, err := app.issueTokens(ctx, c, user, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "トークンの生成に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// respondTwoFactorChallenge は二要素認証が有効なユーザーに、トークンの代わりにチャレンジトークンを返します
+func (app *App) respondTwoFactorChallenge(c *gin.Context, user *models.User) {
+	token, err := app.Keys.GenerateChallengeToken(user.ID, user.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "トークンの生成に失敗しました"})
+		return
+	}
+	c.JSON(http.StatusOK, models.TwoFactorChallengeResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresIn:         int(utils.TwoFactorChallengeTTL.Seconds()),
+	})
+}
+
+// loadEnabledTOTP は有効なTOTP設定を取得します（有効でない場合はレスポンスを書き込んでnilを返します）
+func (app *App) loadEnabledTOTP(c *gin.Context, ctx context.Context, userID int) *models.TOTPEnrollment {
+	enrollment, err := app.TwoFactor.Get(ctx, userID)
+	if err != nil && err != store.ErrNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return nil
+	}
+	if !enrollment.Enabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "二要素認証は有効になっていません"})
+		return nil
+	}
+	return enrollment
+}
+
+// verifySecondFactor は認証コードを検証します（失敗した場合はレスポンスを書き込んでfalseを返します）
+func (app *App) verifySecondFactor(c *gin.Context, ctx context.Context, enrollment *models.TOTPEnrollment, code string) bool {
+	ok, err := app.checkSecondFactor(ctx, enrollment, code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return false
+	}
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証コードが正しくありません"})
+		return false
+	}
+	return true
+}
+
+// checkSecondFactor はTOTPコードまたはリカバリーコードを検証し、使ったコードを再利用できないよう記録します
+func (app *App) checkSecondFactor(ctx context.Context, enrollment *models.TOTPEnrollment, code string) (bool, error) {
+	if step, ok := utils.VerifyTOTP(enrollment.Secret, code, time.Now(), enrollment.LastUsedStep); ok {
+		// 同時に同じコードが送られた場合に備えて、ストア側でも未使用のステップか確認する
+		return app.TwoFactor.UseStep(ctx, enrollment.UserID, step)
+	}
+	return app.TwoFactor.UseRecoveryCode(ctx, enrollment.UserID, utils.HashToken(utils.NormalizeRecoveryCode(code)))
+}
+
+// newRecoveryCodes はリカバリーコードを生成し、平文と保存用のハッシュを返します
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashToken(code)
+	}
+	return codes, hashes, nil
+}
@@ -1,38 +1,40 @@
 package handlers
 
 import (
-	"backend/models"
 	"context"
 	"net/http"
 	"time"
 
+	"backend/middleware"
+	"backend/models"
+
 	"github.com/gin-gonic/gin"
 )
 
 // GetUsers はユーザー一覧を取得するハンドラーです
+// モデレーター以上は全ユーザー、一般ユーザーは自分の情報だけを返します（他人のメールアドレスは公開しない）
 func (app *App) GetUsers(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	rows, err := app.DB.QueryContext(ctx, "SELECT id, name, email FROM users ORDER BY id")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "ユーザー一覧の取得に失敗しました"})
+	userID, ok := currentUserID(c)
+	if !ok {
 		return
 	}
-	defer rows.Close()
 
-	var users []models.User
-	for rows.Next() {
-		var user models.User
-		if err := rows.Scan(&user.ID, &user.Name, &user.Email); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "データベーススキャンエラー"})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if !middleware.CurrentRole(c).AtLeast(models.RoleModerator) {
+		user, err := app.Users.GetByID(ctx, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "ユーザー一覧の取得に失敗しました"})
 			return
 		}
-		users = append(users, user)
+		c.JSON(http.StatusOK, gin.H{"users": []models.User{*user}})
+		return
 	}
 
-	if err := rows.Err(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "データベースエラー"})
+	users, err := app.Users.List(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ユーザー一覧の取得に失敗しました"})
 		return
 	}
 
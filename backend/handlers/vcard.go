@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/models"
+	"backend/store"
+	"backend/utils"
+	"backend/vcard"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxVCardPhotoBytes はvCardに埋め込むアイコン画像の最大サイズです（超える場合はPHOTOを省きます）
+const maxVCardPhotoBytes = 1 << 20
+
+// vcardContentType はvCardのレスポンスのContent-Typeです（RFC 6350）
+const vcardContentType = "text/vcard; charset=utf-8"
+
+// impSchemes はIMPP（インスタントメッセージ）として書き出すリンクのURIスキームです
+var impSchemes = []string{"xmpp:", "sip:", "sips:", "skype:", "im:", "irc:", "ircs:", "aim:", "ymsgr:", "msnim:"}
+
+// GetProfileVCard はプロフィールをvCard 4.0形式で返すハンドラーです（:idは公開IDまたはカスタムURL）
+// 公開範囲と、つながりのある人だけに見せる項目・任意項目・リンクの制限はGetProfileと同じです
+func (app *App) GetProfileVCard(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	profile, access := app.loadVisibleProfile(ctx, c, c.Param("id"))
+	if profile == nil {
+		return
+	}
+
+	card, err := app.profileVCard(ctx, profile, access)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "vCardの作成に失敗しました"})
+		return
+	}
+	writeVCards(c, profile.PublicRef()+".vcf", card)
+}
+
+// ExportConnectionsVCard は交換済みのプロフィールをまとめて1つの.vcfファイルで返すハンドラーです
+// 閲覧できなくなったプロフィールと、つながりのない相手の限定公開のプロフィールは含めず、
+// つながりのない相手の制限された項目は書き出しません
+func (app *App) ExportConnectionsVCard(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ユーザーIDが不正です"})
+		return
+	}
+	if !authorizeOwner(c, userID) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	connections, err := app.Connections.ListByUser(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データの取得に失敗しました"})
+		return
+	}
+
+	// 複数の自分のプロフィールから同じ相手と交換している場合は1件にまとめる
+	seen := map[int]bool{}
+	cards := []*vcard.Card{}
+	for _, conn := range connections {
+		if seen[conn.ConnectedProfileID] {
+			continue
+		}
+		seen[conn.ConnectedProfileID] = true
+
+		profile, err := app.Profiles.Get(ctx, conn.ConnectedProfileID)
+		if err == store.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "データの取得に失敗しました"})
+			return
+		}
+		access, err := app.profileAccessFor(ctx, userID, profile)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "データの取得に失敗しました"})
+			return
+		}
+		if !access.listed {
+			continue
+		}
+		card, err := app.profileVCard(ctx, profile, access)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "vCardの作成に失敗しました"})
+			return
+		}
+		cards = append(cards, card)
+	}
+	writeVCards(c, "qrsona-connections.vcf", cards...)
+}
+
+// profileVCard はプロフィールと閲覧者に見せてよい任意項目・リンクからvCardを作成します
+func (app *App) profileVCard(ctx context.Context, profile *models.Profile, access profileAccess) (*vcard.Card, error) {
+	options, err := app.OptionProfiles.ListByProfile(ctx, profile.ID)
+	if err != nil {
+		return nil, err
+	}
+	links, err := app.Links.ListByProfile(ctx, profile.ID)
+	if err != nil {
+		return nil, err
+	}
+	p := *profile
+	if !access.connected {
+		p.RedactRestricted()
+		options = models.PublicOptionProfiles(options)
+		links = models.PublicLinks(links)
+	}
+
+	card := &vcard.Card{}
+	card.AddText("PRODID", "-//QRsona//vCard Export//JA")
+	card.AddURI("UID", utils.FrontendURL()+"/profile/"+p.PublicID)
+	card.AddText("FN", p.DisplayName)
+	card.AddText("TITLE", p.AKA)
+	card.AddText("BIRTHPLACE", p.Hometown) // RFC 6474
+	if !p.Birthdate.IsZero() {
+		card.AddText("BDAY", p.Birthdate.Format("20060102"))
+	}
+	card.AddText("HOBBY", p.Hobby) // RFC 6715
+	card.AddText("NOTE", p.Comment)
+	if photo := vcardPhoto(p.IconPath); photo != "" {
+		card.AddURI("PHOTO", photo)
+	}
+	card.AddURI("URL", profileURL(&p))
+	for _, link := range links {
+		card.Properties = append(card.Properties, linkProperty(link))
+	}
+	for _, opt := range options {
+		card.AddText("X-QRSONA-OPTION", opt.Content, vcard.Param{Name: "X-LABEL", Value: opt.Title})
+	}
+	return card, nil
+}
+
+// linkProperty はリンクをCommonLinkTypesに基づいてEMAIL・IMPP・URLのいずれかに変換します
+func linkProperty(link models.Link) vcard.Property {
+	lower := strings.ToLower(link.URL)
+	for _, scheme := range impSchemes {
+		if strings.HasPrefix(lower, scheme) {
+			return vcard.Property{Name: "IMPP", Values: []string{link.URL}, URI: true}
+		}
+	}
+	if strings.HasPrefix(lower, "tel:") {
+		return vcard.Property{Name: "TEL", Params: []vcard.Param{{Name: "VALUE", Value: "uri"}}, Values: []string{link.URL}, URI: true}
+	}
+
+	linkType := commonLinkType(link)
+	if linkType != nil && linkType.BaseURL == "mailto:" {
+		address := link.URL
+		if strings.HasPrefix(lower, "mailto:") {
+			address = link.URL[len("mailto:"):]
+		}
+		return vcard.Property{Name: "EMAIL", Values: []string{address}}
+	}
+	prop := vcard.Property{Name: "URL", Values: []string{link.URL}, URI: true}
+	if linkType != nil {
+		prop.Params = []vcard.Param{{Name: "X-SERVICE-TYPE", Value: linkType.Name}}
+	}
+	return prop
+}
+
+// commonLinkType はリンクのURL（またはタイトル）に一致するCommonLinkTypesの種類を返します
+func commonLinkType(link models.Link) *models.LinkType {
+	lower := strings.ToLower(link.URL)
+	for i, linkType := range models.CommonLinkTypes {
+		if strings.HasPrefix(lower, strings.ToLower(linkType.BaseURL)) || strings.EqualFold(link.Title, linkType.Name) {
+			return &models.CommonLinkTypes[i]
+		}
+	}
+	return nil
+}
+
+// vcardPhoto はアイコンをPHOTOの値に変換します
+// Cloudinaryに保存した画像はURLのまま、ローカルに保存した画像はdata: URIとして埋め込みます
+func vcardPhoto(iconPath string) string {
+	if iconPath == "" {
+		return ""
+	}
+	if strings.HasPrefix(iconPath, "http://") || strings.HasPrefix(iconPath, "https://") {
+		return iconPath
+	}
+	info, err := os.Stat(iconPath)
+	if err != nil || info.Size() > maxVCardPhotoBytes {
+		return ""
+	}
+	data, err := os.ReadFile(iconPath)
+	if err != nil {
+		return ""
+	}
+	return "data:" + http.DetectContentType(data) + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// writeVCards はvCardをファイルとしてダウンロードさせるレスポンスを返します
+func writeVCards(c *gin.Context, filename string, cards ...*vcard.Card) {
+	var buf bytes.Buffer
+	if err := vcard.Encode(&buf, cards...); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "vCardの作成に失敗しました"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, vcardContentType, buf.Bytes())
+}
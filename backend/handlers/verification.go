@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"backend/mail"
+	"backend/models"
+	"backend/store"
+	"backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 未確認アカウントに対して制限できる操作
+const (
+	ActionPublishProfile   = "publish_profile"   // プロフィールの作成・更新
+	ActionCreateConnection = "create_connection" // コネクションの作成
+	ActionCreateLink       = "create_link"       // リンクの作成
+)
+
+// EmailUnverifiedMessage はメールアドレス未確認のため操作を拒否した場合のエラーメッセージです
+const EmailUnverifiedMessage = "この操作にはメールアドレスの確認が必要です"
+
+// UnverifiedPolicy はメールアドレス未確認のアカウントに禁止する操作の集合です
+type UnverifiedPolicy map[string]bool
+
+// LoadUnverifiedPolicy は環境変数 UNVERIFIED_ACCOUNT_RESTRICTIONS（カンマ区切り）から制限する操作を読み込みます
+// 未設定の場合はプロフィールの公開とコネクションの作成を制限し、"none" の場合は何も制限しません
+func LoadUnverifiedPolicy() (UnverifiedPolicy, error) {
+	value, ok := os.LookupEnv("UNVERIFIED_ACCOUNT_RESTRICTIONS")
+	if !ok || value == "" {
+		return UnverifiedPolicy{ActionPublishProfile: true, ActionCreateConnection: true}, nil
+	}
+
+	policy := UnverifiedPolicy{}
+	if value == "none" {
+		return policy, nil
+	}
+	for _, action := range strings.Split(value, ",") {
+		action = strings.TrimSpace(action)
+		switch action {
+		case ActionPublishProfile, ActionCreateConnection, ActionCreateLink:
+			policy[action] = true
+		default:
+			return nil, fmt.Errorf("UNVERIFIED_ACCOUNT_RESTRICTIONS に不明な操作があります: %s", action)
+		}
+	}
+	return policy, nil
+}
+
+// EmailVerificationTTL は確認メールのトークンの有効期間を返します（EMAIL_VERIFICATION_EXPIRES_HOURS、デフォルト24時間）
+func EmailVerificationTTL() time.Duration {
+	return time.Duration(envInt("EMAIL_VERIFICATION_EXPIRES_HOURS", 24)) * time.Hour
+}
+
+// RequireVerifiedEmail はポリシーで制限された操作について、メールアドレス確認済みのユーザーだけを通すミドルウェアです
+// AuthRequiredの後に使います
+func (app *App) RequireVerifiedEmail(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !app.UnverifiedPolicy[action] {
+			c.Next()
+			return
+		}
+
+		userID, ok := currentUserID(c)
+		if !ok {
+			c.Abort()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		user, err := app.Users.GetByID(ctx, userID)
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "ユーザーが存在しません"})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+			c.Abort()
+			return
+		}
+		if user.EmailVerifiedAt == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": EmailUnverifiedMessage})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// sendVerificationEmail は確認用トークンを発行してメールを送信します（未使用の古いトークンは無効にします）
+func (app *App) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	if err := app.OneTimeTokens.InvalidateForUser(ctx, user.ID, models.TokenPurposeEmailVerification); err != nil {
+		return err
+	}
+
+	rawToken, tokenHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	ttl := EmailVerificationTTL()
+	token := models.OneTimeToken{
+		UserID:    user.ID,
+		Purpose:   models.TokenPurposeEmailVerification,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := app.OneTimeTokens.Create(ctx, &token); err != nil {
+		return err
+	}
+
+	verifyURL := fmt.Sprintf("%s/verify-email?token=%s", utils.FrontendURL(), url.QueryEscape(rawToken))
+	return app.Mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "【QRsona】メールアドレスの確認",
+		Body: fmt.Sprintf("%s さん\n\nQRsonaへのご登録ありがとうございます。\n"+
+			"以下のリンクからメールアドレスを確認してください（有効期限: %d時間）。\n%s\n\n"+
+			"このメールに心当たりがない場合は破棄してください。\n",
+			user.Name, int(ttl.Hours()), verifyURL),
+	})
+}
+
+// VerifyEmail は確認メールのトークンを検証してメールアドレスを確認済みにするハンドラーです
+func (app *App) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストが不正です"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	invalid := gin.H{"error": "確認用のリンクが無効か、有効期限が切れています"}
+
+	token, err := app.OneTimeTokens.GetByHash(ctx, models.TokenPurposeEmailVerification, utils.HashToken(req.Token))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusBadRequest, invalid)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+
+	consumed, err := app.OneTimeTokens.Consume(ctx, token.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	if !consumed {
+		c.JSON(http.StatusBadRequest, invalid)
+		return
+	}
+
+	if err := app.Users.MarkEmailVerified(ctx, token.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "メールアドレスの確認に失敗しました"})
+		return
+	}
+	user, err := app.Users.GetByID(ctx, token.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "メールアドレスを確認しました", "user": user})
+}
+
+// ResendVerificationEmail はログイン中のユーザーに確認メールを再送するハンドラーです
+func (app *App) ResendVerificationEmail(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := app.Users.GetByID(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "サーバー内部エラーが発生しました"})
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "メールアドレスは既に確認済みです"})
+		return
+	}
+
+	if err := app.sendVerificationEmail(ctx, user); err != nil {
+		fmt.Printf("Failed to send verification mail: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "メールの送信に失敗しました"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "確認メールを送信しました"})
+}
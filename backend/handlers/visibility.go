@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"backend/models"
+	"backend/store"
+	"backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// profileAccess は閲覧者がプロフィールをどこまで見られるかを表します
+type profileAccess struct {
+	visible   bool // プロフィールを閲覧できる
+	connected bool // つながりのある人だけに見せる項目も閲覧できる（所有者を含む）
+	listed    bool // 一覧・一括出力に含めてよい（限定公開のプロフィールは所有者とつながりのある人だけ）
+	owner     bool
+}
+
+// viewerID はAuthOptional・AuthRequiredでセットされた閲覧者のユーザーIDを返します（未ログインの場合は0）
+func viewerID(c *gin.Context) int {
+	id, _ := c.Get("user_id")
+	userID, _ := id.(int)
+	return userID
+}
+
+// profileAccessFor は閲覧者（未ログインの場合は0）のプロフィールへのアクセス範囲を判定します
+// つながりがあるのは、プロフィールの所有者がそのプロフィールから閲覧者のプロフィールへのコネクションを作成済みの場合です
+// （閲覧者が一方的に作成したコネクションでは制限された項目は見られません）
+func (app *App) profileAccessFor(ctx context.Context, viewer int, profile *models.Profile) (profileAccess, error) {
+	if viewer != 0 && viewer == profile.UserID {
+		return ownerAccess, nil
+	}
+
+	var access profileAccess
+	if viewer != 0 && profile.Visibility != models.ProfileVisibilityPrivate {
+		connected, err := app.Connections.SharesWith(ctx, profile.ID, viewer)
+		if err != nil {
+			return access, err
+		}
+		access.connected = connected
+	}
+	switch profile.Visibility {
+	case models.ProfileVisibilityPrivate:
+		access.visible = false
+	case models.ProfileVisibilityConnections:
+		access.visible = access.connected
+	default:
+		access.visible = true
+	}
+	access.listed = access.visible && (!profile.Unlisted() || access.connected)
+	return access, nil
+}
+
+// ownerAccess は所有者本人のアクセス範囲です
+var ownerAccess = profileAccess{visible: true, connected: true, listed: true, owner: true}
+
+// profileByRef は公開APIのパスに指定された公開IDまたはカスタムURLでプロフィールを取得します
+// 連番のIDは所有者本人の場合だけ受け付けます（他人のプロフィールを連番で辿れないようにするため）
+func (app *App) profileByRef(ctx context.Context, viewer int, ref string) (*models.Profile, error) {
+	if id, err := strconv.Atoi(ref); err == nil {
+		profile, err := app.Profiles.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if viewer == 0 || profile.UserID != viewer {
+			return nil, store.ErrNotFound
+		}
+		return profile, nil
+	}
+	return app.Profiles.GetByPublicRef(ctx, ref)
+}
+
+// profileAccessByRef は公開IDまたはカスタムURLでプロフィールを取得してアクセス範囲を判定します
+// 存在しないプロフィールはnilを返し、閲覧できないものとして扱います
+func (app *App) profileAccessByRef(ctx context.Context, viewer int, ref string) (*models.Profile, profileAccess, error) {
+	profile, err := app.profileByRef(ctx, viewer, ref)
+	if err == store.ErrNotFound {
+		return nil, profileAccess{}, nil
+	}
+	if err != nil {
+		return nil, profileAccess{}, err
+	}
+	access, err := app.profileAccessFor(ctx, viewer, profile)
+	return profile, access, err
+}
+
+// profileURL はプロフィールの公開ページのURLを返します（QRコードにもこのURLを使います）
+func profileURL(profile *models.Profile) string {
+	return utils.FrontendURL() + "/profile/" + profile.PublicRef()
+}
+
+// presentProfile はアイコンURL・公開URLを設定し、閲覧者に見せない項目を取り除きます
+// 連番のID・ユーザーIDは所有者本人にだけ返します（他人のプロフィールやユーザーを連番で辿れないようにするため）
+func presentProfile(profile *models.Profile, access profileAccess) {
+	if profile.IconPath != "" {
+		profile.IconURL = fmt.Sprintf("http://localhost:8080/api/profiles/%s/icon", profile.PublicID)
+	}
+	profile.IconPath = ""
+	profile.URL = profileURL(profile)
+	if !access.connected {
+		profile.RedactRestricted()
+	}
+	if !access.owner {
+		profile.ID = 0
+		profile.UserID = 0
+		profile.RestrictedFields = nil
+		profile.AutoAcceptConnections = false
+	}
+}
+
+// hideLinkOwners は所有者以外に返すリンクから連番のユーザーID・プロフィールIDを取り除きます
+func hideLinkOwners(links []models.Link) {
+	for i := range links {
+		links[i].UsersID = 0
+		links[i].ProfileID = nil
+	}
+}
+
+// visibleLinks は閲覧者に見せてよいリンクだけを返します
+// プロフィールに紐づかないリンクは所有者以外には公開設定のものだけを返します
+// 所有者以外に返すリンクからは連番のユーザーID・プロフィールIDを取り除きます
+func (app *App) visibleLinks(ctx context.Context, viewer int, links []models.Link) ([]models.Link, error) {
+	accessByProfile := map[int]profileAccess{}
+	visible := []models.Link{}
+	for _, link := range links {
+		if link.ProfileID == nil {
+			if viewer != 0 && link.UsersID == viewer {
+				visible = append(visible, link)
+			} else if !link.ConnectionsOnly {
+				link.UsersID = 0
+				visible = append(visible, link)
+			}
+			continue
+		}
+
+		access, ok := accessByProfile[*link.ProfileID]
+		if !ok {
+			profile, err := app.Profiles.Get(ctx, *link.ProfileID)
+			if err != nil {
+				return nil, err
+			}
+			if access, err = app.profileAccessFor(ctx, viewer, profile); err != nil {
+				return nil, err
+			}
+			accessByProfile[*link.ProfileID] = access
+		}
+		if access.visible && (access.connected || !link.ConnectionsOnly) {
+			if !access.owner {
+				link.UsersID = 0
+				link.ProfileID = nil
+			}
+			visible = append(visible, link)
+		}
+	}
+	return visible, nil
+}
+
+// normalizeRestrictedFields は制限する項目を検証し、重複を除いて定義順に並べ替えます
+func normalizeRestrictedFields(requested []string) ([]string, bool) {
+	want := map[string]bool{}
+	for _, field := range requested {
+		want[field] = true
+	}
+	fields := []string{}
+	for _, field := range models.RestrictableProfileFields {
+		if want[field] {
+			fields = append(fields, field)
+			delete(want, field)
+		}
+	}
+	return fields, len(want) == 0
+}
@@ -0,0 +1,50 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileMailer は開発・テスト用のMailerです
+// 送信内容をログに出力し、Dirが設定されている場合は1通ごとに .eml ファイルとして保存します
+type FileMailer struct {
+	Dir  string
+	From string
+
+	mu  sync.Mutex
+	seq int
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	fmt.Printf("[mail] to=%s subject=%s\n%s\n", msg.To, msg.Subject, msg.Body)
+	if m.Dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(m.Dir, 0755); err != nil {
+		return fmt.Errorf("メール保存ディレクトリの作成に失敗しました: %v", err)
+	}
+
+	// 同じ秒に複数送っても順序が分かるよう連番を付ける
+	m.mu.Lock()
+	m.seq++
+	name := fmt.Sprintf("%s-%04d-%s.eml", time.Now().Format("20060102T150405"), m.seq, sanitizeFilename(msg.To))
+	m.mu.Unlock()
+
+	content := fmt.Sprintf("From: %s\nTo: %s\nSubject: %s\n\n%s\n", m.From, msg.To, msg.Subject, msg.Body)
+	return os.WriteFile(filepath.Join(m.Dir, name), []byte(content), 0644)
+}
+
+func sanitizeFilename(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r == '<' || r == '>' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, s)
+}
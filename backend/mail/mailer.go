@@ -0,0 +1,57 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Message は送信するメールを表します（本文はプレーンテキスト）
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer はメール送信を抽象化したインターフェースです
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewFromEnv は環境変数 MAIL_DRIVER に応じたMailerを作成します
+//   - smtp: SMTP_HOST / SMTP_PORT / SMTP_USERNAME / SMTP_PASSWORD で指定したサーバーから送信
+//   - file（デフォルト）: MAIL_DIR にファイルとして保存（未設定の場合はログ出力のみ）
+func NewFromEnv() (Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "QRsona <no-reply@qrsona.local>"
+	}
+
+	switch driver := os.Getenv("MAIL_DRIVER"); driver {
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return nil, fmt.Errorf("SMTP_HOSTが設定されていません")
+		}
+		port := 587
+		if p := os.Getenv("SMTP_PORT"); p != "" {
+			n, err := strconv.Atoi(p)
+			if err != nil {
+				return nil, fmt.Errorf("SMTP_PORTが不正です: %v", err)
+			}
+			port = n
+		}
+		return &SMTPMailer{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}, nil
+	case "", "file":
+		return &FileMailer{Dir: os.Getenv("MAIL_DIR"), From: from}, nil
+	default:
+		return nil, fmt.Errorf("MAIL_DRIVERが不正です: %s", driver)
+	}
+}
@@ -17,10 +17,21 @@ func main() {
 		log.Println("Warning: .env file not found")
 	}
 
+	// マイグレーションコマンド（例: go run . migrate up）
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
 	// データベース初期化
 	database.InitDB()
 	defer database.CloseDB()
 
+	// AUTO_MIGRATE=true の場合は起動時にマイグレーションを適用
+	if os.Getenv("AUTO_MIGRATE") == "true" {
+		database.RunMigrations()
+	}
+
 	// Ginルーター作成
 	r := gin.Default()
 
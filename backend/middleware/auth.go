@@ -1,42 +1,193 @@
 package middleware
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
+	"backend/models"
 	"backend/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AuthRequired() gin.HandlerFunc {
+// TokenRevocationChecker はアクセストークンが失効済みか判定します
+type TokenRevocationChecker interface {
+	IsRevoked(ctx context.Context, jti string, userID int, issuedAt time.Time) (bool, error)
+}
+
+// TokenValidator はアクセストークンの署名と有効期限を検証します
+type TokenValidator interface {
+	ValidateJWT(tokenString string) (*utils.Claims, error)
+}
+
+// PersonalAccessTokenLookup はパーソナルアクセストークンをハッシュで検索し、最終使用日時を記録します
+type PersonalAccessTokenLookup interface {
+	GetByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error)
+	TouchLastUsed(ctx context.Context, id int, usedAt time.Time) error
+}
+
+// SessionTracker はアクセストークンを発行したセッションを取得し、最終使用日時を記録します
+type SessionTracker interface {
+	Get(ctx context.Context, id string) (*models.Session, error)
+	TouchLastSeen(ctx context.Context, id string, seenAt time.Time) error
+}
+
+// AccountLookup はトークンの持ち主のアカウント（ロール・停止状態）を取得します
+type AccountLookup interface {
+	GetByID(ctx context.Context, id int) (*models.User, error)
+}
+
+// AuthRequired はBearerトークンを検証し、失効済みのトークン・セッションと停止中のアカウントを拒否するミドルウェアです
+// アクセストークン（JWT）に加えてパーソナルアクセストークンも受け付けます（スコープはRequireScopeで確認します）
+func AuthRequired(tokens TokenValidator, revocations TokenRevocationChecker, sessions SessionTracker, pats PersonalAccessTokenLookup, accounts AccountLookup) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
+		if c.GetHeader("Authorization") == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "認証ヘッダーが必要です"})
 			c.Abort()
 			return
 		}
-
-		// "Bearer <token>" から token 部分を取得
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "認証ヘッダーの形式が正しくありません"})
+		if failure := authenticate(c, tokens, revocations, sessions, pats, accounts); failure != nil {
+			c.JSON(failure.status, gin.H{"error": failure.message})
 			c.Abort()
 			return
 		}
+		c.Next()
+	}
+}
 
-		claims, err := utils.ValidateJWT(parts[1])
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "トークンが無効です"})
-			c.Abort()
-			return
+// AuthOptional は認証ヘッダーがあればAuthRequiredと同じ検証をしてユーザー情報を設定するミドルウェアです
+// 公開APIで閲覧者に応じて表示内容を変えるために使います
+// ヘッダーがない場合や検証に失敗した場合は拒否せず、未ログインの閲覧者として扱います
+func AuthOptional(tokens TokenValidator, revocations TokenRevocationChecker, sessions SessionTracker, pats PersonalAccessTokenLookup, accounts AccountLookup) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" {
+			authenticate(c, tokens, revocations, sessions, pats, accounts)
 		}
+		c.Next()
+	}
+}
 
-		// コンテキストにユーザー情報を設定
-		c.Set("user_id", claims.UserID)
-		c.Set("user_email", claims.Email)
+// authFailure は認証に失敗した理由とレスポンスのステータスコードです
+type authFailure struct {
+	status  int
+	message string
+}
 
-		c.Next()
+func unauthorized(message string) *authFailure {
+	return &authFailure{status: http.StatusUnauthorized, message: message}
+}
+
+// authenticate はAuthorizationヘッダーのトークンを検証し、成功した場合だけコンテキストにユーザー情報を設定します
+func authenticate(c *gin.Context, tokens TokenValidator, revocations TokenRevocationChecker, sessions SessionTracker, pats PersonalAccessTokenLookup, accounts AccountLookup) *authFailure {
+	// "Bearer <token>" から token 部分を取得
+	parts := strings.Split(c.GetHeader("Authorization"), " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return unauthorized("認証ヘッダーの形式が正しくありません")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	if utils.IsPersonalAccessToken(parts[1]) {
+		token, failure := authenticatePersonalAccessToken(ctx, pats, parts[1])
+		if failure != nil {
+			return failure
+		}
+		user, failure := loadAccount(ctx, accounts, token.UserID)
+		if failure != nil {
+			return failure
+		}
+		c.Set("user_id", token.UserID)
+		c.Set("personal_access_token", token)
+		c.Set("user_role", user.Role)
+		return nil
+	}
+
+	claims, err := tokens.ValidateJWT(parts[1])
+	// 有効期限のないトークンはValidateJWTで拒否しているが、検証の実装が変わっても期限のないトークンを通さない
+	if err != nil || claims.ExpiresAt == nil {
+		return unauthorized("トークンが無効です")
+	}
+
+	// 失効リスト（jti・ユーザー単位の一括失効）の確認
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+	revoked, err := revocations.IsRevoked(ctx, claims.ID, claims.UserID, issuedAt)
+	if err != nil {
+		return &authFailure{status: http.StatusInternalServerError, message: "認証状態の確認に失敗しました"}
+	}
+	if revoked {
+		return unauthorized("トークンは失効しています")
+	}
+	if failure := checkSession(ctx, sessions, claims); failure != nil {
+		return failure
+	}
+	user, failure := loadAccount(ctx, accounts, claims.UserID)
+	if failure != nil {
+		return failure
+	}
+
+	// コンテキストにユーザー情報を設定
+	c.Set("user_id", claims.UserID)
+	c.Set("user_email", claims.Email)
+	c.Set("token_id", claims.ID)
+	c.Set("token_expires_at", claims.ExpiresAt.Time)
+	c.Set("session_id", claims.SessionID)
+	c.Set("user_role", user.Role)
+	return nil
+}
+
+// checkSession はアクセストークンを発行したセッションが失効していないことを確認し、最終使用日時を記録します
+func checkSession(ctx context.Context, sessions SessionTracker, claims *utils.Claims) *authFailure {
+	// sidを持たないのはセッションの記録を始める前に発行されたトークンなので、失効リストの確認だけで通す
+	if claims.SessionID == "" {
+		return nil
+	}
+
+	session, err := sessions.Get(ctx, claims.SessionID)
+	if err != nil || session.UserID != claims.UserID {
+		return unauthorized("トークンが無効です")
+	}
+	now := time.Now()
+	if !session.Active(now) {
+		return unauthorized("セッションは失効しています")
+	}
+	if err := sessions.TouchLastSeen(ctx, session.ID, now); err != nil {
+		fmt.Printf("Failed to record session usage: %v\n", err)
+	}
+	return nil
+}
+
+// loadAccount はトークンの持ち主のアカウントを取得し、削除済み・停止中のアカウントを拒否します
+func loadAccount(ctx context.Context, accounts AccountLookup, userID int) (*models.User, *authFailure) {
+	user, err := accounts.GetByID(ctx, userID)
+	if err != nil {
+		// 削除済みのユーザーのトークンも無効として扱う（ストアのエラーと区別しない）
+		return nil, unauthorized("トークンが無効です")
+	}
+	if user.Suspended() {
+		return nil, &authFailure{status: http.StatusForbidden, message: "このアカウントは停止されています"}
+	}
+	return user, nil
+}
+
+// authenticatePersonalAccessToken はパーソナルアクセストークンを検証し、最終使用日時を記録します
+func authenticatePersonalAccessToken(ctx context.Context, pats PersonalAccessTokenLookup, raw string) (*models.PersonalAccessToken, *authFailure) {
+	token, err := pats.GetByHash(ctx, utils.HashToken(raw))
+	if err != nil {
+		return nil, unauthorized("トークンが無効です")
+	}
+	now := time.Now()
+	if token.RevokedAt != nil || (token.ExpiresAt != nil && !token.ExpiresAt.After(now)) {
+		return nil, unauthorized("トークンは失効しています")
+	}
+	if err := pats.TouchLastUsed(ctx, token.ID, now); err != nil {
+		fmt.Printf("Failed to record token usage: %v\n", err)
 	}
+	return token, nil
 }
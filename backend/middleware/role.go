@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+
+	"backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole は呼び出し元にminRole以上のロールを要求するミドルウェアです（AuthRequiredの後に使います）
+func RequireRole(minRole models.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !CurrentRole(c).AtLeast(minRole) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "この操作を行う権限がありません"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// CurrentRole はAuthRequiredで確認した呼び出し元のロールを返します（未認証の場合は空）
+func CurrentRole(c *gin.Context) models.Role {
+	role, _ := c.Get("user_role")
+	r, _ := role.(models.Role)
+	return r
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+
+	"backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireScope はパーソナルアクセストークンで認証されたリクエストにスコープを要求するミドルウェアです（AuthRequiredの後に使います）
+// GET・HEADは "<resource>:read"、それ以外は "<resource>:write" を要求します。アクセストークン（JWT）はすべて許可します
+func RequireScope(resource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := personalAccessToken(c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		scope := resource + ":write"
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			scope = resource + ":read"
+		}
+		if !token.HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "トークンに必要なスコープがありません: " + scope})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireReadScope はパーソナルアクセストークンで認証されたリクエストに、メソッドによらず "<resource>:read" を要求するミドルウェアです
+// AuthOptionalの後に使う公開API（つながりがあれば制限された項目を返す）や、POSTでも読み取るだけのAPIに使います
+// 未ログイン・アクセストークン（JWT）のリクエストはすべて許可します
+func RequireReadScope(resource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := personalAccessToken(c)
+		if !ok {
+			c.Next()
+			return
+		}
+		if scope := resource + ":read"; !token.HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "トークンに必要なスコープがありません: " + scope})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// SessionOnly はパーソナルアクセストークンでの操作を禁止するミドルウェアです（AuthRequiredの後に使います）
+// トークンの発行や二要素認証の設定など、アカウントを乗っ取れる操作に使います
+func SessionOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := personalAccessToken(c); ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "この操作にはパーソナルアクセストークンは使えません。サインインしてください"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// personalAccessToken はAuthRequired・AuthOptionalで認証したパーソナルアクセストークンを返します
+func personalAccessToken(c *gin.Context) (*models.PersonalAccessToken, bool) {
+	v, ok := c.Get("personal_access_token")
+	if !ok {
+		return nil, false
+	}
+	token, ok := v.(*models.PersonalAccessToken)
+	return token, ok
+}
@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// パーソナルアクセストークンのスコープ（writeはreadを含みます）
+const (
+	ScopeProfilesRead     = "profiles:read"
+	ScopeProfilesWrite    = "profiles:write"
+	ScopeLinksRead        = "links:read"
+	ScopeLinksWrite       = "links:write"
+	ScopeConnectionsRead  = "connections:read"
+	ScopeConnectionsWrite = "connections:write"
+	ScopeUsersRead        = "users:read"
+)
+
+// PersonalAccessTokenScopes は発行できるスコープの一覧です
+var PersonalAccessTokenScopes = []string{
+	ScopeProfilesRead, ScopeProfilesWrite,
+	ScopeLinksRead, ScopeLinksWrite,
+	ScopeConnectionsRead, ScopeConnectionsWrite,
+	ScopeUsersRead,
+}
+
+// PersonalAccessToken はスクリプトなどからAPIを使うためのトークンを表します
+type PersonalAccessToken struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"user_id"`
+	Name        string     `json:"name"`
+	TokenHash   string     `json:"-"`
+	TokenPrefix string     `json:"token_prefix"` // 一覧でトークンを見分けるための先頭部分
+	Scopes      []string   `json:"scopes"`
+	ExpiresAt   *time.Time `json:"expires_at"` // nullの場合は無期限
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+}
+
+// HasScope はトークンがスコープを持つかどうかを返します（writeのスコープはreadも許可します）
+func (t *PersonalAccessToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+		if n := len(s) - len(":write"); n > 0 && s[n:] == ":write" && scope == s[:n]+":read" {
+			return true
+		}
+	}
+	return false
+}
+
+// CreatePersonalAccessTokenRequest はパーソナルアクセストークンの発行リクエストを表します
+type CreatePersonalAccessTokenRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays *int     `json:"expires_in_days,omitempty" binding:"omitempty,min=1,max=365"` // 省略した場合は無期限
+}
+
+// CreatePersonalAccessTokenResponse は発行したトークンを表します（平文のトークンを返すのはこの時だけ）
+type CreatePersonalAccessTokenResponse struct {
+	PersonalAccessToken
+	Token string `json:"token"`
+}
+
+// PersonalAccessTokenListResponse はパーソナルアクセストークン一覧のレスポンスを表します
+type PersonalAccessTokenListResponse struct {
+	Tokens []PersonalAccessToken `json:"tokens"`
+}
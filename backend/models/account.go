@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// DeleteAccountRequest はアカウント削除の予約リクエストを表します（本人確認のためパスワードが必要）
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// AccountDeletionResponse はアカウント削除の予約結果を表します
+type AccountDeletionResponse struct {
+	Message             string    `json:"message"`
+	DeletionScheduledAt time.Time `json:"deletion_scheduled_at"` // この日時を過ぎると完全に削除されます
+}
+
+// ExportedUser はデータエクスポートのuser.jsonを表します
+type ExportedUser struct {
+	User       User           `json:"user"`
+	Identities []UserIdentity `json:"identities"` // 連携済みの外部アカウント
+	ExportedAt time.Time      `json:"exported_at"`
+}
+
+// ExportedProfile はデータエクスポートのprofiles.jsonの1件を表します
+type ExportedProfile struct {
+	Profile
+	IconFile string `json:"icon_file,omitempty"` // ZIP内のアイコン画像のパス
+}
+
+// ExportedConnections はデータエクスポートのconnections.jsonを表します
+type ExportedConnections struct {
+	Outgoing []Connection `json:"outgoing"` // 自分のプロフィールが作成したコネクション
+	Incoming []Connection `json:"incoming"` // 他のプロフィールが自分のプロフィールに対して作成したコネクション
+}
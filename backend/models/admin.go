@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// 監査ログの操作の種類
+const (
+	AuditUserSuspend    = "user.suspend"
+	AuditUserUnsuspend  = "user.unsuspend"
+	AuditUserRoleChange = "user.role_change"
+	AuditProfileDelete  = "profile.delete"
+	AuditUserDelete     = "user.delete" // 削除の猶予期間を過ぎたアカウントの完全削除（操作者なし）
+)
+
+// AuditLog は管理操作の監査ログを表します
+type AuditLog struct {
+	ID           int               `json:"id"`
+	ActorUserID  *int              `json:"actor_user_id"` // 操作したユーザー（削除済みの場合はnull）
+	Action       string            `json:"action"`
+	TargetUserID *int              `json:"target_user_id"` // 操作の対象になったユーザー（プロフィールの場合は所有者）
+	TargetType   string            `json:"target_type"`    // user・profile
+	TargetID     int               `json:"target_id"`
+	Details      map[string]string `json:"details"`
+	CreatedAt    time.Time         `json:"created_at"`
+}
+
+// AdminUserListResponse は管理者向けのユーザー検索結果を表します
+type AdminUserListResponse struct {
+	Users   []User `json:"users"`
+	Total   int    `json:"total"`
+	Page    int    `json:"page"`
+	PerPage int    `json:"per_page"`
+}
+
+// AuditLogListResponse は監査ログ一覧のレスポンスを表します
+type AuditLogListResponse struct {
+	Logs    []AuditLog `json:"logs"`
+	Total   int        `json:"total"`
+	Page    int        `json:"page"`
+	PerPage int        `json:"per_page"`
+}
+
+// SuspendUserRequest はアカウント停止リクエストを表します
+type SuspendUserRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// UpdateUserRoleRequest はロール変更リクエストを表します
+type UpdateUserRoleRequest struct {
+	Role Role `json:"role" binding:"required"`
+}
+
+// AdminDeleteProfileRequest は管理者によるプロフィール削除リクエストを表します
+type AdminDeleteProfileRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
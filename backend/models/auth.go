@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // SignUpRequest はユーザー登録リクエストを表します
 type SignUpRequest struct {
 	Name     string `json:"name" binding:"required"`
@@ -12,3 +14,27 @@ type SignInRequest struct {
 	Email    string `json:"email" binding:"required"`
 	Password string `json:"password" binding:"required"`
 }
+
+// ForgotPasswordRequest はパスワードリセットの申請リクエストを表します
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// ResetPasswordRequest はパスワードリセットの確定リクエストを表します
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+// VerifyEmailRequest はメールアドレス確認リクエストを表します
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// LoginAttempt はサインインの連続失敗回数とロック状態を表します（キーは正規化したメールアドレス）
+type LoginAttempt struct {
+	Key          string     `json:"key"`
+	Failures     int        `json:"failures"`
+	LastFailedAt time.Time  `json:"last_failed_at"`
+	LockedUntil  *time.Time `json:"locked_until,omitempty"`
+}
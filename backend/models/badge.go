@@ -0,0 +1,14 @@
+package models
+
+// BadgeRequest はイベント用のバッジPDFの生成リクエストを表します
+// 長さの単位はmmです。省略した項目はA4縦・2列×5行・余白10mm・間隔0mm・切り取り線ありです
+type BadgeRequest struct {
+	ProfileIDs  []string `json:"profile_ids" binding:"required"`                                     // バッジにするプロフィールの公開IDまたはカスタムURL（並べる順）
+	PageSize    string   `json:"page_size,omitempty"`                                                // A3・A4・A5・A6・B4・B5・Letter・Legal
+	Orientation string   `json:"orientation,omitempty" binding:"omitempty,oneof=portrait landscape"` // 用紙の向き
+	Columns     int      `json:"columns,omitempty"`                                                  // 1ページの列数
+	Rows        int      `json:"rows,omitempty"`                                                     // 1ページの行数
+	Margin      *float64 `json:"margin,omitempty"`                                                   // 用紙の端の余白
+	Gap         *float64 `json:"gap,omitempty"`                                                      // バッジどうしの間隔
+	CutLines    *bool    `json:"cut_lines,omitempty"`                                                // バッジの周囲に切り取り線を描画する
+}
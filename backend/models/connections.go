@@ -4,22 +4,25 @@ import "time"
 
 // Connectionはプロフィール間のコネクション情報を表します
 type Connection struct {
-	ID                    int       `json:"id"`
-	ProfileID             int       `json:"profile_id"`              // コネクションを作成したプロフィールID
-	ConnectUsersProfileID int       `json:"connect_user_profile_id"` // 接続先のプロフィールID
-	ConnectedAt           time.Time `json:"connected_at"`            // コネクション作成日時
-	EventName             string    `json:"event_name,omitempty"`    // イベント名
-	EventDate             string    `json:"event_date,omitempty"`    // イベント日付
-	Memo                  string    `json:"memo,omitempty"`          // メモ
+	ID                          int       `json:"id"`
+	ProfileID                   int       `json:"profile_id,omitempty"`                     // コネクションを作成したプロフィールID（他人のコネクションを返す場合は省略）
+	ConnectUsersProfileID       int       `json:"-"`                                        // 接続先のプロフィールID（連番のIDは返さない）
+	ConnectUsersProfilePublicID string    `json:"connect_user_profile_public_id,omitempty"` // 接続先のプロフィールの公開ID
+	ConnectedAt                 time.Time `json:"connected_at"`                             // コネクション作成日時
+	EventName                   string    `json:"event_name,omitempty"`                     // イベント名
+	EventDate                   string    `json:"event_date,omitempty"`                     // イベント日付
+	Memo                        string    `json:"memo,omitempty"`                           // メモ
 }
 
 // CreateConnectionRequestは新規コネクション作成リクエスト
+// 接続先は公開ID（またはカスタムURL）で指定します（連番のIDは自分のプロフィールの場合だけ使えます）
 type CreateConnectionRequest struct {
-	ProfileID             int    `json:"profile_id" binding:"required"`
-	ConnectUsersProfileID int    `json:"connect_user_profile_id" binding:"required"`
-	EventName             string `json:"event_name,omitempty"`
-	EventDate             string `json:"event_date,omitempty"`
-	Memo                  string `json:"memo,omitempty"`
+	ProfileID                   int    `json:"profile_id" binding:"required"`
+	ConnectUsersProfileID       int    `json:"connect_user_profile_id,omitempty"`
+	ConnectUsersProfilePublicID string `json:"connect_user_profile_public_id,omitempty"`
+	EventName                   string `json:"event_name,omitempty"`
+	EventDate                   string `json:"event_date,omitempty"`
+	Memo                        string `json:"memo,omitempty"`
 }
 
 // ConnectionListResponseはコネクション一覧レスポンス
@@ -30,11 +33,44 @@ type ConnectionListResponse struct {
 
 // UserConnectionはユーザーの交換済みプロフィール情報
 type UserConnection struct {
-	ConnectedProfileID    int       `json:"connected_profile_id"`
-	ConnectedProfileTitle string    `json:"connected_profile_title"`
-	ConnectedUserName     string    `json:"connected_user_name"`
-	ConnectedAt           time.Time `json:"connected_at"`
-	EventName             string    `json:"event_name,omitempty"`
-	EventDate             string    `json:"event_date,omitempty"`
-	Memo                  string    `json:"memo,omitempty"`
+	ConnectedProfileID       int       `json:"-"`                           // 連番のIDは返さない
+	ConnectedProfilePublicID string    `json:"connected_profile_public_id"` // 公開ページのURL用
+	ConnectedProfileTitle    string    `json:"connected_profile_title"`
+	ConnectedUserName        string    `json:"connected_user_name"`
+	ConnectedAt              time.Time `json:"connected_at"`
+	EventName                string    `json:"event_name,omitempty"`
+	EventDate                string    `json:"event_date,omitempty"`
+	Memo                     string    `json:"memo,omitempty"`
+}
+
+// ConnectionRequestはコネクション申請を表します
+// 承認されるとProfileIDからConnectUsersProfileIDへのコネクションを作成します
+type ConnectionRequest struct {
+	ID                          int        `json:"id"`
+	ProfileID                   int        `json:"profile_id,omitempty"`                     // 申請したプロフィールID（申請先に返す場合は省略）
+	ConnectUsersProfileID       int        `json:"-"`                                        // 申請先のプロフィールID（連番のIDは返さない）
+	ConnectUsersProfilePublicID string     `json:"connect_user_profile_public_id,omitempty"` // 申請先のプロフィールの公開ID
+	Status                      string     `json:"status"`                                   // ConnectionRequest*
+	EventName                   string     `json:"event_name,omitempty"`
+	EventDate                   string     `json:"event_date,omitempty"`
+	Memo                        string     `json:"memo,omitempty"` // 承認後のコネクションのメモ（申請者にのみ返します）
+	CreatedAt                   time.Time  `json:"created_at"`
+	ExpiresAt                   time.Time  `json:"expires_at"`
+	RespondedAt                 *time.Time `json:"responded_at,omitempty"`
+
+	Profile *Profile `json:"profile,omitempty"` // 一覧で返す相手のプロフィール（閲覧できない場合は省略）
+}
+
+// コネクション申請の状態
+const (
+	ConnectionRequestPending  = "pending"  // 承認待ち
+	ConnectionRequestAccepted = "accepted" // 承認済み（コネクションを作成済み）
+	ConnectionRequestDeclined = "declined" // 拒否
+	ConnectionRequestExpired  = "expired"  // 承認されないまま有効期限が過ぎた
+)
+
+// ConnectionRequestListResponseはコネクション申請一覧レスポンス
+type ConnectionRequestListResponse struct {
+	Requests []ConnectionRequest `json:"requests"`
+	Total    int                 `json:"total"`
 }
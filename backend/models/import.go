@@ -0,0 +1,21 @@
+package models
+
+// ImportRecord はインポートする1件（vCardの1枚・CSVの1行）の内容と検証結果を表します
+type ImportRecord struct {
+	Index          int             `json:"index"` // ファイル内の順番（1始まり）
+	Profile        Profile         `json:"profile"`
+	Links          []Link          `json:"links"`
+	OptionProfiles []OptionProfile `json:"option_profiles"`
+	Errors         []string        `json:"errors,omitempty"` // 検証エラー（1件でもあればインポートしません）
+}
+
+// ImportResponse はインポート（またはドライラン）の結果を表します
+type ImportResponse struct {
+	Error    string         `json:"error,omitempty"`
+	DryRun   bool           `json:"dry_run"`
+	Format   string         `json:"format"` // vcard または csv
+	Total    int            `json:"total"`
+	Valid    int            `json:"valid"`    // 検証エラーのない件数
+	Imported int            `json:"imported"` // 登録した件数（ドライラン・エラーがある場合は0）
+	Records  []ImportRecord `json:"records"`
+}
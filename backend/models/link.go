@@ -4,33 +4,47 @@ import "time"
 
 // Link構造体
 type Link struct {
-	ID          int       `json:"id" db:"id"`
-	UsersID     int       `json:"user_id" db:"user_id"`
-	ProfileID   *int      `json:"profile_id,omitempty" db:"profile_id"`
-	ImageURL    *string   `json:"image_url,omitempty" db:"image_url"`
-	Title       string    `json:"title" db:"title"`
-	Description *string   `json:"description,omitempty" db:"description"`
-	URL         string    `json:"url" db:"url"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID              int       `json:"id" db:"id"`
+	UsersID         int       `json:"user_id,omitempty" db:"user_id"` // 所有者本人にだけ返します（profile_idも同じ）
+	ProfileID       *int      `json:"profile_id,omitempty" db:"profile_id"`
+	ImageURL        *string   `json:"image_url,omitempty" db:"image_url"`
+	Title           string    `json:"title" db:"title"`
+	Description     *string   `json:"description,omitempty" db:"description"`
+	URL             string    `json:"url" db:"url"`
+	ConnectionsOnly bool      `json:"connections_only" db:"connections_only"` // つながりのある人だけに見せる
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
 }
 
 // リンク作成用リクエスト
 type CreateLinkRequest struct {
-	UsersID     *int    `json:"user_id,omitempty"`
-	ProfileID   *int    `json:"profile_id,omitempty"`
-	ImageURL    *string `json:"image_url,omitempty"`
-	Title       string  `json:"title" binding:"required,max=100"`
-	Description *string `json:"description,omitempty"`
-	URL         string  `json:"url" binding:"required,url"`
+	UsersID         *int    `json:"user_id,omitempty"`
+	ProfileID       *int    `json:"profile_id,omitempty"`
+	ImageURL        *string `json:"image_url,omitempty"`
+	Title           string  `json:"title" binding:"required,max=100"`
+	Description     *string `json:"description,omitempty"`
+	URL             string  `json:"url" binding:"required,url"`
+	ConnectionsOnly bool    `json:"connections_only,omitempty"` // つながりのある人だけに見せる（任意）
 }
 
 // リンク更新用リクエスト
 type UpdateLinkRequest struct {
-	ImageURL    *string `json:"image_url,omitempty"`
-	Title       *string `json:"title,omitempty"`
-	Description *string `json:"description,omitempty"`
-	URL         *string `json:"url,omitempty"`
+	ImageURL        *string `json:"image_url,omitempty"`
+	Title           *string `json:"title,omitempty"`
+	Description     *string `json:"description,omitempty"`
+	URL             *string `json:"url,omitempty"`
+	ConnectionsOnly *bool   `json:"connections_only,omitempty"` // つながりのある人だけに見せるか
+}
+
+// PublicLinks はつながりのない閲覧者に見せるリンクだけを返します
+func PublicLinks(links []Link) []Link {
+	public := []Link{}
+	for _, link := range links {
+		if !link.ConnectionsOnly {
+			public = append(public, link)
+		}
+	}
+	return public
 }
 
 // リンク一覧レスポンス
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// UserIdentity は外部プロバイダーのアカウントとユーザーの紐付けを表します
+type UserIdentity struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"user_id"`
+	Provider   string    `json:"provider"`    // github, google など
+	Subject    string    `json:"subject"`     // プロバイダー内で一意なアカウントID
+	Email      string    `json:"email"`       // 紐付け時にプロバイダーから取得したメールアドレス
+	ProfileURL string    `json:"profile_url"` // プロバイダー上のプロフィールページ（GitHubの場合）
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// OAuthState は認可リクエストのstateとPKCEのcode_verifierを表します
+type OAuthState struct {
+	StateHash    string
+	Provider     string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+// OAuthProvidersResponse は利用できるプロバイダーの一覧を表します
+type OAuthProvidersResponse struct {
+	Providers []string `json:"providers"`
+}
+
+// OAuthStartResponse は認可リクエストの開始レスポンスを表します
+type OAuthStartResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+// OAuthCallbackRequest はプロバイダーからリダイレクトされたときのパラメータを表します
+type OAuthCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
@@ -6,6 +6,22 @@ type OptionProfile struct {
 	Title     string `json:"title" db:"title"`           // オプションタイトル
 	Content   string `json:"content" db:"content"`       // オプション内容
 	ProfileID int    `json:"profile_id" db:"profile_id"` // 関連付けられたプロフィールID
+
+	ConnectionsOnly bool `json:"connections_only" db:"connections_only"` // つながりのある人だけに見せる
+}
+
+// PublicOptionProfiles はつながりのない閲覧者に見せる任意項目だけを返します
+func PublicOptionProfiles(options []OptionProfile) []OptionProfile {
+	if options == nil {
+		return nil
+	}
+	public := []OptionProfile{}
+	for _, opt := range options {
+		if !opt.ConnectionsOnly {
+			public = append(public, opt)
+		}
+	}
+	return public
 }
 
 // CreateOptionProfileRequest はオプションプロフィール作成リクエストを表します
@@ -13,12 +29,16 @@ type CreateOptionProfileRequest struct {
 	Title     string `json:"title" binding:"required"`      // オプションタイトル
 	Content   string `json:"content" binding:"required"`    // オプション内容
 	ProfileID int    `json:"profile_id" binding:"required"` // 関連付けられたプロフィールID
+
+	ConnectionsOnly bool `json:"connections_only,omitempty"` // つながりのある人だけに見せる（任意）
 }
 
 // UpdateOptionProfileRequest はオプションプロフィール更新リクエストを表します
 type UpdateOptionProfileRequest struct {
 	Title   string `json:"title,omitempty"`   // オプションタイトル
 	Content string `json:"content,omitempty"` // オプション内容
+
+	ConnectionsOnly *bool `json:"connections_only,omitempty"` // つながりのある人だけに見せるか
 }
 
 // OptionProfileListResponse はオプションプロフィール一覧レスポンスを表します
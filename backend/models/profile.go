@@ -6,8 +6,8 @@ import (
 
 // Profile はユーザーのプロフィール情報を表します
 type Profile struct {
-	ID             int             `json:"id" db:"id"`
-	UserID         int             `json:"user_id" db:"user_id"`
+	ID             int             `json:"id,omitempty" db:"id"`           // 連番のID（所有者本人にだけ返します）
+	UserID         int             `json:"user_id,omitempty" db:"user_id"` // 所有者のユーザーID（所有者本人にだけ返します）
 	DisplayName    string          `json:"display_name" db:"display_name"`
 	IconPath       string          `json:"icon_path,omitempty" db:"icon_path"`
 	IconURL        string          `json:"icon_url,omitempty" db:"-"`              // DB上にないが、フロントに返す用
@@ -19,11 +19,99 @@ type Profile struct {
 	Title          string          `json:"title,omitempty" db:"title"`             // タイトル
 	Description    string          `json:"description,omitempty" db:"description"` // 説明
 	OptionProfiles []OptionProfile `json:"option_profiles,omitempty"`              // オプションプロフィールのリスト
+
+	Visibility       string   `json:"visibility,omitempty" db:"visibility"`               // 公開範囲（ProfileVisibility*）
+	RestrictedFields []string `json:"restricted_fields,omitempty" db:"restricted_fields"` // つながりのある人だけに見せる項目（所有者にのみ返します）
+	// AutoAcceptConnections は交換トークン（QRコード）を使った交換を自動で受け入れるかどうかです（対面でQRコードを読んでもらう用。所有者にのみ返します）
+	// trueの場合は繰り返し使えるQRコードでも相互交換できます。POST /api/connections の申請には適用しません
+	AutoAcceptConnections bool `json:"auto_accept_connections,omitempty" db:"auto_accept_connections"`
+
+	PublicID string `json:"public_id" db:"public_id"`     // 公開URL・QRコード用の推測できないID
+	Slug     string `json:"slug,omitempty" db:"slug"`     // 任意のカスタムURL（小文字）
+	URL      string `json:"profile_url,omitempty" db:"-"` // 公開ページのURL（フロントに返す用）
+}
+
+// PublicRef は公開URLに使う識別子を返します（カスタムURLがあればそちらを優先します）
+func (p *Profile) PublicRef() string {
+	if p.Slug != "" {
+		return p.Slug
+	}
+	return p.PublicID
+}
+
+// プロフィールの公開範囲
+const (
+	ProfileVisibilityPublic      = "public"      // 誰でも閲覧でき、一覧にも表示する
+	ProfileVisibilityUnlisted    = "unlisted"    // URLを知っている人は閲覧できるが、他人には一覧に表示しない
+	ProfileVisibilityConnections = "connections" // つながりのある人だけが閲覧できる
+	ProfileVisibilityPrivate     = "private"     // 所有者だけが閲覧できる
+)
+
+// Unlisted は公開範囲が限定公開（URLを知っている人だけが閲覧できる）かどうかを返します
+// 限定公開のプロフィールは、所有者とつながりのある人以外に向けた一覧・一括出力には含めません
+func (p *Profile) Unlisted() bool {
+	return p.Visibility == ProfileVisibilityUnlisted
+}
+
+// つながりのある人だけに見せることができるプロフィールの項目（表示名は常に公開）
+const (
+	ProfileFieldIcon        = "icon"
+	ProfileFieldAKA         = "aka"
+	ProfileFieldHometown    = "hometown"
+	ProfileFieldBirthdate   = "birthdate"
+	ProfileFieldHobby       = "hobby"
+	ProfileFieldComment     = "comment"
+	ProfileFieldTitle       = "title"
+	ProfileFieldDescription = "description"
+)
+
+// RestrictableProfileFields は公開範囲を制限できる項目の一覧です
+var RestrictableProfileFields = []string{
+	ProfileFieldIcon, ProfileFieldAKA, ProfileFieldHometown, ProfileFieldBirthdate,
+	ProfileFieldHobby, ProfileFieldComment, ProfileFieldTitle, ProfileFieldDescription,
+}
+
+// Restricts は項目がつながりのある人だけに制限されているかどうかを返します
+func (p *Profile) Restricts(field string) bool {
+	for _, f := range p.RestrictedFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactRestricted はつながりのない閲覧者向けに、制限された項目と任意項目を取り除きます
+// 制限の設定そのものも所有者以外には返しません
+func (p *Profile) RedactRestricted() {
+	for _, field := range p.RestrictedFields {
+		switch field {
+		case ProfileFieldIcon:
+			p.IconPath = ""
+			p.IconURL = ""
+		case ProfileFieldAKA:
+			p.AKA = ""
+		case ProfileFieldHometown:
+			p.Hometown = ""
+		case ProfileFieldBirthdate:
+			p.Birthdate = time.Time{}
+		case ProfileFieldHobby:
+			p.Hobby = ""
+		case ProfileFieldComment:
+			p.Comment = ""
+		case ProfileFieldTitle:
+			p.Title = ""
+		case ProfileFieldDescription:
+			p.Description = ""
+		}
+	}
+	p.RestrictedFields = nil
+	p.OptionProfiles = PublicOptionProfiles(p.OptionProfiles)
 }
 
 // CreateProfileRequest はプロフィール作成リクエストを表します
 type CreateProfileRequest struct {
-	UserID      int    `json:"user_id" binding:"required"`
+	UserID      int    `json:"user_id,omitempty"` // 任意。省略時はトークンのユーザー（他人のIDは403）
 	DisplayName string `json:"display_name" binding:"required"`
 	IconBase64  string `json:"icon_base64,omitempty"`                                       // 任意。base64 エンコードされた画像
 	AKA         string `json:"aka,omitempty"`                                               // 肩書き（任意）
@@ -33,6 +121,12 @@ type CreateProfileRequest struct {
 	Comment     string `json:"comment,omitempty"`                                           // コメント（任意）
 	Title       string `json:"title" binding:"required"`                                    // タイトル（必須）
 	Description string `json:"description,omitempty"`                                       // 説明（任意）
+
+	Visibility       string   `json:"visibility,omitempty" binding:"omitempty,oneof=public unlisted connections private"` // 公開範囲（任意）省略時はpublic
+	RestrictedFields []string `json:"restricted_fields,omitempty"`                                                        // つながりのある人だけに見せる項目（任意）
+	Slug             string   `json:"slug,omitempty"`                                                                     // カスタムURL（任意）
+
+	AutoAcceptConnections bool `json:"auto_accept_connections,omitempty"` // QRコードでの交換を自動で受け入れる（任意）
 }
 
 // UpdateProfileRequest はプロフィール更新リクエストを表します
@@ -46,6 +140,12 @@ type UpdateProfileRequest struct {
 	Comment     string `json:"comment,omitempty"`
 	Title       string `json:"title,omitempty"` // タイトル
 	Description string `json:"description,omitempty"`
+
+	Visibility       string    `json:"visibility,omitempty" binding:"omitempty,oneof=public unlisted connections private"`
+	RestrictedFields *[]string `json:"restricted_fields,omitempty"` // 指定した場合は置き換える（空配列で制限を解除）
+	Slug             *string   `json:"slug,omitempty"`              // 指定した場合は置き換える（空文字でカスタムURLを解除）
+
+	AutoAcceptConnections *bool `json:"auto_accept_connections,omitempty"` // QRコードでの交換を自動で受け入れるかどうか
 }
 
 // ProfileListResponse はプロフィール一覧レスポンスを表します
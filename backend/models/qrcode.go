@@ -1,17 +1,95 @@
 package models
 
+import "time"
+
 // QRコード生成リクエスト用の構造体
+// 省略した項目はPNG・誤り訂正レベルM・256px・余白4モジュール・白地に黒で生成します
 type URLRequest struct {
-	URL string `json:"url" binding:"required"`
+	URL             string  `json:"url" binding:"required"`
+	Format          string  `json:"format,omitempty"`           // png（既定）またはsvg
+	Raw             bool    `json:"raw,omitempty"`              // trueで画像そのものを返す（JSONのdata URIではなく）
+	ErrorCorrection string  `json:"error_correction,omitempty"` // L・M・Q・H
+	Size            int     `json:"size,omitempty"`             // 画像の一辺のピクセル数（余白を含む）
+	QuietZone       *int    `json:"quiet_zone,omitempty"`       // 周囲の余白のモジュール数
+	Foreground      string  `json:"foreground,omitempty"`       // #RRGGBB
+	Background      string  `json:"background,omitempty"`       // #RRGGBB
+	Logo            string  `json:"logo,omitempty"`             // 中央に重ねる画像（PNG・JPEG・GIFのdata URIまたはBase64）
+	LogoProfileID   string  `json:"logo_profile_id,omitempty"`  // 中央にアイコンを重ねるプロフィールの公開IDまたはカスタムURL
+	LogoScale       float64 `json:"logo_scale,omitempty"`       // ロゴの一辺のQRコードに対する割合
 }
 
 // QRコード生成レスポンス用の構造体
 type QRCodeResponse struct {
-	QRData string `json:"qr_data"`
-	URL    string `json:"url"`
+	QRData          string `json:"qr_data"`
+	URL             string `json:"url"`
+	Format          string `json:"format"`
+	ErrorCorrection string `json:"error_correction"` // ロゴを重ねた場合はH
+}
+
+// 読み取ったQRコードの内容の種類
+const (
+	QRPayloadProfile  = "profile"  // QRsonaのプロフィールのURL
+	QRPayloadExchange = "exchange" // QRsonaの交換用QRコード（交換トークンのURL）
+	QRPayloadURL      = "url"      // その他のURL
+	QRPayloadText     = "text"     // URL以外
+)
+
+// DecodedQRCode は画像から読み取ったQRコード1つの内容です
+type DecodedQRCode struct {
+	Content   string     `json:"content"`
+	Type      string     `json:"type"`
+	Profile   *Profile   `json:"profile,omitempty"`    // 指しているプロフィール（閲覧できる場合）
+	Token     string     `json:"token,omitempty"`      // 交換トークン（交換用QRコードの場合）
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // 交換トークンの有効期限
+	SingleUse bool       `json:"single_use,omitempty"`
+	Error     string     `json:"error,omitempty"` // プロフィールを解決できなかった理由
+}
+
+// DecodeQRCodeResponse は画像から読み取ったQRコードの一覧です
+type DecodeQRCodeResponse struct {
+	Results []DecodedQRCode `json:"results"`
+	Total   int             `json:"total"`
 }
 
 // ヘルスチェックレスポンス用の構造体
 type HealthResponse struct {
 	Status string `json:"status"`
 }
+
+// CreateExchangeTokenRequest はQRコード用の交換トークンの発行リクエストです
+type CreateExchangeTokenRequest struct {
+	ProfileID  int  `json:"profile_id" binding:"required"`
+	TTLSeconds int  `json:"ttl_seconds,omitempty"` // 有効期間（秒）。省略時はEXCHANGE_TOKEN_EXPIRES_SECONDS
+	SingleUse  bool `json:"single_use,omitempty"`  // 1回使うと無効になる
+}
+
+// ExchangeTokenResponse は発行した交換トークンと、それを埋め込んだQRコードです
+type ExchangeTokenResponse struct {
+	Token     string    `json:"token"`
+	URL       string    `json:"url"`     // QRコードに埋め込んだURL（フロントエンドの交換ページ）
+	QRData    string    `json:"qr_data"` // PNGのdata URI
+	ExpiresAt time.Time `json:"expires_at"`
+	SingleUse bool      `json:"single_use"`
+}
+
+// ExchangeTokenPreviewRequest は交換トークンの内容確認リクエストです
+type ExchangeTokenPreviewRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// RedeemExchangeTokenRequest は交換トークンを使ったコネクション作成リクエストです（相互交換と共通）
+type RedeemExchangeTokenRequest struct {
+	Token     string `json:"token" binding:"required"`
+	ProfileID int    `json:"profile_id" binding:"required"` // 交換に使う自分のプロフィール
+	EventName string `json:"event_name,omitempty"`
+	EventDate string `json:"event_date,omitempty"`
+	Memo      string `json:"memo,omitempty"`
+}
+
+// ExchangeResponse は相互交換の結果です
+type ExchangeResponse struct {
+	Connection        Connection `json:"connection"`         // 自分のプロフィールから相手へのコネクション
+	ReverseConnection Connection `json:"reverse_connection"` // 相手のプロフィールから自分へのコネクション
+	Profile           *Profile   `json:"profile"`            // 交換した相手のプロフィール
+	AlreadyExisted    bool       `json:"already_existed"`    // 既に双方向とも交換済みだった
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Session はサインインごとのセッションを表します
+// IDはリフレッシュトークンのFamilyID・アクセストークンのsidクレームと同じ値です
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     int        `json:"-"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"` // リフレッシュトークンを更新するたびに延長されます
+	RevokedAt  *time.Time `json:"-"`
+	Current    bool       `json:"current"` // リクエストに使ったトークンのセッションかどうか
+}
+
+// Active はセッションが失効しておらず有効期限内かどうかを返します
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// SessionListResponse はセッション一覧のレスポンスを表します
+type SessionListResponse struct {
+	Sessions []Session `json:"sessions"`
+}
+
+// RevokeSessionsResponse は他のセッションをまとめて失効させた結果を表します
+type RevokeSessionsResponse struct {
+	Revoked int `json:"revoked"`
+}
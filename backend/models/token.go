@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// RefreshToken はローテーションされるリフレッシュトークンを表します
+// 同じサインインから派生したトークンは同じFamilyIDを持ちます
+type RefreshToken struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	FamilyID  string     `json:"family_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`    // ローテーション済みの場合に設定
+	RevokedAt *time.Time `json:"revoked_at,omitempty"` // 失効済みの場合に設定
+}
+
+// RefreshTokenRequest はトークン更新リクエストを表します
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// SignOutRequest はサインアウトリクエストを表します
+type SignOutRequest struct {
+	RefreshToken string `json:"refresh_token,omitempty"` // 任意。指定した場合はそのトークン系列も失効させます
+}
+
+// AuthResponse は認証成功時のレスポンスを表します
+type AuthResponse struct {
+	User         User   `json:"user"`
+	Token        string `json:"token"`         // アクセストークン
+	RefreshToken string `json:"refresh_token"` // リフレッシュトークン
+	ExpiresIn    int    `json:"expires_in"`    // アクセストークンの有効秒数
+}
+
+// OneTimeToken の用途
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+)
+
+// OneTimeToken はメールで送る使い捨てトークンを表します（パスワードリセットなど）
+type OneTimeToken struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	Purpose   string     `json:"purpose"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
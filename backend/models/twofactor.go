@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// TOTPEnrollment はユーザーのTOTP設定を表します
+type TOTPEnrollment struct {
+	UserID       int        `json:"user_id"`
+	Secret       string     `json:"-"`            // Base32エンコードした共有シークレット
+	ConfirmedAt  *time.Time `json:"confirmed_at"` // 確認コードの検証が済んで有効になった日時（登録手続き中はnull）
+	LastUsedStep int64      `json:"-"`            // 最後に使われたタイムステップ（同じコードの再利用を防ぐ）
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// Enabled は二要素認証が有効かどうかを返します
+func (e *TOTPEnrollment) Enabled() bool {
+	return e != nil && e.ConfirmedAt != nil
+}
+
+// TOTPEnrollResponse はTOTP登録開始時のレスポンスを表します
+type TOTPEnrollResponse struct {
+	Secret     string `json:"secret"`      // 手入力用のシークレット
+	OTPAuthURI string `json:"otpauth_uri"` // 認証アプリに登録するURI
+	QRData     string `json:"qr_data"`     // otpauth URIのQRコード（data URI形式のPNG）
+}
+
+// TOTPCodeRequest は認証コードを送るリクエストを表します（TOTPコードまたはリカバリーコード）
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableTOTPRequest は二要素認証の解除リクエストを表します
+type DisableTOTPRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// TwoFactorSignInRequest は二段階目のサインインリクエストを表します
+type TwoFactorSignInRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// TwoFactorChallengeResponse は二要素認証が必要な場合のサインインレスポンスを表します
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int    `json:"expires_in"`
+}
+
+// RecoveryCodesResponse は発行したリカバリーコードを返すレスポンスを表します（平文を返すのはこの時だけ）
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TwoFactorStatusResponse は二要素認証の設定状況を表します
+type TwoFactorStatusResponse struct {
+	Enabled                bool       `json:"enabled"`
+	ConfirmedAt            *time.Time `json:"confirmed_at"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+}
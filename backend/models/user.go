@@ -1,7 +1,48 @@
 package models
 
+import "time"
+
 type User struct {
-	ID    int    `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID               int        `json:"id"`
+	Name             string     `json:"name"`
+	Email            string     `json:"email"`
+	PasswordHash     string     `json:"-"`                           // bcryptハッシュ（レスポンスには含めない）
+	EmailVerifiedAt  *time.Time `json:"email_verified_at"`           // メールアドレス確認日時（未確認の場合はnull）
+	Role             Role       `json:"role"`                        // ロール（user・moderator・admin）
+	SuspendedAt      *time.Time `json:"suspended_at,omitempty"`      // アカウント停止日時（停止していない場合はnull）
+	SuspensionReason string     `json:"suspension_reason,omitempty"` // 停止理由
+
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"` // アカウント削除の予定日時（予約していない場合はnull）
+}
+
+// Role はユーザーのロールを表します
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// roleRanks はロールの強さです（大きいほど権限が強い）
+var roleRanks = map[Role]int{RoleUser: 1, RoleModerator: 2, RoleAdmin: 3}
+
+// Valid はロールが定義済みの値かどうかを返します
+func (r Role) Valid() bool {
+	return roleRanks[r] > 0
+}
+
+// AtLeast はロールがminRole以上の権限を持つかどうかを返します
+func (r Role) AtLeast(minRole Role) bool {
+	return r.Valid() && roleRanks[r] >= roleRanks[minRole]
+}
+
+// Outranks はロールがotherより強い権限を持つかどうかを返します（管理操作の対象にできるかの判定に使います）
+func (r Role) Outranks(other Role) bool {
+	return roleRanks[r] > roleRanks[other]
+}
+
+// Suspended はアカウントが停止中かどうかを返します
+func (u *User) Suspended() bool {
+	return u.SuspendedAt != nil
 }
@@ -0,0 +1,70 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// GitHubProvider はGitHubのOAuth Appでログインするプロバイダーです
+// GitHubはOIDCに対応していないため、REST APIでユーザー情報と確認済みのメールアドレスを取得します
+type GitHubProvider struct {
+	ClientID     string
+	ClientSecret string
+	BaseURL      string // 認可・トークンエンドポイントのベースURL（https://github.com）
+	APIURL       string // REST APIのベースURL（https://api.github.com）
+	Client       *http.Client
+}
+
+func (p *GitHubProvider) Name() string {
+	return "github"
+}
+
+func (p *GitHubProvider) AuthCodeURL(ctx context.Context, state, codeChallenge, redirectURI string) (string, error) {
+	return authCodeURL(p.BaseURL+"/login/oauth/authorize", p.ClientID, redirectURI, "read:user user:email", state, codeChallenge)
+}
+
+func (p *GitHubProvider) Exchange(ctx context.Context, code, codeVerifier, redirectURI string) (*Identity, error) {
+	accessToken, err := exchangeCode(ctx, p.Client, p.BaseURL+"/login/oauth/access_token",
+		p.ClientID, p.ClientSecret, code, codeVerifier, redirectURI)
+	if err != nil {
+		return nil, err
+	}
+
+	var user struct {
+		ID      int64  `json:"id"`
+		Login   string `json:"login"`
+		Name    string `json:"name"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := getJSON(ctx, p.Client, p.APIURL+"/user", accessToken, &user); err != nil {
+		return nil, err
+	}
+
+	// プロフィールに公開しているメールアドレスは未確認の場合があるため、確認済みのプライマリアドレスを使う
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, p.Client, p.APIURL+"/user/emails", accessToken, &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Subject:    strconv.FormatInt(user.ID, 10),
+		Name:       user.Name,
+		Username:   user.Login,
+		ProfileURL: user.HTMLURL,
+	}
+	if identity.Name == "" {
+		identity.Name = user.Login
+	}
+	for _, e := range emails {
+		if e.Primary {
+			identity.Email = e.Email
+			identity.EmailVerified = e.Verified
+		}
+	}
+	return identity, nil
+}
@@ -0,0 +1,102 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// OIDCProvider はOpenID Connectに対応したプロバイダー（Googleなど）です
+// エンドポイントは Issuer の /.well-known/openid-configuration から取得します
+type OIDCProvider struct {
+	ProviderName string
+	ClientID     string
+	ClientSecret string
+	Issuer       string
+	Client       *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+}
+
+// oidcDiscovery はDiscoveryドキュメントのうち使用する項目です
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+func (p *OIDCProvider) Name() string {
+	return p.ProviderName
+}
+
+// discover はDiscoveryドキュメントを取得します（成功した結果だけをキャッシュします）
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	var d oidcDiscovery
+	if err := getJSON(ctx, p.Client, strings.TrimRight(p.Issuer, "/")+"/.well-known/openid-configuration", "", &d); err != nil {
+		return nil, err
+	}
+	// なりすましたDiscoveryドキュメントを使わないよう、issuerが設定と一致することを確認する
+	if strings.TrimRight(d.Issuer, "/") != strings.TrimRight(p.Issuer, "/") {
+		return nil, fmt.Errorf("issuerが一致しません: %s", d.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.UserinfoEndpoint == "" {
+		return nil, errors.New("Discoveryドキュメントに必要なエンドポイントがありません")
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, codeChallenge, redirectURI string) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return authCodeURL(d.AuthorizationEndpoint, p.ClientID, redirectURI, "openid email profile", state, codeChallenge)
+}
+
+// Exchange は認可コードを交換し、UserInfoエンドポイントからアカウント情報を取得します
+// トークンはTLSで直接トークンエンドポイントから受け取るため、IDトークンの署名検証の代わりにUserInfoを使います
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier, redirectURI string) (*Identity, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, err := exchangeCode(ctx, p.Client, d.TokenEndpoint,
+		p.ClientID, p.ClientSecret, code, codeVerifier, redirectURI)
+	if err != nil {
+		return nil, err
+	}
+
+	var info struct {
+		Subject       string          `json:"sub"`
+		Email         string          `json:"email"`
+		EmailVerified json.RawMessage `json:"email_verified"`
+		Name          string          `json:"name"`
+	}
+	if err := getJSON(ctx, p.Client, d.UserinfoEndpoint, accessToken, &info); err != nil {
+		return nil, err
+	}
+	if info.Subject == "" {
+		return nil, errors.New("UserInfoにsubがありません")
+	}
+
+	return &Identity{
+		Subject: info.Subject,
+		Email:   info.Email,
+		// プロバイダーによっては "true" のように文字列で返すため両方を受け付ける
+		EmailVerified: string(info.EmailVerified) == "true" || string(info.EmailVerified) == `"true"`,
+		Name:          info.Name,
+	}, nil
+}
@@ -0,0 +1,190 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Identity はプロバイダーから取得したアカウント情報です
+type Identity struct {
+	Subject       string // プロバイダー内で一意なアカウントID
+	Email         string
+	EmailVerified bool
+	Name          string
+	Username      string // GitHubのログイン名など（ない場合は空）
+	ProfileURL    string // プロバイダー上のプロフィールページ（ない場合は空）
+}
+
+// Provider はOAuth2/OIDCの認可コードフロー（PKCE付き）でログインするプロバイダーです
+type Provider interface {
+	Name() string
+	// AuthCodeURL は利用者をリダイレクトする認可エンドポイントのURLを返します
+	AuthCodeURL(ctx context.Context, state, codeChallenge, redirectURI string) (string, error)
+	// Exchange は認可コードをアクセストークンに交換し、アカウント情報を取得します
+	Exchange(ctx context.Context, code, codeVerifier, redirectURI string) (*Identity, error)
+}
+
+// Providers はプロバイダー名からProviderを引く表です
+type Providers map[string]Provider
+
+// Names は設定済みのプロバイダー名を名前順で返します
+func (p Providers) Names() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ProvidersFromEnv は環境変数からプロバイダーを設定します（クライアントIDが未設定のプロバイダーは無効）
+//   - GitHub: OAUTH_GITHUB_CLIENT_ID / OAUTH_GITHUB_CLIENT_SECRET
+//     （OAUTH_GITHUB_URL / OAUTH_GITHUB_API_URL でGitHub Enterpriseやモックサーバーを指定可）
+//   - Google: OAUTH_GOOGLE_CLIENT_ID / OAUTH_GOOGLE_CLIENT_SECRET
+//     （OAUTH_GOOGLE_ISSUER で別のOIDCプロバイダーやモックサーバーを指定可）
+func ProvidersFromEnv() Providers {
+	client := &http.Client{Timeout: 10 * time.Second}
+	providers := Providers{}
+
+	if id := os.Getenv("OAUTH_GITHUB_CLIENT_ID"); id != "" {
+		providers["github"] = &GitHubProvider{
+			ClientID:     id,
+			ClientSecret: os.Getenv("OAUTH_GITHUB_CLIENT_SECRET"),
+			BaseURL:      envOr("OAUTH_GITHUB_URL", "https://github.com"),
+			APIURL:       envOr("OAUTH_GITHUB_API_URL", "https://api.github.com"),
+			Client:       client,
+		}
+	}
+	if id := os.Getenv("OAUTH_GOOGLE_CLIENT_ID"); id != "" {
+		providers["google"] = &OIDCProvider{
+			ProviderName: "google",
+			ClientID:     id,
+			ClientSecret: os.Getenv("OAUTH_GOOGLE_CLIENT_SECRET"),
+			Issuer:       envOr("OAUTH_GOOGLE_ISSUER", "https://accounts.google.com"),
+			Client:       client,
+		}
+	}
+	return providers
+}
+
+func envOr(key, defaultValue string) string {
+	if v := os.Getenv(key); v != "" {
+		return strings.TrimRight(v, "/")
+	}
+	return defaultValue
+}
+
+// NewPKCE はPKCEのcode_verifierとS256のcode_challengeを生成します（RFC 7636）
+func NewPKCE() (verifier, challenge string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("code_verifierの生成に失敗しました: %v", err)
+	}
+	verifier = base64.RawURLEncoding.EncodeToString(buf)
+	return verifier, CodeChallenge(verifier), nil
+}
+
+// CodeChallenge はcode_verifierからS256のcode_challengeを計算します
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// authCodeURL は認可エンドポイントのURLにクエリを付けて返します
+func authCodeURL(endpoint, clientID, redirectURI, scope, state, codeChallenge string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", clientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("scope", scope)
+	q.Set("state", state)
+	q.Set("code_challenge", codeChallenge)
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// exchangeCode はトークンエンドポイントで認可コードをアクセストークンに交換します
+func exchangeCode(ctx context.Context, client *http.Client, endpoint, clientID, clientSecret, code, codeVerifier, redirectURI string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("client_id", clientID)
+	form.Set("client_secret", clientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var res struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	// GitHubはエラーでも200を返すため、ステータスに関わらずボディを確認する
+	if err := doJSON(client, req, &res, true); err != nil {
+		return "", err
+	}
+	if res.Error != "" {
+		return "", fmt.Errorf("トークンの取得に失敗しました: %s %s", res.Error, res.ErrorDescription)
+	}
+	if res.AccessToken == "" {
+		return "", errors.New("トークンの取得に失敗しました: access_tokenがありません")
+	}
+	return res.AccessToken, nil
+}
+
+// getJSON はアクセストークン付きでGETし、JSONをoutにデコードします
+func getJSON(ctx context.Context, client *http.Client, endpoint, accessToken string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	return doJSON(client, req, out, false)
+}
+
+// doJSON はリクエストを送り、JSONレスポンスをoutにデコードします
+func doJSON(client *http.Client, req *http.Request, out interface{}, allowErrorStatus bool) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK && !allowErrorStatus {
+		return fmt.Errorf("%s がステータス %d を返しました", req.URL.Path, resp.StatusCode)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("%s のレスポンスが不正です: %v", req.URL.Path, err)
+	}
+	return nil
+}
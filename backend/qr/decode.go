@@ -0,0 +1,1010 @@
+package qr
+
+import (
+	"errors"
+	"image"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+// ErrNotFound は画像からQRコードを読み取れなかった場合のエラーです
+var ErrNotFound = errors.New("QRコードを読み取れませんでした")
+
+// Decode は画像に写っているQRコードを1つ読み取り、その内容を返します
+// 画面のスクリーンショットや印刷物を正面から撮影した画像を想定しています（多少の傾き・回転・遠近のゆがみには対応します）
+// 明るい地に暗いモジュールのQRコードだけを読み取り、マイクロQRや色を反転したQRコードには対応しません
+func Decode(img image.Image) (string, error) {
+	contents, err := decode(img, 1)
+	if err != nil {
+		return "", err
+	}
+	return contents[0], nil
+}
+
+// DecodeAll は画像に写っているQRコードをすべて読み取り、その内容を返します（1つも読み取れない場合はErrNotFound）
+func DecodeAll(img image.Image) ([]string, error) {
+	return decode(img, maxSymbols)
+}
+
+// maxSymbols は1枚の画像から読み取るQRコードの上限です
+const maxSymbols = 8
+
+func decode(img image.Image, limit int) ([]string, error) {
+	gray := toGray(img)
+	for _, matrix := range []*bitMatrix{gray.globalThreshold(), gray.localThreshold()} {
+		if contents := matrix.decode(limit); len(contents) > 0 {
+			return contents, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// grayImage は画像の輝度（0〜255）です
+type grayImage struct {
+	width, height int
+	pix           []uint8
+}
+
+// toGray は画像を輝度に変換します（透過部分は白地に重ねた色として扱います）
+func toGray(img image.Image) *grayImage {
+	b := img.Bounds()
+	g := &grayImage{width: b.Dx(), height: b.Dy(), pix: make([]uint8, b.Dx()*b.Dy())}
+	for y := 0; y < g.height; y++ {
+		for x := 0; x < g.width; x++ {
+			r, gr, bl, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			white := 0xFFFF - a
+			lum := (299*(r+white) + 587*(gr+white) + 114*(bl+white)) / 1000
+			g.pix[y*g.width+x] = uint8(min(lum, 0xFFFF) >> 8)
+		}
+	}
+	return g
+}
+
+// otsuThreshold は判別分析法で輝度のしきい値を求めます
+func (g *grayImage) otsuThreshold() int {
+	var hist [256]int
+	for _, v := range g.pix {
+		hist[v]++
+	}
+	total := len(g.pix)
+	var sum float64
+	for i, n := range hist {
+		sum += float64(i * n)
+	}
+	var sumBelow, best float64
+	threshold, below := 0, 0
+	for t, n := range hist {
+		below += n
+		if below == 0 {
+			continue
+		}
+		above := total - below
+		if above == 0 {
+			break
+		}
+		sumBelow += float64(t * n)
+		meanBelow := sumBelow / float64(below)
+		meanAbove := (sum - sumBelow) / float64(above)
+		variance := float64(below) * float64(above) * (meanBelow - meanAbove) * (meanBelow - meanAbove)
+		if variance > best {
+			best, threshold = variance, t
+		}
+	}
+	return threshold
+}
+
+// globalThreshold は画像全体で1つのしきい値を使って二値化します
+func (g *grayImage) globalThreshold() *bitMatrix {
+	t := g.otsuThreshold()
+	m := newBitMatrix(g.width, g.height)
+	for i, v := range g.pix {
+		m.bits[i] = int(v) <= t
+	}
+	return m
+}
+
+// localThreshold は周囲の平均輝度と比べて二値化します（撮影した画像の明るさのむらに対応するため）
+// 周囲の明るさがほぼ一様な部分は全体のしきい値で判定します
+func (g *grayImage) localThreshold() *bitMatrix {
+	w, h := g.width, g.height
+	integral := make([]int, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		row := 0
+		for x := 0; x < w; x++ {
+			row += int(g.pix[y*w+x])
+			integral[(y+1)*(w+1)+x+1] = integral[y*(w+1)+x+1] + row
+		}
+	}
+	global := g.otsuThreshold()
+	radius := max(min(w, h)/8, 8)
+	m := newBitMatrix(w, h)
+	for y := 0; y < h; y++ {
+		y0, y1 := max(y-radius, 0), min(y+radius+1, h)
+		for x := 0; x < w; x++ {
+			x0, x1 := max(x-radius, 0), min(x+radius+1, w)
+			sum := integral[y1*(w+1)+x1] - integral[y0*(w+1)+x1] - integral[y1*(w+1)+x0] + integral[y0*(w+1)+x0]
+			mean := sum / ((x1 - x0) * (y1 - y0))
+			v := int(g.pix[y*w+x])
+			switch {
+			case v < mean-10:
+				m.bits[y*w+x] = true
+			case v <= mean+10:
+				m.bits[y*w+x] = v <= global
+			}
+		}
+	}
+	return m
+}
+
+// bitMatrix は二値化した画像です（trueが暗い画素）
+type bitMatrix struct {
+	width, height int
+	bits          []bool
+}
+
+func newBitMatrix(width, height int) *bitMatrix {
+	return &bitMatrix{width: width, height: height, bits: make([]bool, width*height)}
+}
+
+// get は画素が暗いかどうかを返します（画像の外は明るい画素として扱います）
+func (m *bitMatrix) get(x, y int) bool {
+	if x < 0 || y < 0 || x >= m.width || y >= m.height {
+		return false
+	}
+	return m.bits[y*m.width+x]
+}
+
+// point は画像上の座標です
+type point struct {
+	x, y float64
+}
+
+func distance(a, b point) float64 {
+	return math.Hypot(a.x-b.x, a.y-b.y)
+}
+
+// finderPattern は位置検出パターンの候補です
+type finderPattern struct {
+	point
+	moduleSize float64
+	count      int // 検出した走査線の数
+}
+
+// maxFinderCandidates は組み合わせを試す位置検出パターンの候補の数です
+const maxFinderCandidates = 3 * maxSymbols
+
+// maxDecodeAttempts は読み取りを試す位置検出パターンの組み合わせの数です
+const maxDecodeAttempts = 48
+
+// decode は二値化した画像からQRコードを探し、最大limit個読み取ります
+// 読み取れたQRコードの位置検出パターンは、他の組み合わせには使いません
+func (m *bitMatrix) decode(limit int) []string {
+	candidates := m.findFinderPatterns()
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].count > candidates[j].count })
+	if len(candidates) > maxFinderCandidates {
+		candidates = candidates[:maxFinderCandidates]
+	}
+
+	var contents []string
+	used := make([]bool, len(candidates))
+	for i, triple := range finderTriples(candidates) {
+		if i >= maxDecodeAttempts || len(contents) >= limit {
+			break
+		}
+		if used[triple.ids[0]] || used[triple.ids[1]] || used[triple.ids[2]] {
+			continue
+		}
+		if content, err := m.decodeAt(triple); err == nil {
+			contents = append(contents, content)
+			for _, id := range triple.ids {
+				used[id] = true
+			}
+		}
+	}
+	return contents
+}
+
+// findFinderPatterns は横方向に 1:1:3:1:1 の暗・明・暗・明・暗の並びを探し、縦方向にも同じ並びであるものを位置検出パターンの候補とします
+func (m *bitMatrix) findFinderPatterns() []finderPattern {
+	var found []finderPattern
+	for y := 0; y < m.height; y++ {
+		var counts [5]int
+		state := 0
+		for x := 0; x <= m.width; x++ {
+			if m.get(x, y) {
+				if state%2 == 1 {
+					state++
+				}
+				counts[state]++
+				continue
+			}
+			if state%2 == 1 {
+				counts[state]++
+				continue
+			}
+			if state < 4 {
+				state++
+				counts[state]++
+				continue
+			}
+			if finderRatio(counts) {
+				if p, ok := m.confirmFinder(counts, x, y); ok {
+					found = mergeFinder(found, p)
+					counts, state = [5]int{}, 0
+					continue
+				}
+			}
+			counts, state = [5]int{counts[2], counts[3], counts[4], 1, 0}, 3
+		}
+	}
+	return found
+}
+
+// finderRatio は暗・明・暗・明・暗の長さが 1:1:3:1:1 に近いかどうかを返します
+func finderRatio(counts [5]int) bool {
+	total := 0
+	for _, c := range counts {
+		if c == 0 {
+			return false
+		}
+		total += c
+	}
+	if total < 7 {
+		return false
+	}
+	module := float64(total) / 7
+	variance := module / 2
+	for i, c := range counts {
+		expected := module
+		if i == 2 {
+			expected = 3 * module
+		}
+		if math.Abs(expected-float64(c)) >= variance*expected/module {
+			return false
+		}
+	}
+	return true
+}
+
+// crossCheck は(x, y)から(dx, dy)の方向とその逆方向に暗・明・暗・明・暗の並びを数え、1:1:3:1:1であれば中心の位置（(x, y)からの距離）を返します
+func (m *bitMatrix) crossCheck(x, y, dx, dy, maxCount int) (center float64, total int, ok bool) {
+	dark := func(t int) bool { return m.get(x+t*dx, y+t*dy) }
+	var counts [5]int
+
+	t := 0
+	for ; dark(t) && counts[2] <= maxCount; t-- {
+		counts[2]++
+	}
+	for ; !dark(t) && counts[1] <= maxCount; t-- {
+		counts[1]++
+	}
+	for ; dark(t) && counts[0] <= maxCount; t-- {
+		counts[0]++
+	}
+
+	t = 1
+	for ; dark(t) && counts[2] <= maxCount; t++ {
+		counts[2]++
+	}
+	for ; !dark(t) && counts[3] <= maxCount; t++ {
+		counts[3]++
+	}
+	for ; dark(t) && counts[4] <= maxCount; t++ {
+		counts[4]++
+	}
+
+	if !finderRatio(counts) {
+		return 0, 0, false
+	}
+	for _, c := range counts {
+		total += c
+	}
+	return float64(t-counts[4]-counts[3]) - float64(counts[2])/2, total, true
+}
+
+// confirmFinder は横方向に見つけた並びの中心から縦・横に確認し、位置検出パターンの中心とモジュールの大きさを求めます
+func (m *bitMatrix) confirmFinder(counts [5]int, endX, y int) (finderPattern, bool) {
+	horizontal := 0
+	for _, c := range counts {
+		horizontal += c
+	}
+	centerX := float64(endX-counts[4]-counts[3]) - float64(counts[2])/2
+
+	offsetY, vertical, ok := m.crossCheck(int(centerX), y, 0, 1, horizontal)
+	if !ok || 5*abs(vertical-horizontal) >= 2*horizontal {
+		return finderPattern{}, false
+	}
+	centerY := float64(y) + offsetY
+	offsetX, horizontal2, ok := m.crossCheck(int(centerX), int(centerY), 1, 0, horizontal)
+	if !ok || 5*abs(horizontal2-horizontal) >= 2*horizontal {
+		return finderPattern{}, false
+	}
+	centerX = math.Floor(centerX) + offsetX
+
+	return finderPattern{
+		point:      point{centerX, centerY},
+		moduleSize: float64(horizontal2+vertical) / 14,
+		count:      1,
+	}, true
+}
+
+// mergeFinder は同じ位置検出パターンを別の走査線で見つけた場合に平均をとってまとめます
+func mergeFinder(found []finderPattern, p finderPattern) []finderPattern {
+	for i, f := range found {
+		if math.Abs(f.x-p.x) <= f.moduleSize && math.Abs(f.y-p.y) <= f.moduleSize &&
+			math.Abs(f.moduleSize-p.moduleSize) <= math.Max(1, f.moduleSize/2) {
+			n := float64(f.count)
+			found[i] = finderPattern{
+				point:      point{(f.x*n + p.x) / (n + 1), (f.y*n + p.y) / (n + 1)},
+				moduleSize: (f.moduleSize*n + p.moduleSize) / (n + 1),
+				count:      f.count + 1,
+			}
+			return found
+		}
+	}
+	return append(found, p)
+}
+
+// finderTriple は左上・右上・左下の位置検出パターンの組み合わせです
+type finderTriple struct {
+	topLeft, topRight, bottomLeft finderPattern
+	score                         float64 // 直角二等辺三角形からのずれ（小さいほどよい）
+	ids                           [3]int  // 候補のインデックス
+}
+
+// finderTriples は候補から直角二等辺三角形に近い3つの組み合わせを、近い順に返します
+func finderTriples(candidates []finderPattern) []finderTriple {
+	var triples []finderTriple
+	for i := 0; i < len(candidates); i++ {
+		for j := i + 1; j < len(candidates); j++ {
+			for k := j + 1; k < len(candidates); k++ {
+				if t, ok := orderFinders(candidates[i], candidates[j], candidates[k]); ok {
+					t.ids = [3]int{i, j, k}
+					triples = append(triples, t)
+				}
+			}
+		}
+	}
+	sort.Slice(triples, func(i, j int) bool { return triples[i].score < triples[j].score })
+	return triples
+}
+
+// orderFinders は3つのパターンを左上・右上・左下に並べます（直角の頂点を左上とし、画像が回転していても向きを合わせます）
+func orderFinders(a, b, c finderPattern) (finderTriple, bool) {
+	sizes := []float64{a.moduleSize, b.moduleSize, c.moduleSize}
+	sort.Float64s(sizes)
+	if sizes[2] > sizes[0]*1.5 {
+		return finderTriple{}, false
+	}
+
+	ab, bc, ca := distance(a.point, b.point), distance(b.point, c.point), distance(c.point, a.point)
+	// 斜辺の向かいの頂点が左上
+	switch {
+	case bc >= ab && bc >= ca:
+		// aが直角の頂点
+	case ca >= ab && ca >= bc:
+		a, b = b, a
+		bc, ca = ca, bc
+	default:
+		a, c = c, a
+		ab, bc = bc, ab
+	}
+	legs := []float64{ab, ca}
+	hypotenuse := bc
+	if math.Min(legs[0], legs[1]) < 8*sizes[1] {
+		return finderTriple{}, false
+	}
+	legRatio := math.Abs(legs[0]-legs[1]) / math.Max(legs[0], legs[1])
+	angle := math.Abs(hypotenuse*hypotenuse-legs[0]*legs[0]-legs[1]*legs[1]) / (hypotenuse * hypotenuse)
+	if legRatio > 0.2 || angle > 0.25 {
+		return finderTriple{}, false
+	}
+
+	// 画像の座標系（yが下向き）で、左上から右上・左下への外積が正になる向きにする
+	if (b.x-a.x)*(c.y-a.y)-(b.y-a.y)*(c.x-a.x) < 0 {
+		b, c = c, b
+	}
+	return finderTriple{topLeft: a, topRight: b, bottomLeft: c, score: legRatio + angle}, true
+}
+
+// decodeAt は位置検出パターンの組み合わせからシンボルの大きさを推定し、読み取りを試みます
+func (m *bitMatrix) decodeAt(t finderTriple) (string, error) {
+	moduleSize := (t.topLeft.moduleSize + t.topRight.moduleSize + t.bottomLeft.moduleSize) / 3
+	// 位置検出パターンは縦横に走査して測っているので、回転している分だけ大きく測れている
+	angle := math.Atan2(t.topRight.y-t.topLeft.y, t.topRight.x-t.topLeft.x)
+	moduleSize *= math.Max(math.Abs(math.Cos(angle)), math.Abs(math.Sin(angle)))
+	modules := (distance(t.topLeft.point, t.topRight.point) + distance(t.topLeft.point, t.bottomLeft.point)) / (2 * moduleSize)
+	estimate := int(math.Round(modules)) + 7
+	switch estimate % 4 {
+	case 0:
+		estimate++
+	case 2:
+		estimate--
+	case 3:
+		estimate -= 2
+	}
+
+	err := ErrNotFound
+	for _, dimension := range []int{estimate, estimate + 4, estimate - 4} {
+		if dimension < 21 || dimension > 177 {
+			continue
+		}
+		for _, transform := range m.transforms(t, dimension, moduleSize) {
+			var content string
+			content, err = readGrid(m.sample(transform, dimension))
+			if err == nil {
+				return content, nil
+			}
+		}
+	}
+	return "", err
+}
+
+// transform はモジュールの座標（左上を原点とし、1モジュールを1とする）から画像上の座標への変換です
+type transform func(u, v float64) point
+
+// affine は3つの位置検出パターンの中心から求めた変換です
+func affine(t finderTriple, dimension int) transform {
+	span := float64(dimension - 7)
+	ex := point{(t.topRight.x - t.topLeft.x) / span, (t.topRight.y - t.topLeft.y) / span}
+	ey := point{(t.bottomLeft.x - t.topLeft.x) / span, (t.bottomLeft.y - t.topLeft.y) / span}
+	return func(u, v float64) point {
+		return point{t.topLeft.x + (u-3.5)*ex.x + (v-3.5)*ey.x, t.topLeft.y + (u-3.5)*ex.y + (v-3.5)*ey.y}
+	}
+}
+
+// transforms は試す変換を返します。右下の位置合わせパターンが見つかれば、それも使った射影変換（遠近のゆがみに対応）を先に試します
+func (m *bitMatrix) transforms(t finderTriple, dimension int, moduleSize float64) []transform {
+	base := affine(t, dimension)
+	if dimension == 21 {
+		return []transform{base}
+	}
+	// 右下の位置合わせパターンの中心は、下端・右端から7モジュール目
+	corner := float64(dimension) - 6.5
+	alignment, ok := m.findAlignment(base, corner, moduleSize)
+	if !ok {
+		return []transform{base}
+	}
+	d := float64(dimension)
+	perspective, ok := perspectiveTransform(
+		[4]point{{3.5, 3.5}, {d - 3.5, 3.5}, {3.5, d - 3.5}, {corner, corner}},
+		[4]point{t.topLeft.point, t.topRight.point, t.bottomLeft.point, alignment},
+	)
+	if !ok {
+		return []transform{base}
+	}
+	return []transform{perspective, base}
+}
+
+// findAlignment は予想した位置の周辺で、暗・明・暗の同心の正方形（5×5モジュール）に最もよく一致する位置を探します
+func (m *bitMatrix) findAlignment(base transform, corner, moduleSize float64) (point, bool) {
+	center := base(corner, corner)
+	// 周辺のモジュール1つ分の画像上のベクトル
+	origin := base(0, 0)
+	ex, ey := base(1, 0), base(0, 1)
+	ex, ey = point{ex.x - origin.x, ex.y - origin.y}, point{ey.x - origin.x, ey.y - origin.y}
+
+	radius := 4 * moduleSize
+	step := math.Max(1, moduleSize/4)
+	best := 0
+	var sum point
+	var matches float64
+	for dy := -radius; dy <= radius; dy += step {
+		for dx := -radius; dx <= radius; dx += step {
+			c := point{center.x + dx, center.y + dy}
+			score := 0
+			for j := -2; j <= 2; j++ {
+				for i := -2; i <= 2; i++ {
+					x, y := c.x+float64(i)*ex.x+float64(j)*ey.x, c.y+float64(i)*ex.y+float64(j)*ey.y
+					ring := max(abs(i), abs(j))
+					if m.get(int(math.Floor(x)), int(math.Floor(y))) == (ring != 1) {
+						score++
+					}
+				}
+			}
+			switch {
+			case score > best:
+				best, sum, matches = score, c, 1
+			case score == best:
+				sum, matches = point{sum.x + c.x, sum.y + c.y}, matches+1
+			}
+		}
+	}
+	if best < 24 {
+		return point{}, false
+	}
+	return point{sum.x / matches, sum.y / matches}, true
+}
+
+// perspectiveTransform は4組の対応する点から射影変換を求めます
+func perspectiveTransform(from, to [4]point) (transform, bool) {
+	// x = (a u + b v + c) / (g u + h v + 1), y = (d u + e v + f) / (g u + h v + 1)
+	var a [8][9]float64
+	for i := 0; i < 4; i++ {
+		u, v, x, y := from[i].x, from[i].y, to[i].x, to[i].y
+		a[2*i] = [9]float64{u, v, 1, 0, 0, 0, -u * x, -v * x, x}
+		a[2*i+1] = [9]float64{0, 0, 0, u, v, 1, -u * y, -v * y, y}
+	}
+	for col := 0; col < 8; col++ {
+		pivot := col
+		for row := col + 1; row < 8; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return nil, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		for row := 0; row < 8; row++ {
+			if row == col {
+				continue
+			}
+			f := a[row][col] / a[col][col]
+			for k := col; k < 9; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+		}
+	}
+	var p [8]float64
+	for i := range p {
+		p[i] = a[i][8] / a[i][i]
+	}
+	return func(u, v float64) point {
+		w := p[6]*u + p[7]*v + 1
+		return point{(p[0]*u + p[1]*v + p[2]) / w, (p[3]*u + p[4]*v + p[5]) / w}
+	}, true
+}
+
+// sample は各モジュールの中心の画素を読み取ります
+func (m *bitMatrix) sample(t transform, dimension int) [][]bool {
+	grid := make([][]bool, dimension)
+	for y := range grid {
+		grid[y] = make([]bool, dimension)
+		for x := range grid[y] {
+			p := t(float64(x)+0.5, float64(y)+0.5)
+			grid[y][x] = m.get(int(math.Floor(p.x)), int(math.Floor(p.y)))
+		}
+	}
+	return grid
+}
+
+// readGrid はモジュールの並びから形式情報・型番情報を読み取り、誤りを訂正してデータを取り出します
+func readGrid(grid [][]bool) (string, error) {
+	size := len(grid)
+	version := (size - 17) / 4
+	if version < 1 || version > 40 {
+		return "", ErrNotFound
+	}
+	level, mask, ok := readFormat(grid)
+	if !ok {
+		return "", ErrNotFound
+	}
+	if version >= 7 {
+		if v, ok := readVersion(grid); !ok || v != version {
+			return "", ErrNotFound
+		}
+	}
+
+	l := layout(version, level)
+	codewords := readCodewords(grid, version, mask, l.totalCodewords())
+	data, err := deinterleave(codewords, l)
+	if err != nil {
+		return "", err
+	}
+	return parseSegments(data, version)
+}
+
+// bitsAt はモジュールの座標（x, y）の並びを上位ビットからの整数として読み取ります
+func bitsAt(grid [][]bool, coords [][2]int) int {
+	v := 0
+	for _, c := range coords {
+		v <<= 1
+		if grid[c[1]][c[0]] {
+			v |= 1
+		}
+	}
+	return v
+}
+
+// nearest はcodesの中でvとのハミング距離が3以下で最も近いもののインデックスを返します
+func nearest(v int, codes func(i int) int, n int) (int, bool) {
+	best, bestDistance := -1, 4
+	for i := 0; i < n; i++ {
+		if d := popcount(v ^ codes(i)); d < bestDistance {
+			best, bestDistance = i, d
+		}
+	}
+	return best, best >= 0
+}
+
+func popcount(v int) int {
+	n := 0
+	for ; v != 0; v &= v - 1 {
+		n++
+	}
+	return n
+}
+
+// formatCoords は2か所の形式情報のモジュールの座標をビット14から0の順に返します
+func formatCoords(size int) (first, second [][2]int) {
+	// 左上の形式情報
+	for i := 14; i >= 0; i-- {
+		switch {
+		case i < 6:
+			first = append(first, [2]int{8, i})
+		case i < 8:
+			first = append(first, [2]int{8, i + 1})
+		case i == 8:
+			first = append(first, [2]int{7, 8})
+		default:
+			first = append(first, [2]int{14 - i, 8})
+		}
+	}
+	// 右上・左下の形式情報
+	for i := 14; i >= 0; i-- {
+		if i < 8 {
+			second = append(second, [2]int{size - 1 - i, 8})
+		} else {
+			second = append(second, [2]int{8, size - 15 + i})
+		}
+	}
+	return first, second
+}
+
+// readFormat は2か所の形式情報を読み取り、誤り訂正レベルとマスクパターンを返します
+func readFormat(grid [][]bool) (Level, int, bool) {
+	first, second := formatCoords(len(grid))
+	code := func(i int) int { return formatInfo(Level(i>>3), i&7) }
+	for _, coords := range [][][2]int{first, second} {
+		if i, ok := nearest(bitsAt(grid, coords), code, 32); ok {
+			return Level(i >> 3), i & 7, true
+		}
+	}
+	return 0, 0, false
+}
+
+// versionCoords は2か所（右上・左下）の型番情報のモジュールの座標をビット17から0の順に返します
+func versionCoords(size int) (first, second [][2]int) {
+	for i := 17; i >= 0; i-- {
+		a, b := size-11+i%3, i/3
+		first = append(first, [2]int{a, b})
+		second = append(second, [2]int{b, a})
+	}
+	return first, second
+}
+
+// readVersion は2か所の型番情報を読み取ります
+func readVersion(grid [][]bool) (int, bool) {
+	first, second := versionCoords(len(grid))
+	code := func(i int) int { return versionInfo(i + 7) }
+	for _, coords := range [][][2]int{first, second} {
+		if i, ok := nearest(bitsAt(grid, coords), code, 34); ok {
+			return i + 7, true
+		}
+	}
+	return 0, false
+}
+
+// functionModules は位置検出パターン・タイミングパターン・位置合わせパターン・形式情報・型番情報のモジュールを表します
+func functionModules(version int) [][]bool {
+	size := version*4 + 17
+	f := make([][]bool, size)
+	for y := range f {
+		f[y] = make([]bool, size)
+	}
+	fill := func(x0, y0, w, h int) {
+		for y := y0; y < y0+h; y++ {
+			for x := x0; x < x0+w; x++ {
+				f[y][x] = true
+			}
+		}
+	}
+	fill(6, 0, 1, size)
+	fill(0, 6, size, 1)
+	fill(0, 0, 9, 9)
+	fill(size-8, 0, 8, 9)
+	fill(0, size-8, 9, 8)
+	positions := alignmentPositions(version)
+	last := len(positions) - 1
+	for i, py := range positions {
+		for j, px := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			fill(px-2, py-2, 5, 5)
+		}
+	}
+	if version >= 7 {
+		fill(size-11, 0, 3, 6)
+		fill(0, size-11, 6, 3)
+	}
+	return f
+}
+
+// maskBit はマスクパターンがモジュール（x, y）を反転するかどうかを返します
+func maskBit(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// dataModules はデータのモジュールの座標（x, y）を、右下から2列ずつ上下に往復する配置の順に返します
+func dataModules(version int) [][2]int {
+	size := version*4 + 17
+	function := functionModules(version)
+	var coords [][2]int
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < size; vert++ {
+			y := vert
+			if upward {
+				y = size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				if x := right - j; !function[y][x] {
+					coords = append(coords, [2]int{x, y})
+				}
+			}
+		}
+	}
+	return coords
+}
+
+// readCodewords はデータのモジュールを配置の順に読み取り、マスクを解除してコード語に戻します
+func readCodewords(grid [][]bool, version, mask, count int) []byte {
+	codewords := make([]byte, count)
+	for bit, c := range dataModules(version)[:count*8] {
+		x, y := c[0], c[1]
+		if grid[y][x] != maskBit(mask, x, y) {
+			codewords[bit/8] |= 0x80 >> (bit % 8)
+		}
+	}
+	return codewords
+}
+
+// deinterleave はインターリーブされたコード語をRSブロックに分け、誤りを訂正してデータコード語をつなげて返します
+func deinterleave(codewords []byte, l blockLayout) ([]byte, error) {
+	count := l.blocks1 + l.blocks2
+	blocks := make([][]byte, count)
+	dataSize := func(b int) int {
+		if b < l.blocks1 {
+			return l.data1
+		}
+		return l.data2
+	}
+	i := 0
+	for k := 0; k < max(l.data1, l.data2); k++ {
+		for b := range blocks {
+			if k < dataSize(b) {
+				blocks[b] = append(blocks[b], codewords[i])
+				i++
+			}
+		}
+	}
+	for k := 0; k < l.ecPerBlock; k++ {
+		for b := range blocks {
+			blocks[b] = append(blocks[b], codewords[i])
+			i++
+		}
+	}
+
+	var data []byte
+	for b, block := range blocks {
+		if _, err := correctErrors(block, l.ecPerBlock); err != nil {
+			return nil, err
+		}
+		data = append(data, block[:dataSize(b)]...)
+	}
+	return data, nil
+}
+
+// bitReader はデータコード語を上位ビットから読み取ります
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) remaining() int {
+	return len(r.data)*8 - r.pos
+}
+
+func (r *bitReader) read(n int) (int, bool) {
+	if n > r.remaining() {
+		return 0, false
+	}
+	v := 0
+	for i := 0; i < n; i++ {
+		v <<= 1
+		if r.data[r.pos/8]&(0x80>>(r.pos%8)) != 0 {
+			v |= 1
+		}
+		r.pos++
+	}
+	return v, true
+}
+
+// errBadSegment はデータの形式が不正な場合のエラーです
+var errBadSegment = errors.New("データの形式が不正です")
+
+const alphanumericChars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// ECIの指定値（Shift_JIS）
+const eciShiftJIS = 20
+
+// parseSegments はデータコード語のセグメント（数字・英数字・8ビットバイト・漢字）を読み取り、UTF-8の文字列にします
+func parseSegments(data []byte, version int) (string, error) {
+	r := &bitReader{data: data}
+	countBits := func(small, medium, large int) int {
+		switch {
+		case version <= 9:
+			return small
+		case version <= 26:
+			return medium
+		default:
+			return large
+		}
+	}
+
+	var b strings.Builder
+	eci := -1
+	for r.remaining() >= 4 {
+		mode, _ := r.read(4)
+		switch mode {
+		case 0x0: // 終端パターン
+			return b.String(), nil
+		case 0x1: // 数字
+			count, ok := r.read(countBits(10, 12, 14))
+			if !ok {
+				return "", errBadSegment
+			}
+			for ; count >= 3; count -= 3 {
+				v, ok := r.read(10)
+				if !ok || v >= 1000 {
+					return "", errBadSegment
+				}
+				b.WriteString(string([]byte{'0' + byte(v/100), '0' + byte(v/10%10), '0' + byte(v%10)}))
+			}
+			switch count {
+			case 2:
+				v, ok := r.read(7)
+				if !ok || v >= 100 {
+					return "", errBadSegment
+				}
+				b.WriteString(string([]byte{'0' + byte(v/10), '0' + byte(v%10)}))
+			case 1:
+				v, ok := r.read(4)
+				if !ok || v >= 10 {
+					return "", errBadSegment
+				}
+				b.WriteByte('0' + byte(v))
+			}
+		case 0x2: // 英数字
+			count, ok := r.read(countBits(9, 11, 13))
+			if !ok {
+				return "", errBadSegment
+			}
+			for ; count >= 2; count -= 2 {
+				v, ok := r.read(11)
+				if !ok || v >= 45*45 {
+					return "", errBadSegment
+				}
+				b.WriteByte(alphanumericChars[v/45])
+				b.WriteByte(alphanumericChars[v%45])
+			}
+			if count == 1 {
+				v, ok := r.read(6)
+				if !ok || v >= 45 {
+					return "", errBadSegment
+				}
+				b.WriteByte(alphanumericChars[v])
+			}
+		case 0x4: // 8ビットバイト
+			count, ok := r.read(countBits(8, 16, 16))
+			if !ok {
+				return "", errBadSegment
+			}
+			raw := make([]byte, count)
+			for i := range raw {
+				v, ok := r.read(8)
+				if !ok {
+					return "", errBadSegment
+				}
+				raw[i] = byte(v)
+			}
+			// UTF-8として不正なものはShift_JISとして扱う（国内の読み取りアプリに合わせる）
+			if eci == eciShiftJIS || !utf8.Valid(raw) {
+				if decoded, err := japanese.ShiftJIS.NewDecoder().Bytes(raw); err == nil {
+					raw = decoded
+				}
+			}
+			b.Write(raw)
+		case 0x8: // 漢字（Shift_JISの2バイト文字を13ビットで表す）
+			count, ok := r.read(countBits(8, 10, 12))
+			if !ok {
+				return "", errBadSegment
+			}
+			sjis := make([]byte, 0, count*2)
+			for i := 0; i < count; i++ {
+				v, ok := r.read(13)
+				if !ok {
+					return "", errBadSegment
+				}
+				code := v/0xC0<<8 | v%0xC0
+				if code < 0x1F00 {
+					code += 0x8140
+				} else {
+					code += 0xC140
+				}
+				sjis = append(sjis, byte(code>>8), byte(code))
+			}
+			decoded, err := japanese.ShiftJIS.NewDecoder().Bytes(sjis)
+			if err != nil {
+				return "", errBadSegment
+			}
+			b.Write(decoded)
+		case 0x7: // ECI
+			first, ok := r.read(8)
+			if !ok {
+				return "", errBadSegment
+			}
+			switch {
+			case first&0x80 == 0:
+				eci = first
+			case first&0xC0 == 0x80:
+				second, ok := r.read(8)
+				if !ok {
+					return "", errBadSegment
+				}
+				eci = (first&0x3F)<<8 | second
+			default:
+				rest, ok := r.read(16)
+				if !ok {
+					return "", errBadSegment
+				}
+				eci = (first&0x1F)<<16 | rest
+			}
+		case 0x3: // 連結（シンボルの番号とパリティは使わない）
+			if _, ok := r.read(16); !ok {
+				return "", errBadSegment
+			}
+		case 0x5: // FNC1（1番目の位置）
+		case 0x9: // FNC1（2番目の位置）
+			if _, ok := r.read(8); !ok {
+				return "", errBadSegment
+			}
+		default:
+			return "", errBadSegment
+		}
+	}
+	return b.String(), nil
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
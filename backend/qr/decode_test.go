@@ -0,0 +1,466 @@
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// byteCapacity はバージョン・誤り訂正レベルで8ビットバイトモードに入る最大のバイト数です
+func byteCapacity(version int, level Level) int {
+	l := layout(version, level)
+	data := l.blocks1*l.data1 + l.blocks2*l.data2
+	countBits := 8
+	if version > 9 {
+		countBits = 16
+	}
+	return (data*8 - 4 - countBits) / 8
+}
+
+// fillContent はn文字の英小文字の内容を返します（英小文字は8ビットバイトモードで符号化されます）
+func fillContent(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		b.WriteByte('a' + byte(i*7%26))
+	}
+	return b.String()
+}
+
+// encodeVersion は指定したバージョン・誤り訂正レベルになる長さの内容でQRコードを生成します
+func encodeVersion(t *testing.T, version int, level Level) *Code {
+	t.Helper()
+	opts := DefaultOptions()
+	opts.Level = level
+	opts.Size = (version*4 + 17 + 2*opts.QuietZone) * 3
+	code, err := Encode(fillContent(byteCapacity(version, level)), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := len(code.Modules); size != version*4+17 {
+		t.Fatalf("size = %d, want version %d", size, version)
+	}
+	return code
+}
+
+// copyGrid はモジュールの並びを複製します
+func copyGrid(grid [][]bool) [][]bool {
+	c := make([][]bool, len(grid))
+	for y, row := range grid {
+		c[y] = append([]bool{}, row...)
+	}
+	return c
+}
+
+// writeBits はvalueの下位のビットを上位から座標の順に書き込みます
+func writeBits(grid [][]bool, coords [][2]int, value int) {
+	for i, c := range coords {
+		grid[c[1]][c[0]] = value>>(len(coords)-1-i)&1 != 0
+	}
+}
+
+// remask はマスクパターンを掛け替え、2か所の形式情報を書き換えます
+func remask(grid [][]bool, version int, level Level, from, to int) [][]bool {
+	g := copyGrid(grid)
+	for _, c := range dataModules(version) {
+		x, y := c[0], c[1]
+		if maskBit(from, x, y) != maskBit(to, x, y) {
+			g[y][x] = !g[y][x]
+		}
+	}
+	first, second := formatCoords(len(g))
+	writeBits(g, first, formatInfo(level, to))
+	writeBits(g, second, formatInfo(level, to))
+	return g
+}
+
+// blockCodewords はRSブロックごとに、インターリーブした並びでのコード語の位置を返します
+func blockCodewords(l blockLayout) [][]int {
+	blocks := make([][]int, l.blocks1+l.blocks2)
+	dataSize := func(b int) int {
+		if b < l.blocks1 {
+			return l.data1
+		}
+		return l.data2
+	}
+	i := 0
+	for k := 0; k < max(l.data1, l.data2)+l.ecPerBlock; k++ {
+		for b := range blocks {
+			if k < dataSize(b) || k >= max(l.data1, l.data2) {
+				blocks[b] = append(blocks[b], i)
+				i++
+			}
+		}
+	}
+	return blocks
+}
+
+// flipCodeword はk番目のコード語のモジュール（modulesはdataModulesの結果）をすべて反転します
+func flipCodeword(grid [][]bool, modules [][2]int, k int) {
+	for _, c := range modules[k*8 : k*8+8] {
+		grid[c[1]][c[0]] = !grid[c[1]][c[0]]
+	}
+}
+
+// gridImage はモジュールの並びを1モジュールscaleピクセル・余白4モジュールの画像にします
+func gridImage(grid [][]bool, scale int) *image.Gray {
+	size := (len(grid) + 8) * scale
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.SetGray(x, y, color.Gray{Y: 0xFF})
+			mx, my := x/scale-4, y/scale-4
+			if mx >= 0 && my >= 0 && mx < len(grid) && my < len(grid) && grid[my][mx] {
+				img.SetGray(x, y, color.Gray{})
+			}
+		}
+	}
+	return img
+}
+
+func TestRoundTripAllVersionsAndLevels(t *testing.T) {
+	for version := 1; version <= 40; version++ {
+		for level := LevelL; level <= LevelH; level++ {
+			t.Run(fmt.Sprintf("%d-%s", version, level), func(t *testing.T) {
+				code := encodeVersion(t, version, level)
+				if got, err := readGrid(code.Modules); err != nil || got != code.Content {
+					t.Fatalf("readGrid = %.20q, %v", got, err)
+				}
+				if got, err := Decode(code.Image()); err != nil || got != code.Content {
+					t.Fatalf("Decode = %.20q, %v", got, err)
+				}
+			})
+		}
+	}
+}
+
+func TestRoundTripContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"numeric", "012345678901234567"},
+		{"alphanumeric", "HTTPS://QRSONA.EXAMPLE/P/AB-12"},
+		{"byte", "https://qrsona.example/p/abc?ref=qr"},
+		{"utf-8", "山田 花子（エンジニア）"},
+		{"emoji", "👋 hello"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for level := LevelL; level <= LevelH; level++ {
+				opts := DefaultOptions()
+				opts.Level = level
+				code, err := Encode(tt.content, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got, err := Decode(code.Image()); err != nil || got != tt.content {
+					t.Fatalf("%s: Decode = %q, %v", level, got, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMasks(t *testing.T) {
+	for _, version := range []int{1, 6, 7, 22, 40} {
+		for level := LevelL; level <= LevelH; level++ {
+			code := encodeVersion(t, version, level)
+			gotLevel, from, ok := readFormat(code.Modules)
+			if !ok || gotLevel != level {
+				t.Fatalf("version %d %s: readFormat = %s, %v", version, level, gotLevel, ok)
+			}
+			for mask := 0; mask < 8; mask++ {
+				grid := remask(code.Modules, version, level, from, mask)
+				if l, m, ok := readFormat(grid); !ok || l != level || m != mask {
+					t.Fatalf("version %d %s mask %d: readFormat = %s, %d, %v", version, level, mask, l, m, ok)
+				}
+				if got, err := readGrid(grid); err != nil || got != code.Content {
+					t.Fatalf("version %d %s mask %d: readGrid = %.20q, %v", version, level, mask, got, err)
+				}
+			}
+		}
+	}
+
+	// 縮小しても読めることを画像でも確認する
+	code := encodeVersion(t, 3, LevelQ)
+	_, from, _ := readFormat(code.Modules)
+	for mask := 0; mask < 8; mask++ {
+		if got, err := Decode(gridImage(remask(code.Modules, 3, LevelQ, from, mask), 4)); err != nil || got != code.Content {
+			t.Fatalf("mask %d: Decode = %.20q, %v", mask, got, err)
+		}
+	}
+}
+
+func TestFormatAndVersionErrors(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	code := encodeVersion(t, 8, LevelH)
+	level, mask, _ := readFormat(code.Modules)
+	first, second := formatCoords(len(code.Modules))
+
+	// 3ビットまでの誤りは訂正する
+	for n := 0; n <= 3; n++ {
+		grid := copyGrid(code.Modules)
+		writeBits(grid, first, formatInfo(level, mask)^flipBits(rng, 15, n))
+		if l, m, ok := readFormat(grid); !ok || l != level || m != mask {
+			t.Fatalf("%d format errors: %s, %d, %v", n, l, m, ok)
+		}
+	}
+	// 1か所目が壊れていても2か所目から読む
+	grid := copyGrid(code.Modules)
+	writeBits(grid, first, formatInfo(level, mask)^0x7F00)
+	if l, m, ok := readFormat(grid); !ok || l != level || m != mask {
+		t.Fatalf("second copy: %s, %d, %v", l, m, ok)
+	}
+	if got, err := readGrid(grid); err != nil || got != code.Content {
+		t.Fatalf("second copy: readGrid = %.20q, %v", got, err)
+	}
+	// 2か所とも壊れていれば読めない
+	writeBits(grid, second, formatInfo(level, mask)^0x7F00)
+	if _, err := readGrid(grid); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("both copies: err = %v", err)
+	}
+
+	vFirst, vSecond := versionCoords(len(code.Modules))
+	for n := 0; n <= 3; n++ {
+		grid := copyGrid(code.Modules)
+		writeBits(grid, vFirst, versionInfo(8)^flipBits(rng, 18, n))
+		if v, ok := readVersion(grid); !ok || v != 8 {
+			t.Fatalf("%d version errors: %d, %v", n, v, ok)
+		}
+	}
+	// 型番情報がシンボルの大きさと合わなければ読めない
+	grid = copyGrid(code.Modules)
+	writeBits(grid, vFirst, versionInfo(9))
+	writeBits(grid, vSecond, versionInfo(9))
+	if _, err := readGrid(grid); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("mismatched version: err = %v", err)
+	}
+}
+
+// flipBits はwidthビットのうちn個のビットを立てた値を返します
+func flipBits(rng *rand.Rand, width, n int) int {
+	v := 0
+	for _, i := range rng.Perm(width)[:n] {
+		v |= 1 << i
+	}
+	return v
+}
+
+func TestCorrectCodewordErrors(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	for version := 1; version <= 40; version++ {
+		for level := LevelL; level <= LevelH; level++ {
+			code := encodeVersion(t, version, level)
+			l := layout(version, level)
+			blocks := blockCodewords(l)
+			modules := dataModules(version)
+
+			// すべてのブロックに訂正できる数までの誤りを入れる
+			grid := copyGrid(code.Modules)
+			for _, block := range blocks {
+				for _, i := range rng.Perm(len(block))[:l.ecPerBlock/2] {
+					flipCodeword(grid, modules, block[i])
+				}
+			}
+			if got, err := readGrid(grid); err != nil || got != code.Content {
+				t.Fatalf("version %d %s: readGrid = %.20q, %v", version, level, got, err)
+			}
+
+			// 1つのブロックでも訂正できる数を超えれば読めない
+			grid = copyGrid(code.Modules)
+			block := blocks[rng.Intn(len(blocks))]
+			for _, i := range rng.Perm(len(block))[:l.ecPerBlock/2+1] {
+				flipCodeword(grid, modules, block[i])
+			}
+			if _, err := readGrid(grid); err == nil {
+				t.Fatalf("version %d %s: read with too many errors", version, level)
+			}
+		}
+	}
+}
+
+// segmentWriter はテスト用にセグメントのビット列を組み立てます
+type segmentWriter struct {
+	data []byte
+	bits int
+}
+
+func (w *segmentWriter) write(value, n int) *segmentWriter {
+	for i := n - 1; i >= 0; i-- {
+		if w.bits%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		if value>>i&1 != 0 {
+			w.data[w.bits/8] |= 0x80 >> (w.bits % 8)
+		}
+		w.bits++
+	}
+	return w
+}
+
+func (w *segmentWriter) bytes(b []byte) *segmentWriter {
+	for _, c := range b {
+		w.write(int(c), 8)
+	}
+	return w
+}
+
+func TestParseSegments(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		data    *segmentWriter
+		want    string
+	}{
+		{"numeric", 1, new(segmentWriter).write(0x1, 4).write(8, 10).write(12, 10).write(345, 10).write(67, 7), "01234567"},
+		{"numeric version 10", 10, new(segmentWriter).write(0x1, 4).write(1, 12).write(9, 4), "9"},
+		{"numeric version 27", 27, new(segmentWriter).write(0x1, 4).write(3, 14).write(999, 10), "999"},
+		{"alphanumeric", 1, new(segmentWriter).write(0x2, 4).write(5, 9).write(10*45+12, 11).write(41*45+4, 11).write(2, 6), "AC-42"},
+		{"byte utf-8", 1, new(segmentWriter).write(0x4, 4).write(6, 8).bytes([]byte("日本")), "日本"},
+		{"byte version 10", 10, new(segmentWriter).write(0x4, 4).write(2, 16).bytes([]byte("ok")), "ok"},
+		{"byte shift_jis", 1, new(segmentWriter).write(0x4, 4).write(4, 8).bytes([]byte{0x82, 0xA0, 0x82, 0xA2}), "あい"},
+		{"eci shift_jis", 1, new(segmentWriter).write(0x7, 4).write(eciShiftJIS, 8).write(0x4, 4).write(2, 8).bytes([]byte{0x93, 0x5F}), "点"},
+		{"eci utf-8", 1, new(segmentWriter).write(0x7, 4).write(26, 8).write(0x4, 4).write(3, 8).bytes([]byte("花")), "花"},
+		{"eci two bytes", 1, new(segmentWriter).write(0x7, 4).write(0x80|0x01, 8).write(0x00, 8).write(0x2, 4).write(1, 9).write(10, 6), "A"},
+		{"kanji", 1, new(segmentWriter).write(0x8, 4).write(2, 8).write(0xD9F, 13).write(0x1AAA, 13), "点茗"},
+		{"kanji version 27", 27, new(segmentWriter).write(0x8, 4).write(1, 12).write(0x1AAA, 13), "茗"},
+		{"mixed", 1, new(segmentWriter).write(0x8, 4).write(1, 8).write(0xD9F, 13).write(0x1, 4).write(2, 10).write(42, 7).write(0x0, 4), "点42"},
+		{"structured append and fnc1", 1, new(segmentWriter).write(0x3, 4).write(0x0102, 16).write(0x5, 4).write(0x2, 4).write(1, 9).write(35, 6), "Z"},
+		{"no terminator", 1, new(segmentWriter).write(0x2, 4).write(1, 9).write(1, 6).write(0, 3), "1"},
+		{"padding after terminator", 1, new(segmentWriter).write(0x4, 4).write(1, 8).bytes([]byte("x")).write(0, 4).bytes([]byte{0xEC, 0x11}), "x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := parseSegments(tt.data.data, tt.version); err != nil || got != tt.want {
+				t.Fatalf("parseSegments = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSegmentsMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data *segmentWriter
+	}{
+		{"unknown mode", new(segmentWriter).write(0x6, 4).write(0, 12)},
+		{"numeric count truncated", new(segmentWriter).write(0x1, 4).write(0, 4)},
+		{"numeric data truncated", new(segmentWriter).write(0x1, 4).write(6, 10).write(123, 10)},
+		{"numeric out of range", new(segmentWriter).write(0x1, 4).write(3, 10).write(1000, 10)},
+		{"numeric pair out of range", new(segmentWriter).write(0x1, 4).write(2, 10).write(100, 7)},
+		{"numeric digit out of range", new(segmentWriter).write(0x1, 4).write(1, 10).write(10, 4)},
+		{"alphanumeric out of range", new(segmentWriter).write(0x2, 4).write(2, 9).write(45*45, 11)},
+		{"alphanumeric single out of range", new(segmentWriter).write(0x2, 4).write(1, 9).write(45, 6)},
+		{"alphanumeric truncated", new(segmentWriter).write(0x2, 4).write(4, 9).write(0, 11)},
+		{"byte truncated", new(segmentWriter).write(0x4, 4).write(200, 8).bytes([]byte("abc"))},
+		{"kanji truncated", new(segmentWriter).write(0x8, 4).write(3, 8).write(0xD9F, 13)},
+		{"eci truncated", new(segmentWriter).write(0x7, 4).write(0x80, 8)},
+		{"eci three bytes truncated", new(segmentWriter).write(0x7, 4).write(0xC0, 8).write(0, 8)},
+		{"structured append truncated", new(segmentWriter).write(0x3, 4).write(0, 8)},
+		{"fnc1 truncated", new(segmentWriter).write(0x9, 4).write(0, 4)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := parseSegments(tt.data.data, 1); !errors.Is(err, errBadSegment) {
+				t.Fatalf("parseSegments = %q, %v", got, err)
+			}
+		})
+	}
+
+	// 任意のデータでもパニックしない
+	rng := rand.New(rand.NewSource(5))
+	for i := 0; i < 2000; i++ {
+		data := make([]byte, rng.Intn(64))
+		rng.Read(data)
+		parseSegments(data, rng.Intn(40)+1)
+	}
+}
+
+func TestDecodeMalformedInput(t *testing.T) {
+	filled := func(w, h int, c color.Gray) *image.Gray {
+		img := image.NewGray(image.Rect(0, 0, w, h))
+		for i := range img.Pix {
+			img.Pix[i] = c.Y
+		}
+		return img
+	}
+	rng := rand.New(rand.NewSource(6))
+	noise := image.NewGray(image.Rect(0, 0, 200, 200))
+	rng.Read(noise.Pix)
+
+	code := encodeVersion(t, 5, LevelM)
+	img := code.Image()
+	cropped := img.SubImage(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()/2))
+	// 位置検出パターンは残し、データのモジュールを乱数で埋める
+	scrambled := copyGrid(code.Modules)
+	for _, c := range dataModules(5) {
+		scrambled[c[1]][c[0]] = rng.Intn(2) == 0
+	}
+
+	tests := []struct {
+		name string
+		img  image.Image
+	}{
+		{"empty", image.NewGray(image.Rect(0, 0, 0, 0))},
+		{"single pixel", filled(1, 1, color.Gray{})},
+		{"white", filled(100, 100, color.Gray{Y: 0xFF})},
+		{"black", filled(100, 100, color.Gray{})},
+		{"noise", noise},
+		{"cropped", cropped},
+		{"scrambled data", gridImage(scrambled, 4)},
+		{"offset bounds", filled(50, 50, color.Gray{Y: 0xFF}).SubImage(image.Rect(10, 10, 40, 40))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := Decode(tt.img); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Decode = %q, %v", got, err)
+			}
+			if got, err := DecodeAll(tt.img); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("DecodeAll = %q, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestReadGridMalformed(t *testing.T) {
+	square := func(size int, dark bool) [][]bool {
+		grid := make([][]bool, size)
+		for y := range grid {
+			grid[y] = make([]bool, size)
+			for x := range grid[y] {
+				grid[y][x] = dark
+			}
+		}
+		return grid
+	}
+	rng := rand.New(rand.NewSource(7))
+	random := square(45, false)
+	for _, row := range random {
+		for x := range row {
+			row[x] = rng.Intn(2) == 0
+		}
+	}
+
+	tests := []struct {
+		name string
+		grid [][]bool
+	}{
+		{"empty", nil},
+		{"too small", square(20, false)},
+		{"too large", square(181, false)},
+		{"all light", square(21, false)},
+		{"all dark", square(21, true)},
+		{"random", random},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := readGrid(tt.grid); err == nil {
+				t.Fatalf("readGrid = %q", got)
+			}
+		})
+	}
+}
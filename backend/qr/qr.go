@@ -0,0 +1,285 @@
+package qr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// Level は誤り訂正レベルです（L・M・Q・Hの順に約7%・15%・25%・30%まで復元できます）
+type Level int
+
+const (
+	LevelL Level = iota
+	LevelM
+	LevelQ
+	LevelH
+)
+
+var levelNames = [4]string{"L", "M", "Q", "H"}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// ParseLevel は "L"・"M"・"Q"・"H"（大文字小文字を区別しません）を誤り訂正レベルに変換します
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	return 0, fmt.Errorf("誤り訂正レベルはL・M・Q・Hのいずれかを指定してください")
+}
+
+// recovery はgo-qrcodeの誤り訂正レベルを返します（go-qrcodeのHighはQ、HighestはHです）
+func (l Level) recovery() qrcode.RecoveryLevel {
+	return [4]qrcode.RecoveryLevel{qrcode.Low, qrcode.Medium, qrcode.High, qrcode.Highest}[l]
+}
+
+// ParseColor は #RGB・#RRGGBB 形式の色を変換します
+func ParseColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	var rgb [3]uint8
+	if len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("色は#RRGGBB形式で指定してください: %s", s)
+	}
+	if _, err := fmt.Sscanf(hex, "%02x%02x%02x", &rgb[0], &rgb[1], &rgb[2]); err != nil {
+		return color.RGBA{}, fmt.Errorf("色は#RRGGBB形式で指定してください: %s", s)
+	}
+	return color.RGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 0xFF}, nil
+}
+
+// hexColor は色を #rrggbb 形式で返します
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// MinContrast は前景色と背景色に必要なコントラスト比です（これより低いと読み取れない端末があります）
+const MinContrast = 3.0
+
+// contrast は2色のコントラスト比（WCAG 2.x）を返します。前景色の方が明るい場合は1未満になります
+func contrast(foreground, background color.RGBA) float64 {
+	return (relativeLuminance(background) + 0.05) / (relativeLuminance(foreground) + 0.05)
+}
+
+func relativeLuminance(c color.RGBA) float64 {
+	linear := func(v uint8) float64 {
+		f := float64(v) / 255
+		if f <= 0.03928 {
+			return f / 12.92
+		}
+		return math.Pow((f+0.055)/1.055, 2.4)
+	}
+	return 0.2126*linear(c.R) + 0.7152*linear(c.G) + 0.0722*linear(c.B)
+}
+
+// Options はQRコードの画像の生成オプションです
+type Options struct {
+	Level      Level
+	Size       int // 画像の一辺のピクセル数（余白を含む）
+	QuietZone  int // 周囲の余白のモジュール数
+	Foreground color.RGBA
+	Background color.RGBA
+	// Logo は中央に重ねる画像です。指定した場合は誤り訂正レベルをHにします
+	Logo image.Image
+	// LogoScale はロゴの一辺のQRコード（余白を除く）に対する割合です
+	LogoScale float64
+}
+
+// DefaultOptions は既定のオプション（M・256px・余白4モジュール・白地に黒）を返します
+func DefaultOptions() Options {
+	return Options{
+		Level:      LevelM,
+		Size:       256,
+		QuietZone:  4,
+		Foreground: color.RGBA{A: 0xFF},
+		Background: color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF},
+		LogoScale:  0.2,
+	}
+}
+
+// ErrUnreadable は生成したQRコードを読み取れなかった場合のエラーです（ロゴが大きすぎる場合など）
+var ErrUnreadable = errors.New("生成したQRコードを読み取れません")
+
+// Code は生成したQRコードです
+type Code struct {
+	Content string
+	Options Options
+	// Modules は余白を除いたモジュール（trueが暗いモジュール）です
+	Modules [][]bool
+}
+
+// Encode はcontentのQRコードを生成します
+func Encode(content string, opts Options) (*Code, error) {
+	if opts.Logo != nil {
+		opts.Level = LevelH
+	}
+	if contrast(opts.Foreground, opts.Background) < MinContrast {
+		return nil, fmt.Errorf("前景色は背景色より十分に暗い色を指定してください")
+	}
+	q, err := qrcode.New(content, opts.Level.recovery())
+	if err != nil {
+		return nil, fmt.Errorf("内容が長すぎてQRコードにできません")
+	}
+	q.DisableBorder = true
+	code := &Code{Content: content, Options: opts, Modules: q.Bitmap()}
+	if code.modulePixels() < 1 {
+		return nil, fmt.Errorf("サイズが小さすぎます（この内容では%dピクセル以上が必要です）", code.totalModules())
+	}
+	return code, nil
+}
+
+// totalModules は余白を含めた一辺のモジュール数です
+func (c *Code) totalModules() int {
+	return len(c.Modules) + 2*c.Options.QuietZone
+}
+
+// modulePixels は1モジュールのピクセル数です
+func (c *Code) modulePixels() int {
+	return c.Options.Size / c.totalModules()
+}
+
+// logoRect はロゴを置く領域（モジュール単位、余白の左上を原点とする）の左上と一辺の長さを返します
+// 読み取りやすいよう、1モジュール単位に切り上げて中央に置きます
+func (c *Code) logoRect() (offset, side int) {
+	n := len(c.Modules)
+	side = int(float64(n)*c.Options.LogoScale + 0.5)
+	if (n-side)%2 != 0 {
+		side++
+	}
+	return c.Options.QuietZone + (n-side)/2, side
+}
+
+// Image はQRコードの画像を返します（サイズで割り切れない分のピクセルは余白に含めます）
+func (c *Code) Image() *image.RGBA {
+	size := c.Options.Size
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: c.Options.Background}, image.Point{}, draw.Src)
+
+	px := c.modulePixels()
+	origin := (size - px*c.totalModules()) / 2
+	moduleRect := func(x, y, w int) image.Rectangle {
+		return image.Rect(origin+x*px, origin+y*px, origin+(x+w)*px, origin+(y+w)*px)
+	}
+	fg := &image.Uniform{C: c.Options.Foreground}
+	for y, row := range c.Modules {
+		for x, dark := range row {
+			if dark {
+				draw.Draw(img, moduleRect(x+c.Options.QuietZone, y+c.Options.QuietZone, 1), fg, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	if c.Options.Logo != nil {
+		offset, side := c.logoRect()
+		area := moduleRect(offset, offset, side)
+		draw.Draw(img, area, &image.Uniform{C: c.Options.Background}, image.Point{}, draw.Src)
+		inner := area.Inset(px / 2)
+		logo := scaleImage(c.Options.Logo, inner.Dx(), inner.Dy())
+		draw.Draw(img, inner, logo, image.Point{}, draw.Over)
+	}
+	return img
+}
+
+// PNG はQRコードをPNGで返します
+func (c *Code) PNG() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, c.Image()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG はQRコードをSVGで返します（1モジュールを1単位とし、表示サイズをSizeにします）
+func (c *Code) SVG() ([]byte, error) {
+	total := c.totalModules()
+	quiet := c.Options.QuietZone
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		c.Options.Size, c.Options.Size, total, total)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="%s"/>`, total, total, hexColor(c.Options.Background))
+	fmt.Fprintf(&b, `<path fill="%s" d="`, hexColor(c.Options.Foreground))
+	for y, row := range c.Modules {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&b, "M%d %dh%dv1h-%dz", start+quiet, y+quiet, x-start, x-start)
+		}
+	}
+	b.WriteString(`"/>`)
+
+	if c.Options.Logo != nil {
+		offset, side := c.logoRect()
+		var logo bytes.Buffer
+		// 元の画像をそのまま埋め込むと大きくなりすぎるので、PNGと同じ大きさに縮小する
+		logoSize := max((side-1)*c.modulePixels(), 1)
+		if err := png.Encode(&logo, scaleImage(c.Options.Logo, logoSize, logoSize)); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`, offset, offset, side, side, hexColor(c.Options.Background))
+		fmt.Fprintf(&b, `<image x="%g" y="%g" width="%d" height="%d" preserveAspectRatio="xMidYMid meet" href="data:image/png;base64,%s"/>`,
+			float64(offset)+0.5, float64(offset)+0.5, side-1, side-1, base64.StdEncoding.EncodeToString(logo.Bytes()))
+	}
+	b.WriteString(`</svg>`)
+	return []byte(b.String()), nil
+}
+
+// Verify は生成した画像を読み取り、元の内容に戻せることを確認します（ロゴで隠れた部分が多すぎる場合はErrUnreadable）
+// SVGも同じ配置で描画するので、PNGの画像で確認します
+func (c *Code) Verify() error {
+	content, err := Decode(c.Image())
+	if err != nil || content != c.Content {
+		return ErrUnreadable
+	}
+	return nil
+}
+
+// scaleImage は画像を縦横比を保ったままw×hに収まるよう縮小・拡大し、中央に配置した画像を返します
+// 縮小時は対応する範囲の平均をとります
+func scaleImage(src image.Image, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	sb := src.Bounds()
+	if sb.Empty() || w <= 0 || h <= 0 {
+		return dst
+	}
+	scale := min(float64(w)/float64(sb.Dx()), float64(h)/float64(sb.Dy()))
+	dw, dh := int(float64(sb.Dx())*scale+0.5), int(float64(sb.Dy())*scale+0.5)
+	ox, oy := (w-dw)/2, (h-dh)/2
+	for y := 0; y < dh; y++ {
+		sy0 := sb.Min.Y + y*sb.Dy()/dh
+		sy1 := max(sb.Min.Y+(y+1)*sb.Dy()/dh, sy0+1)
+		for x := 0; x < dw; x++ {
+			sx0 := sb.Min.X + x*sb.Dx()/dw
+			sx1 := max(sb.Min.X+(x+1)*sb.Dx()/dw, sx0+1)
+			// 大きな画像を小さく縮小すると範囲の画素数が多くなるため、uint32では桁あふれする
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			dst.SetRGBA(ox+x, oy+y, color.RGBA{R: uint8(r / n >> 8), G: uint8(g / n >> 8), B: uint8(b / n >> 8), A: uint8(a / n >> 8)})
+		}
+	}
+	return dst
+}
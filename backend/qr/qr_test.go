@@ -0,0 +1,23 @@
+package qr
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestScaleImageLargeSource(t *testing.T) {
+	// 1画素に縮小する範囲が6万5千画素を超えても平均の色を保つ
+	want := color.RGBA{R: 0x20, G: 0x80, B: 0xE0, A: 0xFF}
+	src := image.NewRGBA(image.Rect(0, 0, 4096, 4096))
+	draw.Draw(src, src.Bounds(), &image.Uniform{C: want}, image.Point{}, draw.Src)
+	dst := scaleImage(src, 8, 8)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if got := dst.RGBAAt(x, y); got != want {
+				t.Fatalf("(%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
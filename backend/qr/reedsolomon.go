@@ -0,0 +1,145 @@
+package qr
+
+import "errors"
+
+// errTooManyErrors は誤り訂正できる数を超える誤りがあった場合のエラーです
+var errTooManyErrors = errors.New("誤りが多すぎて訂正できません")
+
+// gfExp・gfLog はQRコードで使うGF(256)（原始多項式 x^8+x^4+x^3+x^2+1）の指数・対数表です
+var gfExp, gfLog = func() (exp [512]byte, log [256]int) {
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i] = byte(x)
+		log[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11D
+		}
+	}
+	for i := 255; i < 512; i++ {
+		exp[i] = exp[i-255]
+	}
+	return exp, log
+}()
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+gfLog[b]]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+255-gfLog[b]]
+}
+
+// gfPow は α^n を返します（nは負でもかまいません）
+func gfPow(n int) byte {
+	n %= 255
+	if n < 0 {
+		n += 255
+	}
+	return gfExp[n]
+}
+
+// polyEval は係数を次数の低い順に並べた多項式のxでの値を返します
+func polyEval(poly []byte, x byte) byte {
+	var y byte
+	for i := len(poly) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ poly[i]
+	}
+	return y
+}
+
+// correctErrors はRSブロック（データコード語と誤り訂正コード語）の誤りをその場で訂正し、訂正した数を返します
+// ecCountは誤り訂正コード語の数で、最大でその半分までの誤りを訂正できます
+func correctErrors(block []byte, ecCount int) (int, error) {
+	n := len(block)
+
+	// シンドローム S_j = r(α^j)（先頭のコード語が最高次の係数）
+	syndromes := make([]byte, ecCount)
+	clean := true
+	for j := range syndromes {
+		var s byte
+		x := gfPow(j)
+		for _, c := range block {
+			s = gfMul(s, x) ^ c
+		}
+		syndromes[j] = s
+		if s != 0 {
+			clean = false
+		}
+	}
+	if clean {
+		return 0, nil
+	}
+
+	// Berlekamp-Masseyで誤り位置多項式を求める
+	locator := []byte{1}
+	prev := []byte{1}
+	errCount, shift := 0, 1
+	var prevDiscrepancy byte = 1
+	for i := 0; i < ecCount; i++ {
+		d := syndromes[i]
+		for k := 1; k <= errCount && k < len(locator); k++ {
+			d ^= gfMul(locator[k], syndromes[i-k])
+		}
+		if d == 0 {
+			shift++
+			continue
+		}
+		coef := gfDiv(d, prevDiscrepancy)
+		next := make([]byte, max(len(locator), len(prev)+shift))
+		copy(next, locator)
+		for k, p := range prev {
+			next[k+shift] ^= gfMul(coef, p)
+		}
+		if 2*errCount <= i {
+			prev = locator
+			errCount = i + 1 - errCount
+			prevDiscrepancy = d
+			shift = 1
+		} else {
+			shift++
+		}
+		locator = next
+	}
+	if 2*errCount > ecCount {
+		return 0, errTooManyErrors
+	}
+
+	// 誤り評価多項式 Ω(x) = S(x)Λ(x) mod x^ecCount
+	evaluator := make([]byte, ecCount)
+	for i := range evaluator {
+		for k := 0; k <= i && k < len(locator); k++ {
+			evaluator[i] ^= gfMul(locator[k], syndromes[i-k])
+		}
+	}
+	// Λ'(x)（GF(2^m)では奇数次の項だけが残る）
+	derivative := make([]byte, len(locator))
+	for k := 1; k < len(locator); k += 2 {
+		derivative[k-1] = locator[k]
+	}
+
+	// Chienの探索で誤り位置を求め、Forneyの公式で誤りの値を求める
+	found := 0
+	for i := 0; i < n; i++ {
+		xInv := gfPow(-(n - 1 - i))
+		if polyEval(locator, xInv) != 0 {
+			continue
+		}
+		denominator := polyEval(derivative, xInv)
+		if denominator == 0 {
+			return 0, errTooManyErrors
+		}
+		block[i] ^= gfMul(gfPow(n-1-i), gfDiv(polyEval(evaluator, xInv), denominator))
+		found++
+	}
+	if found != errCount {
+		return 0, errTooManyErrors
+	}
+	return found, nil
+}
@@ -0,0 +1,119 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// rsEncode はデータに誤り訂正コード語（生成多項式 ∏(x - α^i), i = 0..ecCount-1）を付けたRSブロックを返します
+func rsEncode(data []byte, ecCount int) []byte {
+	generator := []byte{1}
+	for i := 0; i < ecCount; i++ {
+		next := make([]byte, len(generator)+1)
+		for j, g := range generator {
+			next[j] ^= g
+			next[j+1] ^= gfMul(g, gfPow(i))
+		}
+		generator = next
+	}
+	rem := make([]byte, ecCount)
+	for _, d := range data {
+		factor := d ^ rem[0]
+		copy(rem, rem[1:])
+		rem[ecCount-1] = 0
+		for j := range rem {
+			rem[j] ^= gfMul(generator[j+1], factor)
+		}
+	}
+	return append(append([]byte{}, data...), rem...)
+}
+
+// injectErrors はブロックの異なるn個の位置に0以外の値をXORします
+func injectErrors(rng *rand.Rand, block []byte, n int) {
+	for _, i := range rng.Perm(len(block))[:n] {
+		block[i] ^= byte(rng.Intn(255) + 1)
+	}
+}
+
+func TestGaloisField(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		if gfExp[gfLog[a]] != byte(a) {
+			t.Fatalf("exp(log(%d)) = %d", a, gfExp[gfLog[a]])
+		}
+		for b := 1; b < 256; b++ {
+			if got := gfDiv(gfMul(byte(a), byte(b)), byte(b)); got != byte(a) {
+				t.Fatalf("%d * %d / %d = %d", a, b, b, got)
+			}
+		}
+		if gfMul(byte(a), 0) != 0 || gfDiv(0, byte(a)) != 0 {
+			t.Fatalf("zero product for %d", a)
+		}
+	}
+	if gfPow(-1) != gfPow(254) || gfPow(255) != 1 || gfPow(8) != 0x1D {
+		t.Fatal("unexpected powers of α")
+	}
+}
+
+func TestCorrectErrors(t *testing.T) {
+	// バージョン1-Mの例（JIS X 0510 附属書I）
+	block := []byte{
+		0x10, 0x20, 0x0C, 0x56, 0x61, 0x80, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11,
+		0xA5, 0x24, 0xD4, 0xC1, 0xED, 0x36, 0xC7, 0x87, 0x2C, 0x55,
+	}
+	if got := rsEncode(block[:16], 10); !bytes.Equal(got, block) {
+		t.Fatalf("rsEncode = % X", got)
+	}
+
+	// 全バージョン・誤り訂正レベルのブロックの大きさで、訂正できる数までの誤りを訂正する
+	type shape struct{ data, ec int }
+	seen := map[shape]bool{}
+	var shapes []shape
+	for _, levels := range blockLayouts {
+		for _, l := range levels {
+			for _, s := range []shape{{l.data1, l.ecPerBlock}, {l.data2, l.ecPerBlock}} {
+				if s.data > 0 && !seen[s] {
+					seen[s] = true
+					shapes = append(shapes, s)
+				}
+			}
+		}
+	}
+	rng := rand.New(rand.NewSource(1))
+	for _, s := range shapes {
+		t.Run(fmt.Sprintf("%d+%d", s.data, s.ec), func(t *testing.T) {
+			data := make([]byte, s.data)
+			rng.Read(data)
+			want := rsEncode(data, s.ec)
+			for n := 0; n <= s.ec/2; n++ {
+				block := append([]byte{}, want...)
+				injectErrors(rng, block, n)
+				corrected, err := correctErrors(block, s.ec)
+				if err != nil {
+					t.Fatalf("%d errors: %v", n, err)
+				}
+				if corrected != n || !bytes.Equal(block, want) {
+					t.Fatalf("%d errors: corrected %d, block % X", n, corrected, block)
+				}
+			}
+		})
+	}
+}
+
+func TestCorrectErrorsBeyondCapacity(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for _, s := range []struct{ data, ec int }{{19, 7}, {16, 10}, {9, 17}, {43, 24}, {15, 30}, {122, 30}} {
+		data := make([]byte, s.data)
+		rng.Read(data)
+		want := rsEncode(data, s.ec)
+		for trial := 0; trial < 20; trial++ {
+			block := append([]byte{}, want...)
+			injectErrors(rng, block, s.ec/2+1)
+			if _, err := correctErrors(block, s.ec); !errors.Is(err, errTooManyErrors) {
+				t.Fatalf("%d+%d trial %d: err = %v", s.data, s.ec, trial, err)
+			}
+		}
+	}
+}
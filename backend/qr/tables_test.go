@@ -0,0 +1,91 @@
+package qr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBlockLayouts(t *testing.T) {
+	// シンボル全体のコード語数（JIS X 0510 表1）
+	totals := map[int]int{1: 26, 2: 44, 7: 196, 10: 346, 27: 1828, 40: 3706}
+	for version := 1; version <= 40; version++ {
+		// データのモジュールにすべてのコード語が入り、余りは7ビット以下
+		modules := len(dataModules(version))
+		for level := LevelL; level <= LevelH; level++ {
+			l := layout(version, level)
+			total := l.totalCodewords()
+			if total != modules/8 {
+				t.Errorf("version %d %s: %d codewords, %d data modules", version, level, total, modules)
+			}
+			if want, ok := totals[version]; ok && total != want {
+				t.Errorf("version %d %s: %d codewords, want %d", version, level, total, want)
+			}
+			if l.blocks2 > 0 && l.data2 != l.data1+1 {
+				t.Errorf("version %d %s: data %d/%d", version, level, l.data1, l.data2)
+			}
+		}
+	}
+}
+
+func TestAlignmentPositions(t *testing.T) {
+	tests := []struct {
+		version int
+		want    []int
+	}{
+		{1, nil},
+		{2, []int{6, 18}},
+		{6, []int{6, 34}},
+		{7, []int{6, 22, 38}},
+		{14, []int{6, 26, 46, 66}},
+		{32, []int{6, 34, 60, 86, 112, 138}},
+		{36, []int{6, 24, 50, 76, 102, 128, 154}},
+		{40, []int{6, 30, 58, 86, 114, 142, 170}},
+	}
+	for _, tt := range tests {
+		if got := alignmentPositions(tt.version); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("version %d: %v, want %v", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestFormatInfo(t *testing.T) {
+	tests := []struct {
+		level Level
+		mask  int
+		want  int
+	}{
+		{LevelL, 0, 0x77C4},
+		{LevelM, 5, 0x40CE},
+		{LevelQ, 0, 0x355F},
+		{LevelH, 0, 0x1689},
+	}
+	for _, tt := range tests {
+		if got := formatInfo(tt.level, tt.mask); got != tt.want {
+			t.Errorf("%s mask %d: %#x, want %#x", tt.level, tt.mask, got, tt.want)
+		}
+	}
+
+	// 32通りの形式情報はどの2つもハミング距離が7以上（3ビットまでの誤りを訂正できる）
+	for i := 0; i < 32; i++ {
+		for j := i + 1; j < 32; j++ {
+			if d := popcount(formatInfo(Level(i>>3), i&7) ^ formatInfo(Level(j>>3), j&7)); d < 7 {
+				t.Errorf("format %d and %d: distance %d", i, j, d)
+			}
+		}
+	}
+}
+
+func TestVersionInfo(t *testing.T) {
+	for version, want := range map[int]int{7: 0x07C94, 21: 0x15683, 40: 0x28C69} {
+		if got := versionInfo(version); got != want {
+			t.Errorf("version %d: %#x, want %#x", version, got, want)
+		}
+	}
+	for i := 7; i <= 40; i++ {
+		for j := i + 1; j <= 40; j++ {
+			if d := popcount(versionInfo(i) ^ versionInfo(j)); d < 8 {
+				t.Errorf("version %d and %d: distance %d", i, j, d)
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type accessTokenResponse struct {
+	ID          int        `json:"id"`
+	Name        string     `json:"name"`
+	Token       string     `json:"token"`
+	TokenPrefix string     `json:"token_prefix"`
+	Scopes      []string   `json:"scopes"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+}
+
+// createAccessToken はパーソナルアクセストークンを発行します
+func (s *testServer) createAccessToken(token, name string, scopes ...string) accessTokenResponse {
+	s.t.Helper()
+	var res accessTokenResponse
+	s.expect(s.do(http.MethodPost, "/api/tokens", gin.H{"name": name, "scopes": scopes}, token), http.StatusCreated, &res)
+	return res
+}
+
+func TestPersonalAccessTokenLifecycle(t *testing.T) {
+	s := newTestServer(t)
+	userID, token := s.signUp("Alice", "alice@example.com")
+
+	s.expect(s.do(http.MethodPost, "/api/tokens", gin.H{"name": "bad", "scopes": []string{"admin"}}, token), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/tokens", gin.H{"name": "empty", "scopes": []string{}}, token), http.StatusBadRequest, nil)
+
+	pat := s.createAccessToken(token, "staff script", "profiles:write", "profiles:read")
+	if !strings.HasPrefix(pat.Token, utils.PersonalAccessTokenPrefix) || !strings.HasPrefix(pat.Token, pat.TokenPrefix) {
+		t.Fatalf("unexpected token: %+v", pat)
+	}
+	if strings.Join(pat.Scopes, " ") != "profiles:read profiles:write" || pat.ExpiresAt != nil {
+		t.Fatalf("unexpected token: %+v", pat)
+	}
+
+	// 平文のトークンは保存しない
+	stored, err := s.stores.AccessTokens.GetByHash(context.Background(), utils.HashToken(pat.Token))
+	if err != nil || stored.TokenHash == pat.Token {
+		t.Fatalf("stored token = %+v, err = %v", stored, err)
+	}
+
+	s.createProfile(userID, pat.Token, "Alice")
+
+	var list struct {
+		Tokens []accessTokenResponse `json:"tokens"`
+	}
+	s.expect(s.do(http.MethodGet, "/api/tokens", nil, token), http.StatusOK, &list)
+	if len(list.Tokens) != 1 || list.Tokens[0].Token != "" || list.Tokens[0].LastUsedAt == nil {
+		t.Fatalf("tokens = %+v", list.Tokens)
+	}
+
+	// 他人のトークンは失効させられない
+	_, bobToken := s.signUp("Bob", "bob@example.com")
+	s.expect(s.do(http.MethodDelete, fmt.Sprintf("/api/tokens/%d", pat.ID), nil, bobToken), http.StatusNotFound, nil)
+
+	s.expect(s.do(http.MethodDelete, fmt.Sprintf("/api/tokens/%d", pat.ID), nil, token), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/users/%d/profiles", userID), nil, pat.Token), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodGet, "/api/tokens", nil, token), http.StatusOK, &list)
+	if len(list.Tokens) != 0 {
+		t.Fatalf("tokens = %+v, want none", list.Tokens)
+	}
+}
+
+func TestPersonalAccessTokenScopes(t *testing.T) {
+	s := newTestServer(t)
+	userID, token := s.signUp("Alice", "alice@example.com")
+	profileID := s.createProfile(userID, token, "Alice")
+
+	readOnly := s.createAccessToken(token, "reader", "profiles:read", "connections:read")
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/users/%d/profiles", userID), nil, readOnly.Token), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/connections?profile_id=%d", profileID), nil, readOnly.Token), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/profiles/%d", profileID), gin.H{"display_name": "Eve"}, readOnly.Token), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/links/user/%d", userID), nil, readOnly.Token), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodGet, "/api/users", nil, readOnly.Token), http.StatusForbidden, nil)
+
+	// writeのスコープはreadも許可する
+	links := s.createAccessToken(token, "links", "links:write")
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/links/user/%d", userID), nil, links.Token), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPost, "/api/links", gin.H{
+		"profile_id": profileID, "title": "Blog", "url": "https://example.com",
+	}, links.Token), http.StatusCreated, nil)
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/users/%d/profiles", userID), nil, links.Token), http.StatusForbidden, nil)
+
+	// 公開APIでも、トークンで閲覧する場合は読み取りのスコープが必要（つながりのある人だけに見せる項目を返すため）
+	publicID := s.publicID(profileID)
+	for _, path := range []string{"/api/profiles/" + publicID, "/api/profiles/" + publicID + "/vcard"} {
+		s.expect(s.do(http.MethodGet, path, nil, readOnly.Token), http.StatusOK, nil)
+		s.expect(s.do(http.MethodGet, path, nil, links.Token), http.StatusForbidden, nil)
+		s.expect(s.do(http.MethodGet, path, nil, ""), http.StatusOK, nil)
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/links/profile/%s", publicID), nil, links.Token), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/links/profile/%s", publicID), nil, readOnly.Token), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodPost, "/api/generate-qr", gin.H{"url": "https://example.com/"}, links.Token), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodPost, "/api/generate-qr", gin.H{"url": "https://example.com/"}, readOnly.Token), http.StatusOK, nil)
+
+	// アカウントを乗っ取れる操作はパーソナルアクセストークンでは行えない
+	s.expect(s.do(http.MethodPost, "/api/tokens", gin.H{"name": "escalate", "scopes": []string{"users:read"}}, links.Token), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodGet, "/api/tokens", nil, links.Token), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodPost, "/api/2fa/totp/enroll", nil, links.Token), http.StatusForbidden, nil)
+}
+
+func TestPersonalAccessTokenExpiry(t *testing.T) {
+	s := newTestServer(t)
+	userID, token := s.signUp("Alice", "alice@example.com")
+
+	var pat accessTokenResponse
+	s.expect(s.do(http.MethodPost, "/api/tokens", gin.H{
+		"name": "event", "scopes": []string{"profiles:read"}, "expires_in_days": 7,
+	}, token), http.StatusCreated, &pat)
+	if pat.ExpiresAt == nil || pat.ExpiresAt.Before(time.Now().Add(6*24*time.Hour)) {
+		t.Fatalf("expires_at = %v", pat.ExpiresAt)
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/users/%d/profiles", userID), nil, pat.Token), http.StatusOK, nil)
+
+	s.expect(s.do(http.MethodPost, "/api/tokens", gin.H{
+		"name": "forever", "scopes": []string{"profiles:read"}, "expires_in_days": 0,
+	}, token), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/users/%d/profiles", userID), nil, utils.PersonalAccessTokenPrefix+"unknown"), http.StatusUnauthorized, nil)
+}
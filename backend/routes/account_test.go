@@ -0,0 +1,205 @@
+package routes
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readExport はエクスポートのZIPを展開してファイル名と内容の対応を返します
+func readExport(t *testing.T, body []byte) map[string][]byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = data
+	}
+	return files
+}
+
+func TestExportAccountData(t *testing.T) {
+	s := newTestServer(t)
+	_, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+
+	var created struct {
+		ID int `json:"id"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/profiles", gin.H{
+		"display_name": "Alice", "title": "仕事用",
+		"icon_base64": "data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte("fake-png")),
+	}, aliceToken), http.StatusCreated, &created)
+	aliceProfile := created.ID
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+
+	s.expect(s.do(http.MethodPost, "/api/option_profiles", gin.H{
+		"title": "好きな食べ物", "content": "寿司", "profile_id": aliceProfile,
+	}, aliceToken), http.StatusCreated, nil)
+	s.expect(s.do(http.MethodPost, "/api/links", gin.H{
+		"title": "GitHub", "url": "https://github.com/alice", "profile_id": aliceProfile,
+	}, aliceToken), http.StatusCreated, nil)
+	s.requestConnection(aliceToken, gin.H{
+		"profile_id": aliceProfile, "connect_user_profile_public_id": s.publicID(bobProfile), "memo": "勉強会",
+	}, bobToken)
+	s.connect(bobToken, bobProfile, aliceProfile, aliceToken)
+
+	w := s.do(http.MethodGet, "/api/account/export", nil, aliceToken)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("export: status=%d content-type=%q body=%s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+	files := readExport(t, w.Body.Bytes())
+
+	var user struct {
+		User       map[string]interface{} `json:"user"`
+		Identities []interface{}          `json:"identities"`
+	}
+	if err := json.Unmarshal(files["user.json"], &user); err != nil {
+		t.Fatal(err)
+	}
+	if user.User["email"] != "alice@example.com" || user.Identities == nil {
+		t.Fatalf("user.json = %s", files["user.json"])
+	}
+	if _, ok := user.User["password"]; ok {
+		t.Fatal("password must not be exported")
+	}
+
+	var profiles []struct {
+		ID             int                      `json:"id"`
+		IconPath       string                   `json:"icon_path"`
+		IconFile       string                   `json:"icon_file"`
+		OptionProfiles []map[string]interface{} `json:"option_profiles"`
+	}
+	if err := json.Unmarshal(files["profiles.json"], &profiles); err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 1 || profiles[0].ID != aliceProfile || len(profiles[0].OptionProfiles) != 1 || profiles[0].IconPath != "" {
+		t.Fatalf("profiles.json = %s", files["profiles.json"])
+	}
+	if icon := files[profiles[0].IconFile]; string(icon) != "fake-png" {
+		t.Fatalf("icon %q = %q", profiles[0].IconFile, icon)
+	}
+
+	var links []map[string]interface{}
+	if err := json.Unmarshal(files["links.json"], &links); err != nil || len(links) != 1 {
+		t.Fatalf("links.json = %s, err = %v", files["links.json"], err)
+	}
+
+	var connections struct {
+		Outgoing []struct {
+			ConnectUsersProfilePublicID string `json:"connect_user_profile_public_id"`
+			Memo                        string `json:"memo"`
+		} `json:"outgoing"`
+		Incoming []struct {
+			ProfileID int `json:"profile_id"`
+		} `json:"incoming"`
+	}
+	if err := json.Unmarshal(files["connections.json"], &connections); err != nil {
+		t.Fatal(err)
+	}
+	if len(connections.Outgoing) != 1 || connections.Outgoing[0].ConnectUsersProfilePublicID != s.publicID(bobProfile) || connections.Outgoing[0].Memo != "勉強会" {
+		t.Fatalf("outgoing = %+v", connections.Outgoing)
+	}
+	if len(connections.Incoming) != 1 || connections.Incoming[0].ProfileID != bobProfile {
+		t.Fatalf("incoming = %+v", connections.Incoming)
+	}
+
+	// パーソナルアクセストークンではエクスポートできない
+	pat := s.createAccessToken(aliceToken, "script", "profiles:read")
+	s.expect(s.do(http.MethodGet, "/api/account/export", nil, pat.Token), http.StatusForbidden, nil)
+}
+
+func TestAccountDeletion(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+
+	var created struct {
+		ID int `json:"id"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/profiles", gin.H{
+		"display_name": "Alice", "title": "仕事用",
+		"icon_base64": base64.StdEncoding.EncodeToString([]byte("fake-png")),
+	}, aliceToken), http.StatusCreated, &created)
+	aliceProfile := created.ID
+	stored, err := s.stores.Profiles.Get(context.Background(), aliceProfile)
+	if err != nil || stored.IconPath == "" {
+		t.Fatalf("icon not stored: %+v, %v", stored, err)
+	}
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+	s.connect(bobToken, bobProfile, aliceProfile, aliceToken)
+	s.expect(s.do(http.MethodPost, "/api/links", gin.H{
+		"title": "GitHub", "url": "https://github.com/alice", "profile_id": aliceProfile,
+	}, aliceToken), http.StatusCreated, nil)
+
+	s.expect(s.do(http.MethodPost, "/api/account/deletion", gin.H{"password": "wrong-password"}, aliceToken), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodDelete, "/api/account/deletion", nil, aliceToken), http.StatusConflict, nil)
+
+	var res models.AccountDeletionResponse
+	s.expect(s.do(http.MethodPost, "/api/account/deletion", gin.H{"password": "password123"}, aliceToken), http.StatusAccepted, &res)
+	if d := time.Until(res.DeletionScheduledAt); d < 29*24*time.Hour || d > 31*24*time.Hour {
+		t.Fatalf("deletion_scheduled_at = %v, want about 30 days later", res.DeletionScheduledAt)
+	}
+	if len(s.mails("alice@example.com")) != 2 {
+		t.Fatal("deletion mail was not sent")
+	}
+
+	// 予約するとセッションは失効し、猶予期間中はサインインして取り消せる
+	s.expect(s.do(http.MethodGet, "/api/users", nil, aliceToken), http.StatusUnauthorized, nil)
+	session := s.signIn("alice@example.com")
+	s.expect(s.do(http.MethodDelete, "/api/account/deletion", nil, session.Token), http.StatusOK, nil)
+	if n, err := s.app.PurgeDeletedAccounts(context.Background()); err != nil || n != 0 {
+		t.Fatalf("purged = %d, err = %v", n, err)
+	}
+
+	// 猶予期間を過ぎると関連データごと完全に削除される
+	s.expect(s.do(http.MethodPost, "/api/account/deletion", gin.H{"password": "password123"}, session.Token), http.StatusAccepted, nil)
+	if err := s.stores.Users.ScheduleDeletion(context.Background(), aliceID, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.app.PurgeDeletedAccounts(context.Background()); err != nil || n != 1 {
+		t.Fatalf("purged = %d, err = %v", n, err)
+	}
+
+	if _, err := s.stores.Users.GetByID(context.Background(), aliceID); err == nil {
+		t.Fatal("user still exists")
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/profiles/%d", aliceProfile), nil, ""), http.StatusNotFound, nil)
+	if _, err := os.Stat(stored.IconPath); !os.IsNotExist(err) {
+		t.Fatalf("icon file remains: %v", err)
+	}
+	var list struct {
+		Total int `json:"total"`
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/connections?profile_id=%d", bobProfile), nil, bobToken), http.StatusOK, &list)
+	if list.Total != 0 {
+		t.Fatalf("connections to deleted account remain: %d", list.Total)
+	}
+	s.expect(s.do(http.MethodPost, "/api/signin", gin.H{"email": "alice@example.com", "password": "password123"}, ""), http.StatusUnauthorized, nil)
+
+	// 同じメールアドレスで登録し直せる
+	s.signUp("Alice", "alice@example.com")
+}
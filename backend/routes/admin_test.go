@@ -0,0 +1,213 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setRole はテスト用にユーザーのロールを直接変更します
+func (s *testServer) setRole(userID int, role models.Role) {
+	s.t.Helper()
+	if err := s.stores.Users.UpdateRole(context.Background(), userID, role); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+type adminUserList struct {
+	Users []struct {
+		ID    int    `json:"id"`
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	} `json:"users"`
+	Total   int `json:"total"`
+	Page    int `json:"page"`
+	PerPage int `json:"per_page"`
+}
+
+func TestAdminRequiresRole(t *testing.T) {
+	s := newTestServer(t)
+	userID, token := s.signUp("Alice", "alice@example.com")
+	bobID, _ := s.signUp("Bob", "bob@example.com")
+
+	s.expect(s.do(http.MethodGet, "/api/admin/users", nil, ""), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodGet, "/api/admin/users", nil, token), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/users/%d/suspend", bobID), gin.H{"reason": "spam"}, token), http.StatusForbidden, nil)
+
+	// モデレーターはロールを変更できない
+	s.setRole(userID, models.RoleModerator)
+	s.expect(s.do(http.MethodGet, "/api/admin/users", nil, token), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/admin/users/%d/role", bobID), gin.H{"role": "moderator"}, token), http.StatusForbidden, nil)
+
+	// パーソナルアクセストークンでは管理者APIを使えない
+	pat := s.createAccessToken(token, "script", "users:read")
+	s.expect(s.do(http.MethodGet, "/api/admin/users", nil, pat.Token), http.StatusForbidden, nil)
+}
+
+func TestAdminSearchUsers(t *testing.T) {
+	s := newTestServer(t)
+	adminID, token := s.signUp("Admin", "admin@example.com")
+	s.setRole(adminID, models.RoleAdmin)
+	for i := 1; i <= 3; i++ {
+		s.signUp(fmt.Sprintf("Member %d", i), fmt.Sprintf("member%d@example.com", i))
+	}
+	s.signUp("Other", "other@example.org")
+
+	var res adminUserList
+	s.expect(s.do(http.MethodGet, "/api/admin/users?q=MEMBER&per_page=2", nil, token), http.StatusOK, &res)
+	if res.Total != 3 || len(res.Users) != 2 || res.Users[0].Email != "member1@example.com" {
+		t.Fatalf("page 1 = %+v", res)
+	}
+	s.expect(s.do(http.MethodGet, "/api/admin/users?q=member&per_page=2&page=2", nil, token), http.StatusOK, &res)
+	if res.Total != 3 || len(res.Users) != 1 || res.Users[0].Email != "member3@example.com" || res.Page != 2 {
+		t.Fatalf("page 2 = %+v", res)
+	}
+
+	s.expect(s.do(http.MethodGet, "/api/admin/users?role=admin", nil, token), http.StatusOK, &res)
+	if res.Total != 1 || res.Users[0].ID != adminID || res.Users[0].Role != "admin" {
+		t.Fatalf("admins = %+v", res)
+	}
+
+	s.expect(s.do(http.MethodGet, "/api/admin/users?role=owner", nil, token), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodGet, "/api/admin/users?status=banned", nil, token), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodGet, "/api/admin/users?per_page=1000", nil, token), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodGet, "/api/admin/users?page=0", nil, token), http.StatusBadRequest, nil)
+}
+
+func TestAdminSuspendUser(t *testing.T) {
+	s := newTestServer(t)
+	modID, modToken := s.signUp("Mod", "mod@example.com")
+	s.setRole(modID, models.RoleModerator)
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	refresh := s.signIn("bob@example.com").RefreshToken
+	pat := s.createAccessToken(bobToken, "script", "profiles:read")
+
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/users/%d/suspend", bobID), gin.H{}, modToken), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/users/%d/suspend", bobID), gin.H{"reason": "spam"}, modToken), http.StatusOK, nil)
+
+	// セッション・パーソナルアクセストークン・サインインはすべて拒否される
+	s.expect(s.do(http.MethodGet, "/api/users", nil, bobToken), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/users/%d/profiles", bobID), nil, pat.Token), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodPost, "/api/token/refresh", gin.H{"refresh_token": refresh}, ""), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodPost, "/api/signin", gin.H{"email": "bob@example.com", "password": "password123"}, ""), http.StatusForbidden, nil)
+
+	var res adminUserList
+	s.expect(s.do(http.MethodGet, "/api/admin/users?status=suspended", nil, modToken), http.StatusOK, &res)
+	if res.Total != 1 || res.Users[0].ID != bobID {
+		t.Fatalf("suspended = %+v", res)
+	}
+
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/users/%d/unsuspend", bobID), nil, modToken), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/users/%d/unsuspend", bobID), nil, modToken), http.StatusConflict, nil)
+	s.signIn("bob@example.com")
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/users/%d/profiles", bobID), nil, pat.Token), http.StatusOK, nil)
+
+	// 自分自身や同じロールのユーザーは停止できない
+	otherModID, _ := s.signUp("Mod2", "mod2@example.com")
+	s.setRole(otherModID, models.RoleModerator)
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/users/%d/suspend", modID), gin.H{"reason": "x"}, modToken), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/users/%d/suspend", otherModID), gin.H{"reason": "x"}, modToken), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodPost, "/api/admin/users/9999/suspend", gin.H{"reason": "x"}, modToken), http.StatusNotFound, nil)
+}
+
+func TestAdminUpdateUserRole(t *testing.T) {
+	s := newTestServer(t)
+	adminID, adminToken := s.signUp("Admin", "admin@example.com")
+	s.setRole(adminID, models.RoleAdmin)
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/admin/users/%d/role", bobID), gin.H{"role": "admin"}, adminToken), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/admin/users/%d/role", bobID), gin.H{"role": "moderator"}, adminToken), http.StatusOK, nil)
+
+	// ロールの変更は発行済みのトークンにもすぐに反映される
+	s.expect(s.do(http.MethodGet, "/api/admin/users", nil, bobToken), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/admin/users/%d/role", adminID), gin.H{"role": "user"}, adminToken), http.StatusForbidden, nil)
+
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/admin/users/%d/role", bobID), gin.H{"role": "user"}, adminToken), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, "/api/admin/users", nil, bobToken), http.StatusForbidden, nil)
+}
+
+func TestAdminDeleteProfileAndAuditLogs(t *testing.T) {
+	s := newTestServer(t)
+	modID, modToken := s.signUp("Mod", "mod@example.com")
+	s.setRole(modID, models.RoleModerator)
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	profileID := s.createProfile(bobID, bobToken, "Bob")
+
+	s.expect(s.do(http.MethodDelete, fmt.Sprintf("/api/admin/profiles/%d", profileID), nil, modToken), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodDelete, fmt.Sprintf("/api/admin/profiles/%d", profileID), gin.H{"reason": "offensive"}, modToken), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/profiles/%d", profileID), nil, ""), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodDelete, fmt.Sprintf("/api/admin/profiles/%d", profileID), gin.H{"reason": "again"}, modToken), http.StatusNotFound, nil)
+
+	// 管理者のプロフィールはモデレーターには削除できない
+	adminID, adminToken := s.signUp("Admin", "admin@example.com")
+	s.setRole(adminID, models.RoleAdmin)
+	adminProfile := s.createProfile(adminID, adminToken, "Admin")
+	s.expect(s.do(http.MethodDelete, fmt.Sprintf("/api/admin/profiles/%d", adminProfile), gin.H{"reason": "x"}, modToken), http.StatusForbidden, nil)
+
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/users/%d/suspend", bobID), gin.H{"reason": "repeat"}, modToken), http.StatusOK, nil)
+
+	var logs struct {
+		Logs []struct {
+			ActorUserID  int               `json:"actor_user_id"`
+			Action       string            `json:"action"`
+			TargetUserID int               `json:"target_user_id"`
+			TargetType   string            `json:"target_type"`
+			TargetID     int               `json:"target_id"`
+			Details      map[string]string `json:"details"`
+		} `json:"logs"`
+		Total int `json:"total"`
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/admin/audit-logs?target_user_id=%d", bobID), nil, modToken), http.StatusOK, &logs)
+	if logs.Total != 2 || logs.Logs[0].Action != models.AuditUserSuspend || logs.Logs[1].Action != models.AuditProfileDelete {
+		t.Fatalf("logs = %+v", logs)
+	}
+	deleted := logs.Logs[1]
+	if deleted.ActorUserID != modID || deleted.TargetID != profileID || deleted.TargetType != "profile" ||
+		deleted.Details["reason"] != "offensive" || deleted.Details["display_name"] != "Bob" {
+		t.Fatalf("profile delete log = %+v", deleted)
+	}
+
+	s.expect(s.do(http.MethodGet, "/api/admin/audit-logs?action=user.suspend", nil, adminToken), http.StatusOK, &logs)
+	if logs.Total != 1 {
+		t.Fatalf("suspend logs = %+v", logs)
+	}
+	s.expect(s.do(http.MethodGet, "/api/admin/audit-logs?actor_id=abc", nil, adminToken), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodGet, "/api/admin/audit-logs", nil, bobToken), http.StatusUnauthorized, nil)
+}
+
+func TestAdminUnlistedProfiles(t *testing.T) {
+	s := newTestServer(t)
+	modID, modToken := s.signUp("Mod", "mod@example.com")
+	s.setRole(modID, models.RoleModerator)
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	public := s.createProfile(bobID, bobToken, "Bob")
+	unlisted := s.createProfile(bobID, bobToken, "Bob (unlisted)")
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/profiles/%d", unlisted), gin.H{"visibility": "unlisted"}, bobToken), http.StatusOK, nil)
+
+	// 限定公開のプロフィールはユーザー詳細の一覧に含めない
+	var detail struct {
+		Profiles []struct {
+			ID int `json:"id"`
+		} `json:"profiles"`
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/admin/users/%d", bobID), nil, modToken), http.StatusOK, &detail)
+	if len(detail.Profiles) != 1 || detail.Profiles[0].ID != public {
+		t.Fatalf("admin profiles = %+v", detail.Profiles)
+	}
+
+	// 報告されたURLの公開IDで削除できる
+	var deleted struct {
+		ProfileID int `json:"profile_id"`
+	}
+	s.expect(s.do(http.MethodDelete, "/api/admin/profiles/"+s.publicID(unlisted), gin.H{"reason": "spam"}, modToken), http.StatusOK, &deleted)
+	if deleted.ProfileID != unlisted {
+		t.Fatalf("deleted profile_id = %d, want %d", deleted.ProfileID, unlisted)
+	}
+	s.expect(s.do(http.MethodDelete, "/api/admin/profiles/no-such-profile", gin.H{"reason": "spam"}, modToken), http.StatusNotFound, nil)
+}
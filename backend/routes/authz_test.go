@@ -0,0 +1,152 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"backend/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCrossUserEditsAreForbidden(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	_, bobToken := s.signUp("Bob", "bob@example.com")
+	aliceProfile := s.createProfile(aliceID, aliceToken, "Alice")
+
+	var link struct {
+		Link struct {
+			ID int `json:"id"`
+		} `json:"link"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/links", gin.H{
+		"title": "GitHub", "url": "https://github.com/alice", "profile_id": aliceProfile,
+	}, aliceToken), http.StatusCreated, &link)
+
+	var userLink struct {
+		Link struct {
+			ID int `json:"id"`
+		} `json:"link"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/links", gin.H{
+		"title": "Blog", "url": "https://alice.example.com", "user_id": aliceID,
+	}, aliceToken), http.StatusCreated, &userLink)
+
+	var opt struct {
+		ID int `json:"id"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/option_profiles", gin.H{
+		"title": "好きな食べ物", "content": "寿司", "profile_id": aliceProfile,
+	}, aliceToken), http.StatusCreated, &opt)
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+	}{
+		{"CreateProfile", http.MethodPost, "/api/profiles", gin.H{"user_id": aliceID, "display_name": "偽物", "title": "偽物"}},
+		{"UpdateProfile", http.MethodPut, fmt.Sprintf("/api/profiles/%d", aliceProfile), gin.H{"display_name": "偽物"}},
+		{"DeleteProfile", http.MethodDelete, fmt.Sprintf("/api/profiles/%d", aliceProfile), nil},
+		{"CreateLinkOnProfile", http.MethodPost, "/api/links", gin.H{"title": "x", "url": "https://example.com", "profile_id": aliceProfile}},
+		{"CreateLinkOnUser", http.MethodPost, "/api/links", gin.H{"title": "x", "url": "https://example.com", "user_id": aliceID}},
+		{"UpdateLink", http.MethodPut, fmt.Sprintf("/api/links/%d", link.Link.ID), gin.H{"title": "偽物"}},
+		{"UpdateUserLink", http.MethodPut, fmt.Sprintf("/api/links/%d", userLink.Link.ID), gin.H{"title": "偽物"}},
+		{"DeleteLink", http.MethodDelete, fmt.Sprintf("/api/links/%d", link.Link.ID), nil},
+		{"CreateOptionProfile", http.MethodPost, "/api/option_profiles", gin.H{"title": "x", "content": "y", "profile_id": aliceProfile}},
+		{"UpdateOptionProfile", http.MethodPatch, fmt.Sprintf("/api/option_profiles/%d", opt.ID), gin.H{"content": "偽物"}},
+		{"DeleteOptionProfile", http.MethodDelete, fmt.Sprintf("/api/option_profiles/%d", opt.ID), nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var res struct {
+				Error string `json:"error"`
+			}
+			s.expect(s.do(tc.method, tc.path, tc.body, bobToken), http.StatusForbidden, &res)
+			if res.Error != handlers.ForbiddenMessage {
+				t.Fatalf("error = %q, want uniform forbidden message", res.Error)
+			}
+		})
+	}
+
+	// 何も変更されていないこと
+	var profile struct {
+		DisplayName string `json:"display_name"`
+	}
+	s.expect(s.do(http.MethodGet, "/api/profiles/"+s.publicID(aliceProfile), nil, ""), http.StatusOK, &profile)
+	if profile.DisplayName != "Alice" {
+		t.Fatalf("display_name = %q, want unchanged", profile.DisplayName)
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/links/%d", link.Link.ID), nil, aliceToken), http.StatusOK, nil)
+
+	var opts struct {
+		Count int `json:"count"`
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/profiles/%d/option-profiles", aliceProfile), nil, aliceToken), http.StatusOK, &opts)
+	if opts.Count != 1 {
+		t.Fatalf("option profiles = %d, want 1", opts.Count)
+	}
+}
+
+func TestCreateProfileDefaultsToCaller(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+
+	var created struct {
+		UserID int `json:"user_id"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/profiles", gin.H{"display_name": "Alice", "title": "仕事用"}, aliceToken), http.StatusCreated, &created)
+	if created.UserID != aliceID {
+		t.Fatalf("user_id = %d, want %d", created.UserID, aliceID)
+	}
+}
+
+func TestConnectionsAreScopedToCaller(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	aliceProfile := s.createProfile(aliceID, aliceToken, "Alice")
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+
+	connectionID := s.requestConnection(aliceToken, gin.H{
+		"profile_id": aliceProfile, "connect_user_profile_public_id": s.publicID(bobProfile), "memo": "秘密のメモ",
+	}, bobToken)
+	path := fmt.Sprintf("/api/connections/%d", connectionID)
+
+	// 認証なしではすべて401
+	s.expect(s.do(http.MethodGet, path, nil, ""), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/connections?profile_id=%d", aliceProfile), nil, ""), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodPost, "/api/connections", gin.H{
+		"profile_id": bobProfile, "connect_user_profile_public_id": s.publicID(aliceProfile),
+	}, ""), http.StatusUnauthorized, nil)
+
+	// 他人のプロフィールとしてコネクションを作成・一覧取得できない
+	s.expect(s.do(http.MethodPost, "/api/connections", gin.H{
+		"profile_id": aliceProfile, "connect_user_profile_public_id": s.publicID(bobProfile),
+	}, bobToken), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/connections?profile_id=%d", aliceProfile), nil, bobToken), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/users/%d/connections", aliceID), nil, bobToken), http.StatusForbidden, nil)
+
+	// 存在しない接続先は作成できない
+	s.expect(s.do(http.MethodPost, "/api/connections", gin.H{
+		"profile_id": aliceProfile, "connect_user_profile_id": 9999,
+	}, aliceToken), http.StatusNotFound, nil)
+
+	// 他人のコネクションは存在しないものとして扱う
+	s.expect(s.do(http.MethodGet, path, nil, bobToken), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodPut, path, gin.H{"memo": "改ざん"}, bobToken), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodDelete, path, nil, bobToken), http.StatusNotFound, nil)
+
+	var got struct {
+		Connection struct {
+			Memo string `json:"memo"`
+		} `json:"connection"`
+	}
+	s.expect(s.do(http.MethodGet, path, nil, aliceToken), http.StatusOK, &got)
+	if got.Connection.Memo != "秘密のメモ" {
+		t.Fatalf("memo = %q, want unchanged", got.Connection.Memo)
+	}
+}
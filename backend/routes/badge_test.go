@@ -0,0 +1,150 @@
+package routes
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/gin-gonic/gin"
+)
+
+var pdfStream = regexp.MustCompile(`(?s)stream\n(.*?)\nendstream`)
+
+// pdfContent はPDFのストリームを展開して連結した内容を返します（画像以外の描画命令の確認用）
+func pdfContent(t *testing.T, body []byte) string {
+	t.Helper()
+	if !bytes.HasPrefix(body, []byte("%PDF-")) || !bytes.HasSuffix(body, []byte("%%EOF\n")) {
+		t.Fatalf("not a PDF: %.40q", body)
+	}
+	var content strings.Builder
+	for _, m := range pdfStream.FindAllSubmatch(body, -1) {
+		zr, err := zlib.NewReader(bytes.NewReader(m[1]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(data, []byte(" Tj ")) {
+			content.Write(data)
+		}
+	}
+	return content.String()
+}
+
+// pdfText はPDFに描画する文字列の表現（UCS-2の16進数）を返します
+func pdfText(s string) string {
+	var b strings.Builder
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	return "<" + b.String() + "> Tj"
+}
+
+func TestGenerateBadges(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	_, carolToken := s.signUp("Carol", "carol@example.com")
+
+	var alice publicProfile
+	s.expect(s.do(http.MethodPost, "/api/profiles", gin.H{
+		"user_id": aliceID, "display_name": "山田 花子", "title": "イベント用", "aka": "エンジニア",
+		"icon_base64": base64.StdEncoding.EncodeToString(testLogo(t)),
+	}, aliceToken), http.StatusCreated, &alice)
+	var bob publicProfile
+	s.expect(s.do(http.MethodPost, "/api/profiles", gin.H{
+		"user_id": bobID, "display_name": "Bob", "title": "イベント用", "aka": "デザイナー",
+		"restricted_fields": []string{"aka"},
+	}, bobToken), http.StatusCreated, &bob)
+	privateProfile := s.createProfile(bobID, bobToken, "Bob (private)")
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/profiles/%d", privateProfile), gin.H{"visibility": "private"}, bobToken), http.StatusOK, nil)
+	bobPrivate := s.publicID(privateProfile)
+
+	// 表示名・肩書き・アイコンと公開ページのQRコードを並べたPDFを返す（同じプロフィールを複数枚にもできる）
+	w := s.do(http.MethodPost, "/api/profiles/badges", gin.H{
+		"profile_ids": []string{alice.PublicID, bob.PublicID, alice.PublicID},
+		"columns":     1, "rows": 2,
+	}, carolToken)
+	s.expect(w, http.StatusOK, nil)
+	if ct := w.Header().Get("Content-Type"); ct != "application/pdf" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename="qrsona-badges.pdf"` {
+		t.Fatalf("Content-Disposition = %q", cd)
+	}
+	body := w.Body.Bytes()
+	if pages := bytes.Count(body, []byte("/Type /Page /")); pages != 2 {
+		t.Fatalf("pages = %d", pages)
+	}
+	// アイコンは同じ画像を1度だけ埋め込む
+	if images := bytes.Count(body, []byte("/Subtype /Image")); images != 1 {
+		t.Fatalf("images = %d", images)
+	}
+	content := pdfContent(t, body)
+	if n := strings.Count(content, pdfText("山田 花子")); n != 2 {
+		t.Fatalf("name drawn %d times", n)
+	}
+	for text, want := range map[string]bool{"エンジニア": true, "Bob": true, "デザイナー": false} {
+		if strings.Contains(content, pdfText(text)) != want {
+			t.Fatalf("%s drawn = %v", text, !want)
+		}
+	}
+
+	// つながりのある人だけに見せる肩書きは、閲覧できる人が作成した場合だけ印刷する
+	w = s.do(http.MethodPost, "/api/profiles/badges", gin.H{"profile_ids": []string{bob.PublicID}, "cut_lines": false}, bobToken)
+	s.expect(w, http.StatusOK, nil)
+	content = pdfContent(t, w.Body.Bytes())
+	if !strings.Contains(content, pdfText("デザイナー")) || strings.Contains(content, " d ") {
+		t.Fatalf("owner badge = %s", content)
+	}
+
+	// 用紙サイズ・向き
+	w = s.do(http.MethodPost, "/api/profiles/badges", gin.H{"profile_ids": []string{alice.PublicID}, "page_size": "a6", "orientation": "landscape", "columns": 1, "rows": 1}, carolToken)
+	s.expect(w, http.StatusOK, nil)
+	if !bytes.Contains(w.Body.Bytes(), []byte("/MediaBox [0 0 419.53 297.64]")) {
+		t.Fatal("A6 landscape MediaBox not found")
+	}
+
+	// 閲覧できないプロフィール・不正な配置
+	s.expect(s.do(http.MethodPost, "/api/profiles/badges", gin.H{"profile_ids": []string{alice.PublicID, bobPrivate}}, carolToken), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodPost, "/api/profiles/badges", gin.H{"profile_ids": []string{}}, carolToken), http.StatusBadRequest, nil)
+	for _, layout := range []gin.H{
+		{"page_size": "B6"},
+		{"orientation": "diagonal"},
+		{"columns": 10},
+		{"rows": -1},
+		{"margin": 200},
+		{"gap": -5},
+	} {
+		layout["profile_ids"] = []string{alice.PublicID}
+		var res struct {
+			Error string `json:"error"`
+		}
+		s.expect(s.do(http.MethodPost, "/api/profiles/badges", layout, carolToken), http.StatusBadRequest, &res)
+		// 入力検証の内部のメッセージは返さない
+		if _, ok := layout["orientation"]; ok && res.Error != "リクエストが不正です" {
+			t.Fatalf("error = %q", res.Error)
+		}
+	}
+	s.expect(s.do(http.MethodPost, "/api/profiles/badges", gin.H{"profile_ids": []string{alice.PublicID}}, ""), http.StatusUnauthorized, nil)
+
+	// プロフィールを読み取るだけなので、パーソナルアクセストークンは profiles:read で使える
+	_, daveToken := s.signUp("Dave", "dave@example.com")
+	readOnly := s.createAccessToken(daveToken, "badge printer", "profiles:read")
+	w = s.do(http.MethodPost, "/api/profiles/badges", gin.H{"profile_ids": []string{alice.PublicID}}, readOnly.Token)
+	s.expect(w, http.StatusOK, nil)
+	if !strings.Contains(pdfContent(t, w.Body.Bytes()), pdfText("山田 花子")) {
+		t.Fatal("name not drawn with profiles:read token")
+	}
+	linksOnly := s.createAccessToken(daveToken, "links", "links:write")
+	s.expect(s.do(http.MethodPost, "/api/profiles/badges", gin.H{"profile_ids": []string{alice.PublicID}}, linksOnly.Token), http.StatusForbidden, nil)
+}
@@ -0,0 +1,158 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type connectionRequestList struct {
+	Requests []struct {
+		ID                          int           `json:"id"`
+		ProfileID                   int           `json:"profile_id"`
+		ConnectUsersProfilePublicID string        `json:"connect_user_profile_public_id"`
+		Status                      string        `json:"status"`
+		Memo                        string        `json:"memo"`
+		Profile                     publicProfile `json:"profile"`
+	} `json:"requests"`
+	Total int `json:"total"`
+}
+
+func TestConnectionRequestLifecycle(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	carolID, carolToken := s.signUp("Carol", "carol@example.com")
+	aliceProfile := s.createProfile(aliceID, aliceToken, "Alice")
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+	carolProfile := s.createProfile(carolID, carolToken, "Carol")
+
+	// 他人のプロフィールへは申請になり、承認されるまでコネクションは作成されない
+	body := gin.H{"profile_id": bobProfile, "connect_user_profile_public_id": s.publicID(aliceProfile), "event_name": "勉強会", "memo": "Goの話をした"}
+	var requested struct {
+		Request struct {
+			ID     int    `json:"id"`
+			Status string `json:"status"`
+		} `json:"request"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/connections", body, bobToken), http.StatusAccepted, &requested)
+	if requested.Request.Status != "pending" {
+		t.Fatalf("requested = %+v", requested)
+	}
+	s.expect(s.do(http.MethodPost, "/api/connections", body, bobToken), http.StatusConflict, nil)
+	var connections struct {
+		Total int `json:"total"`
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/connections?profile_id=%d", bobProfile), nil, bobToken), http.StatusOK, &connections)
+	if connections.Total != 0 {
+		t.Fatalf("connections before accept = %d, want 0", connections.Total)
+	}
+
+	// 申請先には申請者のプロフィールを返し、申請者のメモは返さない
+	var incoming connectionRequestList
+	s.expect(s.do(http.MethodGet, "/api/connection-requests/incoming?status=pending", nil, aliceToken), http.StatusOK, &incoming)
+	if incoming.Total != 1 || incoming.Requests[0].Profile.DisplayName != "Bob" || incoming.Requests[0].Memo != "" {
+		t.Fatalf("incoming = %+v", incoming)
+	}
+	var outgoing connectionRequestList
+	s.expect(s.do(http.MethodGet, "/api/connection-requests/outgoing", nil, bobToken), http.StatusOK, &outgoing)
+	if outgoing.Total != 1 || outgoing.Requests[0].Profile.DisplayName != "Alice" || outgoing.Requests[0].Memo != "Goの話をした" {
+		t.Fatalf("outgoing = %+v", outgoing)
+	}
+	s.expect(s.do(http.MethodGet, "/api/connection-requests/incoming?status=unknown", nil, aliceToken), http.StatusBadRequest, nil)
+
+	// 申請先の所有者だけが承認できる（他人には存在を明かさない）
+	acceptPath := fmt.Sprintf("/api/connection-requests/%d/accept", requested.Request.ID)
+	s.expect(s.do(http.MethodPost, acceptPath, nil, bobToken), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodPost, acceptPath, nil, carolToken), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodPost, acceptPath, nil, ""), http.StatusUnauthorized, nil)
+
+	var accepted struct {
+		Connection struct {
+			ProfileID                   int    `json:"profile_id"`
+			ConnectUsersProfilePublicID string `json:"connect_user_profile_public_id"`
+			EventName                   string `json:"event_name"`
+		} `json:"connection"`
+	}
+	s.expect(s.do(http.MethodPost, acceptPath, nil, aliceToken), http.StatusOK, &accepted)
+	// 申請者のプロフィールの連番のIDは承認した相手に返さない
+	if accepted.Connection.ProfileID != 0 || accepted.Connection.ConnectUsersProfilePublicID != s.publicID(aliceProfile) || accepted.Connection.EventName != "勉強会" {
+		t.Fatalf("accepted = %+v", accepted)
+	}
+	s.expect(s.do(http.MethodPost, acceptPath, nil, aliceToken), http.StatusConflict, nil)
+	var bobConnections struct {
+		Connections []struct {
+			ConnectedProfilePublicID string `json:"connected_profile_public_id"`
+			Memo                     string `json:"memo"`
+		} `json:"connections"`
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/users/%d/connections", bobID), nil, bobToken), http.StatusOK, &bobConnections)
+	if len(bobConnections.Connections) != 1 || bobConnections.Connections[0].ConnectedProfilePublicID != s.publicID(aliceProfile) || bobConnections.Connections[0].Memo != "Goの話をした" {
+		t.Fatalf("bob connections = %+v", bobConnections)
+	}
+	s.expect(s.do(http.MethodPost, "/api/connections", body, bobToken), http.StatusConflict, nil)
+
+	// 拒否した申請はコネクションを作成せず、改めて申請できる
+	carolBody := gin.H{"profile_id": carolProfile, "connect_user_profile_public_id": s.publicID(aliceProfile)}
+	s.expect(s.do(http.MethodPost, "/api/connections", carolBody, carolToken), http.StatusAccepted, &requested)
+	declinePath := fmt.Sprintf("/api/connection-requests/%d/decline", requested.Request.ID)
+	s.expect(s.do(http.MethodPost, declinePath, nil, carolToken), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodPost, declinePath, nil, aliceToken), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPost, declinePath, nil, aliceToken), http.StatusConflict, nil)
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/connection-requests/%d/accept", requested.Request.ID), nil, aliceToken), http.StatusConflict, nil)
+
+	var declined connectionRequestList
+	s.expect(s.do(http.MethodGet, "/api/connection-requests/incoming?status=declined", nil, aliceToken), http.StatusOK, &declined)
+	if declined.Total != 1 || declined.Requests[0].Profile.PublicID != s.publicID(carolProfile) {
+		t.Fatalf("declined = %+v", declined)
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/connections?profile_id=%d", carolProfile), nil, carolToken), http.StatusOK, &connections)
+	if connections.Total != 0 {
+		t.Fatalf("connections after decline = %d, want 0", connections.Total)
+	}
+	s.expect(s.do(http.MethodPost, "/api/connections", carolBody, carolToken), http.StatusAccepted, nil)
+}
+
+func TestConnectionRequestExpiryAndAutoAccept(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	aliceProfile := s.createProfile(aliceID, aliceToken, "Alice")
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+
+	// 有効期限を過ぎた申請はexpiredになり、承認できないが改めて申請できる
+	expired := models.ConnectionRequest{
+		ProfileID:             bobProfile,
+		ConnectUsersProfileID: aliceProfile,
+		ExpiresAt:             time.Now().Add(-time.Minute),
+	}
+	if err := s.stores.ConnectionRequests.Create(context.Background(), &expired); err != nil {
+		t.Fatal(err)
+	}
+	var list connectionRequestList
+	s.expect(s.do(http.MethodGet, "/api/connection-requests/incoming", nil, aliceToken), http.StatusOK, &list)
+	if list.Total != 1 || list.Requests[0].Status != "expired" {
+		t.Fatalf("incoming = %+v", list)
+	}
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/connection-requests/%d/accept", expired.ID), nil, aliceToken), http.StatusGone, nil)
+	body := gin.H{"profile_id": bobProfile, "connect_user_profile_public_id": s.publicID(aliceProfile)}
+	s.expect(s.do(http.MethodPost, "/api/connections", body, bobToken), http.StatusAccepted, nil)
+
+	// 自動で承認する設定はQRコードでの交換にだけ適用し、申請は承認待ちにする（設定は所有者にだけ返す）
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/profiles/%d", bobProfile), gin.H{"auto_accept_connections": true}, bobToken), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPost, "/api/connections", gin.H{"profile_id": aliceProfile, "connect_user_profile_public_id": s.publicID(bobProfile)}, aliceToken), http.StatusAccepted, nil)
+	path := "/api/profiles/" + s.publicID(bobProfile)
+	if body := s.do(http.MethodGet, path, nil, bobToken).Body.String(); !strings.Contains(body, `"auto_accept_connections":true`) {
+		t.Fatalf("owner profile = %s", body)
+	}
+	if body := s.do(http.MethodGet, path, nil, aliceToken).Body.String(); strings.Contains(body, "auto_accept_connections") {
+		t.Fatalf("auto accept setting leaked: %s", body)
+	}
+}
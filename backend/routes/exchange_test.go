@@ -0,0 +1,134 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type exchangeResult struct {
+	Connection struct {
+		ID                          int    `json:"id"`
+		ProfileID                   int    `json:"profile_id"`
+		ConnectUsersProfilePublicID string `json:"connect_user_profile_public_id"`
+		EventName                   string `json:"event_name"`
+		Memo                        string `json:"memo"`
+	} `json:"connection"`
+	ReverseConnection struct {
+		ID                          int    `json:"id"`
+		ProfileID                   int    `json:"profile_id"`
+		ConnectUsersProfilePublicID string `json:"connect_user_profile_public_id"`
+		EventName                   string `json:"event_name"`
+		Memo                        string `json:"memo"`
+	} `json:"reverse_connection"`
+	Profile        publicProfile `json:"profile"`
+	AlreadyExisted bool          `json:"already_existed"`
+}
+
+func TestMutualExchange(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	aliceProfile := s.createProfile(aliceID, aliceToken, "Alice")
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+
+	// 相互交換には1回限りで有効期間の短いトークンが必要
+	reusable := s.mintExchangeToken(aliceToken, aliceProfile, nil)
+	longLived := s.mintExchangeToken(aliceToken, aliceProfile, gin.H{"single_use": true, "ttl_seconds": 3600})
+	for _, token := range []string{reusable.Token, longLived.Token} {
+		s.expect(s.do(http.MethodPost, "/api/exchanges", gin.H{"token": token, "profile_id": bobProfile}, bobToken), http.StatusBadRequest, nil)
+	}
+
+	minted := s.mintExchangeToken(aliceToken, aliceProfile, gin.H{"single_use": true})
+	body := gin.H{"token": minted.Token, "profile_id": bobProfile, "event_name": "勉強会", "memo": "Goの話をした"}
+
+	// 双方向のコネクションをまとめて作成する（メモは本人側だけ）
+	var first exchangeResult
+	s.expect(s.do(http.MethodPost, "/api/exchanges", body, bobToken), http.StatusCreated, &first)
+	// 相手のプロフィールの連番のIDは返さず、公開IDで示す
+	if first.AlreadyExisted || first.Profile.ID != 0 || first.Profile.PublicID != s.publicID(aliceProfile) {
+		t.Fatalf("first = %+v", first)
+	}
+	if c := first.Connection; c.ProfileID != bobProfile || c.ConnectUsersProfilePublicID != s.publicID(aliceProfile) || c.Memo != "Goの話をした" {
+		t.Fatalf("connection = %+v", c)
+	}
+	if c := first.ReverseConnection; c.ProfileID != 0 || c.ConnectUsersProfilePublicID != s.publicID(bobProfile) || c.EventName != "勉強会" || c.Memo != "" {
+		t.Fatalf("reverse connection = %+v", c)
+	}
+	for _, user := range []struct {
+		id    int
+		token string
+	}{{aliceID, aliceToken}, {bobID, bobToken}} {
+		var list struct {
+			Total int `json:"total"`
+		}
+		s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/users/%d/connections", user.id), nil, user.token), http.StatusOK, &list)
+		if list.Total != 1 {
+			t.Fatalf("user %d connections = %d, want 1", user.id, list.Total)
+		}
+	}
+
+	// 再送しても重複せず、既存のコネクションを返す
+	var retry exchangeResult
+	s.expect(s.do(http.MethodPost, "/api/exchanges", body, bobToken), http.StatusOK, &retry)
+	if !retry.AlreadyExisted || retry.Connection.ID != first.Connection.ID || retry.ReverseConnection.ID != first.ReverseConnection.ID {
+		t.Fatalf("retry = %+v", retry)
+	}
+	s.expect(s.do(http.MethodPost, "/api/connections", gin.H{"profile_id": bobProfile, "connect_user_profile_public_id": s.publicID(aliceProfile)}, bobToken), http.StatusConflict, nil)
+
+	// 自分のプロフィール・他人のプロフィールでは交換できない
+	s.expect(s.do(http.MethodPost, "/api/exchanges", gin.H{"token": minted.Token, "profile_id": aliceProfile}, aliceToken), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/exchanges", gin.H{"token": minted.Token, "profile_id": aliceProfile}, bobToken), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodPost, "/api/exchanges", gin.H{"token": "not-a-token", "profile_id": bobProfile}, bobToken), http.StatusBadRequest, nil)
+}
+
+func TestMutualExchangeCompletesOneSidedConnection(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	carolID, carolToken := s.signUp("Carol", "carol@example.com")
+	aliceProfile := s.createProfile(aliceID, aliceToken, "Alice")
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+	carolProfile := s.createProfile(carolID, carolToken, "Carol")
+
+	// 片方向だけのコネクションは残りの向きを作成して交換済みにする
+	oneSided := s.connect(bobToken, bobProfile, aliceProfile, aliceToken)
+
+	single := s.mintExchangeToken(aliceToken, aliceProfile, gin.H{"single_use": true})
+	body := gin.H{"token": single.Token, "profile_id": bobProfile}
+	var exchanged exchangeResult
+	s.expect(s.do(http.MethodPost, "/api/exchanges", body, bobToken), http.StatusCreated, &exchanged)
+	if exchanged.AlreadyExisted || exchanged.Connection.ID != oneSided || exchanged.ReverseConnection.ID == 0 {
+		t.Fatalf("exchanged = %+v", exchanged)
+	}
+
+	// 使い捨てのトークンでも交換済みなら再送できるが、他の人は使えない
+	s.expect(s.do(http.MethodPost, "/api/exchanges", body, bobToken), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPost, "/api/exchanges", gin.H{"token": single.Token, "profile_id": carolProfile}, carolToken), http.StatusGone, nil)
+	var aliceConnections struct {
+		Total int `json:"total"`
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/connections?profile_id=%d", aliceProfile), nil, aliceToken), http.StatusOK, &aliceConnections)
+	if aliceConnections.Total != 1 {
+		t.Fatalf("alice connections = %d, want 1", aliceConnections.Total)
+	}
+}
+
+func TestMutualExchangeWithAutoAccept(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	aliceProfile := s.createProfile(aliceID, aliceToken, "Alice")
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+
+	// 申請を自動で承認する設定のプロフィールは、繰り返し使えるトークンでも相互交換できる
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/profiles/%d", aliceProfile), gin.H{"auto_accept_connections": true}, aliceToken), http.StatusOK, nil)
+	reusable := s.mintExchangeToken(aliceToken, aliceProfile, gin.H{"ttl_seconds": 3600})
+	var exchanged exchangeResult
+	s.expect(s.do(http.MethodPost, "/api/exchanges", gin.H{"token": reusable.Token, "profile_id": bobProfile}, bobToken), http.StatusCreated, &exchanged)
+	if exchanged.ReverseConnection.ID == 0 || exchanged.ReverseConnection.ConnectUsersProfilePublicID != s.publicID(bobProfile) {
+		t.Fatalf("exchanged = %+v", exchanged)
+	}
+}
@@ -0,0 +1,128 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type exchangeToken struct {
+	Token     string    `json:"token"`
+	URL       string    `json:"url"`
+	QRData    string    `json:"qr_data"`
+	ExpiresAt time.Time `json:"expires_at"`
+	SingleUse bool      `json:"single_use"`
+}
+
+// mintExchangeToken はプロフィールの交換トークンを発行します
+func (s *testServer) mintExchangeToken(token string, profileID int, body gin.H) exchangeToken {
+	s.t.Helper()
+	if body == nil {
+		body = gin.H{}
+	}
+	body["profile_id"] = profileID
+	var res exchangeToken
+	s.expect(s.do(http.MethodPost, "/api/exchange-tokens", body, token), http.StatusCreated, &res)
+	return res
+}
+
+func TestExchangeTokenMintAndRedeem(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	aliceProfile := s.createProfile(aliceID, aliceToken, "Alice")
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+
+	minted := s.mintExchangeToken(aliceToken, aliceProfile, gin.H{"ttl_seconds": 120})
+	if !strings.HasPrefix(minted.URL, "http://localhost:3000/exchange?token=") || !strings.HasPrefix(minted.QRData, "data:image/png;base64,") {
+		t.Fatalf("minted = %+v", minted)
+	}
+	if d := time.Until(minted.ExpiresAt); d < 100*time.Second || d > 120*time.Second {
+		t.Fatalf("expires in %v, want about 120s", d)
+	}
+	// トークンに連番のIDは含めない
+	if strings.Contains(minted.Token, "profile_id") {
+		t.Fatal("token must not contain the internal profile id")
+	}
+
+	// 他人のプロフィール・範囲外の有効期間では発行できない
+	s.expect(s.do(http.MethodPost, "/api/exchange-tokens", gin.H{"profile_id": aliceProfile}, bobToken), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodPost, "/api/exchange-tokens", gin.H{"profile_id": aliceProfile, "ttl_seconds": 5}, aliceToken), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/exchange-tokens", gin.H{"profile_id": aliceProfile, "ttl_seconds": 90000}, aliceToken), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/exchange-tokens", gin.H{"profile_id": aliceProfile}, ""), http.StatusUnauthorized, nil)
+
+	// 非公開のプロフィールでもトークンがあれば確認できる
+	s.expect(s.do(http.MethodPut, "/api/profiles/"+fmt.Sprint(aliceProfile), gin.H{"visibility": "private"}, aliceToken), http.StatusOK, nil)
+	var preview struct {
+		Profile   publicProfile `json:"profile"`
+		SingleUse bool          `json:"single_use"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/exchange-tokens/preview", gin.H{"token": minted.Token}, bobToken), http.StatusOK, &preview)
+	if preview.Profile.DisplayName != "Alice" || preview.Profile.ID != 0 || preview.Profile.PublicID != s.publicID(aliceProfile) {
+		t.Fatalf("preview = %+v", preview)
+	}
+
+	// 交換すると自分のプロフィールから相手へのコネクションができる
+	redeem := gin.H{"token": minted.Token, "profile_id": bobProfile, "event_name": "勉強会"}
+	var redeemed struct {
+		Connection struct {
+			ProfileID                   int    `json:"profile_id"`
+			ConnectUsersProfilePublicID string `json:"connect_user_profile_public_id"`
+			EventName                   string `json:"event_name"`
+		} `json:"connection"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/exchange-tokens/redeem", redeem, bobToken), http.StatusCreated, &redeemed)
+	if redeemed.Connection.ProfileID != bobProfile || redeemed.Connection.ConnectUsersProfilePublicID != s.publicID(aliceProfile) || redeemed.Connection.EventName != "勉強会" {
+		t.Fatalf("redeemed = %+v", redeemed)
+	}
+	s.expect(s.do(http.MethodPost, "/api/exchange-tokens/redeem", redeem, bobToken), http.StatusConflict, nil)
+
+	// 自分のプロフィール・他人のプロフィールからは交換できない
+	s.expect(s.do(http.MethodPost, "/api/exchange-tokens/redeem", gin.H{"token": minted.Token, "profile_id": aliceProfile}, aliceToken), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/exchange-tokens/redeem", gin.H{"token": minted.Token, "profile_id": aliceProfile}, bobToken), http.StatusForbidden, nil)
+}
+
+func TestExchangeTokenRejectsInvalidTokens(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	carolID, carolToken := s.signUp("Carol", "carol@example.com")
+	aliceProfile := s.createProfile(aliceID, aliceToken, "Alice")
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+	carolProfile := s.createProfile(carolID, carolToken, "Carol")
+
+	// 1回限りのトークンは最初の交換で使用済みになる
+	single := s.mintExchangeToken(aliceToken, aliceProfile, gin.H{"single_use": true})
+	if !single.SingleUse {
+		t.Fatalf("single = %+v", single)
+	}
+	s.expect(s.do(http.MethodPost, "/api/exchange-tokens/redeem", gin.H{"token": single.Token, "profile_id": bobProfile}, bobToken), http.StatusCreated, nil)
+	s.expect(s.do(http.MethodPost, "/api/exchange-tokens/redeem", gin.H{"token": single.Token, "profile_id": carolProfile}, carolToken), http.StatusGone, nil)
+
+	// 既に交換済みで失敗した場合は使用済みにならない
+	again := s.mintExchangeToken(aliceToken, aliceProfile, gin.H{"single_use": true})
+	s.expect(s.do(http.MethodPost, "/api/exchange-tokens/redeem", gin.H{"token": again.Token, "profile_id": bobProfile}, bobToken), http.StatusConflict, nil)
+	s.expect(s.do(http.MethodPost, "/api/exchange-tokens/redeem", gin.H{"token": again.Token, "profile_id": carolProfile}, carolToken), http.StatusCreated, nil)
+
+	// 期限切れ・改ざん・用途の違うトークンは受け付けない
+	publicID := s.publicID(aliceProfile)
+	expired, _, err := s.app.Keys.GenerateExchangeToken(publicID, false, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	multi := s.mintExchangeToken(aliceToken, aliceProfile, nil)
+	for _, token := range []string{expired, multi.Token[:len(multi.Token)-4] + "AAAA", bobToken, "not-a-token"} {
+		s.expect(s.do(http.MethodPost, "/api/exchange-tokens/redeem", gin.H{"token": token, "profile_id": carolProfile}, carolToken), http.StatusBadRequest, nil)
+		s.expect(s.do(http.MethodPost, "/api/exchange-tokens/preview", gin.H{"token": token}, carolToken), http.StatusBadRequest, nil)
+	}
+	// 交換トークンはアクセストークンとしては使えない
+	s.expect(s.do(http.MethodGet, "/api/sessions", nil, multi.Token), http.StatusUnauthorized, nil)
+
+	// プロフィールを削除するとトークンも使えない
+	s.expect(s.do(http.MethodDelete, "/api/profiles/"+fmt.Sprint(aliceProfile), nil, aliceToken), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPost, "/api/exchange-tokens/redeem", gin.H{"token": multi.Token, "profile_id": carolProfile}, carolToken), http.StatusNotFound, nil)
+}
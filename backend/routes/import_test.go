@@ -0,0 +1,206 @@
+package routes
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type importResult struct {
+	Error    string `json:"error"`
+	DryRun   bool   `json:"dry_run"`
+	Format   string `json:"format"`
+	Total    int    `json:"total"`
+	Valid    int    `json:"valid"`
+	Imported int    `json:"imported"`
+	Records  []struct {
+		Index   int `json:"index"`
+		Profile struct {
+			ID          int    `json:"id"`
+			DisplayName string `json:"display_name"`
+			AKA         string `json:"aka"`
+			Hometown    string `json:"hometown"`
+			Birthdate   string `json:"birthdate"`
+			Title       string `json:"title"`
+			Visibility  string `json:"visibility"`
+			PublicID    string `json:"public_id"`
+		} `json:"profile"`
+		Links []struct {
+			Title string `json:"title"`
+			URL   string `json:"url"`
+		} `json:"links"`
+		OptionProfiles []struct {
+			Title   string `json:"title"`
+			Content string `json:"content"`
+		} `json:"option_profiles"`
+		Errors []string `json:"errors"`
+	} `json:"records"`
+}
+
+// upload はファイルと項目をmultipart/form-dataで送信します
+func (s *testServer) upload(path, filename, content string, fields map[string]string, token string) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, value := range fields {
+		mw.WriteField(name, value)
+	}
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	part.Write([]byte(content))
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+const importVCards = "BEGIN:VCARD\r\n" +
+	"VERSION:3.0\r\n" +
+	"N:山田;太郎;;;\r\n" +
+	"TITLE:エンジニア\\, リーダー\r\n" +
+	"ORG:Example Inc.;開発部\r\n" +
+	"BDAY:1990-04-01\r\n" +
+	"EMAIL;TYPE=INTERNET;TYPE=WORK:taro@example.com\r\n" +
+	"TEL;CELL:090 1234 5678\r\n" +
+	"item1.URL:https\\://github.com/taro\r\n" +
+	"NOTE;ENCODING=QUOTED-PRINTABLE;CHARSET=UTF-8:=E3=82=88=E3=82=8D=E3=81=97=E3=81=8F=\r\n" +
+	"=E3=81=AD\r\n" +
+	"END:VCARD\r\n" +
+	"BEGIN:VCARD\r\n" +
+	"VERSION:4.0\r\n" +
+	"FN:Hanako\r\n" +
+	"BIRTHPLACE:Osaka\r\n" +
+	"URL;X-SERVICE-TYPE=Blog:https://example.com/hanako/very/long/path/that/needs/to/be/folded/acro\r\n" +
+	" ss/lines\r\n" +
+	"X-QRSONA-OPTION;X-LABEL=好きな食べ物:カレー\r\n" +
+	"END:VCARD\r\n"
+
+func TestImportVCard(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	aliceProfile := s.createProfile(aliceID, aliceToken, "Alice")
+
+	// ドライランでは変換結果だけを返し、何も登録しない
+	var preview importResult
+	s.expect(s.upload("/api/profiles/import?dry_run=true", "contacts.vcf", importVCards, nil, aliceToken), http.StatusOK, &preview)
+	if !preview.DryRun || preview.Format != "vcard" || preview.Total != 2 || preview.Valid != 2 || preview.Imported != 0 {
+		t.Fatalf("preview = %+v", preview)
+	}
+	taro := preview.Records[0]
+	if taro.Profile.DisplayName != "山田 太郎" || taro.Profile.AKA != "エンジニア, リーダー" || taro.Profile.Visibility != "private" || taro.Profile.ID != 0 {
+		t.Fatalf("taro = %+v", taro.Profile)
+	}
+	wantLinks := []string{"Email mailto:taro@example.com", "電話 tel:09012345678", "GitHub https://github.com/taro"}
+	if len(taro.Links) != len(wantLinks) {
+		t.Fatalf("taro links = %+v", taro.Links)
+	}
+	for i, want := range wantLinks {
+		if got := taro.Links[i].Title + " " + taro.Links[i].URL; got != want {
+			t.Fatalf("link[%d] = %q, want %q", i, got, want)
+		}
+	}
+	if len(taro.OptionProfiles) != 1 || taro.OptionProfiles[0].Content != "Example Inc. 開発部" {
+		t.Fatalf("taro options = %+v", taro.OptionProfiles)
+	}
+	hanako := preview.Records[1]
+	if hanako.Profile.Hometown != "Osaka" || len(hanako.Links) != 1 || hanako.Links[0].Title != "Blog" ||
+		!strings.HasSuffix(hanako.Links[0].URL, "/across/lines") || hanako.OptionProfiles[0].Title != "好きな食べ物" {
+		t.Fatalf("hanako = %+v", hanako)
+	}
+	var profiles struct {
+		Count int `json:"count"`
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/users/%d/profiles", aliceID), nil, aliceToken), http.StatusOK, &profiles)
+	if profiles.Count != 1 {
+		t.Fatalf("profiles after dry run = %d, want 1", profiles.Count)
+	}
+
+	// 登録すると交換済み一覧にも表示される
+	var imported importResult
+	s.expect(s.upload("/api/profiles/import", "contacts.vcf", importVCards, map[string]string{
+		"profile_id": fmt.Sprint(aliceProfile), "title": "名刺",
+	}, aliceToken), http.StatusCreated, &imported)
+	if imported.Imported != 2 || imported.Records[0].Profile.ID == 0 || imported.Records[0].Profile.PublicID == "" || imported.Records[0].Profile.Title != "名刺" {
+		t.Fatalf("imported = %+v", imported)
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/users/%d/profiles", aliceID), nil, aliceToken), http.StatusOK, &profiles)
+	if profiles.Count != 3 {
+		t.Fatalf("profiles after import = %d, want 3", profiles.Count)
+	}
+	var connections struct {
+		Total int `json:"total"`
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/users/%d/connections", aliceID), nil, aliceToken), http.StatusOK, &connections)
+	if connections.Total != 2 {
+		t.Fatalf("connections = %d, want 2", connections.Total)
+	}
+	var links struct {
+		Total int `json:"total"`
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/links/profile/%d", imported.Records[0].Profile.ID), nil, aliceToken), http.StatusOK, &links)
+	if links.Total != 3 {
+		t.Fatalf("imported links = %d, want 3", links.Total)
+	}
+
+	// 他人のプロフィールからはコネクションを作成できない
+	_, bobToken := s.signUp("Bob", "bob@example.com")
+	s.expect(s.upload("/api/profiles/import", "contacts.vcf", importVCards, map[string]string{
+		"profile_id": fmt.Sprint(aliceProfile),
+	}, bobToken), http.StatusForbidden, nil)
+	s.expect(s.upload("/api/profiles/import", "contacts.vcf", "BEGIN:VCARD\r\nFN:Broken\r\n", nil, aliceToken), http.StatusBadRequest, nil)
+}
+
+func TestImportCSVValidation(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+
+	csv := "\ufeffname,aka,birthdate,email,link:Portfolio,option:最寄り駅\n" +
+		"Taro,Engineer,1990-04-01,taro@example.com,https://example.com/taro,梅田\n" +
+		",Designer,1990/04/01,,not a url,\n" +
+		"\"Jiro, Jr.\",,,,,\n"
+
+	// 1件でもエラーがあれば何も登録せず、レコードごとのエラーを返す
+	var result importResult
+	s.expect(s.upload("/api/profiles/import", "contacts.csv", csv, nil, aliceToken), http.StatusUnprocessableEntity, &result)
+	if result.Error == "" || result.Format != "csv" || result.Total != 3 || result.Valid != 2 || result.Imported != 0 {
+		t.Fatalf("result = %+v", result)
+	}
+	if errs := result.Records[1].Errors; len(errs) != 3 {
+		t.Fatalf("record 2 errors = %v, want 3", errs)
+	}
+	if result.Records[0].Errors != nil || result.Records[2].Profile.DisplayName != "Jiro, Jr." {
+		t.Fatalf("records = %+v", result.Records)
+	}
+	var profiles struct {
+		Count int `json:"count"`
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/users/%d/profiles", aliceID), nil, aliceToken), http.StatusOK, &profiles)
+	if profiles.Count != 0 {
+		t.Fatalf("profiles after failed import = %d, want 0", profiles.Count)
+	}
+
+	// エラーを直せばまとめて登録できる
+	fixed := strings.Replace(csv, ",Designer,1990/04/01,,not a url,", "Hanako,Designer,1990-04-01,,https://example.com/hanako,", 1)
+	var imported importResult
+	s.expect(s.upload("/api/profiles/import", "contacts.csv", fixed, map[string]string{"visibility": "unlisted"}, aliceToken), http.StatusCreated, &imported)
+	if imported.Imported != 3 || imported.Records[0].Profile.Visibility != "unlisted" || imported.Records[0].OptionProfiles[0].Title != "最寄り駅" {
+		t.Fatalf("imported = %+v", imported)
+	}
+
+	s.expect(s.upload("/api/profiles/import", "contacts.csv", "name,nickname\nTaro,T\n", nil, aliceToken), http.StatusBadRequest, nil)
+	s.expect(s.upload("/api/profiles/import", "contacts.csv", fixed, map[string]string{"visibility": "everyone"}, aliceToken), http.StatusBadRequest, nil)
+	s.expect(s.upload("/api/profiles/import", "contacts.csv", fixed, nil, ""), http.StatusUnauthorized, nil)
+}
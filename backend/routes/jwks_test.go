@@ -0,0 +1,223 @@
+package routes
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/handlers"
+	"backend/store"
+	"backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// writeKey は秘密鍵をPKCS#8のPEMとして <dir>/<kid>.pem に保存します
+func writeKey(t *testing.T, dir, kid string, key interface{}) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newKeyedServer は鍵ディレクトリとアクティブなkidを指定してテスト用サーバーを作成します
+// 再起動したサーバーを再現するため、ストアは呼び出し側から渡します
+func newKeyedServer(t *testing.T, stores *store.Stores, dir, activeKID string) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	t.Setenv("JWT_KEYS_DIR", dir)
+	t.Setenv("JWT_ACTIVE_KID", activeKID)
+	t.Setenv("CLOUDINARY_CLOUD_NAME", "")
+	mailDir := t.TempDir()
+	t.Setenv("MAIL_DRIVER", "file")
+	t.Setenv("MAIL_DIR", mailDir)
+
+	app, err := handlers.NewApp(stores)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	RegisterRoutes(r, app)
+	return &testServer{t: t, router: r, app: app, stores: stores, mailDir: mailDir}
+}
+
+// tokenHeader はJWTのヘッダー部をデコードします
+func tokenHeader(t *testing.T, token string) map[string]interface{} {
+	t.Helper()
+	raw, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var header map[string]interface{}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		t.Fatal(err)
+	}
+	return header
+}
+
+func TestJWKSPublishesSigningKey(t *testing.T) {
+	s := newTestServer(t)
+	_, token := s.signUp("Alice", "alice@example.com")
+
+	var jwks utils.JWKSet
+	w := s.do(http.MethodGet, "/.well-known/jwks.json", nil, "")
+	s.expect(w, http.StatusOK, &jwks)
+	if len(jwks.Keys) != 1 {
+		t.Fatalf("len(keys) = %d, want 1", len(jwks.Keys))
+	}
+	key := jwks.Keys[0]
+	if key.KeyType != "OKP" || key.Curve != "Ed25519" || key.Algorithm != "EdDSA" || key.X == "" {
+		t.Fatalf("unexpected jwk: %+v", key)
+	}
+
+	header := tokenHeader(t, token)
+	if header["alg"] != "EdDSA" || header["kid"] != key.KeyID {
+		t.Fatalf("token header = %v, want kid %q", header, key.KeyID)
+	}
+
+	// 公開されたJWKだけでトークンを検証できる
+	x, err := base64.RawURLEncoding.DecodeString(key.X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(token, func(*jwt.Token) (interface{}, error) {
+		return ed25519.PublicKey(x), nil
+	}, jwt.WithValidMethods([]string{"EdDSA"})); err != nil {
+		t.Fatalf("token does not verify with published key: %v", err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	_, oldKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeKey(t, dir, "2026-01", oldKey)
+	writeKey(t, dir, "2026-02", newKey)
+
+	stores := store.NewMemory()
+	before := newKeyedServer(t, stores, dir, "2026-01")
+	_, oldToken := before.signUp("Alice", "alice@example.com")
+	if kid := tokenHeader(t, oldToken)["kid"]; kid != "2026-01" {
+		t.Fatalf("kid = %v, want 2026-01", kid)
+	}
+
+	// JWT_ACTIVE_KID 未指定時は辞書順で最後の鍵で署名する
+	after := newKeyedServer(t, stores, dir, "")
+	_, newToken := after.signUp("Bob", "bob@example.com")
+	header := tokenHeader(t, newToken)
+	if header["kid"] != "2026-02" || header["alg"] != "RS256" {
+		t.Fatalf("token header = %v, want RS256 with kid 2026-02", header)
+	}
+
+	// ローテーション後も古い鍵で署名されたトークンを受け付ける
+	after.expect(after.do(http.MethodGet, "/api/users", nil, oldToken), http.StatusOK, nil)
+	after.expect(after.do(http.MethodGet, "/api/users", nil, newToken), http.StatusOK, nil)
+
+	var jwks utils.JWKSet
+	after.expect(after.do(http.MethodGet, "/.well-known/jwks.json", nil, ""), http.StatusOK, &jwks)
+	if len(jwks.Keys) != 2 || jwks.Keys[0].KeyID != "2026-01" || jwks.Keys[1].KeyType != "RSA" {
+		t.Fatalf("unexpected jwks: %+v", jwks)
+	}
+
+	// 古い鍵を取り除くと、その鍵のトークンは無効になる
+	if err := os.Remove(filepath.Join(dir, "2026-01.pem")); err != nil {
+		t.Fatal(err)
+	}
+	retired := newKeyedServer(t, stores, dir, "")
+	retired.expect(retired.do(http.MethodGet, "/api/users", nil, oldToken), http.StatusUnauthorized, nil)
+}
+
+func TestTokensWithUnexpectedAlgorithmAreRejected(t *testing.T) {
+	s := newTestServer(t)
+	_, token := s.signUp("Alice", "alice@example.com")
+	kid := tokenHeader(t, token)["kid"].(string)
+
+	var jwks utils.JWKSet
+	s.expect(s.do(http.MethodGet, "/.well-known/jwks.json", nil, ""), http.StatusOK, &jwks)
+	publicKey, _ := base64.RawURLEncoding.DecodeString(jwks.Keys[0].X)
+
+	claims := utils.Claims{
+		UserID: 1,
+		Email:  "alice@example.com",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "forged",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+
+	// 公開鍵をHMACの秘密として使うアルゴリズム混同攻撃
+	hs := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	hs.Header["kid"] = kid
+	forged, err := hs.SignedString(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.expect(s.do(http.MethodGet, "/api/users", nil, forged), http.StatusUnauthorized, nil)
+
+	// 署名なし（alg: none）
+	none := jwt.NewWithClaims(jwt.SigningMethodNone, claims)
+	none.Header["kid"] = kid
+	unsigned, err := none.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.expect(s.do(http.MethodGet, "/api/users", nil, unsigned), http.StatusUnauthorized, nil)
+
+	// 公開されていない鍵での署名
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	other := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	other.Header["kid"] = kid
+	foreign, err := other.SignedString(otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.expect(s.do(http.MethodGet, "/api/users", nil, foreign), http.StatusUnauthorized, nil)
+}
+
+func TestTokensWithoutExpirationAreRejected(t *testing.T) {
+	dir := t.TempDir()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeKey(t, dir, "2026-01", key)
+	s := newKeyedServer(t, store.NewMemory(), dir, "2026-01")
+	userID, _ := s.signUp("Alice", "alice@example.com")
+
+	// 正しい鍵で署名されていても、有効期限（exp）のないトークンは受け付けない
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, utils.Claims{
+		UserID:           userID,
+		Email:            "alice@example.com",
+		TokenUse:         utils.TokenUseAccess,
+		RegisteredClaims: jwt.RegisteredClaims{ID: "no-exp", IssuedAt: jwt.NewNumericDate(time.Now())},
+	})
+	token.Header["kid"] = "2026-01"
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.expect(s.do(http.MethodGet, "/api/users", nil, signed), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodGet, "/api/profiles/unknown", nil, signed), http.StatusNotFound, nil)
+}
@@ -0,0 +1,302 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"backend/oauth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mockAccount はモックプロバイダーのアカウントです
+type mockAccount struct {
+	Subject  string
+	Email    string
+	Verified bool
+	Name     string
+	Login    string // GitHubのログイン名
+}
+
+type mockGrant struct {
+	challenge   string
+	redirectURI string
+	account     mockAccount
+}
+
+// mockProvider はOIDCプロバイダーとGitHubのエンドポイントを模したテスト用サーバーです
+type mockProvider struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu     sync.Mutex
+	seq    int
+	grants map[string]mockGrant   // 認可コード -> 認可内容
+	tokens map[string]mockAccount // アクセストークン -> アカウント
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+	p := &mockProvider{t: t, grants: map[string]mockGrant{}, tokens: map[string]mockAccount{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"userinfo_endpoint":      p.server.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/github/login/oauth/access_token", p.token)
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		account, ok := p.account(r)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"sub": account.Subject, "email": account.Email, "email_verified": account.Verified, "name": account.Name,
+		})
+	})
+	mux.HandleFunc("/github-api/user", func(w http.ResponseWriter, r *http.Request) {
+		account, ok := p.account(r)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"id": json.Number(account.Subject), "login": account.Login, "name": account.Name,
+			"html_url": "https://github.com/" + account.Login,
+		})
+	})
+	mux.HandleFunc("/github-api/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		account, ok := p.account(r)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, []map[string]interface{}{
+			{"email": "noreply@users.github.com", "primary": false, "verified": true},
+			{"email": account.Email, "primary": true, "verified": account.Verified},
+		})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	t.Setenv("OAUTH_GOOGLE_CLIENT_ID", "google-client")
+	t.Setenv("OAUTH_GOOGLE_CLIENT_SECRET", "google-secret")
+	t.Setenv("OAUTH_GOOGLE_ISSUER", p.server.URL)
+	t.Setenv("OAUTH_GITHUB_CLIENT_ID", "github-client")
+	t.Setenv("OAUTH_GITHUB_CLIENT_SECRET", "github-secret")
+	t.Setenv("OAUTH_GITHUB_URL", p.server.URL+"/github")
+	t.Setenv("OAUTH_GITHUB_API_URL", p.server.URL+"/github-api")
+	return p
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// authorize は利用者が認可画面で許可した状態を再現し、認可コードとstateを返します
+func (p *mockProvider) authorize(authURL string, account mockAccount) (code, state string) {
+	p.t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		p.t.Fatalf("unexpected authorization request: %s", authURL)
+	}
+	if !strings.HasPrefix(q.Get("redirect_uri"), "http://localhost:3000/auth/callback/") {
+		p.t.Fatalf("redirect_uri = %q", q.Get("redirect_uri"))
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.seq++
+	code = fmt.Sprintf("code-%d", p.seq)
+	p.grants[code] = mockGrant{challenge: q.Get("code_challenge"), redirectURI: q.Get("redirect_uri"), account: account}
+	return code, q.Get("state")
+}
+
+// token は認可コードとPKCEのcode_verifierを検証してアクセストークンを発行します
+func (p *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	grant, ok := p.grants[r.Form.Get("code")]
+	delete(p.grants, r.Form.Get("code"))
+	if !ok || oauth.CodeChallenge(r.Form.Get("code_verifier")) != grant.challenge ||
+		r.Form.Get("redirect_uri") != grant.redirectURI || !strings.HasSuffix(r.Form.Get("client_secret"), "-secret") {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	accessToken := "token-" + r.Form.Get("code")
+	p.tokens[accessToken] = grant.account
+	writeJSON(w, map[string]string{"access_token": accessToken, "token_type": "Bearer"})
+}
+
+func (p *mockProvider) account(r *http.Request) (mockAccount, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	account, ok := p.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	return account, ok
+}
+
+// oauthSignIn はプロバイダーでのログインを最初から最後まで行い、コールバックのレスポンスを返します
+func (s *testServer) oauthSignIn(p *mockProvider, provider string, account mockAccount) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var start struct {
+		AuthorizationURL string `json:"authorization_url"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/oauth/"+provider+"/start", nil, ""), http.StatusOK, &start)
+	code, state := p.authorize(start.AuthorizationURL, account)
+	return s.do(http.MethodPost, "/api/oauth/"+provider+"/callback", gin.H{"code": code, "state": state}, "")
+}
+
+func TestOAuthProviders(t *testing.T) {
+	newMockProvider(t)
+	s := newTestServer(t)
+
+	var res struct {
+		Providers []string `json:"providers"`
+	}
+	s.expect(s.do(http.MethodGet, "/api/oauth/providers", nil, ""), http.StatusOK, &res)
+	if strings.Join(res.Providers, ",") != "github,google" {
+		t.Fatalf("providers = %v", res.Providers)
+	}
+	s.expect(s.do(http.MethodPost, "/api/oauth/unknown/start", nil, ""), http.StatusNotFound, nil)
+}
+
+func TestOIDCSignInCreatesAndLinksAccounts(t *testing.T) {
+	p := newMockProvider(t)
+	s := newTestServer(t)
+	carol := mockAccount{Subject: "g-carol", Email: "Carol@Example.com", Verified: true, Name: "Carol"}
+
+	// 初回は確認済みのアカウントとして新規登録される
+	var first authResponse
+	s.expect(s.oauthSignIn(p, "google", carol), http.StatusOK, &first)
+	if first.User.Email != "carol@example.com" || first.User.Name != "Carol" || first.Token == "" {
+		t.Fatalf("unexpected response: %+v", first)
+	}
+	s.createProfile(first.User.ID, first.Token, "Carol")
+
+	var second authResponse
+	s.expect(s.oauthSignIn(p, "google", carol), http.StatusOK, &second)
+	if second.User.ID != first.User.ID {
+		t.Fatalf("user id = %d, want %d", second.User.ID, first.User.ID)
+	}
+
+	// 確認済みの既存アカウントには同じメールアドレスで紐付く
+	bobID, _ := s.signUp("Bob", "bob@example.com")
+	var bob authResponse
+	s.expect(s.oauthSignIn(p, "google", mockAccount{Subject: "g-bob", Email: "bob@example.com", Verified: true}), http.StatusOK, &bob)
+	if bob.User.ID != bobID {
+		t.Fatalf("user id = %d, want %d", bob.User.ID, bobID)
+	}
+
+	// 未確認の既存アカウントやプロバイダーで未確認のメールアドレスには紐付けない
+	s.signUpUnverified("Dave", "dave@example.com")
+	s.expect(s.oauthSignIn(p, "google", mockAccount{Subject: "g-dave", Email: "dave@example.com", Verified: true}), http.StatusConflict, nil)
+	s.expect(s.oauthSignIn(p, "google", mockAccount{Subject: "g-erin", Email: "bob@example.com", Verified: false}), http.StatusBadRequest, nil)
+}
+
+func TestOAuthStateIsSingleUseAndBoundToProvider(t *testing.T) {
+	p := newMockProvider(t)
+	s := newTestServer(t)
+	account := mockAccount{Subject: "g-carol", Email: "carol@example.com", Verified: true}
+
+	var start struct {
+		AuthorizationURL string `json:"authorization_url"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/oauth/github/start", nil, ""), http.StatusOK, &start)
+	code, state := p.authorize(start.AuthorizationURL, account)
+
+	// 別のプロバイダー向けのstateや不明なstateは受け付けない
+	s.expect(s.do(http.MethodPost, "/api/oauth/google/callback", gin.H{"code": code, "state": state}, ""), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/oauth/github/callback", gin.H{"code": code, "state": state}, ""), http.StatusBadRequest, nil)
+
+	// 認可コードをプロバイダーが拒否した場合
+	s.expect(s.do(http.MethodPost, "/api/oauth/google/start", nil, ""), http.StatusOK, &start)
+	_, state = p.authorize(start.AuthorizationURL, account)
+	s.expect(s.do(http.MethodPost, "/api/oauth/google/callback", gin.H{"code": "forged", "state": state}, ""), http.StatusUnauthorized, nil)
+}
+
+func TestGitHubSignInPopulatesLinkOnFirstProfile(t *testing.T) {
+	p := newMockProvider(t)
+	s := newTestServer(t)
+	octo := mockAccount{Subject: "1001", Email: "octo@example.com", Verified: true, Login: "octocat"}
+
+	var res authResponse
+	s.expect(s.oauthSignIn(p, "github", octo), http.StatusOK, &res)
+	if res.User.Name != "octocat" {
+		t.Fatalf("name = %q, want login name", res.User.Name)
+	}
+
+	profileLinks := func(profileID int) []string {
+		var list struct {
+			Links []struct {
+				URL string `json:"url"`
+			} `json:"links"`
+		}
+		s.expect(s.do(http.MethodGet, "/api/links/profile/"+s.publicID(profileID), nil, ""), http.StatusOK, &list)
+		var urls []string
+		for _, l := range list.Links {
+			urls = append(urls, l.URL)
+		}
+		return urls
+	}
+
+	first := s.createProfile(res.User.ID, res.Token, "Octo")
+	if got := profileLinks(first); len(got) != 1 || got[0] != "https://github.com/octocat" {
+		t.Fatalf("links = %v", got)
+	}
+	second := s.createProfile(res.User.ID, res.Token, "Octo (work)")
+	if got := profileLinks(second); len(got) != 0 {
+		t.Fatalf("links = %v, want none on second profile", got)
+	}
+
+	// 既存のプロフィールがあるアカウントは連携時に最初のプロフィールへ追加し、再ログインでは重複させない
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	aliceProfile := s.createProfile(aliceID, aliceToken, "Alice")
+	alice := mockAccount{Subject: "1002", Email: "alice@example.com", Verified: true, Login: "alice"}
+	s.expect(s.oauthSignIn(p, "github", alice), http.StatusOK, nil)
+	s.expect(s.oauthSignIn(p, "github", alice), http.StatusOK, nil)
+	if got := profileLinks(aliceProfile); len(got) != 1 || got[0] != "https://github.com/alice" {
+		t.Fatalf("links = %v", got)
+	}
+
+	// 最初のプロフィールが限定公開の場合はGitHubアカウントと結び付けない
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/profiles/%d", bobProfile), gin.H{"visibility": "unlisted"}, bobToken), http.StatusOK, nil)
+	s.expect(s.oauthSignIn(p, "github", mockAccount{Subject: "1003", Email: "bob@example.com", Verified: true, Login: "bob"}), http.StatusOK, nil)
+	if got := profileLinks(bobProfile); len(got) != 0 {
+		t.Fatalf("links = %v, want none on unlisted profile", got)
+	}
+}
+
+func TestOAuthSignInRequiresSecondFactor(t *testing.T) {
+	p := newMockProvider(t)
+	s := newTestServer(t)
+	_, token := s.signUp("Alice", "alice@example.com")
+	s.enableTOTP(token)
+
+	var res challengeResponse
+	s.expect(s.oauthSignIn(p, "google", mockAccount{Subject: "g-alice", Email: "alice@example.com", Verified: true}), http.StatusOK, &res)
+	if !res.TwoFactorRequired || res.ChallengeToken == "" || res.Token != "" {
+		t.Fatalf("unexpected response: %+v", res)
+	}
+}
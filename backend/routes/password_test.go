@@ -0,0 +1,86 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"backend/models"
+	"backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPasswordReset(t *testing.T) {
+	s := newTestServer(t)
+	_, oldToken := s.signUp("Alice", "alice@example.com")
+	session := s.signIn("alice@example.com")
+
+	s.expect(s.do(http.MethodPost, "/api/password/forgot", gin.H{"email": "alice@example.com"}, ""), http.StatusOK, nil)
+	resetToken := s.lastMailToken("alice@example.com")
+
+	s.expect(s.do(http.MethodPost, "/api/password/reset", gin.H{"token": resetToken, "password": "short"}, ""), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/password/reset", gin.H{"token": "unknown", "password": "newpassword"}, ""), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/password/reset", gin.H{"token": resetToken, "password": "newpassword"}, ""), http.StatusOK, nil)
+
+	// トークンは1回しか使えない
+	s.expect(s.do(http.MethodPost, "/api/password/reset", gin.H{"token": resetToken, "password": "otherpassword"}, ""), http.StatusBadRequest, nil)
+
+	// 既存のセッションは失効する
+	s.expect(s.do(http.MethodGet, "/api/users", nil, oldToken), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodGet, "/api/users", nil, session.Token), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodPost, "/api/token/refresh", gin.H{"refresh_token": session.RefreshToken}, ""), http.StatusUnauthorized, nil)
+
+	// 新しいパスワードでのみサインインできる
+	s.expect(s.do(http.MethodPost, "/api/signin", gin.H{"email": "alice@example.com", "password": "password123"}, ""), http.StatusUnauthorized, nil)
+	var res authResponse
+	s.expect(s.do(http.MethodPost, "/api/signin", gin.H{"email": "alice@example.com", "password": "newpassword"}, ""), http.StatusOK, &res)
+	s.expect(s.do(http.MethodGet, "/api/users", nil, res.Token), http.StatusOK, nil)
+}
+
+func TestForgotPasswordDoesNotRevealAccounts(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("Alice", "alice@example.com")
+
+	var known, unknown map[string]string
+	s.expect(s.do(http.MethodPost, "/api/password/forgot", gin.H{"email": "alice@example.com"}, ""), http.StatusOK, &known)
+	s.expect(s.do(http.MethodPost, "/api/password/forgot", gin.H{"email": "nobody@example.com"}, ""), http.StatusOK, &unknown)
+	if known["message"] != unknown["message"] {
+		t.Fatalf("responses differ: %v / %v", known, unknown)
+	}
+	if len(s.mails("nobody@example.com")) != 0 {
+		t.Fatal("mail sent to unknown address")
+	}
+}
+
+func TestPasswordResetTokenIsSingleActiveAndExpires(t *testing.T) {
+	s := newTestServer(t)
+	userID, _ := s.signUp("Alice", "alice@example.com")
+
+	s.expect(s.do(http.MethodPost, "/api/password/forgot", gin.H{"email": "alice@example.com"}, ""), http.StatusOK, nil)
+	first := s.lastMailToken("alice@example.com")
+	s.expect(s.do(http.MethodPost, "/api/password/forgot", gin.H{"email": "alice@example.com"}, ""), http.StatusOK, nil)
+	second := s.lastMailToken("alice@example.com")
+
+	// 再申請すると古いトークンは使えなくなる
+	s.expect(s.do(http.MethodPost, "/api/password/reset", gin.H{"token": first, "password": "newpassword"}, ""), http.StatusBadRequest, nil)
+
+	// 期限切れのトークンは使えない
+	raw, hash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := models.OneTimeToken{
+		UserID:    userID,
+		Purpose:   models.TokenPurposePasswordReset,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}
+	if err := s.stores.OneTimeTokens.Create(context.Background(), &expired); err != nil {
+		t.Fatal(err)
+	}
+	s.expect(s.do(http.MethodPost, "/api/password/reset", gin.H{"token": raw, "password": "newpassword"}, ""), http.StatusBadRequest, nil)
+
+	s.expect(s.do(http.MethodPost, "/api/password/reset", gin.H{"token": second, "password": "newpassword"}, ""), http.StatusOK, nil)
+}
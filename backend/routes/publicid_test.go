@@ -0,0 +1,220 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type publicProfile struct {
+	ID          int    `json:"id"`
+	DisplayName string `json:"display_name"`
+	PublicID    string `json:"public_id"`
+	Slug        string `json:"slug"`
+	ProfileURL  string `json:"profile_url"`
+	IconURL     string `json:"icon_url"`
+}
+
+func TestPublicProfileIDs(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+
+	var created publicProfile
+	s.expect(s.do(http.MethodPost, "/api/profiles", gin.H{
+		"user_id": aliceID, "display_name": "Alice", "title": "仕事用",
+	}, aliceToken), http.StatusCreated, &created)
+	if !regexp.MustCompile(`^[A-Za-z0-9_-]{22}$`).MatchString(created.PublicID) {
+		t.Fatalf("public_id = %q", created.PublicID)
+	}
+	if created.ProfileURL != "http://localhost:3000/profile/"+created.PublicID {
+		t.Fatalf("profile_url = %q", created.ProfileURL)
+	}
+	other := s.createProfile(aliceID, aliceToken, "Alice2")
+	if s.publicID(other) == created.PublicID {
+		t.Fatal("public_id must be unique")
+	}
+
+	// 公開APIは公開IDで参照でき、連番のIDでは本人以外は辿れない
+	s.expect(s.do(http.MethodGet, "/api/profiles/"+created.PublicID, nil, ""), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, "/api/profiles/"+created.PublicID, nil, bobToken), http.StatusOK, nil)
+	for _, token := range []string{"", bobToken} {
+		s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/profiles/%d", created.ID), nil, token), http.StatusNotFound, nil)
+		s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/profiles/%d/icon", created.ID), nil, token), http.StatusNotFound, nil)
+	}
+	s.expect(s.do(http.MethodGet, "/api/profiles/"+strings.ToLower(created.PublicID)+"x", nil, ""), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/profiles/%d", created.ID), nil, aliceToken), http.StatusOK, nil)
+
+	// 交換済み一覧から相手の公開ページを開ける
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+	s.connect(bobToken, bobProfile, created.ID, aliceToken)
+	var connections struct {
+		Connections []struct {
+			ConnectedProfilePublicID string `json:"connected_profile_public_id"`
+		} `json:"connections"`
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/users/%d/connections", bobID), nil, bobToken), http.StatusOK, &connections)
+	if len(connections.Connections) != 1 || connections.Connections[0].ConnectedProfilePublicID != created.PublicID {
+		t.Fatalf("connections = %+v", connections.Connections)
+	}
+}
+
+func TestSequentialIDsAreHiddenFromOthers(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	aliceProfile := s.createProfile(aliceID, aliceToken, "Alice")
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+	alicePublicID := s.publicID(aliceProfile)
+	var created struct {
+		Link struct {
+			ID int `json:"id"`
+		} `json:"link"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/links", gin.H{
+		"profile_id": aliceProfile, "title": "Blog", "url": "https://example.com",
+	}, aliceToken), http.StatusCreated, &created)
+
+	// 本人以外には連番のプロフィールID・ユーザーIDを返さない
+	hidden := func(path, token string, keys ...string) {
+		t.Helper()
+		var body map[string]interface{}
+		s.expect(s.do(http.MethodGet, path, nil, token), http.StatusOK, &body)
+		for _, key := range keys {
+			if v, ok := body[key]; ok {
+				t.Fatalf("GET %s: %s = %v", path, key, v)
+			}
+		}
+	}
+	for _, token := range []string{"", bobToken} {
+		hidden("/api/profiles/"+alicePublicID, token, "id", "user_id")
+	}
+	var own publicProfile
+	s.expect(s.do(http.MethodGet, "/api/profiles/"+alicePublicID, nil, aliceToken), http.StatusOK, &own)
+	if own.ID != aliceProfile {
+		t.Fatalf("own = %+v", own)
+	}
+
+	var links struct {
+		Links []map[string]interface{} `json:"links"`
+	}
+	s.expect(s.do(http.MethodGet, "/api/links/profile/"+alicePublicID, nil, bobToken), http.StatusOK, &links)
+	if len(links.Links) != 1 || links.Links[0]["user_id"] != nil || links.Links[0]["profile_id"] != nil {
+		t.Fatalf("links = %+v", links.Links)
+	}
+	var link struct {
+		Link map[string]interface{} `json:"link"`
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/links/%d", created.Link.ID), nil, bobToken), http.StatusOK, &link)
+	if link.Link["profile_id"] != nil {
+		t.Fatalf("link = %+v", link.Link)
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/links/%d", created.Link.ID), nil, aliceToken), http.StatusOK, &link)
+	if link.Link["profile_id"] != float64(aliceProfile) {
+		t.Fatalf("own link = %+v", link.Link)
+	}
+
+	// 他人のプロフィールは連番のIDでは指定できない（存在の確認にも申請にも使えない）
+	s.expect(s.do(http.MethodPost, "/api/connections", gin.H{"profile_id": bobProfile, "connect_user_profile_id": aliceProfile}, bobToken), http.StatusNotFound, nil)
+	// 自分のプロフィールどうしは連番のIDでも指定できる
+	bobSecond := s.createProfile(bobID, bobToken, "Bob (work)")
+	s.expect(s.do(http.MethodPost, "/api/connections", gin.H{"profile_id": bobSecond, "connect_user_profile_id": bobProfile}, bobToken), http.StatusOK, nil)
+
+	// 接続先は公開IDで指定でき、申請先には申請者のプロフィールの連番のIDを返さない
+	s.requestConnection(bobToken, gin.H{"profile_id": bobProfile, "connect_user_profile_public_id": alicePublicID}, aliceToken)
+	var requests struct {
+		Requests []map[string]interface{} `json:"requests"`
+	}
+	s.expect(s.do(http.MethodGet, "/api/connection-requests/incoming", nil, aliceToken), http.StatusOK, &requests)
+	if len(requests.Requests) != 1 || requests.Requests[0]["profile_id"] != nil {
+		t.Fatalf("requests = %+v", requests.Requests)
+	}
+	var connections struct {
+		Connections []struct {
+			ConnectUsersProfilePublicID string `json:"connect_user_profile_public_id"`
+		} `json:"connections"`
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/connections?profile_id=%d", bobProfile), nil, bobToken), http.StatusOK, &connections)
+	if len(connections.Connections) != 1 || connections.Connections[0].ConnectUsersProfilePublicID != alicePublicID {
+		t.Fatalf("connections = %+v", connections.Connections)
+	}
+	s.expect(s.do(http.MethodPost, "/api/connections", gin.H{"profile_id": bobProfile}, bobToken), http.StatusBadRequest, nil)
+
+	// 接続先の連番のIDはどのレスポンスにも含めない
+	carolID, carolToken := s.signUp("Carol", "carol@example.com")
+	carolProfile := s.createProfile(carolID, carolToken, "Carol")
+	for _, w := range []*httptest.ResponseRecorder{
+		s.do(http.MethodPost, "/api/connections", gin.H{"profile_id": carolProfile, "connect_user_profile_public_id": alicePublicID}, carolToken),
+		s.do(http.MethodGet, "/api/connection-requests/outgoing", nil, carolToken),
+		s.do(http.MethodGet, "/api/connection-requests/outgoing", nil, bobToken),
+		s.do(http.MethodGet, fmt.Sprintf("/api/connections?profile_id=%d", bobProfile), nil, bobToken),
+		s.do(http.MethodGet, fmt.Sprintf("/api/users/%d/connections", bobID), nil, bobToken),
+	} {
+		if w.Code >= 300 || strings.Contains(w.Body.String(), `"connect_user_profile_id"`) || strings.Contains(w.Body.String(), `"connected_profile_id"`) {
+			t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestProfileSlug(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+
+	var created publicProfile
+	s.expect(s.do(http.MethodPost, "/api/profiles", gin.H{
+		"user_id": aliceID, "display_name": "Alice", "title": "仕事用", "slug": "Alice-Dev",
+	}, aliceToken), http.StatusCreated, &created)
+	if created.Slug != "alice-dev" || created.ProfileURL != "http://localhost:3000/profile/alice-dev" {
+		t.Fatalf("created = %+v", created)
+	}
+
+	// カスタムURLは大文字小文字を区別せずに解決し、公開IDでも引き続き参照できる
+	var profile publicProfile
+	s.expect(s.do(http.MethodGet, "/api/profiles/ALICE-dev", nil, ""), http.StatusOK, &profile)
+	if profile.PublicID != created.PublicID || profile.DisplayName != "Alice" {
+		t.Fatalf("profile = %+v", profile)
+	}
+	s.expect(s.do(http.MethodGet, "/api/profiles/"+created.PublicID, nil, ""), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, "/api/links/profile/alice-dev", nil, ""), http.StatusOK, nil)
+
+	// 形式・予約語・不適切な語句・重複は拒否
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+	bobPath := fmt.Sprintf("/api/profiles/%d", bobProfile)
+	for _, slug := range []string{"ab", "bob_smith", "-bob", "bob--smith", "12345", "this-slug-is-way-too-long", "ボブ"} {
+		s.expect(s.do(http.MethodPut, bobPath, gin.H{"slug": slug}, bobToken), http.StatusBadRequest, nil)
+	}
+	for _, slug := range []string{"admin", "mypage", "Login"} {
+		s.expect(s.do(http.MethodPut, bobPath, gin.H{"slug": slug}, bobToken), http.StatusBadRequest, nil)
+	}
+	for _, slug := range []string{"fuck-you", "sh1thead"} {
+		s.expect(s.do(http.MethodPut, bobPath, gin.H{"slug": slug}, bobToken), http.StatusBadRequest, nil)
+	}
+	s.expect(s.do(http.MethodPut, bobPath, gin.H{"slug": "alice-DEV"}, bobToken), http.StatusConflict, nil)
+	s.expect(s.do(http.MethodPost, "/api/profiles", gin.H{
+		"user_id": bobID, "display_name": "Bob", "title": "趣味用", "slug": "alice-dev",
+	}, bobToken), http.StatusConflict, nil)
+
+	var updated publicProfile
+	s.expect(s.do(http.MethodPut, bobPath, gin.H{"slug": "bob"}, bobToken), http.StatusOK, &updated)
+	if updated.Slug != "bob" {
+		t.Fatalf("updated = %+v", updated)
+	}
+	s.expect(s.do(http.MethodGet, "/api/profiles/bob", nil, ""), http.StatusOK, nil)
+
+	// 空文字で解除すると古いURLは使えなくなり、他のプロフィールで使えるようになる
+	var cleared publicProfile
+	s.expect(s.do(http.MethodPut, bobPath, gin.H{"slug": ""}, bobToken), http.StatusOK, &cleared)
+	if cleared.Slug != "" || cleared.ProfileURL != "http://localhost:3000/profile/"+cleared.PublicID {
+		t.Fatalf("cleared = %+v", cleared)
+	}
+	s.expect(s.do(http.MethodGet, "/api/profiles/bob", nil, ""), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodPost, "/api/profiles", gin.H{
+		"user_id": aliceID, "display_name": "Alice", "title": "趣味用", "slug": "bob",
+	}, aliceToken), http.StatusCreated, nil)
+}
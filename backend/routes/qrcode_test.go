@@ -0,0 +1,292 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+
+	"backend/qr"
+	"backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type qrCodeResult struct {
+	QRData          string `json:"qr_data"`
+	URL             string `json:"url"`
+	Format          string `json:"format"`
+	ErrorCorrection string `json:"error_correction"`
+}
+
+// decodePNG はPNGの画像を読み込み、QRコードの内容を返します
+func (s *testServer) decodePNG(data []byte) (image.Image, string) {
+	s.t.Helper()
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	content, err := qr.Decode(img)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return img, content
+}
+
+// testLogo はロゴ用のグラデーションのPNG画像を返します
+func testLogo(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 4), G: 0x40, B: uint8(y * 4), A: 0xFF})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGenerateQRCodeOptions(t *testing.T) {
+	s := newTestServer(t)
+	url := "https://example.com/profile/abc123"
+
+	// 既定はPNGのdata URI・誤り訂正レベルM
+	var res qrCodeResult
+	s.expect(s.do(http.MethodPost, "/api/generate-qr", gin.H{"url": url}, ""), http.StatusOK, &res)
+	if res.Format != "png" || res.ErrorCorrection != "M" || !strings.HasPrefix(res.QRData, "data:image/png;base64,") {
+		t.Fatalf("res = %+v", res)
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(res.QRData, "data:image/png;base64,"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img, content := s.decodePNG(data); content != url || img.Bounds().Dx() != 256 {
+		t.Fatalf("decoded %q (%v)", content, img.Bounds())
+	}
+
+	// 画像そのものを返す場合はContent-Typeを設定する
+	w := s.do(http.MethodPost, "/api/generate-qr", gin.H{
+		"url": url, "raw": true, "size": 300, "quiet_zone": 0, "error_correction": "q",
+		"foreground": "#1a237e", "background": "#fff8e1",
+	}, "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("raw png = %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	img, content := s.decodePNG(w.Body.Bytes())
+	if content != url || img.Bounds().Dx() != 300 {
+		t.Fatalf("decoded %q (%v)", content, img.Bounds())
+	}
+	// 余白なしでも割り切れない分のピクセルは背景色、そのすぐ内側から位置検出パターン（前景色）になる
+	corner := 0
+	for img.At(corner, corner) == img.At(0, 0) && corner < 150 {
+		corner++
+	}
+	if r, g, b, _ := img.At(corner, corner).RGBA(); corner > 8 || r>>8 != 0x1a || g>>8 != 0x23 || b>>8 != 0x7e {
+		t.Fatalf("pixel (%d, %d) = %v", corner, corner, img.At(corner, corner))
+	}
+
+	w = s.do(http.MethodPost, "/api/generate-qr", gin.H{"url": url, "format": "svg", "raw": true, "foreground": "#123456"}, "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/svg+xml" {
+		t.Fatalf("raw svg = %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if body := w.Body.String(); !strings.HasPrefix(body, "<svg") || !strings.Contains(body, `fill="#123456"`) {
+		t.Fatalf("svg = %s", body)
+	}
+	s.expect(s.do(http.MethodPost, "/api/generate-qr", gin.H{"url": url, "format": "svg"}, ""), http.StatusOK, &res)
+	if res.Format != "svg" || !strings.HasPrefix(res.QRData, "data:image/svg+xml;base64,") {
+		t.Fatalf("svg res = %+v", res)
+	}
+
+	// 不正なオプション
+	for _, body := range []gin.H{
+		{"url": url, "format": "gif"},
+		{"url": url, "error_correction": "X"},
+		{"url": url, "size": 10},
+		{"url": url, "quiet_zone": 20},
+		{"url": url, "foreground": "blue"},
+		{"url": url, "foreground": "#eeeeee", "background": "#ffffff"},
+		{"url": url, "foreground": "#ffffff", "background": "#000000"},
+		{"url": url, "logo": "not-base64!"},
+		{"url": url, "logo": base64.StdEncoding.EncodeToString([]byte("not an image"))},
+		{"url": url, "logo_scale": 0.5},
+		{"url": strings.Repeat("x", 5000)},
+	} {
+		s.expect(s.do(http.MethodPost, "/api/generate-qr", body, ""), http.StatusBadRequest, nil)
+	}
+}
+
+func TestGenerateQRCodeWithLogo(t *testing.T) {
+	s := newTestServer(t)
+	_, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	url := "https://example.com/profile/abc123"
+	logo := testLogo(t)
+
+	// ロゴを重ねると誤り訂正レベルをHにし、読み取れることを確認して返す
+	w := s.do(http.MethodPost, "/api/generate-qr", gin.H{
+		"url": url, "raw": true, "error_correction": "L", "logo_scale": 0.25,
+		"logo": "data:image/png;base64," + base64.StdEncoding.EncodeToString(logo),
+	}, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("logo = %d %s", w.Code, w.Body.String())
+	}
+	if img, content := s.decodePNG(w.Body.Bytes()); content != url || img.At(128, 128) == img.At(0, 0) {
+		t.Fatalf("decoded %q", content)
+	}
+	var res qrCodeResult
+	s.expect(s.do(http.MethodPost, "/api/generate-qr", gin.H{"url": url, "format": "svg", "logo": base64.StdEncoding.EncodeToString(logo)}, ""), http.StatusOK, &res)
+	svg, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(res.QRData, "data:image/svg+xml;base64,"))
+	if res.ErrorCorrection != "H" || !strings.Contains(string(svg), `href="data:image/png;base64,`) {
+		t.Fatalf("svg logo = %+v", res)
+	}
+
+	// プロフィールのアイコンをロゴにする（閲覧できるプロフィールのみ）
+	var created struct {
+		ID int `json:"id"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/profiles", gin.H{
+		"display_name": "Alice", "title": "仕事用", "icon_base64": base64.StdEncoding.EncodeToString(logo),
+	}, aliceToken), http.StatusCreated, &created)
+	aliceRef := s.publicID(created.ID)
+	s.expect(s.do(http.MethodPost, "/api/generate-qr", gin.H{"url": url, "logo_profile_id": aliceRef}, ""), http.StatusOK, &res)
+	if res.ErrorCorrection != "H" {
+		t.Fatalf("profile logo = %+v", res)
+	}
+	s.expect(s.do(http.MethodPost, "/api/generate-qr", gin.H{"url": url, "logo_profile_id": aliceRef, "logo": base64.StdEncoding.EncodeToString(logo)}, ""), http.StatusBadRequest, nil)
+
+	bobRef := s.publicID(s.createProfile(bobID, bobToken, "Bob"))
+	s.expect(s.do(http.MethodPost, "/api/generate-qr", gin.H{"url": url, "logo_profile_id": bobRef}, ""), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/profiles/%d", created.ID), gin.H{"visibility": "private"}, aliceToken), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPost, "/api/generate-qr", gin.H{"url": url, "logo_profile_id": aliceRef}, bobToken), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodPost, "/api/generate-qr", gin.H{"url": url, "logo_profile_id": aliceRef}, aliceToken), http.StatusOK, nil)
+}
+
+// qrSheet は複数のQRコードを並べたPNG画像を返します（印刷したバッジなどを撮影した画像の代わり）
+func qrSheet(t *testing.T, contents ...string) string {
+	t.Helper()
+	sheet := image.NewRGBA(image.Rect(0, 0, 360*max(len(contents), 1), 400))
+	draw.Draw(sheet, sheet.Bounds(), image.White, image.Point{}, draw.Src)
+	for i, content := range contents {
+		opts := qr.DefaultOptions()
+		opts.Size = 320
+		code, err := qr.Encode(content, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img := code.Image()
+		draw.Draw(sheet, img.Bounds().Add(image.Pt(360*i+20, 20+20*i)), img, image.Point{}, draw.Src)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, sheet); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+type decodedQRCodes struct {
+	Results []struct {
+		Content string         `json:"content"`
+		Type    string         `json:"type"`
+		Profile *publicProfile `json:"profile"`
+		Token   string         `json:"token"`
+		Error   string         `json:"error"`
+	} `json:"results"`
+	Total int `json:"total"`
+}
+
+func TestDecodeQRCode(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	_, carolToken := s.signUp("Carol", "carol@example.com")
+	aliceProfile := s.createProfile(aliceID, aliceToken, "Alice")
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/profiles/%d", bobProfile), gin.H{"visibility": "private"}, bobToken), http.StatusOK, nil)
+
+	aliceURL := utils.FrontendURL() + "/profile/" + s.publicID(aliceProfile)
+	bobURL := utils.FrontendURL() + "/profile/" + s.publicID(bobProfile)
+	exchange := s.mintExchangeToken(bobToken, bobProfile, nil)
+	sheet := qrSheet(t, aliceURL, exchange.URL, bobURL, "https://example.com/", "こんにちは")
+
+	// 画像に写っているQRコードをすべて読み取り、QRsonaのプロフィールは閲覧できる範囲で解決する
+	var res decodedQRCodes
+	s.expect(s.upload("/api/decode-qr", "badge.png", sheet, nil, carolToken), http.StatusOK, &res)
+	if res.Total != 5 {
+		t.Fatalf("res = %+v", res)
+	}
+	byContent := map[string]int{}
+	for i, r := range res.Results {
+		byContent[r.Content] = i
+	}
+	for content, want := range map[string]struct {
+		kind, displayName string
+		hasError          bool
+	}{
+		aliceURL:               {kind: "profile", displayName: "Alice"},
+		exchange.URL:           {kind: "exchange", displayName: "Bob"},
+		bobURL:                 {kind: "profile", hasError: true},
+		"https://example.com/": {kind: "url"},
+		"こんにちは":                {kind: "text"},
+	} {
+		i, ok := byContent[content]
+		if !ok {
+			t.Fatalf("%q not decoded: %+v", content, res)
+		}
+		r := res.Results[i]
+		name := ""
+		if r.Profile != nil {
+			name = r.Profile.DisplayName
+		}
+		if r.Type != want.kind || name != want.displayName || (r.Error != "") != want.hasError {
+			t.Fatalf("%q = %+v", content, r)
+		}
+	}
+	if r := res.Results[byContent[exchange.URL]]; r.Token != exchange.Token {
+		t.Fatalf("exchange = %+v", r)
+	}
+
+	// 交換相手のプロフィールはログインしている場合だけ返す
+	var anonymous decodedQRCodes
+	s.expect(s.upload("/api/decode-qr", "badge.png", sheet, nil, ""), http.StatusOK, &anonymous)
+	for _, r := range anonymous.Results {
+		if r.Type == "exchange" && (r.Profile != nil || r.Error == "") {
+			t.Fatalf("anonymous exchange = %+v", r)
+		}
+	}
+
+	// QRコードが写っていない画像・画像でないファイル
+	s.expect(s.upload("/api/decode-qr", "blank.png", qrSheet(t), nil, carolToken), http.StatusUnprocessableEntity, nil)
+	s.expect(s.upload("/api/decode-qr", "badge.txt", "not an image", nil, carolToken), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/decode-qr", nil, carolToken), http.StatusBadRequest, nil)
+
+	// 解像度の大きすぎる画像は展開する前に拒否する（圧縮後は小さくても展開後のメモリが大きくなるため）
+	s.expect(s.upload("/api/decode-qr", "huge.png", pngHeader(5000, 4000), nil, carolToken), http.StatusRequestEntityTooLarge, nil)
+	// 1200万画素のカメラの画像は上限内（ヘッダーだけなので読み込めずに400）
+	s.expect(s.upload("/api/decode-qr", "camera.png", pngHeader(4032, 3024), nil, carolToken), http.StatusBadRequest, nil)
+}
+
+// pngHeader は指定した大きさのPNGのヘッダー（IHDRまで）を返します（画像データは含みません）
+func pngHeader(width, height int) string {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(width))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(height))
+	ihdr[8] = 8 // ビット深度（カラータイプ0はグレースケール）
+	chunk := append([]byte("IHDR"), ihdr...)
+	var b bytes.Buffer
+	b.WriteString("\x89PNG\r\n\x1a\n")
+	binary.Write(&b, binary.BigEndian, uint32(len(ihdr)))
+	b.Write(chunk)
+	binary.Write(&b, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	return b.String()
+}
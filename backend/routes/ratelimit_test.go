@@ -0,0 +1,127 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// doFrom は送信元IPを指定してリクエストを送ります
+func (s *testServer) doFrom(ip, method, path string, body interface{}) *httptest.ResponseRecorder {
+	s.t.Helper()
+	raw, err := json.Marshal(body)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(raw))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = ip + ":12345"
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitByIP(t *testing.T) {
+	s := newTestServer(t)
+	body := gin.H{"url": "https://example.com"}
+
+	for i := 0; i < 30; i++ {
+		w := s.doFrom("203.0.113.1", http.MethodPost, "/api/generate-qr", body)
+		s.expect(w, http.StatusOK, nil)
+		if got := w.Header().Get("X-RateLimit-Limit"); got != "30" {
+			t.Fatalf("X-RateLimit-Limit = %q", got)
+		}
+		if got := w.Header().Get("X-RateLimit-Remaining"); got != strconv.Itoa(29-i) {
+			t.Fatalf("X-RateLimit-Remaining = %q, want %d", got, 29-i)
+		}
+	}
+
+	w := s.doFrom("203.0.113.1", http.MethodPost, "/api/generate-qr", body)
+	s.expect(w, http.StatusTooManyRequests, nil)
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || retryAfter < 1 || retryAfter > 2 {
+		t.Fatalf("Retry-After = %q", w.Header().Get("Retry-After"))
+	}
+	if reset, _ := strconv.Atoi(w.Header().Get("X-RateLimit-Reset")); reset < 59 || reset > 60 {
+		t.Fatalf("X-RateLimit-Reset = %q", w.Header().Get("X-RateLimit-Reset"))
+	}
+
+	// 別のIPや別のルートは影響を受けない
+	s.expect(s.doFrom("203.0.113.2", http.MethodPost, "/api/generate-qr", body), http.StatusOK, nil)
+	s.expect(s.doFrom("203.0.113.1", http.MethodGet, "/api/health", nil), http.StatusOK, nil)
+}
+
+func TestSignInProgressiveLockout(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("Alice", "alice@example.com")
+	ctx := context.Background()
+
+	signIn := func(password string) *httptest.ResponseRecorder {
+		return s.do(http.MethodPost, "/api/signin", gin.H{"email": "alice@example.com", "password": password}, "")
+	}
+	retryAfter := func(w *httptest.ResponseRecorder) int {
+		n, _ := strconv.Atoi(w.Header().Get("Retry-After"))
+		return n
+	}
+	// expireLock はロック期間が過ぎた状態を再現します
+	expireLock := func() {
+		if err := s.stores.LoginAttempts.Lock(ctx, "alice@example.com", time.Now().Add(-time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 4; i++ {
+		s.expect(signIn("wrong"), http.StatusUnauthorized, nil)
+	}
+	w := signIn("wrong")
+	s.expect(w, http.StatusTooManyRequests, nil)
+	if got := retryAfter(w); got < 59 || got > 60 {
+		t.Fatalf("Retry-After = %d, want 60", got)
+	}
+
+	// ロック中は正しいパスワードでも拒否する
+	s.expect(signIn("password123"), http.StatusTooManyRequests, nil)
+
+	// ロック解除後も失敗が続くとロック時間が倍になる
+	expireLock()
+	w = signIn("wrong")
+	s.expect(w, http.StatusTooManyRequests, nil)
+	if got := retryAfter(w); got < 119 || got > 120 {
+		t.Fatalf("Retry-After = %d, want 120", got)
+	}
+
+	// 成功すると失敗回数はリセットされる
+	expireLock()
+	s.expect(signIn("password123"), http.StatusOK, nil)
+	s.expect(signIn("wrong"), http.StatusUnauthorized, nil)
+}
+
+func TestSignInLockoutForUnknownAccount(t *testing.T) {
+	s := newTestServer(t)
+	body := gin.H{"email": "nobody@example.com", "password": "password123"}
+
+	for i := 0; i < 4; i++ {
+		s.expect(s.do(http.MethodPost, "/api/signin", body, ""), http.StatusUnauthorized, nil)
+	}
+	s.expect(s.do(http.MethodPost, "/api/signin", body, ""), http.StatusTooManyRequests, nil)
+}
+
+func TestForgotPasswordMailsAreThrottledPerAddress(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("Alice", "alice@example.com")
+	sent := len(s.mails("alice@example.com"))
+
+	for i := 0; i < 4; i++ {
+		s.expect(s.do(http.MethodPost, "/api/password/forgot", gin.H{"email": "alice@example.com"}, ""), http.StatusOK, nil)
+	}
+	if got := len(s.mails("alice@example.com")) - sent; got != 3 {
+		t.Fatalf("sent %d reset mails, want 3", got)
+	}
+}
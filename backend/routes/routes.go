@@ -1,73 +1,193 @@
 package routes
 
 import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
 	"backend/database"
 	"backend/handlers"
 	"backend/middleware"
+	"backend/models"
+	"backend/store"
 
 	"github.com/gin-gonic/gin"
 )
 
 // SetupRoutesはAPIルーティングの設定を行います
 func SetupRoutes(r *gin.Engine) {
-	// CORSミドルウェア
-	r.Use(middleware.CORSMiddleware())
-
-	// ハンドラの初期化（DBコネクションとCloudinaryクライアントセット）
-	app, err := handlers.NewApp(database.DB)
+	// ハンドラの初期化（PostgreSQLストアとCloudinaryクライアントセット）
+	stores := store.NewPostgres(database.DB)
+	// レート制限は単一インスタンスならメモリで十分（複数インスタンスで共有する場合は RATE_LIMIT_BACKEND=postgres）
+	if os.Getenv("RATE_LIMIT_BACKEND") != "postgres" {
+		stores.RateLimits = store.NewMemoryRateLimits()
+	}
+	app, err := handlers.NewApp(stores)
 	if err != nil {
 		panic("Failed to initialize app: " + err.Error())
 	}
 
+	// クライアントIP（レート制限のキー）の判定で X-Forwarded-For を信頼するプロキシ
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		if err := r.SetTrustedProxies(strings.Split(proxies, ",")); err != nil {
+			panic("Invalid TRUSTED_PROXIES: " + err.Error())
+		}
+	}
+
+	// 削除の猶予期間を過ぎたアカウントを定期的に完全削除する
+	go app.RunAccountPurger(context.Background(), time.Hour)
+
+	RegisterRoutes(r, app)
+}
+
+// RegisterRoutesは初期化済みのAppを使ってルートを登録します（テストではメモリストアのAppを渡します）
+func RegisterRoutes(r *gin.Engine, app *handlers.App) {
+	// CORSミドルウェア
+	r.Use(middleware.CORSMiddleware())
+
 	// 静的ファイル配信（開発環境用）
 	r.Static("/api/uploads", "./uploads")
 
+	// 認証ミドルウェア（署名はKeyManagerで検証し、失効済みトークンと停止中のアカウントはストアで確認。パーソナルアクセストークンも受け付ける）
+	authRequired := middleware.AuthRequired(app.Keys, app.RevokedTokens, app.Sessions, app.AccessTokens, app.Users)
+	// 公開APIの閲覧者の識別（トークンがない・無効な場合は未ログインとして扱う。公開範囲の判定に使う）
+	authOptional := middleware.AuthOptional(app.Keys, app.RevokedTokens, app.Sessions, app.AccessTokens, app.Users)
+	// パーソナルアクセストークンでは行えない操作（トークン発行・二要素認証の設定など）
+	sessionOnly := middleware.SessionOnly()
+	// パーソナルアクセストークンのスコープ確認（GETは<resource>:read、それ以外は<resource>:write）
+	scope := middleware.RequireScope
+	// メソッドによらず<resource>:readを要求（公開API・読み取るだけのPOST用。未ログインは許可）
+	readScope := middleware.RequireReadScope
+	// ロールの確認（指定したロール以上を要求）
+	requireRole := middleware.RequireRole
+
+	// レート制限（nameごとにバケットを分ける）
+	byIP := func(name string, limit store.RateLimit) gin.HandlerFunc {
+		return middleware.RateLimitByIP(app.RateLimits, name, limit)
+	}
+	byUser := func(name string, limit store.RateLimit) gin.HandlerFunc {
+		return middleware.RateLimitByUser(app.RateLimits, name, limit)
+	}
+
+	// アクセストークン検証用の公開鍵（他サービス向け）
+	r.GET("/.well-known/jwks.json", app.JWKS)
+
 	// APIルートグループ
 	api := r.Group("/api")
 	{
-		api.GET("/health", handlers.HealthCheck)          // ヘルスチェック
-		api.POST("/generate-qr", handlers.GenerateQRCode) // QRコード生成
-		api.POST("/signup", app.SignUp)                   // サインアップ
-		api.POST("/signin", app.SignIn)                   // サインイン
+		api.GET("/health", handlers.HealthCheck)                                                                                    // ヘルスチェック
+		api.POST("/generate-qr", byIP("generate-qr", store.PerMinute(30)), authOptional, readScope("profiles"), app.GenerateQRCode) // QRコード生成（ロゴにプロフィールのアイコンを使う場合は閲覧できるもののみ）
+		api.POST("/decode-qr", byIP("decode-qr", store.PerMinute(10)), authOptional, readScope("profiles"), app.DecodeQRCode)       // アップロードした画像のQRコードの読み取り（QRsonaのプロフィールは閲覧できる範囲で解決）
+		api.POST("/signup", byIP("signup", store.PerMinute(10)), app.SignUp)                                                        // サインアップ
+		api.POST("/signin", byIP("signin", store.PerMinute(20)), app.SignIn)                                                        // サインイン（アカウント単位の連続失敗ロックあり）
+		api.POST("/token/refresh", byIP("token-refresh", store.PerMinute(30)), app.RefreshToken)                                    // アクセストークン再発行（リフレッシュトークンをローテーション）
+		api.POST("/signin/2fa", byIP("signin-2fa", store.PerMinute(20)), app.SignInTwoFactor)                                       // サインイン二段階目（TOTPコードまたはリカバリーコード）
+		api.POST("/signout", authRequired, sessionOnly, app.SignOut)                                                                // サインアウト（トークン失効）
+		api.POST("/password/forgot", byIP("password-forgot", store.PerMinute(5)), app.ForgotPassword)                               // パスワードリセットメール送信
+		api.POST("/password/reset", byIP("password-reset", store.PerMinute(10)), app.ResetPassword)                                 // パスワード再設定（既存セッションは失効）
+		api.POST("/email/verify", byIP("email-verify", store.PerMinute(10)), app.VerifyEmail)                                       // メールアドレス確認
+		api.POST("/email/verification", authRequired, sessionOnly,
+			byUser("email-verification", store.RateLimit{Burst: 3, Interval: 5 * time.Minute}), app.ResendVerificationEmail) // 確認メール再送
+
+		// 外部プロバイダー（GitHub・Google）でのログイン
+		api.GET("/oauth/providers", app.GetOAuthProviders)                                                    // 利用できるプロバイダー一覧
+		api.POST("/oauth/:provider/start", byIP("oauth-start", store.PerMinute(20)), app.StartOAuth)          // 認可URLの発行（state・PKCE）
+		api.POST("/oauth/:provider/callback", byIP("oauth-callback", store.PerMinute(20)), app.OAuthCallback) // 認可コードでサインイン（アカウント連携・新規登録）
+
+		// 二要素認証（TOTP）の設定
+		twoFactor := api.Group("/2fa")
+		twoFactor.Use(authRequired, sessionOnly)
+		{
+			twoFactor.GET("", app.GetTwoFactorStatus)                                                                   // 設定状況
+			twoFactor.POST("/totp/enroll", app.EnrollTOTP)                                                              // 登録開始（otpauth URIとQRコード）
+			twoFactor.POST("/totp/confirm", byUser("2fa-confirm", store.PerMinute(10)), app.ConfirmTOTP)                // 確認コードで有効化（リカバリーコード発行）
+			twoFactor.POST("/totp/disable", byUser("2fa-disable", store.PerMinute(10)), app.DisableTOTP)                // 解除
+			twoFactor.POST("/recovery-codes", byUser("2fa-recovery", store.PerMinute(10)), app.RegenerateRecoveryCodes) // リカバリーコード再発行
+		}
 
-		api.GET("/users", middleware.AuthRequired(), app.GetUsers) // 全ユーザー取得（認証要）
+		// パーソナルアクセストークンの管理
+		tokens := api.Group("/tokens")
+		tokens.Use(authRequired, sessionOnly)
+		{
+			tokens.GET("", app.ListPersonalAccessTokens)         // 一覧（最終使用日時つき）
+			tokens.POST("", app.CreatePersonalAccessToken)       // 発行（トークンはこのレスポンスでのみ返す）
+			tokens.DELETE("/:id", app.RevokePersonalAccessToken) // 失効
+		}
+
+		// サインイン中の端末（セッション）の管理
+		sessions := api.Group("/sessions")
+		sessions.Use(authRequired, sessionOnly)
+		{
+			sessions.GET("", app.ListSessions)           // 一覧（現在のセッションにはcurrentを付ける）
+			sessions.DELETE("", app.RevokeOtherSessions) // 現在のセッション以外をすべて失効
+			sessions.DELETE("/:id", app.RevokeSession)   // 指定したセッションを失効
+		}
+
+		// アカウント（データのエクスポート・削除）
+		account := api.Group("/account")
+		account.Use(authRequired, sessionOnly)
+		{
+			account.GET("/export", byUser("account-export", store.RateLimit{Burst: 3, Interval: 10 * time.Minute}), app.ExportAccountData) // データ一式のZIP
+			account.POST("/deletion", byUser("account-deletion", store.PerMinute(5)), app.RequestAccountDeletion)                          // 削除の予約（パスワードが必要）
+			account.DELETE("/deletion", app.CancelAccountDeletion)                                                                         // 削除の予約の取り消し
+		}
+
+		api.GET("/users", authRequired, scope("users"), app.GetUsers) // ユーザー一覧（認証要・一般ユーザーは自分の情報のみ）
+
+		// 管理者API（モデレーター以上・パーソナルアクセストークンでは使えない）
+		admin := api.Group("/admin")
+		admin.Use(authRequired, sessionOnly, requireRole(models.RoleModerator))
+		{
+			admin.GET("/users", app.AdminListUsers)                                              // ユーザー検索（?q=&role=&status=&page=&per_page=）
+			admin.GET("/users/:id", app.AdminGetUser)                                            // ユーザー詳細（プロフィール一覧つき）
+			admin.POST("/users/:id/suspend", app.AdminSuspendUser)                               // アカウント停止（セッションも失効）
+			admin.POST("/users/:id/unsuspend", app.AdminUnsuspendUser)                           // アカウント停止解除
+			admin.PUT("/users/:id/role", requireRole(models.RoleAdmin), app.AdminUpdateUserRole) // ロール変更（管理者のみ）
+			admin.DELETE("/profiles/:id", app.AdminDeleteProfile)                                // プロフィールの強制削除（アイコン画像も削除）
+			admin.GET("/audit-logs", app.AdminListAuditLogs)                                     // 監査ログ
+		}
 
 		// リンク系API
 		links := api.Group("/links")
-		links.Use(middleware.AuthRequired())
+		links.Use(authRequired, scope("links"))
 		{
-			links.POST("", app.CreateLink)                 // 新規リンク作成
-			links.GET("/user/:userId", app.GetLinksByUser) // ユーザー別リンク一覧
-			links.GET("/:id", app.GetLink)                 // リンク詳細取得
-			links.PUT("/:id", app.UpdateLink)              // リンク更新
-			links.DELETE("/:id", app.DeleteLink)           // リンク削除
+			links.POST("", app.RequireVerifiedEmail(handlers.ActionCreateLink), app.CreateLink) // 新規リンク作成
+			links.GET("/user/:userId", app.GetLinksByUser)                                      // ユーザー別リンク一覧
+			links.GET("/:id", app.GetLink)                                                      // リンク詳細取得
+			links.PUT("/:id", app.UpdateLink)                                                   // リンク更新
+			links.DELETE("/:id", app.DeleteLink)                                                // リンク削除
 
 			links.GET("/types/common", app.GetCommonLinkTypes) // 共通リンクテンプレ取得
 		}
 
 		// 公開リンクAPI（認証不要）
-		api.GET("/links/profile/:profile_id", app.GetLinksByProfile) // プロフィール別リンク一覧（公開）
+		api.GET("/links/profile/:profile_id", byIP("public", store.PerMinute(120)), authOptional, readScope("links"), app.GetLinksByProfile) // プロフィール別リンク一覧（公開）
 
 		// プロフィール関連
 		profiles := api.Group("/profiles")
-		profiles.Use(middleware.AuthRequired())
+		profiles.Use(authRequired, scope("profiles"))
 		{
-			profiles.POST("", app.CreateProfile)    // プロフィール作成
-			profiles.PUT("/:id", app.UpdateProfile) // プロフィール更新
+			profiles.POST("", app.RequireVerifiedEmail(handlers.ActionPublishProfile), app.CreateProfile)                                                        // プロフィール作成
+			profiles.PUT("/:id", app.RequireVerifiedEmail(handlers.ActionPublishProfile), app.UpdateProfile)                                                     // プロフィール更新
+			profiles.POST("/import", byUser("import-profiles", store.PerMinute(5)), app.RequireVerifiedEmail(handlers.ActionPublishProfile), app.ImportProfiles) // vCard・CSVからプロフィールを一括作成（?dry_run=true で検証のみ）
 
 			// プロフィールごとのオプションプロフィール一覧取得
 			profiles.GET("/:id/option-profiles", app.GetOptionProfilesByProfileID)
 
 			profiles.DELETE("/:id", app.DeleteProfile) // プロフィール削除
 		}
+		// イベント用バッジのPDF（閲覧できるプロフィールの表示名・肩書き・アイコン・QRコード。POSTでも読み取るだけなので :read で使える）
+		api.POST("/profiles/badges", authRequired, readScope("profiles"), byUser("profile-badges", store.PerMinute(10)), app.GenerateBadges)
 
 		// 公開API（認証不要）
-		api.GET("/profiles/:id", app.GetProfile)          // プロフィール取得（公開）
-		api.GET("/profiles/:id/icon", app.GetProfileIcon) // プロフィールアイコン取得（公開）
+		api.GET("/profiles/:id", byIP("public", store.PerMinute(120)), authOptional, readScope("profiles"), app.GetProfile)               // プロフィール取得（公開）
+		api.GET("/profiles/:id/icon", byIP("public-icon", store.PerMinute(120)), authOptional, readScope("profiles"), app.GetProfileIcon) // プロフィールアイコン取得（公開）
+		api.GET("/profiles/:id/vcard", byIP("public", store.PerMinute(120)), authOptional, readScope("profiles"), app.GetProfileVCard)    // プロフィールのvCard（公開）
 
 		// option_profiles関連
 		optionProfiles := api.Group("/option_profiles")
-		optionProfiles.Use(middleware.AuthRequired())
+		optionProfiles.Use(authRequired, scope("profiles"))
 		{
 			optionProfiles.POST("", app.CreateOptionProfile)       // 作成
 			optionProfiles.PATCH("/:id", app.UpdateOptionProfile)  // 更新
@@ -76,20 +196,46 @@ func SetupRoutes(r *gin.Engine) {
 
 		// 認証必要 - ユーザー関連サブ
 		users := api.Group("/users")
-		users.Use(middleware.AuthRequired())
+		users.Use(authRequired)
 		{
-			users.GET("/:userId/profiles", app.GetProfilesByUserID)   // ユーザー毎プロフィール一覧
-			users.GET("/:userId/connections", app.GetUserConnections) // ユーザーの交換済みプロフィール一覧
+			users.GET("/:userId/profiles", scope("profiles"), app.GetProfilesByUserID)                // ユーザー毎プロフィール一覧
+			users.GET("/:userId/connections", scope("connections"), app.GetUserConnections)           // ユーザーの交換済みプロフィール一覧
+			users.GET("/:userId/connections/vcard", scope("connections"), app.ExportConnectionsVCard) // 交換済みプロフィールのvCard一括エクスポート
 		}
 
-		// コネクション関連: profile_idに変更
+		// コネクション関連: profile_idに変更（認証要・自分のプロフィールのコネクションのみ操作可能）
 		connections := api.Group("/connections")
+		connections.Use(authRequired, scope("connections"))
+		{
+			connections.POST("", byUser("create-connection", store.PerMinute(60)), app.RequireVerifiedEmail(handlers.ActionCreateConnection), app.CreateConnection) // コネクション作成（リクエストbody: profile_id, connect_user_profile_public_id）
+			connections.GET("", app.GetConnections)                                                                                                                 // コネクション一覧取得（?profile_id=xxx）
+			connections.DELETE("/:id", app.DeleteConnection)                                                                                                        // コネクション削除
+			connections.GET("/:id", app.GetConnection)                                                                                                              // コネクション詳細取得
+			connections.PUT("/:id", app.UpdateConnection)                                                                                                           // コネクション更新
+		}
+
+		// コネクション申請（他人のプロフィールへのコネクションは相手の承認後に作成）
+		connectionRequests := api.Group("/connection-requests")
+		connectionRequests.Use(authRequired, scope("connections"))
 		{
-			connections.POST("", app.CreateConnection)       // コネクション作成（リクエストbody: profile_id, connect_user_profile_id）
-			connections.GET("", app.GetConnections)          // コネクション一覧取得（?profile_id=xxx）
-			connections.DELETE("/:id", app.DeleteConnection) // コネクション削除
-			connections.GET("/:id", app.GetConnection)       // コネクション詳細取得
-			connections.PUT("/:id", app.UpdateConnection)    // コネクション更新
+			connectionRequests.GET("/incoming", app.ListIncomingConnectionRequests) // 自分のプロフィールへの申請一覧（?status=pending|accepted|declined|expired）
+			connectionRequests.GET("/outgoing", app.ListOutgoingConnectionRequests) // 自分のプロフィールからの申請一覧
+			connectionRequests.POST("/:id/accept", app.AcceptConnectionRequest)     // 承認（コネクションを作成）
+			connectionRequests.POST("/:id/decline", app.DeclineConnectionRequest)   // 拒否
 		}
+
+		// QRコードの交換トークン（有効期限付き・1回限りにもできる署名付きトークン）
+		exchangeTokens := api.Group("/exchange-tokens")
+		exchangeTokens.Use(authRequired)
+		{
+			exchangeTokens.POST("", scope("profiles"), byUser("create-exchange-token", store.PerMinute(30)), app.CreateExchangeToken) // 交換トークンとQRコードの発行（リクエストbody: profile_id, ttl_seconds, single_use）
+			exchangeTokens.POST("/preview", scope("connections"), app.PreviewExchangeToken)                                           // 交換トークンが指すプロフィールの確認
+			exchangeTokens.POST("/redeem", scope("connections"), byUser("create-connection", store.PerMinute(60)),
+				app.RequireVerifiedEmail(handlers.ActionCreateConnection), app.RedeemExchangeToken) // 交換トークンでコネクションを作成
+		}
+
+		// 相互交換（交換トークンを使い、双方向のコネクションを1つのトランザクションで作成。再送しても重複しない）
+		api.POST("/exchanges", authRequired, scope("connections"), byUser("create-connection", store.PerMinute(60)),
+			app.RequireVerifiedEmail(handlers.ActionCreateConnection), app.CreateExchange)
 	}
 }
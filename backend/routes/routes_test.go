@@ -0,0 +1,572 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"backend/handlers"
+	"backend/models"
+	"backend/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testServer はメモリストアを使ったテスト用サーバーです
+type testServer struct {
+	t       *testing.T
+	router  *gin.Engine
+	app     *handlers.App
+	stores  *store.Stores
+	mailDir string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	t.Setenv("JWT_KEYS_DIR", "")
+	t.Setenv("CLOUDINARY_CLOUD_NAME", "")
+	mailDir := t.TempDir()
+	t.Setenv("MAIL_DRIVER", "file")
+	t.Setenv("MAIL_DIR", mailDir)
+
+	// アイコン保存先（./uploads）がリポジトリを汚さないよう一時ディレクトリで実行する
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	stores := store.NewMemory()
+	app, err := handlers.NewApp(stores)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	RegisterRoutes(r, app)
+	return &testServer{t: t, router: r, app: app, stores: stores, mailDir: mailDir}
+}
+
+// do はリクエストを送り、レスポンスを返します（tokenが空の場合は認証ヘッダーなし）
+func (s *testServer) do(method, path string, body interface{}, token string) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		reader = bytes.NewReader(raw)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// expect はステータスコードを検証し、JSONボディをoutにデコードします
+func (s *testServer) expect(w *httptest.ResponseRecorder, status int, out interface{}) {
+	s.t.Helper()
+	if w.Code != status {
+		s.t.Fatalf("status = %d, want %d; body = %s", w.Code, status, w.Body.String())
+	}
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			s.t.Fatalf("invalid JSON response: %v; body = %s", err, w.Body.String())
+		}
+	}
+}
+
+type authResponse struct {
+	User struct {
+		ID    int    `json:"id"`
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	} `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// signUp はユーザーを登録し、確認メールのリンクでメールアドレスを確認してからIDとトークンを返します
+func (s *testServer) signUp(name, email string) (int, string) {
+	s.t.Helper()
+	res := s.signUpUnverified(name, email)
+	s.expect(s.do(http.MethodPost, "/api/email/verify", gin.H{"token": s.lastMailToken(email)}, ""), http.StatusOK, nil)
+	return res.User.ID, res.Token
+}
+
+// signUpUnverified はユーザーを登録するだけで、メールアドレスは未確認のままにします
+func (s *testServer) signUpUnverified(name, email string) authResponse {
+	s.t.Helper()
+	var res authResponse
+	s.expect(s.do(http.MethodPost, "/api/signup", gin.H{
+		"name": name, "email": email, "password": "password123",
+	}, ""), http.StatusOK, &res)
+	return res
+}
+
+// mails は宛先に送られたメールを送信順に返します
+func (s *testServer) mails(to string) []string {
+	s.t.Helper()
+	entries, err := os.ReadDir(s.mailDir)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	var mails []string
+	for _, name := range names {
+		raw, err := os.ReadFile(filepath.Join(s.mailDir, name))
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		if strings.Contains(string(raw), "\nTo: "+to+"\n") {
+			mails = append(mails, string(raw))
+		}
+	}
+	return mails
+}
+
+var tokenParam = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+// lastMailToken は宛先に最後に送られたメールのリンクからトークンを取り出します
+func (s *testServer) lastMailToken(to string) string {
+	s.t.Helper()
+	mails := s.mails(to)
+	if len(mails) == 0 {
+		s.t.Fatalf("no mail sent to %s", to)
+	}
+	m := tokenParam.FindStringSubmatch(mails[len(mails)-1])
+	if m == nil {
+		s.t.Fatalf("mail has no token: %s", mails[len(mails)-1])
+	}
+	return m[1]
+}
+
+// createProfile はプロフィールを作成してIDを返します
+func (s *testServer) createProfile(userID int, token, displayName string) int {
+	s.t.Helper()
+	var res struct {
+		ID int `json:"id"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/profiles", gin.H{
+		"user_id": userID, "display_name": displayName, "title": displayName + "のプロフィール",
+	}, token), http.StatusCreated, &res)
+	return res.ID
+}
+
+// publicID はプロフィールの公開IDを返します（公開APIのパスには連番のIDではなくこちらを使います）
+func (s *testServer) publicID(profileID int) string {
+	s.t.Helper()
+	profile, err := s.stores.Profiles.Get(context.Background(), profileID)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return profile.PublicID
+}
+
+func TestHealthCheck(t *testing.T) {
+	s := newTestServer(t)
+	var res struct {
+		Status string `json:"status"`
+	}
+	s.expect(s.do(http.MethodGet, "/api/health", nil, ""), http.StatusOK, &res)
+	if res.Status == "" {
+		t.Fatal("status is empty")
+	}
+}
+
+func TestGenerateQRCode(t *testing.T) {
+	s := newTestServer(t)
+
+	var res struct {
+		QRData string `json:"qr_data"`
+		URL    string `json:"url"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/generate-qr", gin.H{"url": "https://example.com"}, ""), http.StatusOK, &res)
+	if res.URL != "https://example.com" || len(res.QRData) < len("data:image/png;base64,") {
+		t.Fatalf("unexpected response: %+v", res)
+	}
+
+	s.expect(s.do(http.MethodPost, "/api/generate-qr", gin.H{}, ""), http.StatusBadRequest, nil)
+}
+
+func TestSignUpAndSignIn(t *testing.T) {
+	s := newTestServer(t)
+	id, token := s.signUp("Alice", "alice@example.com")
+	if id == 0 || token == "" {
+		t.Fatalf("signup returned id=%d token=%q", id, token)
+	}
+
+	// 同じメールアドレスでは登録できない
+	s.expect(s.do(http.MethodPost, "/api/signup", gin.H{
+		"name": "Alice2", "email": "alice@example.com", "password": "password123",
+	}, ""), http.StatusConflict, nil)
+
+	var res authResponse
+	s.expect(s.do(http.MethodPost, "/api/signin", gin.H{
+		"email": "alice@example.com", "password": "password123",
+	}, ""), http.StatusOK, &res)
+	if res.User.ID != id || res.Token == "" {
+		t.Fatalf("unexpected signin response: %+v", res)
+	}
+
+	s.expect(s.do(http.MethodPost, "/api/signin", gin.H{
+		"email": "alice@example.com", "password": "wrong",
+	}, ""), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodPost, "/api/signin", gin.H{
+		"email": "nobody@example.com", "password": "password123",
+	}, ""), http.StatusUnauthorized, nil)
+}
+
+func TestGetUsers(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, token := s.signUp("Alice", "alice@example.com")
+	s.signUp("Bob", "bob@example.com")
+
+	s.expect(s.do(http.MethodGet, "/api/users", nil, ""), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodGet, "/api/users", nil, "invalid"), http.StatusUnauthorized, nil)
+
+	// 一般ユーザーには他人のメールアドレスを返さない
+	var res struct {
+		Users []map[string]interface{} `json:"users"`
+	}
+	s.expect(s.do(http.MethodGet, "/api/users", nil, token), http.StatusOK, &res)
+	if len(res.Users) != 1 || res.Users[0]["email"] != "alice@example.com" {
+		t.Fatalf("users = %v, want only alice", res.Users)
+	}
+
+	if err := s.stores.Users.UpdateRole(context.Background(), aliceID, models.RoleModerator); err != nil {
+		t.Fatal(err)
+	}
+	s.expect(s.do(http.MethodGet, "/api/users", nil, token), http.StatusOK, &res)
+	if len(res.Users) != 2 {
+		t.Fatalf("len(users) = %d, want 2", len(res.Users))
+	}
+	if _, ok := res.Users[0]["password"]; ok {
+		t.Fatal("password must not be exposed")
+	}
+}
+
+func TestProfileRoutes(t *testing.T) {
+	s := newTestServer(t)
+	userID, token := s.signUp("Alice", "alice@example.com")
+
+	s.expect(s.do(http.MethodPost, "/api/profiles", gin.H{"user_id": userID, "display_name": "Alice"}, token), http.StatusBadRequest, nil)
+
+	icon := base64.StdEncoding.EncodeToString([]byte("fake-png"))
+	var created struct {
+		ID      int    `json:"id"`
+		IconURL string `json:"icon_url"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/profiles", gin.H{
+		"user_id": userID, "display_name": "Alice", "title": "仕事用",
+		"birthdate": "2000-01-02", "icon_base64": "data:image/png;base64," + icon,
+	}, token), http.StatusCreated, &created)
+	if created.IconURL == "" {
+		t.Fatal("icon_url is empty")
+	}
+
+	var profile struct {
+		ID          int    `json:"id"`
+		DisplayName string `json:"display_name"`
+		Title       string `json:"title"`
+		Birthdate   string `json:"birthdate"`
+		IconPath    string `json:"icon_path"`
+	}
+	publicPath := "/api/profiles/" + s.publicID(created.ID)
+	s.expect(s.do(http.MethodGet, publicPath, nil, ""), http.StatusOK, &profile)
+	if profile.DisplayName != "Alice" || profile.Title != "仕事用" || profile.Birthdate[:10] != "2000-01-02" {
+		t.Fatalf("unexpected profile: %+v", profile)
+	}
+	if profile.IconPath != "" {
+		t.Fatal("icon_path must not be exposed")
+	}
+	s.expect(s.do(http.MethodGet, "/api/profiles/9999", nil, ""), http.StatusNotFound, nil)
+
+	// 連番のIDでは本人以外は参照できない
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/profiles/%d", created.ID), nil, ""), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/profiles/%d", created.ID), nil, token), http.StatusOK, nil)
+
+	w := s.do(http.MethodGet, publicPath+"/icon", nil, "")
+	if w.Code != http.StatusOK || w.Body.String() != "fake-png" {
+		t.Fatalf("icon: status=%d body=%q", w.Code, w.Body.String())
+	}
+
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/profiles/%d", created.ID), gin.H{"hobby": "読書"}, ""), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/profiles/%d", created.ID), gin.H{}, token), http.StatusBadRequest, nil)
+	var updated struct {
+		Hobby string `json:"hobby"`
+		Title string `json:"title"`
+	}
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/profiles/%d", created.ID), gin.H{"hobby": "読書"}, token), http.StatusOK, &updated)
+	if updated.Hobby != "読書" || updated.Title != "仕事用" {
+		t.Fatalf("unexpected updated profile: %+v", updated)
+	}
+
+	// アイコンの変更は更新に成功した場合だけ古いアイコンを削除する
+	uploads := func() []string {
+		files, err := filepath.Glob(filepath.Join("uploads", "*"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return files
+	}
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/profiles/%d", bobProfile), gin.H{"slug": "taken-url"}, bobToken), http.StatusOK, nil)
+	before := uploads()
+	newIcon := base64.StdEncoding.EncodeToString([]byte("new-png"))
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/profiles/%d", created.ID), gin.H{"slug": "taken-url", "icon_base64": newIcon}, token), http.StatusConflict, nil)
+	if w := s.do(http.MethodGet, publicPath+"/icon", nil, ""); w.Body.String() != "fake-png" {
+		t.Fatalf("icon after failed update = %q", w.Body.String())
+	}
+	if after := uploads(); len(after) != len(before) {
+		t.Fatalf("uploads after failed update = %v, want %v", after, before)
+	}
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/profiles/%d", created.ID), gin.H{"icon_base64": newIcon}, token), http.StatusOK, nil)
+	if w := s.do(http.MethodGet, publicPath+"/icon", nil, ""); w.Body.String() != "new-png" {
+		t.Fatalf("icon after update = %q", w.Body.String())
+	}
+	if after := uploads(); len(after) != len(before) {
+		t.Fatalf("uploads after update = %v, want %v", after, before)
+	}
+
+	var list struct {
+		Profiles []struct {
+			ID int `json:"id"`
+		} `json:"profiles"`
+		Count int `json:"count"`
+	}
+	s.createProfile(userID, token, "Alice2")
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/users/%d/profiles", userID), nil, token), http.StatusOK, &list)
+	if list.Count != 2 || list.Profiles[1].ID != created.ID {
+		t.Fatalf("unexpected profile list: %+v", list)
+	}
+	s.expect(s.do(http.MethodGet, "/api/users/9999/profiles", nil, token), http.StatusForbidden, nil)
+
+	s.expect(s.do(http.MethodDelete, fmt.Sprintf("/api/profiles/%d", created.ID), nil, token), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, publicPath, nil, ""), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodDelete, fmt.Sprintf("/api/profiles/%d", created.ID), nil, token), http.StatusNotFound, nil)
+}
+
+func TestDeleteProfileRequiresOwner(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	_, bobToken := s.signUp("Bob", "bob@example.com")
+	profileID := s.createProfile(aliceID, aliceToken, "Alice")
+
+	s.expect(s.do(http.MethodDelete, fmt.Sprintf("/api/profiles/%d", profileID), nil, bobToken), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodGet, "/api/profiles/"+s.publicID(profileID), nil, ""), http.StatusOK, nil)
+}
+
+func TestOptionProfileRoutes(t *testing.T) {
+	s := newTestServer(t)
+	userID, token := s.signUp("Alice", "alice@example.com")
+	profileID := s.createProfile(userID, token, "Alice")
+
+	s.expect(s.do(http.MethodPost, "/api/option_profiles", gin.H{
+		"title": "好きな食べ物", "content": "寿司", "profile_id": 9999,
+	}, token), http.StatusBadRequest, nil)
+
+	var opt struct {
+		ID      int    `json:"id"`
+		Title   string `json:"title"`
+		Content string `json:"content"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/option_profiles", gin.H{
+		"title": "好きな食べ物", "content": "寿司", "profile_id": profileID,
+	}, token), http.StatusCreated, &opt)
+
+	s.expect(s.do(http.MethodPatch, fmt.Sprintf("/api/option_profiles/%d", opt.ID), gin.H{}, token), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPatch, fmt.Sprintf("/api/option_profiles/%d", opt.ID), gin.H{"content": "ラーメン"}, token), http.StatusOK, &opt)
+	if opt.Title != "好きな食べ物" || opt.Content != "ラーメン" {
+		t.Fatalf("unexpected option profile: %+v", opt)
+	}
+
+	var list struct {
+		Options []struct {
+			ID int `json:"id"`
+		} `json:"option_profiles"`
+		Count int `json:"count"`
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/profiles/%d/option-profiles", profileID), nil, token), http.StatusOK, &list)
+	if list.Count != 1 || list.Options[0].ID != opt.ID {
+		t.Fatalf("unexpected option profile list: %+v", list)
+	}
+
+	s.expect(s.do(http.MethodDelete, fmt.Sprintf("/api/option_profiles/%d", opt.ID), nil, token), http.StatusOK, nil)
+	s.expect(s.do(http.MethodDelete, fmt.Sprintf("/api/option_profiles/%d", opt.ID), nil, token), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodPatch, fmt.Sprintf("/api/option_profiles/%d", opt.ID), gin.H{"content": "x"}, token), http.StatusNotFound, nil)
+}
+
+func TestLinkRoutes(t *testing.T) {
+	s := newTestServer(t)
+	userID, token := s.signUp("Alice", "alice@example.com")
+	profileID := s.createProfile(userID, token, "Alice")
+
+	s.expect(s.do(http.MethodPost, "/api/links", gin.H{"title": "GitHub", "url": "https://github.com/alice"}, ""), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodPost, "/api/links", gin.H{"title": "GitHub", "url": "https://github.com/alice"}, token), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/links", gin.H{"title": "GitHub", "url": "not a url", "profile_id": profileID}, token), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/links", gin.H{"title": "GitHub", "url": "https://github.com/alice", "profile_id": 9999}, token), http.StatusBadRequest, nil)
+
+	type linkBody struct {
+		ID        int    `json:"id"`
+		Title     string `json:"title"`
+		URL       string `json:"url"`
+		ProfileID *int   `json:"profile_id"`
+	}
+	var created struct {
+		Link linkBody `json:"link"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/links", gin.H{
+		"title": "GitHub", "url": "https://github.com/alice", "profile_id": profileID,
+	}, token), http.StatusCreated, &created)
+	s.expect(s.do(http.MethodPost, "/api/links", gin.H{
+		"title": "Blog", "url": "https://alice.example.com", "user_id": userID,
+	}, token), http.StatusCreated, nil)
+
+	var got struct {
+		Link linkBody `json:"link"`
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/links/%d", created.Link.ID), nil, token), http.StatusOK, &got)
+	if got.Link.Title != "GitHub" || got.Link.ProfileID == nil || *got.Link.ProfileID != profileID {
+		t.Fatalf("unexpected link: %+v", got.Link)
+	}
+	s.expect(s.do(http.MethodGet, "/api/links/9999", nil, token), http.StatusNotFound, nil)
+
+	var list struct {
+		Links []linkBody `json:"links"`
+		Total int        `json:"total"`
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/links/user/%d", userID), nil, token), http.StatusOK, &list)
+	if list.Total != 2 {
+		t.Fatalf("user links total = %d, want 2", list.Total)
+	}
+	s.expect(s.do(http.MethodGet, "/api/links/profile/"+s.publicID(profileID), nil, ""), http.StatusOK, &list)
+	if list.Total != 1 || list.Links[0].ID != created.Link.ID {
+		t.Fatalf("unexpected profile links: %+v", list)
+	}
+
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/links/%d", created.Link.ID), gin.H{"title": "GitHub (main)"}, token), http.StatusOK, &got)
+	if got.Link.Title != "GitHub (main)" || got.Link.URL != "https://github.com/alice" {
+		t.Fatalf("unexpected updated link: %+v", got.Link)
+	}
+	s.expect(s.do(http.MethodPut, "/api/links/9999", gin.H{"title": "x"}, token), http.StatusNotFound, nil)
+
+	s.expect(s.do(http.MethodDelete, fmt.Sprintf("/api/links/%d", created.Link.ID), nil, token), http.StatusOK, nil)
+	s.expect(s.do(http.MethodDelete, fmt.Sprintf("/api/links/%d", created.Link.ID), nil, token), http.StatusNotFound, nil)
+
+	var types struct {
+		LinkTypes []struct {
+			Name string `json:"name"`
+		} `json:"link_types"`
+	}
+	s.expect(s.do(http.MethodGet, "/api/links/types/common", nil, token), http.StatusOK, &types)
+	if len(types.LinkTypes) == 0 {
+		t.Fatal("link types are empty")
+	}
+}
+
+func TestConnectionRoutes(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	aliceProfile := s.createProfile(aliceID, aliceToken, "Alice")
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+
+	body := gin.H{"profile_id": aliceProfile, "connect_user_profile_public_id": s.publicID(bobProfile), "event_name": "Tech Meetup"}
+	connectionID := s.requestConnection(aliceToken, body, bobToken)
+	s.expect(s.do(http.MethodPost, "/api/connections", body, aliceToken), http.StatusConflict, nil)
+	s.expect(s.do(http.MethodPost, "/api/connections", gin.H{"profile_id": aliceProfile}, aliceToken), http.StatusBadRequest, nil)
+
+	var list struct {
+		Connections []struct {
+			ID int `json:"id"`
+		} `json:"connections"`
+		Total int `json:"total"`
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/connections?profile_id=%d", aliceProfile), nil, aliceToken), http.StatusOK, &list)
+	if list.Total != 1 || list.Connections[0].ID != connectionID {
+		t.Fatalf("unexpected connections: %+v", list)
+	}
+	s.expect(s.do(http.MethodGet, "/api/connections", nil, aliceToken), http.StatusBadRequest, nil)
+
+	path := fmt.Sprintf("/api/connections/%d", connectionID)
+	s.expect(s.do(http.MethodGet, path, nil, aliceToken), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPut, path, gin.H{"event_name": "Tech Meetup", "memo": "Goの話をした"}, aliceToken), http.StatusOK, nil)
+
+	var userConns struct {
+		Connections []struct {
+			ConnectedProfilePublicID string `json:"connected_profile_public_id"`
+			ConnectedUserName        string `json:"connected_user_name"`
+			Memo                     string `json:"memo"`
+		} `json:"connections"`
+		Total int `json:"total"`
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/users/%d/connections", aliceID), nil, aliceToken), http.StatusOK, &userConns)
+	if userConns.Total != 1 || userConns.Connections[0].ConnectedProfilePublicID != s.publicID(bobProfile) ||
+		userConns.Connections[0].ConnectedUserName != "Bob" || userConns.Connections[0].Memo != "Goの話をした" {
+		t.Fatalf("unexpected user connections: %+v", userConns)
+	}
+
+	s.expect(s.do(http.MethodDelete, path, nil, aliceToken), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, path, nil, aliceToken), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodPut, path, gin.H{"memo": "x"}, aliceToken), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodDelete, path, nil, aliceToken), http.StatusNotFound, nil)
+}
+
+func TestDeleteProfileRemovesRelatedData(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	aliceProfile := s.createProfile(aliceID, aliceToken, "Alice")
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+
+	s.connect(bobToken, bobProfile, aliceProfile, aliceToken)
+	s.expect(s.do(http.MethodPost, "/api/links", gin.H{
+		"title": "GitHub", "url": "https://github.com/alice", "profile_id": aliceProfile,
+	}, aliceToken), http.StatusCreated, nil)
+
+	alicePublicID := s.publicID(aliceProfile)
+	s.expect(s.do(http.MethodDelete, fmt.Sprintf("/api/profiles/%d", aliceProfile), nil, aliceToken), http.StatusOK, nil)
+
+	var list struct {
+		Total int `json:"total"`
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/connections?profile_id=%d", bobProfile), nil, bobToken), http.StatusOK, &list)
+	if list.Total != 0 {
+		t.Fatalf("connections to deleted profile remain: %d", list.Total)
+	}
+	s.expect(s.do(http.MethodGet, "/api/links/profile/"+alicePublicID, nil, ""), http.StatusOK, &list)
+	if list.Total != 0 {
+		t.Fatalf("links of deleted profile remain: %d", list.Total)
+	}
+}
@@ -0,0 +1,150 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type sessionList struct {
+	Sessions []struct {
+		ID         string    `json:"id"`
+		UserAgent  string    `json:"user_agent"`
+		IPAddress  string    `json:"ip_address"`
+		LastSeenAt time.Time `json:"last_seen_at"`
+		Current    bool      `json:"current"`
+	} `json:"sessions"`
+}
+
+// signInFrom は送信元IPとUser-Agentを指定してサインインします
+func (s *testServer) signInFrom(ip, userAgent, email string) authResponse {
+	s.t.Helper()
+	raw, err := json.Marshal(gin.H{"email": email, "password": "password123"})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/signin", bytes.NewReader(raw))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.RemoteAddr = ip + ":12345"
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	var res authResponse
+	s.expect(w, http.StatusOK, &res)
+	return res
+}
+
+func TestListSessions(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("Alice", "alice@example.com")
+	laptop := s.signInFrom("203.0.113.1", "Mozilla/5.0 (Macintosh)", "alice@example.com")
+	phone := s.signInFrom("198.51.100.7", "Mozilla/5.0 (iPhone)", "alice@example.com")
+
+	var list sessionList
+	s.expect(s.do(http.MethodGet, "/api/sessions", nil, phone.Token), http.StatusOK, &list)
+	// サインアップ時のセッションを含む
+	if len(list.Sessions) != 3 {
+		t.Fatalf("sessions = %+v", list.Sessions)
+	}
+	var current, mac int
+	for _, session := range list.Sessions {
+		if session.Current {
+			current++
+			if session.UserAgent != "Mozilla/5.0 (iPhone)" || session.IPAddress != "198.51.100.7" {
+				t.Fatalf("current session = %+v", session)
+			}
+		}
+		if session.UserAgent == "Mozilla/5.0 (Macintosh)" && session.IPAddress == "203.0.113.1" && !session.LastSeenAt.IsZero() {
+			mac++
+		}
+	}
+	if current != 1 || mac != 1 {
+		t.Fatalf("sessions = %+v", list.Sessions)
+	}
+
+	// リフレッシュしても同じセッションのまま
+	var refreshed authResponse
+	s.expect(s.do(http.MethodPost, "/api/token/refresh", gin.H{"refresh_token": laptop.RefreshToken}, ""), http.StatusOK, &refreshed)
+	s.expect(s.do(http.MethodGet, "/api/sessions", nil, refreshed.Token), http.StatusOK, &list)
+	if len(list.Sessions) != 3 {
+		t.Fatalf("sessions after refresh = %+v", list.Sessions)
+	}
+
+	// 他のユーザーのセッションは見えず、パーソナルアクセストークンでは使えない
+	_, bobToken := s.signUp("Bob", "bob@example.com")
+	s.expect(s.do(http.MethodGet, "/api/sessions", nil, bobToken), http.StatusOK, &list)
+	if len(list.Sessions) != 1 {
+		t.Fatalf("bob sessions = %+v", list.Sessions)
+	}
+	pat := s.createAccessToken(phone.Token, "script", "profiles:read")
+	s.expect(s.do(http.MethodGet, "/api/sessions", nil, pat.Token), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodGet, "/api/sessions", nil, ""), http.StatusUnauthorized, nil)
+}
+
+func TestRevokeSession(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("Alice", "alice@example.com")
+	laptop := s.signInFrom("203.0.113.1", "laptop", "alice@example.com")
+	phone := s.signInFrom("198.51.100.7", "phone", "alice@example.com")
+
+	var list sessionList
+	s.expect(s.do(http.MethodGet, "/api/sessions", nil, phone.Token), http.StatusOK, &list)
+	var laptopID string
+	for _, session := range list.Sessions {
+		if session.UserAgent == "laptop" {
+			laptopID = session.ID
+		}
+	}
+
+	// 他人のセッションは失効させられない
+	_, bobToken := s.signUp("Bob", "bob@example.com")
+	s.expect(s.do(http.MethodDelete, "/api/sessions/"+laptopID, nil, bobToken), http.StatusNotFound, nil)
+
+	// 失効させたセッションのアクセストークン・リフレッシュトークンはすぐに使えなくなる
+	s.expect(s.do(http.MethodDelete, "/api/sessions/"+laptopID, nil, phone.Token), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, "/api/users", nil, laptop.Token), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodPost, "/api/token/refresh", gin.H{"refresh_token": laptop.RefreshToken}, ""), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodDelete, "/api/sessions/"+laptopID, nil, phone.Token), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodGet, "/api/users", nil, phone.Token), http.StatusOK, nil)
+}
+
+func TestRevokeOtherSessions(t *testing.T) {
+	s := newTestServer(t)
+	_, signUpToken := s.signUp("Alice", "alice@example.com")
+	laptop := s.signInFrom("203.0.113.1", "laptop", "alice@example.com")
+	phone := s.signInFrom("198.51.100.7", "phone", "alice@example.com")
+	_, bobToken := s.signUp("Bob", "bob@example.com")
+
+	var res struct {
+		Revoked int `json:"revoked"`
+	}
+	s.expect(s.do(http.MethodDelete, "/api/sessions", nil, phone.Token), http.StatusOK, &res)
+	if res.Revoked != 2 {
+		t.Fatalf("revoked = %d, want 2", res.Revoked)
+	}
+	s.expect(s.do(http.MethodGet, "/api/users", nil, signUpToken), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodGet, "/api/users", nil, laptop.Token), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodPost, "/api/token/refresh", gin.H{"refresh_token": laptop.RefreshToken}, ""), http.StatusUnauthorized, nil)
+
+	// 現在のセッションと他のユーザーのセッションは有効なまま
+	var list sessionList
+	s.expect(s.do(http.MethodGet, "/api/sessions", nil, phone.Token), http.StatusOK, &list)
+	if len(list.Sessions) != 1 || !list.Sessions[0].Current {
+		t.Fatalf("sessions = %+v", list.Sessions)
+	}
+	s.expect(s.do(http.MethodGet, "/api/users", nil, bobToken), http.StatusOK, nil)
+
+	// サインアウトしたセッションは一覧から消える
+	s.expect(s.do(http.MethodPost, "/api/signout", nil, phone.Token), http.StatusOK, nil)
+	next := s.signIn("alice@example.com")
+	s.expect(s.do(http.MethodGet, "/api/sessions", nil, next.Token), http.StatusOK, &list)
+	if len(list.Sessions) != 1 || !list.Sessions[0].Current {
+		t.Fatalf("sessions after signout = %+v", list.Sessions)
+	}
+}
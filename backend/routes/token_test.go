@@ -0,0 +1,101 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// signIn はサインインしてトークン一式を返します
+func (s *testServer) signIn(email string) authResponse {
+	s.t.Helper()
+	var res authResponse
+	s.expect(s.do(http.MethodPost, "/api/signin", gin.H{
+		"email": email, "password": "password123",
+	}, ""), http.StatusOK, &res)
+	return res
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("Alice", "alice@example.com")
+	first := s.signIn("alice@example.com")
+	if first.RefreshToken == "" || first.ExpiresIn <= 0 {
+		t.Fatalf("signin response lacks refresh token: %+v", first)
+	}
+
+	var second authResponse
+	s.expect(s.do(http.MethodPost, "/api/token/refresh", gin.H{"refresh_token": first.RefreshToken}, ""), http.StatusOK, &second)
+	if second.Token == "" || second.RefreshToken == "" || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("refresh did not rotate tokens: %+v", second)
+	}
+	s.expect(s.do(http.MethodGet, "/api/users", nil, second.Token), http.StatusOK, nil)
+
+	var third authResponse
+	s.expect(s.do(http.MethodPost, "/api/token/refresh", gin.H{"refresh_token": second.RefreshToken}, ""), http.StatusOK, &third)
+
+	s.expect(s.do(http.MethodPost, "/api/token/refresh", gin.H{"refresh_token": "unknown"}, ""), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodPost, "/api/token/refresh", gin.H{}, ""), http.StatusBadRequest, nil)
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("Alice", "alice@example.com")
+	first := s.signIn("alice@example.com")
+	other := s.signIn("alice@example.com")
+
+	var second authResponse
+	s.expect(s.do(http.MethodPost, "/api/token/refresh", gin.H{"refresh_token": first.RefreshToken}, ""), http.StatusOK, &second)
+	s.expect(s.do(http.MethodGet, "/api/users", nil, second.Token), http.StatusOK, nil)
+	var sessions struct {
+		Sessions []struct {
+			ID string `json:"id"`
+		} `json:"sessions"`
+	}
+	s.expect(s.do(http.MethodGet, "/api/sessions", nil, other.Token), http.StatusOK, &sessions)
+	before := len(sessions.Sessions)
+
+	// 使用済みトークンの再利用は拒否され、同じ系列の最新トークンも使えなくなる
+	s.expect(s.do(http.MethodPost, "/api/token/refresh", gin.H{"refresh_token": first.RefreshToken}, ""), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodPost, "/api/token/refresh", gin.H{"refresh_token": second.RefreshToken}, ""), http.StatusUnauthorized, nil)
+	// セッションごと失効し、発行済みのアクセストークンも使えず、セッション一覧にも残らない
+	for _, token := range []string{first.Token, second.Token} {
+		s.expect(s.do(http.MethodGet, "/api/users", nil, token), http.StatusUnauthorized, nil)
+	}
+	s.expect(s.do(http.MethodGet, "/api/sessions", nil, other.Token), http.StatusOK, &sessions)
+	if len(sessions.Sessions) != before-1 {
+		t.Fatalf("sessions = %d, want %d", len(sessions.Sessions), before-1)
+	}
+
+	// 別のサインインで得た系列には影響しない
+	s.expect(s.do(http.MethodPost, "/api/token/refresh", gin.H{"refresh_token": other.RefreshToken}, ""), http.StatusOK, nil)
+}
+
+func TestSignOutRevokesTokens(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("Alice", "alice@example.com")
+	session := s.signIn("alice@example.com")
+	other := s.signIn("alice@example.com")
+
+	s.expect(s.do(http.MethodPost, "/api/signout", gin.H{"refresh_token": session.RefreshToken}, ""), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodPost, "/api/signout", gin.H{"refresh_token": session.RefreshToken}, session.Token), http.StatusOK, nil)
+
+	// 失効したアクセストークン・リフレッシュトークンは使えない
+	s.expect(s.do(http.MethodGet, "/api/users", nil, session.Token), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodPost, "/api/token/refresh", gin.H{"refresh_token": session.RefreshToken}, ""), http.StatusUnauthorized, nil)
+
+	// 他のサインインは有効なまま
+	s.expect(s.do(http.MethodGet, "/api/users", nil, other.Token), http.StatusOK, nil)
+}
+
+func TestSignOutDoesNotRevokeOthersRefreshToken(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("Alice", "alice@example.com")
+	s.signUp("Bob", "bob@example.com")
+	alice := s.signIn("alice@example.com")
+	bob := s.signIn("bob@example.com")
+
+	s.expect(s.do(http.MethodPost, "/api/signout", gin.H{"refresh_token": alice.RefreshToken}, bob.Token), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPost, "/api/token/refresh", gin.H{"refresh_token": alice.RefreshToken}, ""), http.StatusOK, nil)
+}
@@ -0,0 +1,197 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type challengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	Token             string `json:"token"`
+}
+
+// totpCode は現在時刻からoffsetステップずらした認証コードを返します
+func totpCode(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	code, err := utils.TOTPCode(secret, utils.TOTPStep(time.Now())+offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// enableTOTP は二要素認証を有効にしてシークレットとリカバリーコードを返します（現在のステップのコードは使用済みになります）
+func (s *testServer) enableTOTP(token string) (string, []string) {
+	s.t.Helper()
+	var enroll struct {
+		Secret     string `json:"secret"`
+		OTPAuthURI string `json:"otpauth_uri"`
+		QRData     string `json:"qr_data"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/2fa/totp/enroll", nil, token), http.StatusOK, &enroll)
+	if !strings.HasPrefix(enroll.OTPAuthURI, "otpauth://totp/") || !strings.Contains(enroll.OTPAuthURI, "secret="+enroll.Secret) {
+		s.t.Fatalf("otpauth_uri = %q", enroll.OTPAuthURI)
+	}
+	if !strings.HasPrefix(enroll.QRData, "data:image/png;base64,") {
+		s.t.Fatalf("qr_data = %.40q", enroll.QRData)
+	}
+
+	var confirmed struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/2fa/totp/confirm", gin.H{"code": totpCode(s.t, enroll.Secret, 0)}, token), http.StatusOK, &confirmed)
+	if len(confirmed.RecoveryCodes) != 10 {
+		s.t.Fatalf("got %d recovery codes, want 10", len(confirmed.RecoveryCodes))
+	}
+	return enroll.Secret, confirmed.RecoveryCodes
+}
+
+// challenge はパスワードでサインインしてチャレンジトークンを返します
+func (s *testServer) challenge(email string) string {
+	s.t.Helper()
+	var res challengeResponse
+	s.expect(s.do(http.MethodPost, "/api/signin", gin.H{"email": email, "password": "password123"}, ""), http.StatusOK, &res)
+	if !res.TwoFactorRequired || res.ChallengeToken == "" || res.Token != "" {
+		s.t.Fatalf("unexpected signin response: %+v", res)
+	}
+	return res.ChallengeToken
+}
+
+func TestTwoFactorEnrollAndSignIn(t *testing.T) {
+	s := newTestServer(t)
+	_, token := s.signUp("Alice", "alice@example.com")
+
+	var status struct {
+		Enabled bool `json:"enabled"`
+	}
+	s.expect(s.do(http.MethodGet, "/api/2fa", nil, token), http.StatusOK, &status)
+	if status.Enabled {
+		t.Fatal("2FA should be disabled by default")
+	}
+
+	// 登録開始前や誤ったコードでは有効にならない
+	s.expect(s.do(http.MethodPost, "/api/2fa/totp/confirm", gin.H{"code": "123456"}, token), http.StatusBadRequest, nil)
+	secret, _ := s.enableTOTP(token)
+	s.expect(s.do(http.MethodPost, "/api/2fa/totp/enroll", nil, token), http.StatusConflict, nil)
+	s.expect(s.do(http.MethodGet, "/api/2fa", nil, token), http.StatusOK, &status)
+	if !status.Enabled {
+		t.Fatal("2FA should be enabled")
+	}
+
+	challenge := s.challenge("alice@example.com")
+
+	// チャレンジトークンはアクセストークンとして使えない
+	s.expect(s.do(http.MethodGet, "/api/2fa", nil, challenge), http.StatusUnauthorized, nil)
+
+	// 有効化に使ったコードは再利用できない
+	s.expect(s.do(http.MethodPost, "/api/signin/2fa", gin.H{
+		"challenge_token": challenge, "code": totpCode(t, secret, 0),
+	}, ""), http.StatusUnauthorized, nil)
+
+	var res authResponse
+	s.expect(s.do(http.MethodPost, "/api/signin/2fa", gin.H{
+		"challenge_token": challenge, "code": totpCode(t, secret, 1),
+	}, ""), http.StatusOK, &res)
+	if res.Token == "" || res.RefreshToken == "" {
+		t.Fatalf("unexpected response: %+v", res)
+	}
+	s.expect(s.do(http.MethodGet, "/api/2fa", nil, res.Token), http.StatusOK, nil)
+
+	// チャレンジトークンは一度しか使えない
+	s.expect(s.do(http.MethodPost, "/api/signin/2fa", gin.H{
+		"challenge_token": challenge, "code": totpCode(t, secret, 1),
+	}, ""), http.StatusUnauthorized, nil)
+
+	// アクセストークンはチャレンジトークンとして使えない
+	s.expect(s.do(http.MethodPost, "/api/signin/2fa", gin.H{
+		"challenge_token": res.Token, "code": totpCode(t, secret, 1),
+	}, ""), http.StatusUnauthorized, nil)
+}
+
+func TestTwoFactorRecoveryCodes(t *testing.T) {
+	s := newTestServer(t)
+	_, token := s.signUp("Alice", "alice@example.com")
+	_, codes := s.enableTOTP(token)
+
+	// 大文字やハイフンなしで入力しても受け付ける
+	input := strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))
+	s.expect(s.do(http.MethodPost, "/api/signin/2fa", gin.H{
+		"challenge_token": s.challenge("alice@example.com"), "code": input,
+	}, ""), http.StatusOK, nil)
+
+	// 使用済みのリカバリーコードは使えない
+	s.expect(s.do(http.MethodPost, "/api/signin/2fa", gin.H{
+		"challenge_token": s.challenge("alice@example.com"), "code": codes[0],
+	}, ""), http.StatusUnauthorized, nil)
+
+	var status struct {
+		RecoveryCodesRemaining int `json:"recovery_codes_remaining"`
+	}
+	s.expect(s.do(http.MethodGet, "/api/2fa", nil, token), http.StatusOK, &status)
+	if status.RecoveryCodesRemaining != 9 {
+		t.Fatalf("recovery_codes_remaining = %d, want 9", status.RecoveryCodesRemaining)
+	}
+
+	// 再発行すると古いコードは使えなくなる
+	var regenerated struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/2fa/recovery-codes", gin.H{"code": codes[1]}, token), http.StatusOK, &regenerated)
+	if len(regenerated.RecoveryCodes) != 10 {
+		t.Fatalf("got %d recovery codes, want 10", len(regenerated.RecoveryCodes))
+	}
+	s.expect(s.do(http.MethodPost, "/api/signin/2fa", gin.H{
+		"challenge_token": s.challenge("alice@example.com"), "code": codes[2],
+	}, ""), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodPost, "/api/signin/2fa", gin.H{
+		"challenge_token": s.challenge("alice@example.com"), "code": regenerated.RecoveryCodes[0],
+	}, ""), http.StatusOK, nil)
+}
+
+func TestTwoFactorFailuresLockAccount(t *testing.T) {
+	s := newTestServer(t)
+	_, token := s.signUp("Alice", "alice@example.com")
+	s.enableTOTP(token)
+	challenge := s.challenge("alice@example.com")
+
+	for i := 0; i < 4; i++ {
+		s.expect(s.do(http.MethodPost, "/api/signin/2fa", gin.H{
+			"challenge_token": challenge, "code": "000000",
+		}, ""), http.StatusUnauthorized, nil)
+	}
+	s.expect(s.do(http.MethodPost, "/api/signin/2fa", gin.H{
+		"challenge_token": challenge, "code": "000000",
+	}, ""), http.StatusTooManyRequests, nil)
+	s.expect(s.do(http.MethodPost, "/api/signin", gin.H{
+		"email": "alice@example.com", "password": "password123",
+	}, ""), http.StatusTooManyRequests, nil)
+}
+
+func TestDisableTOTP(t *testing.T) {
+	s := newTestServer(t)
+	_, token := s.signUp("Alice", "alice@example.com")
+	secret, _ := s.enableTOTP(token)
+
+	s.expect(s.do(http.MethodPost, "/api/2fa/totp/disable", gin.H{
+		"password": "wrong", "code": totpCode(t, secret, 1),
+	}, token), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodPost, "/api/2fa/totp/disable", gin.H{
+		"password": "password123", "code": "000000",
+	}, token), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodPost, "/api/2fa/totp/disable", gin.H{
+		"password": "password123", "code": totpCode(t, secret, 1),
+	}, token), http.StatusOK, nil)
+
+	// 解除後はパスワードだけでサインインできる
+	res := s.signIn("alice@example.com")
+	if res.Token == "" {
+		t.Fatal("signin should return tokens after disabling 2FA")
+	}
+}
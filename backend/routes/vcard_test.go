@@ -0,0 +1,118 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestProfileVCard(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+
+	var created publicProfile
+	s.expect(s.do(http.MethodPost, "/api/profiles", gin.H{
+		"user_id": aliceID, "display_name": "Alice, Smith", "title": "仕事用", "aka": "エンジニア",
+		"hometown": "Osaka", "birthdate": "1990-04-01", "restricted_fields": []string{"hometown"},
+		"comment": strings.Repeat("よろしくお願いします。", 10),
+	}, aliceToken), http.StatusCreated, &created)
+	for _, link := range []gin.H{
+		{"title": "GitHub", "url": "https://github.com/alice"},
+		{"title": "Email", "url": "mailto:alice@example.com"},
+		{"title": "Chat", "url": "xmpp:alice@example.com"},
+		{"title": "Blog", "url": "https://example.com/blog", "connections_only": true},
+	} {
+		link["profile_id"] = created.ID
+		s.expect(s.do(http.MethodPost, "/api/links", link, aliceToken), http.StatusCreated, nil)
+	}
+	s.expect(s.do(http.MethodPost, "/api/option_profiles", gin.H{
+		"profile_id": created.ID, "title": "好きな食べ物", "content": "カレー",
+	}, aliceToken), http.StatusCreated, nil)
+
+	path := "/api/profiles/" + created.PublicID + "/vcard"
+	w := s.do(http.MethodGet, path, nil, "")
+	s.expect(w, http.StatusOK, nil)
+	if ct := w.Header().Get("Content-Type"); ct != "text/vcard; charset=utf-8" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename="`+created.PublicID+`.vcf"` {
+		t.Fatalf("Content-Disposition = %q", cd)
+	}
+
+	body := w.Body.String()
+	if !strings.HasPrefix(body, "BEGIN:VCARD\r\nVERSION:4.0\r\n") || !strings.HasSuffix(body, "END:VCARD\r\n") {
+		t.Fatalf("vcard = %q", body)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Fatalf("line not folded: %q", line)
+		}
+	}
+	unfolded := strings.ReplaceAll(body, "\r\n ", "")
+	for _, want := range []string{
+		"FN:Alice\\, Smith\r\n",
+		"TITLE:エンジニア\r\n",
+		"BDAY:19900401\r\n",
+		"NOTE:" + strings.Repeat("よろしくお願いします。", 10) + "\r\n",
+		"URL:http://localhost:3000/profile/" + created.PublicID + "\r\n",
+		"URL;X-SERVICE-TYPE=GitHub:https://github.com/alice\r\n",
+		"EMAIL:alice@example.com\r\n",
+		"IMPP:xmpp:alice@example.com\r\n",
+		"X-QRSONA-OPTION;X-LABEL=好きな食べ物:カレー\r\n",
+	} {
+		if !strings.Contains(unfolded, want) {
+			t.Fatalf("vcard missing %q:\n%s", want, unfolded)
+		}
+	}
+	// つながりのない人には制限した項目・つながりのある人だけのリンクを書き出さない
+	if strings.Contains(unfolded, "Osaka") || strings.Contains(unfolded, "example.com/blog") {
+		t.Fatalf("restricted data exported:\n%s", unfolded)
+	}
+
+	s.connect(aliceToken, created.ID, bobProfile, bobToken)
+	connected := strings.ReplaceAll(s.do(http.MethodGet, path, nil, bobToken).Body.String(), "\r\n ", "")
+	if !strings.Contains(connected, "BIRTHPLACE:Osaka\r\n") || !strings.Contains(connected, "URL:https://example.com/blog\r\n") {
+		t.Fatalf("vcard for connection:\n%s", connected)
+	}
+
+	// 非公開のプロフィールは本人以外には返さない
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/profiles/%d", created.ID), gin.H{"visibility": "private"}, aliceToken), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, path, nil, bobToken), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodGet, path, nil, aliceToken), http.StatusOK, nil)
+}
+
+func TestExportConnectionsVCard(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	carolID, carolToken := s.signUp("Carol", "carol@example.com")
+	aliceProfile := s.createProfile(aliceID, aliceToken, "Alice")
+	aliceWork := s.createProfile(aliceID, aliceToken, "Alice Work")
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+	carolProfile := s.createProfile(carolID, carolToken, "Carol")
+
+	// 同じ相手と複数のプロフィールで交換していても1件にまとめる
+	s.connect(aliceToken, aliceProfile, bobProfile, bobToken)
+	s.connect(aliceToken, aliceWork, bobProfile, bobToken)
+	s.connect(aliceToken, aliceProfile, carolProfile, carolToken)
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/profiles/%d", carolProfile), gin.H{"visibility": "private"}, carolToken), http.StatusOK, nil)
+
+	path := fmt.Sprintf("/api/users/%d/connections/vcard", aliceID)
+	w := s.do(http.MethodGet, path, nil, aliceToken)
+	s.expect(w, http.StatusOK, nil)
+	body := w.Body.String()
+	if n := strings.Count(body, "BEGIN:VCARD\r\n"); n != 1 {
+		t.Fatalf("cards = %d, want 1:\n%s", n, body)
+	}
+	if !strings.Contains(body, "FN:Bob\r\n") || strings.Contains(body, "Carol") {
+		t.Fatalf("vcard = %s", body)
+	}
+
+	s.expect(s.do(http.MethodGet, path, nil, bobToken), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodGet, path, nil, ""), http.StatusUnauthorized, nil)
+}
@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"backend/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSignUpNormalizesAndValidatesEmail(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, email := range []string{"", "alice", "alice@", "@example.com", "alice@localhost", "Alice <alice@example.com>", "alice@example..com"} {
+		s.expect(s.do(http.MethodPost, "/api/signup", gin.H{
+			"name": "Alice", "email": email, "password": "password123",
+		}, ""), http.StatusBadRequest, nil)
+	}
+
+	res := s.signUpUnverified("Alice", "  Alice@Example.COM ")
+	if res.User.Email != "alice@example.com" {
+		t.Fatalf("email = %q, want normalized", res.User.Email)
+	}
+
+	// 大文字小文字だけが違うアドレスは重複として扱う
+	s.expect(s.do(http.MethodPost, "/api/signup", gin.H{
+		"name": "Alice2", "email": "ALICE@example.com", "password": "password123",
+	}, ""), http.StatusConflict, nil)
+
+	s.expect(s.do(http.MethodPost, "/api/signin", gin.H{
+		"email": "ALICE@EXAMPLE.COM", "password": "password123",
+	}, ""), http.StatusOK, nil)
+}
+
+func TestEmailVerification(t *testing.T) {
+	s := newTestServer(t)
+	res := s.signUpUnverified("Alice", "alice@example.com")
+	if res.User.ID == 0 || res.Token == "" {
+		t.Fatalf("unexpected signup response: %+v", res)
+	}
+	first := s.lastMailToken("alice@example.com")
+
+	// 未確認のアカウントはプロフィールを公開できない
+	var errRes map[string]string
+	s.expect(s.do(http.MethodPost, "/api/profiles", gin.H{
+		"user_id": res.User.ID, "display_name": "Alice", "title": "仕事用",
+	}, res.Token), http.StatusForbidden, &errRes)
+	if errRes["error"] != handlers.EmailUnverifiedMessage {
+		t.Fatalf("error = %q", errRes["error"])
+	}
+
+	// 再送すると古いトークンは使えなくなる
+	s.expect(s.do(http.MethodPost, "/api/email/verification", nil, ""), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodPost, "/api/email/verification", nil, res.Token), http.StatusOK, nil)
+	second := s.lastMailToken("alice@example.com")
+	s.expect(s.do(http.MethodPost, "/api/email/verify", gin.H{"token": first}, ""), http.StatusBadRequest, nil)
+
+	var verified struct {
+		User struct {
+			EmailVerifiedAt *string `json:"email_verified_at"`
+		} `json:"user"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/email/verify", gin.H{"token": second}, ""), http.StatusOK, &verified)
+	if verified.User.EmailVerifiedAt == nil {
+		t.Fatal("email_verified_at is not set")
+	}
+	s.expect(s.do(http.MethodPost, "/api/email/verify", gin.H{"token": second}, ""), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/email/verification", nil, res.Token), http.StatusConflict, nil)
+
+	s.createProfile(res.User.ID, res.Token, "Alice")
+
+	var signin authResponse
+	s.expect(s.do(http.MethodPost, "/api/signin", gin.H{
+		"email": "alice@example.com", "password": "password123",
+	}, ""), http.StatusOK, &signin)
+}
+
+func TestUnverifiedPolicyIsConfigurable(t *testing.T) {
+	t.Setenv("UNVERIFIED_ACCOUNT_RESTRICTIONS", handlers.ActionCreateConnection)
+	s := newTestServer(t)
+
+	alice := s.signUpUnverified("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+
+	// プロフィールの公開は許可されているが、コネクションの作成はできない
+	aliceProfile := s.createProfile(alice.User.ID, alice.Token, "Alice")
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+	s.expect(s.do(http.MethodPost, "/api/connections", gin.H{
+		"profile_id": aliceProfile, "connect_user_profile_public_id": s.publicID(bobProfile),
+	}, alice.Token), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodPost, "/api/connections", gin.H{
+		"profile_id": bobProfile, "connect_user_profile_public_id": s.publicID(aliceProfile),
+	}, bobToken), http.StatusAccepted, nil)
+}
+
+func TestUnverifiedPolicyCanBeDisabled(t *testing.T) {
+	t.Setenv("UNVERIFIED_ACCOUNT_RESTRICTIONS", "none")
+	s := newTestServer(t)
+
+	alice := s.signUpUnverified("Alice", "alice@example.com")
+	s.createProfile(alice.User.ID, alice.Token, "Alice")
+}
@@ -0,0 +1,257 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type visibleProfile struct {
+	ID               int      `json:"id"`
+	DisplayName      string   `json:"display_name"`
+	Hometown         *string  `json:"hometown"`
+	Hobby            *string  `json:"hobby"`
+	Title            string   `json:"title"`
+	Visibility       string   `json:"visibility"`
+	RestrictedFields []string `json:"restricted_fields"`
+}
+
+// connect はfromのプロフィールからtoのプロフィールへのコネクションを作成します
+// 他人のプロフィールへのコネクションは申請になるので、toの所有者（ownerToken）が承認します
+func (s *testServer) connect(token string, from, to int, ownerToken string) int {
+	s.t.Helper()
+	return s.requestConnection(token, gin.H{"profile_id": from, "connect_user_profile_public_id": s.publicID(to)}, ownerToken)
+}
+
+// requestConnection はコネクションを申請し、接続先の所有者（ownerToken）が承認して作成したコネクションのIDを返します
+func (s *testServer) requestConnection(token string, body gin.H, ownerToken string) int {
+	s.t.Helper()
+	var requested struct {
+		Request struct {
+			ID int `json:"id"`
+		} `json:"request"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/connections", body, token), http.StatusAccepted, &requested)
+	var accepted struct {
+		Connection struct {
+			ID int `json:"id"`
+		} `json:"connection"`
+	}
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/connection-requests/%d/accept", requested.Request.ID), nil, ownerToken), http.StatusOK, &accepted)
+	return accepted.Connection.ID
+}
+
+func TestProfileVisibilityLevels(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	_, carolToken := s.signUp("Carol", "carol@example.com")
+	aliceProfile := s.createProfile(aliceID, aliceToken, "Alice")
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+	path := "/api/profiles/" + s.publicID(aliceProfile)
+	editPath := fmt.Sprintf("/api/profiles/%d", aliceProfile)
+
+	var profile visibleProfile
+	s.expect(s.do(http.MethodGet, path, nil, ""), http.StatusOK, &profile)
+	if profile.Visibility != "public" {
+		t.Fatalf("default visibility = %q", profile.Visibility)
+	}
+	s.expect(s.do(http.MethodPut, editPath, gin.H{"visibility": "everyone"}, aliceToken), http.StatusBadRequest, nil)
+
+	// 非公開は本人以外には存在しないものとして扱う
+	s.expect(s.do(http.MethodPut, editPath, gin.H{"visibility": "private"}, aliceToken), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, path, nil, ""), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodGet, path, nil, bobToken), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodGet, path+"/icon", nil, ""), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodGet, path, nil, aliceToken), http.StatusOK, nil)
+	// 閲覧できないプロフィールへはコネクションを申請できない（存在しない場合と同じ404）
+	s.expect(s.do(http.MethodPost, "/api/connections", gin.H{"profile_id": bobProfile, "connect_user_profile_public_id": s.publicID(aliceProfile)}, bobToken), http.StatusNotFound, nil)
+
+	// つながりのある人のみ：AliceがBobとつながると見える（Bobからの一方的なコネクションでは見えない）
+	s.expect(s.do(http.MethodPut, editPath, gin.H{"visibility": "public"}, aliceToken), http.StatusOK, nil)
+	s.connect(bobToken, bobProfile, aliceProfile, aliceToken)
+	s.expect(s.do(http.MethodPut, editPath, gin.H{"visibility": "connections"}, aliceToken), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, path, nil, bobToken), http.StatusNotFound, nil)
+	s.connect(aliceToken, aliceProfile, bobProfile, bobToken)
+	s.expect(s.do(http.MethodGet, path, nil, bobToken), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, path, nil, carolToken), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodGet, path, nil, ""), http.StatusNotFound, nil)
+
+	// 限定公開はIDを知っていれば見える。ユーザーごとのプロフィール一覧は本人しか取得できない
+	s.expect(s.do(http.MethodPut, editPath, gin.H{"visibility": "unlisted"}, aliceToken), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, path, nil, ""), http.StatusOK, nil)
+	var list struct {
+		Profiles []visibleProfile `json:"profiles"`
+		Count    int              `json:"count"`
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/users/%d/profiles", aliceID), nil, carolToken), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/users/%d/profiles", aliceID), nil, aliceToken), http.StatusOK, &list)
+	if list.Count != 1 || list.Profiles[0].Visibility != "unlisted" {
+		t.Fatalf("own profiles = %+v", list.Profiles)
+	}
+}
+
+func TestProfileRestrictedFields(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	carolID, carolToken := s.signUp("Carol", "carol@example.com")
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+	carolProfile := s.createProfile(carolID, carolToken, "Carol")
+
+	// 制限できない項目は指定できない
+	s.expect(s.do(http.MethodPost, "/api/profiles", gin.H{
+		"user_id": aliceID, "display_name": "Alice", "restricted_fields": []string{"display_name"},
+	}, aliceToken), http.StatusBadRequest, nil)
+
+	var created visibleProfile
+	s.expect(s.do(http.MethodPost, "/api/profiles", gin.H{
+		"user_id": aliceID, "display_name": "Alice", "title": "Engineer",
+		"hometown": "Osaka", "hobby": "Climbing",
+		"restricted_fields": []string{"hometown", "hometown"},
+	}, aliceToken), http.StatusCreated, &created)
+	if len(created.RestrictedFields) != 1 || created.RestrictedFields[0] != "hometown" {
+		t.Fatalf("restricted_fields = %v", created.RestrictedFields)
+	}
+	path := "/api/profiles/" + s.publicID(created.ID)
+	editPath := fmt.Sprintf("/api/profiles/%d", created.ID)
+	s.connect(aliceToken, created.ID, bobProfile, bobToken)
+	s.connect(carolToken, carolProfile, created.ID, aliceToken)
+
+	// 未ログイン・一方的にコネクションを作成しただけの人には制限した項目を返さない
+	for _, token := range []string{"", carolToken} {
+		var profile visibleProfile
+		s.expect(s.do(http.MethodGet, path, nil, token), http.StatusOK, &profile)
+		if profile.Hometown != nil || profile.Hobby == nil || profile.DisplayName != "Alice" || profile.RestrictedFields != nil {
+			t.Fatalf("profile for stranger = %+v", profile)
+		}
+	}
+
+	// Aliceがつながった相手と本人には返す（制限の設定は本人にのみ返す）
+	var profile visibleProfile
+	s.expect(s.do(http.MethodGet, path, nil, bobToken), http.StatusOK, &profile)
+	if profile.Hometown == nil || *profile.Hometown != "Osaka" || profile.RestrictedFields != nil {
+		t.Fatalf("profile for connection = %+v", profile)
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/users/%d/profiles", aliceID), nil, aliceToken), http.StatusOK, nil)
+
+	// 制限の解除
+	s.expect(s.do(http.MethodPut, editPath, gin.H{"restricted_fields": []string{"title"}}, aliceToken), http.StatusOK, nil)
+	var updated visibleProfile
+	s.expect(s.do(http.MethodGet, path, nil, ""), http.StatusOK, &updated)
+	if updated.Hometown == nil || updated.Title != "" {
+		t.Fatalf("profile after update = %+v", updated)
+	}
+}
+
+func TestConnectionsOnlyLinksAndOptions(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	aliceProfile := s.createProfile(aliceID, aliceToken, "Alice")
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+
+	s.expect(s.do(http.MethodPost, "/api/links", gin.H{
+		"profile_id": aliceProfile, "title": "Blog", "url": "https://example.com/blog",
+	}, aliceToken), http.StatusCreated, nil)
+	var private struct {
+		Link struct {
+			ID int `json:"id"`
+		} `json:"link"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/links", gin.H{
+		"profile_id": aliceProfile, "title": "Phone", "url": "tel:0000000000", "connections_only": true,
+	}, aliceToken), http.StatusCreated, &private)
+	s.expect(s.do(http.MethodPost, "/api/option_profiles", gin.H{
+		"profile_id": aliceProfile, "title": "好きな食べ物", "content": "カレー",
+	}, aliceToken), http.StatusCreated, nil)
+	s.expect(s.do(http.MethodPost, "/api/option_profiles", gin.H{
+		"profile_id": aliceProfile, "title": "最寄り駅", "content": "梅田", "connections_only": true,
+	}, aliceToken), http.StatusCreated, nil)
+
+	countLinks := func(token string) int {
+		var res struct {
+			Total int `json:"total"`
+		}
+		s.expect(s.do(http.MethodGet, "/api/links/profile/"+s.publicID(aliceProfile), nil, token), http.StatusOK, &res)
+		return res.Total
+	}
+	countOptions := func(token string) int {
+		var res struct {
+			Count int `json:"count"`
+		}
+		s.expect(s.do(http.MethodGet, "/api/profiles/"+s.publicID(aliceProfile)+"/option-profiles", nil, token), http.StatusOK, &res)
+		return res.Count
+	}
+	linkPath := fmt.Sprintf("/api/links/%d", private.Link.ID)
+
+	if n := countLinks(""); n != 1 {
+		t.Fatalf("anonymous links = %d, want 1", n)
+	}
+	if n := countOptions(bobToken); n != 1 {
+		t.Fatalf("stranger options = %d, want 1", n)
+	}
+	s.expect(s.do(http.MethodGet, linkPath, nil, bobToken), http.StatusNotFound, nil)
+
+	s.connect(aliceToken, aliceProfile, bobProfile, bobToken)
+	if n := countLinks(bobToken); n != 2 {
+		t.Fatalf("connection links = %d, want 2", n)
+	}
+	if n := countOptions(bobToken); n != 2 {
+		t.Fatalf("connection options = %d, want 2", n)
+	}
+	s.expect(s.do(http.MethodGet, linkPath, nil, bobToken), http.StatusOK, nil)
+
+	// 公開に戻すと誰でも見える
+	s.expect(s.do(http.MethodPut, linkPath, gin.H{"connections_only": false}, aliceToken), http.StatusOK, nil)
+	if n := countLinks(""); n != 2 {
+		t.Fatalf("anonymous links after update = %d, want 2", n)
+	}
+
+	// 非公開のプロフィールのリンクは空
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/profiles/%d", aliceProfile), gin.H{"visibility": "private"}, aliceToken), http.StatusOK, nil)
+	if n := countLinks(bobToken); n != 0 {
+		t.Fatalf("private profile links = %d, want 0", n)
+	}
+	if n := countLinks(aliceToken); n != 2 {
+		t.Fatalf("own links = %d, want 2", n)
+	}
+}
+
+func TestUnlistedProfilesExcludedFromBulkOutputs(t *testing.T) {
+	s := newTestServer(t)
+	aliceID, aliceToken := s.signUp("Alice", "alice@example.com")
+	bobID, bobToken := s.signUp("Bob", "bob@example.com")
+	_, carolToken := s.signUp("Carol", "carol@example.com")
+	aliceProfile := s.createProfile(aliceID, aliceToken, "Alice")
+	bobProfile := s.createProfile(bobID, bobToken, "Bob")
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/profiles/%d", aliceProfile), gin.H{"visibility": "unlisted"}, aliceToken), http.StatusOK, nil)
+	alice := s.publicID(aliceProfile)
+
+	// 限定公開のプロフィールは、つながりのない人は個別には見られても一括では出力できない
+	s.connect(bobToken, bobProfile, aliceProfile, aliceToken)
+	s.expect(s.do(http.MethodGet, "/api/profiles/"+alice, nil, carolToken), http.StatusOK, nil)
+	badges := gin.H{"profile_ids": []string{alice}}
+	s.expect(s.do(http.MethodPost, "/api/profiles/badges", badges, carolToken), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodPost, "/api/profiles/badges", badges, bobToken), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodPost, "/api/profiles/badges", badges, aliceToken), http.StatusOK, nil)
+
+	vcardPath := fmt.Sprintf("/api/users/%d/connections/vcard", bobID)
+	w := s.do(http.MethodGet, vcardPath, nil, bobToken)
+	s.expect(w, http.StatusOK, nil)
+	if strings.Contains(w.Body.String(), "FN:Alice") {
+		t.Fatalf("unlisted profile exported without connection:\n%s", w.Body.String())
+	}
+
+	// 所有者がつながった相手には一覧・一括出力にも含める
+	s.connect(aliceToken, aliceProfile, bobProfile, bobToken)
+	s.expect(s.do(http.MethodPost, "/api/profiles/badges", badges, bobToken), http.StatusOK, nil)
+	w = s.do(http.MethodGet, vcardPath, nil, bobToken)
+	s.expect(w, http.StatusOK, nil)
+	if !strings.Contains(w.Body.String(), "FN:Alice\r\n") {
+		t.Fatalf("vcard = %s", w.Body.String())
+	}
+}
@@ -0,0 +1,174 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"backend/models"
+
+	"github.com/lib/pq"
+)
+
+// PersonalAccessTokenStore はパーソナルアクセストークンの永続化を扱います
+type PersonalAccessTokenStore interface {
+	// Create はトークンを登録し、採番したIDと作成日時をtokenに設定します
+	Create(ctx context.Context, token *models.PersonalAccessToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error)
+	// ListByUser は失効していないトークンを新しい順に返します
+	ListByUser(ctx context.Context, userID int) ([]models.PersonalAccessToken, error)
+	// Revoke はユーザーのトークンを失効させます（存在しないか他人のトークンの場合はErrNotFound）
+	Revoke(ctx context.Context, userID, id int) error
+	// TouchLastUsed は最終使用日時を記録します（書き込みを減らすため1分以内の再記録は省きます）
+	TouchLastUsed(ctx context.Context, id int, usedAt time.Time) error
+}
+
+const accessTokenColumns = "id, user_id, name, token_hash, token_prefix, scopes, expires_at, last_used_at, created_at, revoked_at"
+
+type pgAccessTokenStore struct {
+	db *sql.DB
+}
+
+func scanAccessToken(row rowScanner) (*models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.TokenHash, &token.TokenPrefix,
+		pq.Array(&token.Scopes), &expiresAt, &lastUsedAt, &token.CreatedAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return &token, nil
+}
+
+func (s *pgAccessTokenStore) Create(ctx context.Context, token *models.PersonalAccessToken) error {
+	return s.db.QueryRowContext(ctx,
+		`INSERT INTO personal_access_tokens (user_id, name, token_hash, token_prefix, scopes, expires_at)
+         VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+		token.UserID, token.Name, token.TokenHash, token.TokenPrefix, pq.Array(token.Scopes), token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt)
+}
+
+func (s *pgAccessTokenStore) GetByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error) {
+	token, err := scanAccessToken(s.db.QueryRowContext(ctx,
+		"SELECT "+accessTokenColumns+" FROM personal_access_tokens WHERE token_hash = $1", tokenHash))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return token, nil
+}
+
+func (s *pgAccessTokenStore) ListByUser(ctx context.Context, userID int) ([]models.PersonalAccessToken, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+accessTokenColumns+` FROM personal_access_tokens
+         WHERE user_id = $1 AND revoked_at IS NULL ORDER BY id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []models.PersonalAccessToken{}
+	for rows.Next() {
+		token, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *token)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *pgAccessTokenStore) Revoke(ctx context.Context, userID, id int) error {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE personal_access_tokens SET revoked_at = now()
+         WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *pgAccessTokenStore) TouchLastUsed(ctx context.Context, id int, usedAt time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE personal_access_tokens SET last_used_at = $2
+         WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $3)`,
+		id, usedAt, usedAt.Add(-time.Minute))
+	return err
+}
+
+type memAccessTokenStore struct {
+	m *memoryDB
+}
+
+func (s *memAccessTokenStore) Create(ctx context.Context, token *models.PersonalAccessToken) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	token.ID = s.m.nextID("personal_access_tokens")
+	token.CreatedAt = time.Now()
+	s.m.accessTokens[token.ID] = *token
+	return nil
+}
+
+func (s *memAccessTokenStore) GetByHash(ctx context.Context, tokenHash string) (*models.PersonalAccessToken, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for _, token := range s.m.accessTokens {
+		if token.TokenHash == tokenHash {
+			return &token, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memAccessTokenStore) ListByUser(ctx context.Context, userID int) ([]models.PersonalAccessToken, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	tokens := []models.PersonalAccessToken{}
+	for id := s.m.seq["personal_access_tokens"]; id > 0; id-- {
+		if token, ok := s.m.accessTokens[id]; ok && token.UserID == userID && token.RevokedAt == nil {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+func (s *memAccessTokenStore) Revoke(ctx context.Context, userID, id int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	token, ok := s.m.accessTokens[id]
+	if !ok || token.UserID != userID || token.RevokedAt != nil {
+		return ErrNotFound
+	}
+	now := time.Now()
+	token.RevokedAt = &now
+	s.m.accessTokens[id] = token
+	return nil
+}
+
+func (s *memAccessTokenStore) TouchLastUsed(ctx context.Context, id int, usedAt time.Time) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	token, ok := s.m.accessTokens[id]
+	if !ok || (token.LastUsedAt != nil && !token.LastUsedAt.Before(usedAt.Add(-time.Minute))) {
+		return nil
+	}
+	token.LastUsedAt = &usedAt
+	s.m.accessTokens[id] = token
+	return nil
+}
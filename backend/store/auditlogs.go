@@ -0,0 +1,160 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/models"
+)
+
+// AuditLogStore は管理操作の監査ログの永続化を扱います
+type AuditLogStore interface {
+	// Create は監査ログを記録し、採番したIDと記録日時をlogに設定します
+	Create(ctx context.Context, log *models.AuditLog) error
+	// List は条件に合う監査ログを新しい順に返します（totalはページングする前の件数）
+	List(ctx context.Context, query AuditLogSearch) (logs []models.AuditLog, total int, err error)
+}
+
+// AuditLogSearch は監査ログ検索の条件です（0・空の項目は絞り込まない）
+type AuditLogSearch struct {
+	ActorUserID  int
+	TargetUserID int
+	Action       string
+	Limit        int
+	Offset       int
+}
+
+const auditLogColumns = "id, actor_user_id, action, target_user_id, target_type, target_id, details, created_at"
+
+type pgAuditLogStore struct {
+	db *sql.DB
+}
+
+func scanAuditLog(row rowScanner) (*models.AuditLog, error) {
+	var log models.AuditLog
+	var actorID, targetUserID sql.NullInt64
+	var details []byte
+	err := row.Scan(&log.ID, &actorID, &log.Action, &targetUserID, &log.TargetType, &log.TargetID, &details, &log.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if actorID.Valid {
+		id := int(actorID.Int64)
+		log.ActorUserID = &id
+	}
+	if targetUserID.Valid {
+		id := int(targetUserID.Int64)
+		log.TargetUserID = &id
+	}
+	if err := json.Unmarshal(details, &log.Details); err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
+func (s *pgAuditLogStore) Create(ctx context.Context, log *models.AuditLog) error {
+	if log.Details == nil {
+		log.Details = map[string]string{}
+	}
+	details, err := json.Marshal(log.Details)
+	if err != nil {
+		return err
+	}
+	return s.db.QueryRowContext(ctx,
+		`INSERT INTO audit_logs (actor_user_id, action, target_user_id, target_type, target_id, details)
+         VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+		log.ActorUserID, log.Action, log.TargetUserID, log.TargetType, log.TargetID, details,
+	).Scan(&log.ID, &log.CreatedAt)
+}
+
+func (s *pgAuditLogStore) List(ctx context.Context, query AuditLogSearch) ([]models.AuditLog, int, error) {
+	var conds []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if query.ActorUserID != 0 {
+		conds = append(conds, "actor_user_id = "+arg(query.ActorUserID))
+	}
+	if query.TargetUserID != 0 {
+		conds = append(conds, "target_user_id = "+arg(query.TargetUserID))
+	}
+	if query.Action != "" {
+		conds = append(conds, "action = "+arg(query.Action))
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_logs"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+auditLogColumns+" FROM audit_logs"+where+" ORDER BY id DESC LIMIT "+arg(query.Limit)+" OFFSET "+arg(query.Offset),
+		args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	logs := []models.AuditLog{}
+	for rows.Next() {
+		log, err := scanAuditLog(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		logs = append(logs, *log)
+	}
+	return logs, total, rows.Err()
+}
+
+type memAuditLogStore struct {
+	m *memoryDB
+}
+
+func (s *memAuditLogStore) Create(ctx context.Context, log *models.AuditLog) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if log.Details == nil {
+		log.Details = map[string]string{}
+	}
+	log.ID = s.m.nextID("audit_logs")
+	log.CreatedAt = time.Now()
+	s.m.auditLogs[log.ID] = *log
+	return nil
+}
+
+func (s *memAuditLogStore) List(ctx context.Context, query AuditLogSearch) ([]models.AuditLog, int, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	matches := func(id *int, want int) bool {
+		return want == 0 || (id != nil && *id == want)
+	}
+	var matched []models.AuditLog
+	for id := s.m.seq["audit_logs"]; id > 0; id-- {
+		log, ok := s.m.auditLogs[id]
+		if !ok || !matches(log.ActorUserID, query.ActorUserID) || !matches(log.TargetUserID, query.TargetUserID) {
+			continue
+		}
+		if query.Action != "" && log.Action != query.Action {
+			continue
+		}
+		matched = append(matched, log)
+	}
+
+	logs := []models.AuditLog{}
+	for i := query.Offset; i < len(matched) && len(logs) < query.Limit; i++ {
+		logs = append(logs, matched[i])
+	}
+	return logs, len(matched), nil
+}
@@ -0,0 +1,328 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"backend/models"
+)
+
+// ConnectionRequestStore は他人のプロフィールへのコネクション申請の永続化を扱います
+// 有効期限を過ぎた承認待ちの申請は、読み出し時にexpiredとして返します
+type ConnectionRequestStore interface {
+	// Create は承認待ちの申請を登録します（同じ組み合わせの承認待ちの申請かコネクションが既にある場合はErrConflict）
+	Create(ctx context.Context, req *models.ConnectionRequest) error
+	Get(ctx context.Context, id int) (*models.ConnectionRequest, error)
+	// ListIncoming はユーザーのプロフィールへの申請を新しい順に返します（statusが空の場合はすべて）
+	ListIncoming(ctx context.Context, userID int, status string) ([]models.ConnectionRequest, error)
+	// ListOutgoing はユーザーのプロフィールからの申請を新しい順に返します（statusが空の場合はすべて）
+	ListOutgoing(ctx context.Context, userID int, status string) ([]models.ConnectionRequest, error)
+	// Accept は承認待ちの申請を承認し、同じトランザクションでコネクションを作成します
+	// 承認待ちでない（期限切れを含む）場合はErrConflictを返します。コネクションが既にある場合はそれを返します
+	Accept(ctx context.Context, id int) (*models.ConnectionRequest, *models.Connection, error)
+	// Decline は承認待ちの申請を拒否します（承認待ちでない場合はErrConflict）
+	Decline(ctx context.Context, id int) (*models.ConnectionRequest, error)
+}
+
+// connectionRequestStatus は期限切れを反映した申請の状態です
+const connectionRequestStatus = `CASE WHEN r.status = 'pending' AND r.expires_at <= now() THEN 'expired' ELSE r.status END`
+
+const connectionRequestColumns = `r.id, r.profile_id, r.connect_user_profile_id, ` + connectionRequestStatus + `,
+        r.event_name, r.event_date, r.memo, r.created_at, r.expires_at, r.responded_at`
+
+type pgConnectionRequestStore struct {
+	db *sql.DB
+}
+
+func scanConnectionRequest(row rowScanner) (*models.ConnectionRequest, error) {
+	var req models.ConnectionRequest
+	var respondedAt sql.NullTime
+	err := row.Scan(
+		&req.ID, &req.ProfileID, &req.ConnectUsersProfileID, &req.Status,
+		&req.EventName, &req.EventDate, &req.Memo, &req.CreatedAt, &req.ExpiresAt, &respondedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if respondedAt.Valid {
+		req.RespondedAt = &respondedAt.Time
+	}
+	return &req, nil
+}
+
+func (s *pgConnectionRequestStore) Create(ctx context.Context, req *models.ConnectionRequest) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("トランザクションの開始に失敗しました: %v", err)
+	}
+	defer tx.Rollback() // エラー時に自動ロールバック
+
+	if _, err := findConnection(ctx, tx, req.ProfileID, req.ConnectUsersProfileID); err == nil {
+		return ErrConflict
+	} else if err != ErrNotFound {
+		return err
+	}
+	// 期限切れの申請は承認待ちの一意制約から外し、改めて申請できるようにする
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE connection_requests SET status = 'expired'
+         WHERE profile_id = $1 AND connect_user_profile_id = $2 AND status = 'pending' AND expires_at <= now()`,
+		req.ProfileID, req.ConnectUsersProfileID,
+	); err != nil {
+		return err
+	}
+
+	req.Status = models.ConnectionRequestPending
+	req.CreatedAt = time.Now()
+	req.RespondedAt = nil
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO connection_requests (profile_id, connect_user_profile_id, status, event_name, event_date, memo, created_at, expires_at)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		req.ProfileID, req.ConnectUsersProfileID, req.Status, req.EventName, req.EventDate, req.Memo, req.CreatedAt, req.ExpiresAt,
+	).Scan(&req.ID)
+	if isUniqueViolation(err) {
+		return ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *pgConnectionRequestStore) Get(ctx context.Context, id int) (*models.ConnectionRequest, error) {
+	req, err := scanConnectionRequest(s.db.QueryRowContext(ctx,
+		"SELECT "+connectionRequestColumns+" FROM connection_requests r WHERE r.id = $1", id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return req, nil
+}
+
+func (s *pgConnectionRequestStore) ListIncoming(ctx context.Context, userID int, status string) ([]models.ConnectionRequest, error) {
+	return s.list(ctx, "r.connect_user_profile_id", userID, status)
+}
+
+func (s *pgConnectionRequestStore) ListOutgoing(ctx context.Context, userID int, status string) ([]models.ConnectionRequest, error) {
+	return s.list(ctx, "r.profile_id", userID, status)
+}
+
+// list はprofileColumnのプロフィールがユーザーのものである申請を返します
+func (s *pgConnectionRequestStore) list(ctx context.Context, profileColumn string, userID int, status string) ([]models.ConnectionRequest, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+connectionRequestColumns+` FROM connection_requests r
+         JOIN profiles p ON p.id = `+profileColumn+`
+         WHERE p.user_id = $1 AND ($2 = '' OR `+connectionRequestStatus+` = $2)
+         ORDER BY r.created_at DESC, r.id DESC`,
+		userID, status,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.ConnectionRequest
+	for rows.Next() {
+		req, err := scanConnectionRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *req)
+	}
+	return list, rows.Err()
+}
+
+// respondConnectionRequest は承認待ちの申請の状態を更新します（承認待ちでない場合はErrConflict、存在しない場合はErrNotFound）
+func respondConnectionRequest(ctx context.Context, tx *sql.Tx, id int, status string) (*models.ConnectionRequest, error) {
+	req, err := scanConnectionRequest(tx.QueryRowContext(ctx,
+		`UPDATE connection_requests r SET status = $2, responded_at = now()
+         WHERE r.id = $1 AND r.status = 'pending' AND r.expires_at > now()
+         RETURNING `+connectionRequestColumns,
+		id, status,
+	))
+	if err == sql.ErrNoRows {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM connection_requests WHERE id = $1)`, id).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, ErrNotFound
+		}
+		return nil, ErrConflict
+	}
+	return req, err
+}
+
+func (s *pgConnectionRequestStore) Accept(ctx context.Context, id int) (*models.ConnectionRequest, *models.Connection, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("トランザクションの開始に失敗しました: %v", err)
+	}
+	defer tx.Rollback() // エラー時に自動ロールバック
+
+	req, err := respondConnectionRequest(ctx, tx, id, models.ConnectionRequestAccepted)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn := &models.Connection{
+		ProfileID:             req.ProfileID,
+		ConnectUsersProfileID: req.ConnectUsersProfileID,
+		EventName:             req.EventName,
+		EventDate:             req.EventDate,
+		Memo:                  req.Memo,
+	}
+	if err := insertConnection(ctx, tx, conn); err == ErrConflict {
+		// 申請中に交換トークンなどで作成済みになっていた場合はそのまま承認する
+		if conn, err = findConnection(ctx, tx, req.ProfileID, req.ConnectUsersProfileID); err != nil {
+			return nil, nil, err
+		}
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return req, conn, nil
+}
+
+func (s *pgConnectionRequestStore) Decline(ctx context.Context, id int) (*models.ConnectionRequest, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("トランザクションの開始に失敗しました: %v", err)
+	}
+	defer tx.Rollback() // エラー時に自動ロールバック
+
+	req, err := respondConnectionRequest(ctx, tx, id, models.ConnectionRequestDeclined)
+	if err != nil {
+		return nil, err
+	}
+	return req, tx.Commit()
+}
+
+type memConnectionRequestStore struct {
+	m *memoryDB
+}
+
+// connectionRequest は期限切れを反映した申請を返します（呼び出し側でロックを取得していること）
+func (m *memoryDB) connectionRequest(id int) (models.ConnectionRequest, bool) {
+	req, ok := m.connectionRequests[id]
+	if ok && req.Status == models.ConnectionRequestPending && !req.ExpiresAt.After(time.Now()) {
+		req.Status = models.ConnectionRequestExpired
+	}
+	return req, ok
+}
+
+func (s *memConnectionRequestStore) Create(ctx context.Context, req *models.ConnectionRequest) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.findConnection(req.ProfileID, req.ConnectUsersProfileID); ok {
+		return ErrConflict
+	}
+	for id := range s.m.connectionRequests {
+		existing, _ := s.m.connectionRequest(id)
+		if existing.ProfileID == req.ProfileID && existing.ConnectUsersProfileID == req.ConnectUsersProfileID &&
+			existing.Status == models.ConnectionRequestPending {
+			return ErrConflict
+		}
+	}
+
+	req.ID = s.m.nextID("connection_requests")
+	req.Status = models.ConnectionRequestPending
+	req.CreatedAt = time.Now()
+	req.RespondedAt = nil
+	s.m.connectionRequests[req.ID] = *req
+	return nil
+}
+
+func (s *memConnectionRequestStore) Get(ctx context.Context, id int) (*models.ConnectionRequest, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	req, ok := s.m.connectionRequest(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &req, nil
+}
+
+func (s *memConnectionRequestStore) ListIncoming(ctx context.Context, userID int, status string) ([]models.ConnectionRequest, error) {
+	return s.list(userID, status, func(req models.ConnectionRequest) int { return req.ConnectUsersProfileID }), nil
+}
+
+func (s *memConnectionRequestStore) ListOutgoing(ctx context.Context, userID int, status string) ([]models.ConnectionRequest, error) {
+	return s.list(userID, status, func(req models.ConnectionRequest) int { return req.ProfileID }), nil
+}
+
+// list はprofileOfが返すプロフィールがユーザーのものである申請を新しい順に返します
+func (s *memConnectionRequestStore) list(userID int, status string, profileOf func(req models.ConnectionRequest) int) []models.ConnectionRequest {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var list []models.ConnectionRequest
+	for id := range s.m.connectionRequests {
+		req, _ := s.m.connectionRequest(id)
+		if profile, ok := s.m.profiles[profileOf(req)]; !ok || profile.UserID != userID {
+			continue
+		}
+		if status != "" && req.Status != status {
+			continue
+		}
+		list = append(list, req)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].ID > list[j].ID
+		}
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+	return list
+}
+
+// respond は承認待ちの申請の状態を更新します（呼び出し側でロックを取得していること）
+func (s *memConnectionRequestStore) respond(id int, status string) (*models.ConnectionRequest, error) {
+	req, ok := s.m.connectionRequest(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if req.Status != models.ConnectionRequestPending {
+		return nil, ErrConflict
+	}
+	now := time.Now()
+	req.Status = status
+	req.RespondedAt = &now
+	s.m.connectionRequests[id] = req
+	return &req, nil
+}
+
+func (s *memConnectionRequestStore) Accept(ctx context.Context, id int) (*models.ConnectionRequest, *models.Connection, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	req, err := s.respond(id, models.ConnectionRequestAccepted)
+	if err != nil {
+		return nil, nil, err
+	}
+	if existing, ok := s.m.findConnection(req.ProfileID, req.ConnectUsersProfileID); ok {
+		return req, &existing, nil
+	}
+	conn := &models.Connection{
+		ProfileID:             req.ProfileID,
+		ConnectUsersProfileID: req.ConnectUsersProfileID,
+		EventName:             req.EventName,
+		EventDate:             req.EventDate,
+		Memo:                  req.Memo,
+	}
+	if err := s.m.insertConnection(conn); err != nil {
+		return nil, nil, err
+	}
+	return req, conn, nil
+}
+
+func (s *memConnectionRequestStore) Decline(ctx context.Context, id int) (*models.ConnectionRequest, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	return s.respond(id, models.ConnectionRequestDeclined)
+}
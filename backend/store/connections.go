@@ -0,0 +1,370 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"backend/models"
+)
+
+// ConnectionStore はプロフィール間のコネクションの永続化を扱います
+type ConnectionStore interface {
+	// Create はコネクションを登録します（同じ組み合わせが既にある場合はErrConflict）
+	Create(ctx context.Context, conn *models.Connection) error
+	Get(ctx context.Context, id int) (*models.Connection, error)
+	// ListByProfile は指定プロフィールが作成したコネクションを新しい順に返します
+	ListByProfile(ctx context.Context, profileID int) ([]models.Connection, error)
+	// ListIncoming は他のプロフィールが指定プロフィールに対して作成したコネクションを新しい順に返します
+	ListIncoming(ctx context.Context, profileID int) ([]models.Connection, error)
+	// SharesWith はプロフィールがユーザーのいずれかのプロフィールとのコネクションを作成済みかどうかを返します
+	// （プロフィールの所有者がつながりを認めた相手かどうかの判定に使います）
+	SharesWith(ctx context.Context, profileID, userID int) (bool, error)
+	// ListByUser はユーザーのプロフィールが交換した相手の情報を新しい順に返します
+	ListByUser(ctx context.Context, userID int) ([]models.UserConnection, error)
+	Update(ctx context.Context, id int, eventName, eventDate, memo string) error
+	Delete(ctx context.Context, id int) error
+}
+
+const connectionColumns = "id, profile_id, connect_user_profile_id, connected_at, event_name, event_date, memo"
+
+type pgConnectionStore struct {
+	db *sql.DB
+}
+
+func scanConnection(row rowScanner) (*models.Connection, error) {
+	var conn models.Connection
+	err := row.Scan(
+		&conn.ID, &conn.ProfileID, &conn.ConnectUsersProfileID, &conn.ConnectedAt,
+		&conn.EventName, &conn.EventDate, &conn.Memo,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &conn, nil
+}
+
+const insertConnectionQuery = `INSERT INTO connections (profile_id, connect_user_profile_id, connected_at, event_name, event_date, memo)
+         VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+
+// connectionInserter は*sql.DBと*sql.Txの共通部分です
+type connectionInserter interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// insertConnection はコネクションを登録します（同じ向きのコネクションが既にある場合はErrConflict）
+// 重複は一意制約で検出し、トランザクションを中断させないようON CONFLICT DO NOTHINGで挿入します
+func insertConnection(ctx context.Context, q connectionInserter, conn *models.Connection) error {
+	conn.ConnectedAt = time.Now()
+	err := q.QueryRowContext(ctx,
+		`INSERT INTO connections (profile_id, connect_user_profile_id, connected_at, event_name, event_date, memo)
+         VALUES ($1, $2, $3, $4, $5, $6)
+         ON CONFLICT (profile_id, connect_user_profile_id) DO NOTHING
+         RETURNING id`,
+		conn.ProfileID, conn.ConnectUsersProfileID, conn.ConnectedAt, conn.EventName, conn.EventDate, conn.Memo,
+	).Scan(&conn.ID)
+	if err == sql.ErrNoRows {
+		return ErrConflict
+	}
+	return err
+}
+
+// findConnection は指定した向きのコネクションを取得します
+func findConnection(ctx context.Context, q connectionInserter, profileID, connectProfileID int) (*models.Connection, error) {
+	conn, err := scanConnection(q.QueryRowContext(ctx,
+		"SELECT "+connectionColumns+" FROM connections WHERE profile_id = $1 AND connect_user_profile_id = $2",
+		profileID, connectProfileID,
+	))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return conn, nil
+}
+
+func (s *pgConnectionStore) Create(ctx context.Context, conn *models.Connection) error {
+	return insertConnection(ctx, s.db, conn)
+}
+
+func (s *pgConnectionStore) Get(ctx context.Context, id int) (*models.Connection, error) {
+	conn, err := scanConnection(s.db.QueryRowContext(ctx,
+		"SELECT "+connectionColumns+" FROM connections WHERE id = $1", id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return conn, nil
+}
+
+func (s *pgConnectionStore) ListByProfile(ctx context.Context, profileID int) ([]models.Connection, error) {
+	return s.queryConnections(ctx,
+		"SELECT "+connectionColumns+" FROM connections WHERE profile_id = $1 ORDER BY connected_at DESC",
+		profileID,
+	)
+}
+
+func (s *pgConnectionStore) ListIncoming(ctx context.Context, profileID int) ([]models.Connection, error) {
+	return s.queryConnections(ctx,
+		"SELECT "+connectionColumns+" FROM connections WHERE connect_user_profile_id = $1 ORDER BY connected_at DESC",
+		profileID,
+	)
+}
+
+func (s *pgConnectionStore) SharesWith(ctx context.Context, profileID, userID int) (bool, error) {
+	var shared bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS(
+             SELECT 1 FROM connections
+             WHERE profile_id = $1 AND connect_user_profile_id IN (SELECT id FROM profiles WHERE user_id = $2)
+         )`,
+		profileID, userID,
+	).Scan(&shared)
+	return shared, err
+}
+
+func (s *pgConnectionStore) queryConnections(ctx context.Context, query string, args ...interface{}) ([]models.Connection, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.Connection
+	for rows.Next() {
+		conn, err := scanConnection(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *conn)
+	}
+	return list, rows.Err()
+}
+
+func (s *pgConnectionStore) ListByUser(ctx context.Context, userID int) ([]models.UserConnection, error) {
+	// ユーザーの交換済みプロフィール情報を取得
+	query := `
+		SELECT DISTINCT
+			cp.id as connected_profile_id,
+			cp.public_id as connected_profile_public_id,
+			cp.title as connected_profile_title,
+			cu.name as connected_user_name,
+			c.connected_at,
+			c.event_name,
+			c.event_date,
+			c.memo
+		FROM connections c
+		JOIN profiles p ON c.profile_id = p.id
+		JOIN profiles cp ON c.connect_user_profile_id = cp.id
+		JOIN users cu ON cp.user_id = cu.id
+		WHERE p.user_id = $1
+		ORDER BY c.connected_at DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var connections []models.UserConnection
+	for rows.Next() {
+		var conn models.UserConnection
+		if err := rows.Scan(
+			&conn.ConnectedProfileID,
+			&conn.ConnectedProfilePublicID,
+			&conn.ConnectedProfileTitle,
+			&conn.ConnectedUserName,
+			&conn.ConnectedAt,
+			&conn.EventName,
+			&conn.EventDate,
+			&conn.Memo,
+		); err != nil {
+			return nil, err
+		}
+		connections = append(connections, conn)
+	}
+	return connections, rows.Err()
+}
+
+func (s *pgConnectionStore) Update(ctx context.Context, id int, eventName, eventDate, memo string) error {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE connections SET event_name = $1, event_date = $2, memo = $3 WHERE id = $4`,
+		eventName, eventDate, memo, id,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *pgConnectionStore) Delete(ctx context.Context, id int) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM connections WHERE id=$1`, id)
+	if err != nil {
+		return err
+	}
+	if num, _ := res.RowsAffected(); num == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type memConnectionStore struct {
+	m *memoryDB
+}
+
+func (s *memConnectionStore) Create(ctx context.Context, conn *models.Connection) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	return s.m.insertConnection(conn)
+}
+
+// findConnection は指定した向きのコネクションを返します（呼び出し側でロックを取得していること）
+func (m *memoryDB) findConnection(profileID, connectProfileID int) (models.Connection, bool) {
+	for _, existing := range m.connections {
+		if existing.ProfileID == profileID && existing.ConnectUsersProfileID == connectProfileID {
+			return existing, true
+		}
+	}
+	return models.Connection{}, false
+}
+
+// insertConnection はコネクションを登録します（呼び出し側でロックを取得していること）
+func (m *memoryDB) insertConnection(conn *models.Connection) error {
+	if _, ok := m.findConnection(conn.ProfileID, conn.ConnectUsersProfileID); ok {
+		return ErrConflict
+	}
+	conn.ID = m.nextID("connections")
+	conn.ConnectedAt = time.Now()
+	m.connections[conn.ID] = *conn
+	return nil
+}
+
+func (s *memConnectionStore) Get(ctx context.Context, id int) (*models.Connection, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	conn, ok := s.m.connections[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &conn, nil
+}
+
+func (s *memConnectionStore) ListByProfile(ctx context.Context, profileID int) ([]models.Connection, error) {
+	return s.list(func(conn models.Connection) bool { return conn.ProfileID == profileID }), nil
+}
+
+func (s *memConnectionStore) ListIncoming(ctx context.Context, profileID int) ([]models.Connection, error) {
+	return s.list(func(conn models.Connection) bool { return conn.ConnectUsersProfileID == profileID }), nil
+}
+
+func (s *memConnectionStore) SharesWith(ctx context.Context, profileID, userID int) (bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for _, conn := range s.m.connections {
+		if conn.ProfileID != profileID {
+			continue
+		}
+		if target, ok := s.m.profiles[conn.ConnectUsersProfileID]; ok && target.UserID == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// list は条件に合うコネクションを新しい順に返します
+func (s *memConnectionStore) list(match func(conn models.Connection) bool) []models.Connection {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var list []models.Connection
+	for _, conn := range s.m.connections {
+		if match(conn) {
+			list = append(list, conn)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].ConnectedAt.Equal(list[j].ConnectedAt) {
+			return list[i].ID > list[j].ID
+		}
+		return list[i].ConnectedAt.After(list[j].ConnectedAt)
+	})
+	return list
+}
+
+func (s *memConnectionStore) ListByUser(ctx context.Context, userID int) ([]models.UserConnection, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	type row struct {
+		id   int
+		conn models.UserConnection
+	}
+	var rows []row
+	for _, conn := range s.m.connections {
+		profile, ok := s.m.profiles[conn.ProfileID]
+		if !ok || profile.UserID != userID {
+			continue
+		}
+		connected, ok := s.m.profiles[conn.ConnectUsersProfileID]
+		if !ok {
+			continue
+		}
+		connectedUser, ok := s.m.users[connected.UserID]
+		if !ok {
+			continue
+		}
+		rows = append(rows, row{id: conn.ID, conn: models.UserConnection{
+			ConnectedProfileID:       connected.ID,
+			ConnectedProfilePublicID: connected.PublicID,
+			ConnectedProfileTitle:    connected.Title,
+			ConnectedUserName:        connectedUser.Name,
+			ConnectedAt:              conn.ConnectedAt,
+			EventName:                conn.EventName,
+			EventDate:                conn.EventDate,
+			Memo:                     conn.Memo,
+		}})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].conn.ConnectedAt.Equal(rows[j].conn.ConnectedAt) {
+			return rows[i].id > rows[j].id
+		}
+		return rows[i].conn.ConnectedAt.After(rows[j].conn.ConnectedAt)
+	})
+
+	var connections []models.UserConnection
+	for _, r := range rows {
+		connections = append(connections, r.conn)
+	}
+	return connections, nil
+}
+
+func (s *memConnectionStore) Update(ctx context.Context, id int, eventName, eventDate, memo string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	conn, ok := s.m.connections[id]
+	if !ok {
+		return ErrNotFound
+	}
+	conn.EventName = eventName
+	conn.EventDate = eventDate
+	conn.Memo = memo
+	s.m.connections[id] = conn
+	return nil
+}
+
+func (s *memConnectionStore) Delete(ctx context.Context, id int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.connections[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.m.connections, id)
+	return nil
+}
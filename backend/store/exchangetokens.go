@@ -0,0 +1,168 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"backend/models"
+)
+
+// ExchangeTokenStore はQRコードの交換トークンを使ったコネクションの作成を扱います
+type ExchangeTokenStore interface {
+	// Redeem はコネクションを作成し、singleUseの場合は同じトランザクションでjtiを使用済みとして記録します
+	// 使用済みのトークンはErrTokenUsed、同じ組み合わせのコネクションが既にある場合はErrConflictを返します（いずれも何も登録しません）
+	Redeem(ctx context.Context, jti string, expiresAt time.Time, singleUse bool, conn *models.Connection) error
+	// Exchange は2つのプロフィール間の双方向のコネクションを1つのトランザクションで作成します
+	// 既にある向きのコネクションは作成せずに既存のものでforward・reverseを置き換え、両方とも既にあった場合はcreated=falseを返します
+	// （この場合はトークンを使用済みにしないので、再送しても同じ結果になります）
+	Exchange(ctx context.Context, jti string, expiresAt time.Time, singleUse bool, forward, reverse *models.Connection) (created bool, err error)
+}
+
+type pgExchangeTokenStore struct {
+	db *sql.DB
+}
+
+// consumeExchangeToken は使い捨てのトークンのjtiを使用済みとして記録します（既に使用済みの場合はErrTokenUsed）
+func consumeExchangeToken(ctx context.Context, tx *sql.Tx, jti string, expiresAt time.Time) error {
+	// 期限切れのエントリはもう照合する必要がないので、ついでに掃除する
+	if _, err := tx.ExecContext(ctx, `DELETE FROM used_exchange_tokens WHERE expires_at < now()`); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO used_exchange_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING`,
+		jti, expiresAt,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrTokenUsed
+	}
+	return nil
+}
+
+func (s *pgExchangeTokenStore) Redeem(ctx context.Context, jti string, expiresAt time.Time, singleUse bool, conn *models.Connection) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("トランザクションの開始に失敗しました: %v", err)
+	}
+	defer tx.Rollback() // エラー時に自動ロールバック
+
+	if singleUse {
+		if err := consumeExchangeToken(ctx, tx, jti, expiresAt); err != nil {
+			return err
+		}
+	}
+	if err := insertConnection(ctx, tx, conn); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *pgExchangeTokenStore) Exchange(ctx context.Context, jti string, expiresAt time.Time, singleUse bool, forward, reverse *models.Connection) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("トランザクションの開始に失敗しました: %v", err)
+	}
+	defer tx.Rollback() // エラー時に自動ロールバック
+
+	// 交換済みであれば何もしない（再送時に使い捨てのトークンで410にならないよう、使用済みの確認より先に行う）
+	existingForward, err := findConnection(ctx, tx, forward.ProfileID, forward.ConnectUsersProfileID)
+	if err != nil && err != ErrNotFound {
+		return false, err
+	}
+	existingReverse, err := findConnection(ctx, tx, reverse.ProfileID, reverse.ConnectUsersProfileID)
+	if err != nil && err != ErrNotFound {
+		return false, err
+	}
+	if existingForward != nil && existingReverse != nil {
+		*forward, *reverse = *existingForward, *existingReverse
+		return false, nil
+	}
+
+	if singleUse {
+		if err := consumeExchangeToken(ctx, tx, jti, expiresAt); err != nil {
+			return false, err
+		}
+	}
+
+	// 互いに逆向きの交換が同時に行われてもデッドロックしないよう、プロフィールIDの小さい向きから登録する
+	pair := []*models.Connection{forward, reverse}
+	if reverse.ProfileID < forward.ProfileID {
+		pair[0], pair[1] = reverse, forward
+	}
+	for _, conn := range pair {
+		err := insertConnection(ctx, tx, conn)
+		if err == ErrConflict {
+			existing, err := findConnection(ctx, tx, conn.ProfileID, conn.ConnectUsersProfileID)
+			if err != nil {
+				return false, err
+			}
+			*conn = *existing
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+type memExchangeTokenStore struct {
+	m *memoryDB
+}
+
+func (s *memExchangeTokenStore) Redeem(ctx context.Context, jti string, expiresAt time.Time, singleUse bool, conn *models.Connection) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if singleUse {
+		if _, used := s.m.usedExchangeTokens[jti]; used {
+			return ErrTokenUsed
+		}
+	}
+	if err := s.m.insertConnection(conn); err != nil {
+		return err
+	}
+	if singleUse {
+		s.m.usedExchangeTokens[jti] = expiresAt
+	}
+	return nil
+}
+
+func (s *memExchangeTokenStore) Exchange(ctx context.Context, jti string, expiresAt time.Time, singleUse bool, forward, reverse *models.Connection) (bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	existingForward, forwardExists := s.m.findConnection(forward.ProfileID, forward.ConnectUsersProfileID)
+	existingReverse, reverseExists := s.m.findConnection(reverse.ProfileID, reverse.ConnectUsersProfileID)
+	if forwardExists && reverseExists {
+		*forward, *reverse = existingForward, existingReverse
+		return false, nil
+	}
+	if singleUse {
+		if _, used := s.m.usedExchangeTokens[jti]; used {
+			return false, ErrTokenUsed
+		}
+		s.m.usedExchangeTokens[jti] = expiresAt
+	}
+
+	if forwardExists {
+		*forward = existingForward
+	} else if err := s.m.insertConnection(forward); err != nil {
+		return false, err
+	}
+	if reverseExists {
+		*reverse = existingReverse
+	} else if err := s.m.insertConnection(reverse); err != nil {
+		return false, err
+	}
+	return true, nil
+}
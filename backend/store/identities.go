@@ -0,0 +1,252 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"backend/models"
+)
+
+// IdentityStore は外部プロバイダーのアカウントとユーザーの紐付けを扱います
+type IdentityStore interface {
+	GetBySubject(ctx context.Context, provider, subject string) (*models.UserIdentity, error)
+	ListByUser(ctx context.Context, userID int) ([]models.UserIdentity, error)
+	// Create は既存のユーザーにアカウントを紐付けます（同じプロバイダーのアカウントが紐付け済みの場合はErrConflict）
+	Create(ctx context.Context, identity *models.UserIdentity) error
+	// CreateWithUser はユーザーを登録し、同じトランザクションでアカウントを紐付けます
+	// user.EmailVerifiedAtが設定されていれば確認済みとして登録します
+	CreateWithUser(ctx context.Context, user *models.User, identity *models.UserIdentity) error
+}
+
+// OAuthStateStore は認可リクエストのstateを扱います
+type OAuthStateStore interface {
+	Create(ctx context.Context, state *models.OAuthState) error
+	// Consume はstateを取り出して削除します（一度しか使えません。期限切れの場合もErrNotFound）
+	Consume(ctx context.Context, stateHash string) (*models.OAuthState, error)
+}
+
+const identityColumns = "id, user_id, provider, subject, email, profile_url, created_at"
+
+type pgIdentityStore struct {
+	db *sql.DB
+}
+
+func scanIdentity(row rowScanner) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	err := row.Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject,
+		&identity.Email, &identity.ProfileURL, &identity.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (s *pgIdentityStore) GetBySubject(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	identity, err := scanIdentity(s.db.QueryRowContext(ctx,
+		"SELECT "+identityColumns+" FROM user_identities WHERE provider = $1 AND subject = $2",
+		provider, subject,
+	))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return identity, nil
+}
+
+func (s *pgIdentityStore) ListByUser(ctx context.Context, userID int) ([]models.UserIdentity, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+identityColumns+" FROM user_identities WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identities []models.UserIdentity
+	for rows.Next() {
+		identity, err := scanIdentity(rows)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, *identity)
+	}
+	return identities, rows.Err()
+}
+
+// identityInserter は*sql.DBと*sql.Txの共通部分です
+type identityInserter interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func insertIdentity(ctx context.Context, q identityInserter, identity *models.UserIdentity) error {
+	err := q.QueryRowContext(ctx,
+		`INSERT INTO user_identities (user_id, provider, subject, email, profile_url)
+         VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`,
+		identity.UserID, identity.Provider, identity.Subject, identity.Email, identity.ProfileURL,
+	).Scan(&identity.ID, &identity.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+func (s *pgIdentityStore) Create(ctx context.Context, identity *models.UserIdentity) error {
+	return insertIdentity(ctx, s.db, identity)
+}
+
+func (s *pgIdentityStore) CreateWithUser(ctx context.Context, user *models.User, identity *models.UserIdentity) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // エラー時に自動ロールバック
+
+	defaultRole(user)
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO users (name, email, password, email_verified_at, role) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		user.Name, user.Email, user.PasswordHash, user.EmailVerifiedAt, user.Role,
+	).Scan(&user.ID)
+	if isUniqueViolation(err) {
+		return ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	identity.UserID = user.ID
+	if err := insertIdentity(ctx, tx, identity); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type pgOAuthStateStore struct {
+	db *sql.DB
+}
+
+func (s *pgOAuthStateStore) Create(ctx context.Context, state *models.OAuthState) error {
+	// 使われずに期限切れになったstateを掃除する
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM oauth_states WHERE expires_at < now()`); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO oauth_states (state_hash, provider, code_verifier, expires_at) VALUES ($1, $2, $3, $4)`,
+		state.StateHash, state.Provider, state.CodeVerifier, state.ExpiresAt,
+	)
+	return err
+}
+
+func (s *pgOAuthStateStore) Consume(ctx context.Context, stateHash string) (*models.OAuthState, error) {
+	var state models.OAuthState
+	err := s.db.QueryRowContext(ctx,
+		`DELETE FROM oauth_states WHERE state_hash = $1 AND expires_at > now()
+         RETURNING state_hash, provider, code_verifier, expires_at`,
+		stateHash,
+	).Scan(&state.StateHash, &state.Provider, &state.CodeVerifier, &state.ExpiresAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &state, nil
+}
+
+type memIdentityStore struct {
+	m *memoryDB
+}
+
+func (s *memIdentityStore) GetBySubject(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for _, identity := range s.m.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return &identity, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memIdentityStore) ListByUser(ctx context.Context, userID int) ([]models.UserIdentity, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var identities []models.UserIdentity
+	for id := 1; id <= s.m.seq["user_identities"]; id++ {
+		if identity, ok := s.m.identities[id]; ok && identity.UserID == userID {
+			identities = append(identities, identity)
+		}
+	}
+	return identities, nil
+}
+
+func (s *memIdentityStore) Create(ctx context.Context, identity *models.UserIdentity) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	return s.m.insertIdentity(identity)
+}
+
+// insertIdentity はアカウントの紐付けを登録します（呼び出し側でロックを取得していること）
+func (m *memoryDB) insertIdentity(identity *models.UserIdentity) error {
+	for _, existing := range m.identities {
+		if existing.Provider == identity.Provider && existing.Subject == identity.Subject {
+			return ErrConflict
+		}
+	}
+	identity.ID = m.nextID("user_identities")
+	identity.CreatedAt = time.Now()
+	m.identities[identity.ID] = *identity
+	return nil
+}
+
+func (s *memIdentityStore) CreateWithUser(ctx context.Context, user *models.User, identity *models.UserIdentity) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for _, u := range s.m.users {
+		if u.Email == user.Email {
+			return ErrConflict
+		}
+	}
+	for _, existing := range s.m.identities {
+		if existing.Provider == identity.Provider && existing.Subject == identity.Subject {
+			return ErrConflict
+		}
+	}
+	defaultRole(user)
+	user.ID = s.m.nextID("users")
+	s.m.users[user.ID] = *user
+	identity.UserID = user.ID
+	return s.m.insertIdentity(identity)
+}
+
+type memOAuthStateStore struct {
+	m *memoryDB
+}
+
+func (s *memOAuthStateStore) Create(ctx context.Context, state *models.OAuthState) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	now := time.Now()
+	for hash, st := range s.m.oauthStates {
+		if st.ExpiresAt.Before(now) {
+			delete(s.m.oauthStates, hash)
+		}
+	}
+	s.m.oauthStates[state.StateHash] = *state
+	return nil
+}
+
+func (s *memOAuthStateStore) Consume(ctx context.Context, stateHash string) (*models.OAuthState, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	state, ok := s.m.oauthStates[stateHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	delete(s.m.oauthStates, stateHash)
+	if !state.ExpiresAt.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return &state, nil
+}
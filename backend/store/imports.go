@@ -0,0 +1,130 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"backend/models"
+)
+
+// ImportStore はvCard・CSVから読み込んだプロフィールの一括登録を扱います
+type ImportStore interface {
+	// Import はプロフィールと紐づくリンク・任意項目を1つのトランザクションで登録し、採番したIDを各レコードに設定します
+	// 途中で失敗した場合は何も登録しません
+	// connectFromが0以外の場合は、そのプロフィールから登録した各プロフィールへのコネクションも作成します
+	Import(ctx context.Context, records []models.ImportRecord, connectFrom int) error
+}
+
+type pgImportStore struct {
+	db *sql.DB
+}
+
+func (s *pgImportStore) Import(ctx context.Context, records []models.ImportRecord, connectFrom int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("トランザクションの開始に失敗しました: %v", err)
+	}
+	defer tx.Rollback() // エラー時に自動ロールバック
+
+	now := time.Now()
+	for i := range records {
+		record := &records[i]
+		profile := &record.Profile
+		defaultVisibility(profile)
+		err := tx.QueryRowContext(ctx, insertProfileQuery, profileInsertArgs(profile)...).Scan(&profile.ID)
+		if isUniqueViolation(err) {
+			return ErrConflict
+		}
+		if err != nil {
+			return fmt.Errorf("%d件目のプロフィールの登録に失敗しました: %v", record.Index, err)
+		}
+
+		for j := range record.Links {
+			link := &record.Links[j]
+			link.ProfileID = &profile.ID
+			link.CreatedAt = now
+			link.UpdatedAt = now
+			if err := tx.QueryRowContext(ctx, insertLinkQuery,
+				nullIfZero(link.UsersID), link.ProfileID, link.ImageURL, link.Title, link.Description, link.URL,
+				link.ConnectionsOnly, now, now,
+			).Scan(&link.ID); err != nil {
+				return fmt.Errorf("%d件目のリンクの登録に失敗しました: %v", record.Index, err)
+			}
+		}
+
+		for j := range record.OptionProfiles {
+			opt := &record.OptionProfiles[j]
+			opt.ProfileID = profile.ID
+			if err := tx.QueryRowContext(ctx, insertOptionProfileQuery,
+				opt.Title, opt.Content, opt.ProfileID, opt.ConnectionsOnly,
+			).Scan(&opt.ID); err != nil {
+				return fmt.Errorf("%d件目の任意項目の登録に失敗しました: %v", record.Index, err)
+			}
+		}
+
+		if connectFrom != 0 {
+			var id int
+			if err := tx.QueryRowContext(ctx, insertConnectionQuery,
+				connectFrom, profile.ID, now, "", "", "",
+			).Scan(&id); err != nil {
+				return fmt.Errorf("%d件目のコネクションの登録に失敗しました: %v", record.Index, err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+type memImportStore struct {
+	m *memoryDB
+}
+
+func (s *memImportStore) Import(ctx context.Context, records []models.ImportRecord, connectFrom int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	// 先に重複を確認し、途中まで登録された状態にならないようにする
+	for _, record := range records {
+		for _, other := range s.m.profiles {
+			if other.PublicID == record.Profile.PublicID || (record.Profile.Slug != "" && other.Slug == record.Profile.Slug) {
+				return ErrConflict
+			}
+		}
+	}
+
+	now := time.Now()
+	for i := range records {
+		record := &records[i]
+		profile := &record.Profile
+		defaultVisibility(profile)
+		profile.ID = s.m.nextID("profiles")
+		stored := *profile
+		stored.IconURL = ""
+		stored.OptionProfiles = nil
+		s.m.profiles[profile.ID] = stored
+
+		for j := range record.Links {
+			link := &record.Links[j]
+			link.ID = s.m.nextID("link")
+			link.ProfileID = &profile.ID
+			link.CreatedAt = now
+			link.UpdatedAt = now
+			s.m.links[link.ID] = *link
+		}
+		for j := range record.OptionProfiles {
+			opt := &record.OptionProfiles[j]
+			opt.ID = s.m.nextID("option_profiles")
+			opt.ProfileID = profile.ID
+			s.m.optionProfiles[opt.ID] = *opt
+		}
+		if connectFrom != 0 {
+			id := s.m.nextID("connections")
+			s.m.connections[id] = models.Connection{
+				ID: id, ProfileID: connectFrom, ConnectUsersProfileID: profile.ID, ConnectedAt: now,
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"backend/models"
+)
+
+// LinkStore はリンクの永続化を扱います
+type LinkStore interface {
+	// Create はリンクを登録し、採番したIDと作成日時をlinkに設定します
+	Create(ctx context.Context, link *models.Link) error
+	Get(ctx context.Context, id int) (*models.Link, error)
+	// ListByUser はユーザー直下のリンクとユーザーのプロフィールに紐づくリンクを新しい順に返します
+	ListByUser(ctx context.Context, userID int) ([]models.Link, error)
+	ListByProfile(ctx context.Context, profileID int) ([]models.Link, error)
+	// Update は画像URL・タイトル・説明・URL・公開範囲を更新し、更新日時をlinkに設定します
+	Update(ctx context.Context, link *models.Link) error
+	Delete(ctx context.Context, id int) error
+}
+
+const linkColumns = "id, user_id, profile_id, image_url, title, description, url, connections_only, created_at, updated_at"
+
+type pgLinkStore struct {
+	db *sql.DB
+}
+
+// scanLink はNULLを許容しつつリンク1行を読み込みます
+func scanLink(row rowScanner) (*models.Link, error) {
+	var link models.Link
+	var userIDPtr, profileIDPtr sql.NullInt64
+	var imageURL, description sql.NullString
+
+	err := row.Scan(
+		&link.ID, &userIDPtr, &profileIDPtr,
+		&imageURL, &link.Title, &description, &link.URL,
+		&link.ConnectionsOnly, &link.CreatedAt, &link.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// NULL値の処理
+	if userIDPtr.Valid {
+		link.UsersID = int(userIDPtr.Int64)
+	}
+	if profileIDPtr.Valid {
+		profileID := int(profileIDPtr.Int64)
+		link.ProfileID = &profileID
+	}
+	if imageURL.Valid {
+		link.ImageURL = &imageURL.String
+	}
+	if description.Valid {
+		link.Description = &description.String
+	}
+	return &link, nil
+}
+
+func (s *pgLinkStore) queryLinks(ctx context.Context, query string, args ...interface{}) ([]models.Link, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []models.Link
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, *link)
+	}
+	return links, rows.Err()
+}
+
+const insertLinkQuery = `INSERT INTO link (user_id, profile_id, image_url, title, description, url, connections_only, created_at, updated_at)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
+
+func (s *pgLinkStore) Create(ctx context.Context, link *models.Link) error {
+	now := time.Now()
+	err := s.db.QueryRowContext(
+		ctx,
+		insertLinkQuery,
+		nullIfZero(link.UsersID), link.ProfileID, link.ImageURL, link.Title, link.Description, link.URL,
+		link.ConnectionsOnly, now, now,
+	).Scan(&link.ID)
+	if err != nil {
+		return err
+	}
+	link.CreatedAt = now
+	link.UpdatedAt = now
+	return nil
+}
+
+func (s *pgLinkStore) Get(ctx context.Context, id int) (*models.Link, error) {
+	link, err := scanLink(s.db.QueryRowContext(
+		ctx,
+		"SELECT "+linkColumns+" FROM link WHERE id = $1",
+		id,
+	))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return link, nil
+}
+
+func (s *pgLinkStore) ListByUser(ctx context.Context, userID int) ([]models.Link, error) {
+	return s.queryLinks(
+		ctx,
+		`SELECT `+linkColumns+`
+         FROM link
+         WHERE user_id = $1 OR profile_id IN (SELECT id FROM profiles WHERE user_id = $1)
+         ORDER BY created_at DESC`,
+		userID,
+	)
+}
+
+func (s *pgLinkStore) ListByProfile(ctx context.Context, profileID int) ([]models.Link, error) {
+	return s.queryLinks(
+		ctx,
+		`SELECT `+linkColumns+`
+         FROM link
+         WHERE profile_id = $1
+         ORDER BY created_at DESC`,
+		profileID,
+	)
+}
+
+func (s *pgLinkStore) Update(ctx context.Context, link *models.Link) error {
+	now := time.Now()
+	result, err := s.db.ExecContext(
+		ctx,
+		`UPDATE link
+         SET image_url = $1, title = $2, description = $3, url = $4, connections_only = $5, updated_at = $6
+         WHERE id = $7`,
+		link.ImageURL, link.Title, link.Description, link.URL, link.ConnectionsOnly, now, link.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	link.UpdatedAt = now
+	return nil
+}
+
+func (s *pgLinkStore) Delete(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM link WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type memLinkStore struct {
+	m *memoryDB
+}
+
+// sortLinks は作成日時の新しい順（同時刻はID降順）に並べます
+func sortLinks(links []models.Link) {
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].CreatedAt.Equal(links[j].CreatedAt) {
+			return links[i].ID > links[j].ID
+		}
+		return links[i].CreatedAt.After(links[j].CreatedAt)
+	})
+}
+
+func (s *memLinkStore) Create(ctx context.Context, link *models.Link) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	now := time.Now()
+	link.ID = s.m.nextID("link")
+	link.CreatedAt = now
+	link.UpdatedAt = now
+	s.m.links[link.ID] = *link
+	return nil
+}
+
+func (s *memLinkStore) Get(ctx context.Context, id int) (*models.Link, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	link, ok := s.m.links[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &link, nil
+}
+
+func (s *memLinkStore) ListByUser(ctx context.Context, userID int) ([]models.Link, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var links []models.Link
+	for _, link := range s.m.links {
+		owned := link.UsersID == userID
+		if !owned && link.ProfileID != nil {
+			profile, ok := s.m.profiles[*link.ProfileID]
+			owned = ok && profile.UserID == userID
+		}
+		if owned {
+			links = append(links, link)
+		}
+	}
+	sortLinks(links)
+	return links, nil
+}
+
+func (s *memLinkStore) ListByProfile(ctx context.Context, profileID int) ([]models.Link, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var links []models.Link
+	for _, link := range s.m.links {
+		if link.ProfileID != nil && *link.ProfileID == profileID {
+			links = append(links, link)
+		}
+	}
+	sortLinks(links)
+	return links, nil
+}
+
+func (s *memLinkStore) Update(ctx context.Context, link *models.Link) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	stored, ok := s.m.links[link.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	stored.ImageURL = link.ImageURL
+	stored.Title = link.Title
+	stored.Description = link.Description
+	stored.URL = link.URL
+	stored.ConnectionsOnly = link.ConnectionsOnly
+	stored.UpdatedAt = time.Now()
+	s.m.links[link.ID] = stored
+	link.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+func (s *memLinkStore) Delete(ctx context.Context, id int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.links[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.m.links, id)
+	return nil
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"backend/models"
+)
+
+// memoryDB はメモリストアが共有するデータ領域です
+// テーブル間の結合や削除の連鎖を再現するため、すべてのストアで1つのインスタンスを共有します
+type memoryDB struct {
+	mu sync.Mutex
+
+	users          map[int]models.User
+	profiles       map[int]models.Profile
+	links          map[int]models.Link
+	optionProfiles map[int]models.OptionProfile
+	connections    map[int]models.Connection
+	refreshTokens  map[int]models.RefreshToken
+	sessions       map[string]models.Session
+	revokedTokens  map[string]time.Time // jti -> 有効期限
+	userCutoffs    map[int]time.Time    // user_id -> この時刻より前に発行されたトークンは失効
+	oneTimeTokens  map[int]models.OneTimeToken
+	rateLimits     map[string]rateLimitBucket
+	loginAttempts  map[string]models.LoginAttempt
+	totp           map[int]models.TOTPEnrollment
+	recoveryCodes  map[int][]recoveryCode
+	identities     map[int]models.UserIdentity
+	oauthStates    map[string]models.OAuthState // state_hash -> state
+	accessTokens   map[int]models.PersonalAccessToken
+	auditLogs      map[int]models.AuditLog
+
+	usedExchangeTokens map[string]time.Time // jti -> 有効期限
+	connectionRequests map[int]models.ConnectionRequest
+
+	seq map[string]int
+}
+
+func newMemoryDB() *memoryDB {
+	return &memoryDB{
+		users:          map[int]models.User{},
+		profiles:       map[int]models.Profile{},
+		links:          map[int]models.Link{},
+		optionProfiles: map[int]models.OptionProfile{},
+		connections:    map[int]models.Connection{},
+		refreshTokens:  map[int]models.RefreshToken{},
+		sessions:       map[string]models.Session{},
+		revokedTokens:  map[string]time.Time{},
+		userCutoffs:    map[int]time.Time{},
+		oneTimeTokens:  map[int]models.OneTimeToken{},
+		rateLimits:     map[string]rateLimitBucket{},
+		loginAttempts:  map[string]models.LoginAttempt{},
+		totp:           map[int]models.TOTPEnrollment{},
+		recoveryCodes:  map[int][]recoveryCode{},
+		identities:     map[int]models.UserIdentity{},
+		oauthStates:    map[string]models.OAuthState{},
+		accessTokens:   map[int]models.PersonalAccessToken{},
+		auditLogs:      map[int]models.AuditLog{},
+		seq:            map[string]int{},
+
+		usedExchangeTokens: map[string]time.Time{},
+		connectionRequests: map[int]models.ConnectionRequest{},
+	}
+}
+
+// nextID はテーブルごとの連番を払い出します（呼び出し側でロックを取得していること）
+func (m *memoryDB) nextID(table string) int {
+	m.seq[table]++
+	return m.seq[table]
+}
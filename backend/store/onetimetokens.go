@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"backend/models"
+)
+
+// OneTimeTokenStore はメールで送る使い捨てトークンの永続化を扱います（トークンはハッシュで保存します）
+type OneTimeTokenStore interface {
+	// Create はトークンを登録し、採番したIDをtoken.IDに設定します
+	Create(ctx context.Context, token *models.OneTimeToken) error
+	GetByHash(ctx context.Context, purpose, hash string) (*models.OneTimeToken, error)
+	// Consume は未使用かつ有効期限内のトークンを使用済みにします
+	// 既に使用済みまたは期限切れの場合はfalseを返します（同時リクエストでも1回だけtrueになります）
+	Consume(ctx context.Context, id int) (bool, error)
+	// InvalidateForUser はユーザーの未使用トークンをすべて使用済みにします
+	InvalidateForUser(ctx context.Context, userID int, purpose string) error
+}
+
+type pgOneTimeTokenStore struct {
+	db *sql.DB
+}
+
+func (s *pgOneTimeTokenStore) Create(ctx context.Context, token *models.OneTimeToken) error {
+	return s.db.QueryRowContext(ctx,
+		`INSERT INTO one_time_tokens (user_id, purpose, token_hash, expires_at)
+         VALUES ($1, $2, $3, $4) RETURNING id, created_at`,
+		token.UserID, token.Purpose, token.TokenHash, token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt)
+}
+
+func (s *pgOneTimeTokenStore) GetByHash(ctx context.Context, purpose, hash string) (*models.OneTimeToken, error) {
+	var token models.OneTimeToken
+	var usedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, user_id, purpose, token_hash, expires_at, created_at, used_at
+         FROM one_time_tokens WHERE purpose = $1 AND token_hash = $2`,
+		purpose, hash,
+	).Scan(&token.ID, &token.UserID, &token.Purpose, &token.TokenHash,
+		&token.ExpiresAt, &token.CreatedAt, &usedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	return &token, nil
+}
+
+func (s *pgOneTimeTokenStore) Consume(ctx context.Context, id int) (bool, error) {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE one_time_tokens SET used_at = now()
+         WHERE id = $1 AND used_at IS NULL AND expires_at > now()`,
+		id,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+func (s *pgOneTimeTokenStore) InvalidateForUser(ctx context.Context, userID int, purpose string) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE one_time_tokens SET used_at = now()
+         WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`,
+		userID, purpose,
+	)
+	return err
+}
+
+type memOneTimeTokenStore struct {
+	m *memoryDB
+}
+
+func (s *memOneTimeTokenStore) Create(ctx context.Context, token *models.OneTimeToken) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for _, existing := range s.m.oneTimeTokens {
+		if existing.TokenHash == token.TokenHash {
+			return ErrConflict
+		}
+	}
+	token.ID = s.m.nextID("one_time_tokens")
+	token.CreatedAt = time.Now()
+	s.m.oneTimeTokens[token.ID] = *token
+	return nil
+}
+
+func (s *memOneTimeTokenStore) GetByHash(ctx context.Context, purpose, hash string) (*models.OneTimeToken, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for _, token := range s.m.oneTimeTokens {
+		if token.Purpose == purpose && token.TokenHash == hash {
+			return &token, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memOneTimeTokenStore) Consume(ctx context.Context, id int) (bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	token, ok := s.m.oneTimeTokens[id]
+	now := time.Now()
+	if !ok || token.UsedAt != nil || !token.ExpiresAt.After(now) {
+		return false, nil
+	}
+	token.UsedAt = &now
+	s.m.oneTimeTokens[id] = token
+	return true, nil
+}
+
+func (s *memOneTimeTokenStore) InvalidateForUser(ctx context.Context, userID int, purpose string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	now := time.Now()
+	for id, token := range s.m.oneTimeTokens {
+		if token.UserID == userID && token.Purpose == purpose && token.UsedAt == nil {
+			token.UsedAt = &now
+			s.m.oneTimeTokens[id] = token
+		}
+	}
+	return nil
+}
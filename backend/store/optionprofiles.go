@@ -0,0 +1,192 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"backend/models"
+)
+
+// OptionProfileStore は任意項目（option_profiles）の永続化を扱います
+type OptionProfileStore interface {
+	// Create は任意項目を登録し、採番したIDをopt.IDに設定します
+	Create(ctx context.Context, opt *models.OptionProfile) error
+	Get(ctx context.Context, id int) (*models.OptionProfile, error)
+	// Update は空でない項目（connectionsOnlyはnil以外）のみ更新し、更新後の任意項目を返します
+	Update(ctx context.Context, id int, title, content string, connectionsOnly *bool) (*models.OptionProfile, error)
+	Delete(ctx context.Context, id int) error
+	ListByProfile(ctx context.Context, profileID int) ([]models.OptionProfile, error)
+}
+
+const optionProfileColumns = "id, title, content, profile_id, connections_only"
+
+type pgOptionProfileStore struct {
+	db *sql.DB
+}
+
+func scanOptionProfile(row rowScanner) (*models.OptionProfile, error) {
+	var opt models.OptionProfile
+	if err := row.Scan(&opt.ID, &opt.Title, &opt.Content, &opt.ProfileID, &opt.ConnectionsOnly); err != nil {
+		return nil, err
+	}
+	return &opt, nil
+}
+
+const insertOptionProfileQuery = `INSERT INTO option_profiles (title, content, profile_id, connections_only) VALUES ($1, $2, $3, $4) RETURNING id`
+
+func (s *pgOptionProfileStore) Create(ctx context.Context, opt *models.OptionProfile) error {
+	return s.db.QueryRowContext(ctx, insertOptionProfileQuery, opt.Title, opt.Content, opt.ProfileID, opt.ConnectionsOnly).Scan(&opt.ID)
+}
+
+func (s *pgOptionProfileStore) Get(ctx context.Context, id int) (*models.OptionProfile, error) {
+	opt, err := scanOptionProfile(s.db.QueryRowContext(ctx,
+		"SELECT "+optionProfileColumns+" FROM option_profiles WHERE id = $1", id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return opt, nil
+}
+
+func (s *pgOptionProfileStore) Update(ctx context.Context, id int, title, content string, connectionsOnly *bool) (*models.OptionProfile, error) {
+	// 部分更新に対応
+	fields := []string{}
+	params := []interface{}{}
+	paramCnt := 1
+	if title != "" {
+		fields = append(fields, fmt.Sprintf("title = $%d", paramCnt))
+		params = append(params, title)
+		paramCnt++
+	}
+	if content != "" {
+		fields = append(fields, fmt.Sprintf("content = $%d", paramCnt))
+		params = append(params, content)
+		paramCnt++
+	}
+	if connectionsOnly != nil {
+		fields = append(fields, fmt.Sprintf("connections_only = $%d", paramCnt))
+		params = append(params, *connectionsOnly)
+		paramCnt++
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("更新する項目がありません")
+	}
+
+	updateQuery := fmt.Sprintf(
+		"UPDATE option_profiles SET %s WHERE id = $%d RETURNING "+optionProfileColumns,
+		strings.Join(fields, ", "), paramCnt,
+	)
+	params = append(params, id)
+
+	updated, err := scanOptionProfile(s.db.QueryRowContext(ctx, updateQuery, params...))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return updated, nil
+}
+
+func (s *pgOptionProfileStore) Delete(ctx context.Context, id int) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM option_profiles WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *pgOptionProfileStore) ListByProfile(ctx context.Context, profileID int) ([]models.OptionProfile, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		"SELECT "+optionProfileColumns+" FROM option_profiles WHERE profile_id = $1 ORDER BY id DESC", profileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	options := []models.OptionProfile{}
+	for rows.Next() {
+		opt, err := scanOptionProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, *opt)
+	}
+	return options, rows.Err()
+}
+
+type memOptionProfileStore struct {
+	m *memoryDB
+}
+
+func (s *memOptionProfileStore) Create(ctx context.Context, opt *models.OptionProfile) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	opt.ID = s.m.nextID("option_profiles")
+	s.m.optionProfiles[opt.ID] = *opt
+	return nil
+}
+
+func (s *memOptionProfileStore) Get(ctx context.Context, id int) (*models.OptionProfile, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	opt, ok := s.m.optionProfiles[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &opt, nil
+}
+
+func (s *memOptionProfileStore) Update(ctx context.Context, id int, title, content string, connectionsOnly *bool) (*models.OptionProfile, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if title == "" && content == "" && connectionsOnly == nil {
+		return nil, fmt.Errorf("更新する項目がありません")
+	}
+	opt, ok := s.m.optionProfiles[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if title != "" {
+		opt.Title = title
+	}
+	if content != "" {
+		opt.Content = content
+	}
+	if connectionsOnly != nil {
+		opt.ConnectionsOnly = *connectionsOnly
+	}
+	s.m.optionProfiles[id] = opt
+	return &opt, nil
+}
+
+func (s *memOptionProfileStore) Delete(ctx context.Context, id int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.optionProfiles[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.m.optionProfiles, id)
+	return nil
+}
+
+func (s *memOptionProfileStore) ListByProfile(ctx context.Context, profileID int) ([]models.OptionProfile, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	options := []models.OptionProfile{}
+	for _, opt := range s.m.optionProfiles {
+		if opt.ProfileID == profileID {
+			options = append(options, opt)
+		}
+	}
+	sort.Slice(options, func(i, j int) bool { return options[i].ID > options[j].ID })
+	return options, nil
+}
@@ -0,0 +1,442 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"backend/models"
+
+	"github.com/lib/pq"
+)
+
+// ProfileStore はプロフィールの永続化を扱います
+type ProfileStore interface {
+	// Create はプロフィールを登録し、採番したIDをprofile.IDに設定します（公開ID・カスタムURLの重複はErrConflict）
+	Create(ctx context.Context, profile *models.Profile) error
+	// Get はアイコンパスを含むプロフィールを返します
+	Get(ctx context.Context, id int) (*models.Profile, error)
+	// GetByPublicRef は公開IDまたはカスタムURLでプロフィールを返します
+	GetByPublicRef(ctx context.Context, ref string) (*models.Profile, error)
+	ListByUser(ctx context.Context, userID int) ([]models.Profile, error)
+	// Update はnil以外のフィールドのみ更新し、更新後のプロフィールを返します（カスタムURLの重複はErrConflict）
+	Update(ctx context.Context, id int, update ProfileUpdate) (*models.Profile, error)
+	// Delete はプロフィールと関連するoption_profiles・connections・linkをまとめて削除します
+	Delete(ctx context.Context, id int) error
+}
+
+// ProfileUpdate はプロフィールの部分更新内容を表します（nilのフィールドは更新しません）
+type ProfileUpdate struct {
+	DisplayName *string
+	IconPath    *string
+	AKA         *string
+	Hometown    *string
+	Birthdate   *time.Time
+	Hobby       *string
+	Comment     *string
+	Title       *string
+	Description *string
+
+	Visibility       *string
+	RestrictedFields *[]string
+	Slug             *string // 空文字でカスタムURLを解除
+
+	AutoAcceptConnections *bool
+}
+
+// IsEmpty は更新する項目がない場合にtrueを返します
+func (u ProfileUpdate) IsEmpty() bool {
+	return u.DisplayName == nil && u.IconPath == nil && u.AKA == nil && u.Hometown == nil &&
+		u.Birthdate == nil && u.Hobby == nil && u.Comment == nil && u.Title == nil && u.Description == nil &&
+		u.Visibility == nil && u.RestrictedFields == nil && u.Slug == nil && u.AutoAcceptConnections == nil
+}
+
+// apply は更新内容をプロフィールに反映します（メモリストア用）
+func (u ProfileUpdate) apply(p *models.Profile) {
+	if u.DisplayName != nil {
+		p.DisplayName = *u.DisplayName
+	}
+	if u.IconPath != nil {
+		p.IconPath = *u.IconPath
+	}
+	if u.AKA != nil {
+		p.AKA = *u.AKA
+	}
+	if u.Hometown != nil {
+		p.Hometown = *u.Hometown
+	}
+	if u.Birthdate != nil {
+		p.Birthdate = *u.Birthdate
+	}
+	if u.Hobby != nil {
+		p.Hobby = *u.Hobby
+	}
+	if u.Comment != nil {
+		p.Comment = *u.Comment
+	}
+	if u.Title != nil {
+		p.Title = *u.Title
+	}
+	if u.Description != nil {
+		p.Description = *u.Description
+	}
+	if u.Visibility != nil {
+		p.Visibility = *u.Visibility
+	}
+	if u.RestrictedFields != nil {
+		p.RestrictedFields = append([]string{}, *u.RestrictedFields...)
+	}
+	if u.Slug != nil {
+		p.Slug = *u.Slug
+	}
+	if u.AutoAcceptConnections != nil {
+		p.AutoAcceptConnections = *u.AutoAcceptConnections
+	}
+}
+
+// defaultVisibility は公開範囲が指定されていないプロフィールを公開にします
+func defaultVisibility(profile *models.Profile) {
+	if profile.Visibility == "" {
+		profile.Visibility = models.ProfileVisibilityPublic
+	}
+	if profile.RestrictedFields == nil {
+		profile.RestrictedFields = []string{}
+	}
+}
+
+const profileColumns = `id, user_id, display_name, icon_path, aka, hometown,
+        birthdate, hobby, comment, title, description, visibility, restricted_fields, public_id, slug, auto_accept_connections`
+
+type pgProfileStore struct {
+	db *sql.DB
+}
+
+// scanProfile はNULLを許容しつつプロフィール1行を読み込みます
+func scanProfile(row rowScanner) (*models.Profile, error) {
+	var profile models.Profile
+	var iconPath, aka, hometown, hobby, comment, title, description, slug sql.NullString
+	var birthdate sql.NullTime
+
+	err := row.Scan(
+		&profile.ID, &profile.UserID, &profile.DisplayName, &iconPath,
+		&aka, &hometown, &birthdate, &hobby, &comment, &title, &description,
+		&profile.Visibility, pq.Array(&profile.RestrictedFields), &profile.PublicID, &slug, &profile.AutoAcceptConnections,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	profile.IconPath = iconPath.String
+	profile.AKA = aka.String
+	profile.Hometown = hometown.String
+	profile.Hobby = hobby.String
+	profile.Comment = comment.String
+	profile.Title = title.String
+	profile.Description = description.String
+	profile.Slug = slug.String
+	if birthdate.Valid {
+		profile.Birthdate = birthdate.Time
+	}
+	return &profile, nil
+}
+
+const insertProfileQuery = `INSERT INTO profiles (
+        user_id, display_name, icon_path, aka, hometown,
+        birthdate, hobby, comment, title, description, visibility, restricted_fields, public_id, slug, auto_accept_connections
+    ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+    RETURNING id`
+
+// profileInsertArgs はinsertProfileQueryの引数を返します
+func profileInsertArgs(profile *models.Profile) []interface{} {
+	var birthdate *time.Time
+	if !profile.Birthdate.IsZero() {
+		birthdate = &profile.Birthdate
+	}
+	return []interface{}{
+		profile.UserID, profile.DisplayName, profile.IconPath, profile.AKA, profile.Hometown,
+		birthdate, profile.Hobby, profile.Comment, profile.Title, profile.Description,
+		profile.Visibility, pq.Array(profile.RestrictedFields), profile.PublicID, nullIfEmpty(profile.Slug),
+		profile.AutoAcceptConnections,
+	}
+}
+
+func (s *pgProfileStore) Create(ctx context.Context, profile *models.Profile) error {
+	defaultVisibility(profile)
+	err := s.db.QueryRowContext(ctx, insertProfileQuery, profileInsertArgs(profile)...).Scan(&profile.ID)
+	if isUniqueViolation(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+func (s *pgProfileStore) Get(ctx context.Context, id int) (*models.Profile, error) {
+	profile, err := scanProfile(s.db.QueryRowContext(
+		ctx,
+		"SELECT "+profileColumns+" FROM profiles WHERE id = $1",
+		id,
+	))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return profile, nil
+}
+
+func (s *pgProfileStore) GetByPublicRef(ctx context.Context, ref string) (*models.Profile, error) {
+	// 公開IDを優先し、なければカスタムURL（大文字小文字を区別しない）で探す
+	profile, err := scanProfile(s.db.QueryRowContext(
+		ctx,
+		"SELECT "+profileColumns+` FROM profiles WHERE public_id = $1 OR slug = lower($1)
+         ORDER BY public_id = $1 DESC LIMIT 1`,
+		ref,
+	))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return profile, nil
+}
+
+func (s *pgProfileStore) ListByUser(ctx context.Context, userID int) ([]models.Profile, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		"SELECT "+profileColumns+" FROM profiles WHERE user_id = $1 ORDER BY id DESC",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := []models.Profile{}
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, *profile)
+	}
+	return profiles, rows.Err()
+}
+
+func (s *pgProfileStore) Update(ctx context.Context, id int, update ProfileUpdate) (*models.Profile, error) {
+	fields := []string{}
+	params := []interface{}{}
+
+	set := func(column string, value interface{}) {
+		params = append(params, value)
+		fields = append(fields, fmt.Sprintf("%s = $%d", column, len(params)))
+	}
+
+	if update.DisplayName != nil {
+		set("display_name", *update.DisplayName)
+	}
+	if update.IconPath != nil {
+		set("icon_path", *update.IconPath)
+	}
+	if update.AKA != nil {
+		set("aka", *update.AKA)
+	}
+	if update.Hometown != nil {
+		set("hometown", *update.Hometown)
+	}
+	if update.Birthdate != nil {
+		set("birthdate", *update.Birthdate)
+	}
+	if update.Hobby != nil {
+		set("hobby", *update.Hobby)
+	}
+	if update.Comment != nil {
+		set("comment", *update.Comment)
+	}
+	if update.Title != nil {
+		set("title", *update.Title)
+	}
+	if update.Description != nil {
+		set("description", *update.Description)
+	}
+	if update.Visibility != nil {
+		set("visibility", *update.Visibility)
+	}
+	if update.RestrictedFields != nil {
+		set("restricted_fields", pq.Array(*update.RestrictedFields))
+	}
+	if update.Slug != nil {
+		set("slug", nullIfEmpty(*update.Slug))
+	}
+	if update.AutoAcceptConnections != nil {
+		set("auto_accept_connections", *update.AutoAcceptConnections)
+	}
+
+	if len(fields) == 0 {
+		return s.Get(ctx, id)
+	}
+
+	params = append(params, id)
+	query := fmt.Sprintf(
+		"UPDATE profiles SET %s WHERE id = $%d RETURNING "+profileColumns,
+		strings.Join(fields, ", "), len(params),
+	)
+
+	profile, err := scanProfile(s.db.QueryRowContext(ctx, query, params...))
+	if isUniqueViolation(err) {
+		return nil, ErrConflict
+	}
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return profile, nil
+}
+
+func (s *pgProfileStore) Delete(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("トランザクションの開始に失敗しました: %v", err)
+	}
+	defer tx.Rollback() // エラー時に自動ロールバック
+
+	// 関連データを先に削除（外部キー制約のため）
+	if _, err := tx.ExecContext(ctx, "DELETE FROM option_profiles WHERE profile_id = $1", id); err != nil {
+		return fmt.Errorf("関連データの削除に失敗しました（option_profiles）: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM connections WHERE profile_id = $1 OR connect_user_profile_id = $1", id); err != nil {
+		return fmt.Errorf("関連データの削除に失敗しました（connections）: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM link WHERE profile_id = $1", id); err != nil {
+		return fmt.Errorf("関連データの削除に失敗しました（link）: %v", err)
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM profiles WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("プロフィールの削除に失敗しました: %v", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return tx.Commit()
+}
+
+type memProfileStore struct {
+	m *memoryDB
+}
+
+func (s *memProfileStore) Create(ctx context.Context, profile *models.Profile) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	defaultVisibility(profile)
+	for _, other := range s.m.profiles {
+		if other.PublicID == profile.PublicID || (profile.Slug != "" && other.Slug == profile.Slug) {
+			return ErrConflict
+		}
+	}
+	profile.ID = s.m.nextID("profiles")
+	stored := *profile
+	stored.IconURL = ""
+	stored.OptionProfiles = nil
+	s.m.profiles[profile.ID] = stored
+	return nil
+}
+
+func (s *memProfileStore) Get(ctx context.Context, id int) (*models.Profile, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	profile, ok := s.m.profiles[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &profile, nil
+}
+
+func (s *memProfileStore) GetByPublicRef(ctx context.Context, ref string) (*models.Profile, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var bySlug *models.Profile
+	for _, profile := range s.m.profiles {
+		if profile.PublicID == ref {
+			return &profile, nil
+		}
+		if profile.Slug != "" && profile.Slug == strings.ToLower(ref) {
+			p := profile
+			bySlug = &p
+		}
+	}
+	if bySlug == nil {
+		return nil, ErrNotFound
+	}
+	return bySlug, nil
+}
+
+func (s *memProfileStore) ListByUser(ctx context.Context, userID int) ([]models.Profile, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	profiles := []models.Profile{}
+	for _, profile := range s.m.profiles {
+		if profile.UserID == userID {
+			profiles = append(profiles, profile)
+		}
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].ID > profiles[j].ID })
+	return profiles, nil
+}
+
+func (s *memProfileStore) Update(ctx context.Context, id int, update ProfileUpdate) (*models.Profile, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	profile, ok := s.m.profiles[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if update.Slug != nil && *update.Slug != "" {
+		for otherID, other := range s.m.profiles {
+			if otherID != id && other.Slug == *update.Slug {
+				return nil, ErrConflict
+			}
+		}
+	}
+	update.apply(&profile)
+	s.m.profiles[id] = profile
+	return &profile, nil
+}
+
+func (s *memProfileStore) Delete(ctx context.Context, id int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.profiles[id]; !ok {
+		return ErrNotFound
+	}
+	s.m.deleteProfile(id)
+	return nil
+}
+
+// deleteProfile はプロフィールと関連するoption_profiles・connections・connection_requests・linkを削除します（呼び出し側でロックを取得していること）
+func (m *memoryDB) deleteProfile(id int) {
+	for optID, opt := range m.optionProfiles {
+		if opt.ProfileID == id {
+			delete(m.optionProfiles, optID)
+		}
+	}
+	for connID, conn := range m.connections {
+		if conn.ProfileID == id || conn.ConnectUsersProfileID == id {
+			delete(m.connections, connID)
+		}
+	}
+	for reqID, req := range m.connectionRequests {
+		if req.ProfileID == id || req.ConnectUsersProfileID == id {
+			delete(m.connectionRequests, reqID)
+		}
+	}
+	for linkID, link := range m.links {
+		if link.ProfileID != nil && *link.ProfileID == id {
+			delete(m.links, linkID)
+		}
+	}
+	delete(m.profiles, id)
+}
@@ -0,0 +1,251 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"math/rand"
+	"time"
+
+	"backend/models"
+)
+
+// RateLimit はトークンバケットの設定です
+// バケットにはBurst個までトークンが貯まり、Intervalごとに1個補充されます
+type RateLimit struct {
+	Burst    int
+	Interval time.Duration
+}
+
+// PerMinute は1分あたりn回（最大n回まで連続可）の制限を返します
+func PerMinute(n int) RateLimit {
+	return RateLimit{Burst: n, Interval: time.Minute / time.Duration(n)}
+}
+
+// RateLimitResult はトークンを1つ取り出した結果です
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // 拒否された場合、次のトークンが補充されるまでの時間
+	ResetAfter time.Duration // バケットが満杯に戻るまでの時間
+}
+
+// RateLimitStore はキーごとのトークンバケットを管理します
+type RateLimitStore interface {
+	// Take はキーのバケットからトークンを1つ取り出します
+	Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
+
+// LoginAttemptStore はサインイン失敗回数とロック状態を管理します
+type LoginAttemptStore interface {
+	Get(ctx context.Context, key string) (*models.LoginAttempt, error)
+	// RecordFailure は失敗回数を1増やします（最後の失敗からresetAfter以上経過していた場合は1から数え直します）
+	RecordFailure(ctx context.Context, key string, resetAfter time.Duration) (*models.LoginAttempt, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	// Reset は失敗回数とロックを解除します
+	Reset(ctx context.Context, key string) error
+}
+
+// takeToken はバケットを経過時間分だけ補充してからトークンを1つ取り出し、取り出し後のトークン数を返します
+func takeToken(tokens float64, updatedAt, now time.Time, limit RateLimit) (float64, RateLimitResult) {
+	burst := float64(limit.Burst)
+	if elapsed := now.Sub(updatedAt); elapsed > 0 {
+		tokens = math.Min(burst, tokens+float64(elapsed)/float64(limit.Interval))
+	}
+
+	result := RateLimitResult{Limit: limit.Burst}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - tokens) * float64(limit.Interval))
+	}
+	result.Remaining = int(tokens)
+	result.ResetAfter = time.Duration((burst - tokens) * float64(limit.Interval))
+	return tokens, result
+}
+
+type pgRateLimitStore struct {
+	db *sql.DB
+}
+
+func (s *pgRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	now := time.Now()
+
+	// 長期間使われていないバケットはたまに掃除する
+	if rand.Intn(1000) == 0 {
+		s.db.ExecContext(ctx, `DELETE FROM rate_limit_buckets WHERE updated_at < $1`, now.Add(-24*time.Hour))
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	defer tx.Rollback() // エラー時に自動ロールバック
+
+	// 行ロックで同じキーへの同時リクエストを直列化する
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO rate_limit_buckets (key, tokens, updated_at) VALUES ($1, $2, $3)
+         ON CONFLICT (key) DO NOTHING`,
+		key, float64(limit.Burst), now,
+	)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	var tokens float64
+	var updatedAt time.Time
+	err = tx.QueryRowContext(ctx,
+		`SELECT tokens, updated_at FROM rate_limit_buckets WHERE key = $1 FOR UPDATE`, key,
+	).Scan(&tokens, &updatedAt)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	tokens, result := takeToken(tokens, updatedAt, now, limit)
+	_, err = tx.ExecContext(ctx,
+		`UPDATE rate_limit_buckets SET tokens = $2, updated_at = $3 WHERE key = $1`,
+		key, tokens, now,
+	)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	return result, tx.Commit()
+}
+
+type pgLoginAttemptStore struct {
+	db *sql.DB
+}
+
+func scanLoginAttempt(row rowScanner) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	var lockedUntil sql.NullTime
+	if err := row.Scan(&attempt.Key, &attempt.Failures, &attempt.LastFailedAt, &lockedUntil); err != nil {
+		return nil, err
+	}
+	if lockedUntil.Valid {
+		attempt.LockedUntil = &lockedUntil.Time
+	}
+	return &attempt, nil
+}
+
+func (s *pgLoginAttemptStore) Get(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	attempt, err := scanLoginAttempt(s.db.QueryRowContext(ctx,
+		`SELECT key, failures, last_failed_at, locked_until FROM login_attempts WHERE key = $1`, key))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return attempt, nil
+}
+
+func (s *pgLoginAttemptStore) RecordFailure(ctx context.Context, key string, resetAfter time.Duration) (*models.LoginAttempt, error) {
+	now := time.Now()
+	return scanLoginAttempt(s.db.QueryRowContext(ctx,
+		`INSERT INTO login_attempts AS a (key, failures, last_failed_at) VALUES ($1, 1, $2)
+         ON CONFLICT (key) DO UPDATE SET
+             failures = CASE WHEN a.last_failed_at < $3 THEN 1 ELSE a.failures + 1 END,
+             last_failed_at = EXCLUDED.last_failed_at
+         RETURNING key, failures, last_failed_at, locked_until`,
+		key, now, now.Add(-resetAfter),
+	))
+}
+
+func (s *pgLoginAttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE login_attempts SET locked_until = $2 WHERE key = $1`, key, until)
+	return err
+}
+
+func (s *pgLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM login_attempts WHERE key = $1`, key)
+	return err
+}
+
+// rateLimitBucket はメモリストアのトークンバケットです
+type rateLimitBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	limit     RateLimit
+}
+
+type memRateLimitStore struct {
+	m *memoryDB
+}
+
+// NewMemoryRateLimits はプロセス内だけで動作するレート制限ストアを作成します（単一インスタンス用）
+func NewMemoryRateLimits() RateLimitStore {
+	return &memRateLimitStore{newMemoryDB()}
+}
+
+func (s *memRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	now := time.Now()
+
+	// キーが増えすぎたら満杯に戻っているバケットを捨てる（満杯のバケットは新規作成と同じ）
+	if len(s.m.rateLimits) > 10000 {
+		for k, b := range s.m.rateLimits {
+			if tokens, _ := takeToken(b.tokens, b.updatedAt, now, b.limit); tokens+1 >= float64(b.limit.Burst) {
+				delete(s.m.rateLimits, k)
+			}
+		}
+	}
+
+	bucket, ok := s.m.rateLimits[key]
+	if !ok {
+		bucket = rateLimitBucket{tokens: float64(limit.Burst), updatedAt: now}
+	}
+	tokens, result := takeToken(bucket.tokens, bucket.updatedAt, now, limit)
+	s.m.rateLimits[key] = rateLimitBucket{tokens: tokens, updatedAt: now, limit: limit}
+	return result, nil
+}
+
+type memLoginAttemptStore struct {
+	m *memoryDB
+}
+
+func (s *memLoginAttemptStore) Get(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	attempt, ok := s.m.loginAttempts[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &attempt, nil
+}
+
+func (s *memLoginAttemptStore) RecordFailure(ctx context.Context, key string, resetAfter time.Duration) (*models.LoginAttempt, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	now := time.Now()
+	attempt, ok := s.m.loginAttempts[key]
+	if !ok || attempt.LastFailedAt.Before(now.Add(-resetAfter)) {
+		attempt.Key = key
+		attempt.Failures = 0
+	}
+	attempt.Failures++
+	attempt.LastFailedAt = now
+	s.m.loginAttempts[key] = attempt
+	return &attempt, nil
+}
+
+func (s *memLoginAttemptStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if attempt, ok := s.m.loginAttempts[key]; ok {
+		attempt.LockedUntil = &until
+		s.m.loginAttempts[key] = attempt
+	}
+	return nil
+}
+
+func (s *memLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	delete(s.m.loginAttempts, key)
+	return nil
+}
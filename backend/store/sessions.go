@@ -0,0 +1,234 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"backend/models"
+)
+
+// SessionStore はサインインごとのセッションの永続化を扱います
+type SessionStore interface {
+	// Create はセッションを登録し、作成日時をsessionに設定します
+	Create(ctx context.Context, session *models.Session) error
+	Get(ctx context.Context, id string) (*models.Session, error)
+	// ListActive はユーザーの有効なセッションを最後に使われた順に返します
+	ListActive(ctx context.Context, userID int, now time.Time) ([]models.Session, error)
+	// Extend はリフレッシュトークンの更新に合わせて有効期限と最終使用日時を更新します
+	Extend(ctx context.Context, id string, seenAt, expiresAt time.Time) error
+	// TouchLastSeen は最終使用日時を記録します（書き込みを減らすため1分以内の再記録は省きます）
+	TouchLastSeen(ctx context.Context, id string, seenAt time.Time) error
+	// Revoke はユーザーのセッションを失効させます（存在しないか他人のセッション、失効済みの場合はErrNotFound）
+	Revoke(ctx context.Context, userID int, id string) error
+	// RevokeAllForUser はexceptID以外のユーザーのセッションをすべて失効させ、失効させたIDを返します
+	RevokeAllForUser(ctx context.Context, userID int, exceptID string) ([]string, error)
+}
+
+const sessionColumns = "id, user_id, user_agent, ip_address, created_at, last_seen_at, expires_at, revoked_at"
+
+type pgSessionStore struct {
+	db *sql.DB
+}
+
+func scanSession(row rowScanner) (*models.Session, error) {
+	var session models.Session
+	var revokedAt sql.NullTime
+	err := row.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IPAddress,
+		&session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	return &session, nil
+}
+
+func (s *pgSessionStore) Create(ctx context.Context, session *models.Session) error {
+	return s.db.QueryRowContext(ctx,
+		`INSERT INTO sessions (id, user_id, user_agent, ip_address, expires_at)
+         VALUES ($1, $2, $3, $4, $5) RETURNING created_at, last_seen_at`,
+		session.ID, session.UserID, session.UserAgent, session.IPAddress, session.ExpiresAt,
+	).Scan(&session.CreatedAt, &session.LastSeenAt)
+}
+
+func (s *pgSessionStore) Get(ctx context.Context, id string) (*models.Session, error) {
+	session, err := scanSession(s.db.QueryRowContext(ctx,
+		"SELECT "+sessionColumns+" FROM sessions WHERE id = $1", id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return session, nil
+}
+
+func (s *pgSessionStore) ListActive(ctx context.Context, userID int, now time.Time) ([]models.Session, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+sessionColumns+` FROM sessions
+         WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+         ORDER BY last_seen_at DESC, created_at DESC`, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+	return sessions, rows.Err()
+}
+
+func (s *pgSessionStore) Extend(ctx context.Context, id string, seenAt, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE sessions SET last_seen_at = $2, expires_at = $3 WHERE id = $1`,
+		id, seenAt, expiresAt)
+	return err
+}
+
+func (s *pgSessionStore) TouchLastSeen(ctx context.Context, id string, seenAt time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE sessions SET last_seen_at = $2 WHERE id = $1 AND last_seen_at < $3`,
+		id, seenAt, seenAt.Add(-time.Minute))
+	return err
+}
+
+func (s *pgSessionStore) Revoke(ctx context.Context, userID int, id string) error {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE sessions SET revoked_at = now()
+         WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *pgSessionStore) RevokeAllForUser(ctx context.Context, userID int, exceptID string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`UPDATE sessions SET revoked_at = now()
+         WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL RETURNING id`, userID, exceptID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+type memSessionStore struct {
+	m *memoryDB
+}
+
+func (s *memSessionStore) Create(ctx context.Context, session *models.Session) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.sessions[session.ID]; ok {
+		return ErrConflict
+	}
+	session.CreatedAt = time.Now()
+	session.LastSeenAt = session.CreatedAt
+	s.m.sessions[session.ID] = *session
+	return nil
+}
+
+func (s *memSessionStore) Get(ctx context.Context, id string) (*models.Session, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	session, ok := s.m.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &session, nil
+}
+
+func (s *memSessionStore) ListActive(ctx context.Context, userID int, now time.Time) ([]models.Session, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	sessions := []models.Session{}
+	for _, session := range s.m.sessions {
+		if session.UserID == userID && session.Active(now) {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastSeenAt.Equal(sessions[j].LastSeenAt) {
+			return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+		}
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+	return sessions, nil
+}
+
+func (s *memSessionStore) Extend(ctx context.Context, id string, seenAt, expiresAt time.Time) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if session, ok := s.m.sessions[id]; ok {
+		session.LastSeenAt = seenAt
+		session.ExpiresAt = expiresAt
+		s.m.sessions[id] = session
+	}
+	return nil
+}
+
+func (s *memSessionStore) TouchLastSeen(ctx context.Context, id string, seenAt time.Time) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	session, ok := s.m.sessions[id]
+	if !ok || !session.LastSeenAt.Before(seenAt.Add(-time.Minute)) {
+		return nil
+	}
+	session.LastSeenAt = seenAt
+	s.m.sessions[id] = session
+	return nil
+}
+
+func (s *memSessionStore) Revoke(ctx context.Context, userID int, id string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	session, ok := s.m.sessions[id]
+	if !ok || session.UserID != userID || session.RevokedAt != nil {
+		return ErrNotFound
+	}
+	now := time.Now()
+	session.RevokedAt = &now
+	s.m.sessions[id] = session
+	return nil
+}
+
+func (s *memSessionStore) RevokeAllForUser(ctx context.Context, userID int, exceptID string) ([]string, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	now := time.Now()
+	ids := []string{}
+	for id, session := range s.m.sessions {
+		if session.UserID == userID && id != exceptID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+			s.m.sessions[id] = session
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrNotFound は対象のデータが存在しない場合に返されます
+	ErrNotFound = errors.New("データが見つかりません")
+	// ErrConflict は一意制約などによりデータが重複する場合に返されます
+	ErrConflict = errors.New("データが既に存在します")
+	// ErrTokenUsed は使い捨てのトークンが既に使用されている場合に返されます
+	ErrTokenUsed = errors.New("トークンは既に使用されています")
+)
+
+// Stores はハンドラーが利用するストアをまとめたものです
+type Stores struct {
+	Users              UserStore
+	Profiles           ProfileStore
+	Links              LinkStore
+	OptionProfiles     OptionProfileStore
+	Connections        ConnectionStore
+	RefreshTokens      RefreshTokenStore
+	Sessions           SessionStore
+	RevokedTokens      RevokedTokenStore
+	OneTimeTokens      OneTimeTokenStore
+	RateLimits         RateLimitStore
+	LoginAttempts      LoginAttemptStore
+	TwoFactor          TwoFactorStore
+	Identities         IdentityStore
+	OAuthStates        OAuthStateStore
+	AccessTokens       PersonalAccessTokenStore
+	AuditLogs          AuditLogStore
+	Imports            ImportStore
+	ExchangeTokens     ExchangeTokenStore
+	ConnectionRequests ConnectionRequestStore
+}
+
+// NewPostgres はPostgreSQLを使うストア一式を作成します
+func NewPostgres(db *sql.DB) *Stores {
+	return &Stores{
+		Users:              &pgUserStore{db: db},
+		Profiles:           &pgProfileStore{db: db},
+		Links:              &pgLinkStore{db: db},
+		OptionProfiles:     &pgOptionProfileStore{db: db},
+		Connections:        &pgConnectionStore{db: db},
+		RefreshTokens:      &pgRefreshTokenStore{db: db},
+		Sessions:           &pgSessionStore{db: db},
+		RevokedTokens:      &pgRevokedTokenStore{db: db},
+		OneTimeTokens:      &pgOneTimeTokenStore{db: db},
+		RateLimits:         &pgRateLimitStore{db: db},
+		LoginAttempts:      &pgLoginAttemptStore{db: db},
+		TwoFactor:          &pgTwoFactorStore{db: db},
+		Identities:         &pgIdentityStore{db: db},
+		OAuthStates:        &pgOAuthStateStore{db: db},
+		AccessTokens:       &pgAccessTokenStore{db: db},
+		AuditLogs:          &pgAuditLogStore{db: db},
+		Imports:            &pgImportStore{db: db},
+		ExchangeTokens:     &pgExchangeTokenStore{db: db},
+		ConnectionRequests: &pgConnectionRequestStore{db: db},
+	}
+}
+
+// NewMemory はメモリ上で動作するストア一式を作成します（テスト・ローカル確認用）
+func NewMemory() *Stores {
+	m := newMemoryDB()
+	return &Stores{
+		Users:              &memUserStore{m},
+		Profiles:           &memProfileStore{m},
+		Links:              &memLinkStore{m},
+		OptionProfiles:     &memOptionProfileStore{m},
+		Connections:        &memConnectionStore{m},
+		RefreshTokens:      &memRefreshTokenStore{m},
+		Sessions:           &memSessionStore{m},
+		RevokedTokens:      &memRevokedTokenStore{m},
+		OneTimeTokens:      &memOneTimeTokenStore{m},
+		RateLimits:         &memRateLimitStore{m},
+		LoginAttempts:      &memLoginAttemptStore{m},
+		TwoFactor:          &memTwoFactorStore{m},
+		Identities:         &memIdentityStore{m},
+		OAuthStates:        &memOAuthStateStore{m},
+		AccessTokens:       &memAccessTokenStore{m},
+		AuditLogs:          &memAuditLogStore{m},
+		Imports:            &memImportStore{m},
+		ExchangeTokens:     &memExchangeTokenStore{m},
+		ConnectionRequests: &memConnectionRequestStore{m},
+	}
+}
+
+// rowScanner は *sql.Row と *sql.Rows の共通インターフェースです
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// notFound は sql.ErrNoRows を ErrNotFound に変換します
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// isUniqueViolation は一意制約違反のエラーかどうかを判定します
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// nullIfZero は0をNULLとして扱います
+func nullIfZero(v int) interface{} {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
+// nullIfEmpty は空文字をNULLとして扱います
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,241 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"backend/models"
+)
+
+// RefreshTokenStore はリフレッシュトークンの永続化を扱います（トークンはハッシュで保存します）
+type RefreshTokenStore interface {
+	// Create はトークンを登録し、採番したIDをtoken.IDに設定します
+	Create(ctx context.Context, token *models.RefreshToken) error
+	GetByHash(ctx context.Context, hash string) (*models.RefreshToken, error)
+	// MarkUsed は未使用・未失効のトークンを使用済みにします
+	// 既に使用済みまたは失効済みの場合はfalseを返します（同時リクエストでも1回だけtrueになります）
+	MarkUsed(ctx context.Context, id int) (bool, error)
+	// RevokeFamily は同じ系列のトークンをすべて失効させます
+	RevokeFamily(ctx context.Context, familyID string) error
+	// RevokeAllForUser はユーザーのトークンをすべて失効させます
+	RevokeAllForUser(ctx context.Context, userID int) error
+}
+
+// RevokedTokenStore は失効させたアクセストークンを管理します
+type RevokedTokenStore interface {
+	// Revoke はjtiを失効リストに追加します（expiresAtを過ぎたエントリは削除して構いません）
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	// RevokeUser はbeforeより前に発行されたユーザーのアクセストークンをすべて失効させます
+	RevokeUser(ctx context.Context, userID int, before time.Time) error
+	// IsRevoked はjti単位またはユーザー単位で失効済みかどうかを判定します
+	IsRevoked(ctx context.Context, jti string, userID int, issuedAt time.Time) (bool, error)
+}
+
+type pgRefreshTokenStore struct {
+	db *sql.DB
+}
+
+func (s *pgRefreshTokenStore) Create(ctx context.Context, token *models.RefreshToken) error {
+	return s.db.QueryRowContext(ctx,
+		`INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+         VALUES ($1, $2, $3, $4) RETURNING id, created_at`,
+		token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt)
+}
+
+func (s *pgRefreshTokenStore) GetByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	var usedAt, revokedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, user_id, family_id, token_hash, expires_at, created_at, used_at, revoked_at
+         FROM refresh_tokens WHERE token_hash = $1`,
+		hash,
+	).Scan(&token.ID, &token.UserID, &token.FamilyID, &token.TokenHash,
+		&token.ExpiresAt, &token.CreatedAt, &usedAt, &revokedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return &token, nil
+}
+
+func (s *pgRefreshTokenStore) MarkUsed(ctx context.Context, id int) (bool, error) {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET used_at = now()
+         WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL`,
+		id,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+func (s *pgRefreshTokenStore) RevokeFamily(ctx context.Context, familyID string) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = now() WHERE family_id = $1 AND revoked_at IS NULL`,
+		familyID,
+	)
+	return err
+}
+
+func (s *pgRefreshTokenStore) RevokeAllForUser(ctx context.Context, userID int) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL`,
+		userID,
+	)
+	return err
+}
+
+type pgRevokedTokenStore struct {
+	db *sql.DB
+}
+
+func (s *pgRevokedTokenStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	// 期限切れのエントリはもう照合する必要がないので、ついでに掃除する
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at < now()`); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING`,
+		jti, expiresAt,
+	)
+	return err
+}
+
+func (s *pgRevokedTokenStore) RevokeUser(ctx context.Context, userID int, before time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO user_token_revocations (user_id, revoked_before) VALUES ($1, $2)
+         ON CONFLICT (user_id) DO UPDATE SET revoked_before = GREATEST(user_token_revocations.revoked_before, EXCLUDED.revoked_before)`,
+		userID, before,
+	)
+	return err
+}
+
+func (s *pgRevokedTokenStore) IsRevoked(ctx context.Context, jti string, userID int, issuedAt time.Time) (bool, error) {
+	var revoked bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE jti = $1)
+             OR EXISTS(SELECT 1 FROM user_token_revocations WHERE user_id = $2 AND revoked_before > $3)`,
+		jti, userID, issuedAt,
+	).Scan(&revoked)
+	return revoked, err
+}
+
+type memRefreshTokenStore struct {
+	m *memoryDB
+}
+
+func (s *memRefreshTokenStore) Create(ctx context.Context, token *models.RefreshToken) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for _, existing := range s.m.refreshTokens {
+		if existing.TokenHash == token.TokenHash {
+			return ErrConflict
+		}
+	}
+	token.ID = s.m.nextID("refresh_tokens")
+	token.CreatedAt = time.Now()
+	s.m.refreshTokens[token.ID] = *token
+	return nil
+}
+
+func (s *memRefreshTokenStore) GetByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for _, token := range s.m.refreshTokens {
+		if token.TokenHash == hash {
+			return &token, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memRefreshTokenStore) MarkUsed(ctx context.Context, id int) (bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	token, ok := s.m.refreshTokens[id]
+	if !ok || token.UsedAt != nil || token.RevokedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	s.m.refreshTokens[id] = token
+	return true, nil
+}
+
+func (s *memRefreshTokenStore) RevokeFamily(ctx context.Context, familyID string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.revokeRefreshTokens(func(t models.RefreshToken) bool { return t.FamilyID == familyID })
+	return nil
+}
+
+func (s *memRefreshTokenStore) RevokeAllForUser(ctx context.Context, userID int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.revokeRefreshTokens(func(t models.RefreshToken) bool { return t.UserID == userID })
+	return nil
+}
+
+// revokeRefreshTokens は条件に一致する未失効のトークンを失効させます（呼び出し側でロックを取得していること）
+func (m *memoryDB) revokeRefreshTokens(match func(models.RefreshToken) bool) {
+	now := time.Now()
+	for id, token := range m.refreshTokens {
+		if token.RevokedAt == nil && match(token) {
+			token.RevokedAt = &now
+			m.refreshTokens[id] = token
+		}
+	}
+}
+
+type memRevokedTokenStore struct {
+	m *memoryDB
+}
+
+func (s *memRevokedTokenStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	now := time.Now()
+	for id, exp := range s.m.revokedTokens {
+		if exp.Before(now) {
+			delete(s.m.revokedTokens, id)
+		}
+	}
+	s.m.revokedTokens[jti] = expiresAt
+	return nil
+}
+
+func (s *memRevokedTokenStore) RevokeUser(ctx context.Context, userID int, before time.Time) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if before.After(s.m.userCutoffs[userID]) {
+		s.m.userCutoffs[userID] = before
+	}
+	return nil
+}
+
+func (s *memRevokedTokenStore) IsRevoked(ctx context.Context, jti string, userID int, issuedAt time.Time) (bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.revokedTokens[jti]; ok {
+		return true, nil
+	}
+	cutoff, ok := s.m.userCutoffs[userID]
+	return ok && cutoff.After(issuedAt), nil
+}
@@ -0,0 +1,280 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"backend/models"
+)
+
+// TwoFactorStore はTOTPの設定とリカバリーコードの永続化を扱います（リカバリーコードはハッシュで保存します）
+type TwoFactorStore interface {
+	Get(ctx context.Context, userID int) (*models.TOTPEnrollment, error)
+	// SavePending は登録手続き中のシークレットを保存します（手続き中のものがあれば置き換えます）
+	SavePending(ctx context.Context, userID int, secret string) error
+	// Confirm は登録手続き中のTOTPを有効にし、リカバリーコードを登録します
+	// stepは確認に使ったタイムステップで、同じコードでのサインインを防ぐために記録します
+	Confirm(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error
+	// UseStep はstepが最後に使ったステップより新しい場合だけ記録してtrueを返します（同じコードの再利用防止）
+	UseStep(ctx context.Context, userID int, step int64) (bool, error)
+	// ReplaceRecoveryCodes は未使用・使用済みを含めてリカバリーコードを置き換えます
+	ReplaceRecoveryCodes(ctx context.Context, userID int, hashes []string) error
+	// UseRecoveryCode は未使用のリカバリーコードを使用済みにします（該当するコードがない場合はfalse）
+	UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID int) (int, error)
+	// Delete はTOTPの設定とリカバリーコードを削除します
+	Delete(ctx context.Context, userID int) error
+}
+
+type pgTwoFactorStore struct {
+	db *sql.DB
+}
+
+func (s *pgTwoFactorStore) Get(ctx context.Context, userID int) (*models.TOTPEnrollment, error) {
+	var e models.TOTPEnrollment
+	var confirmedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx,
+		`SELECT user_id, secret, confirmed_at, last_used_step, created_at FROM user_totp WHERE user_id = $1`,
+		userID,
+	).Scan(&e.UserID, &e.Secret, &confirmedAt, &e.LastUsedStep, &e.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if confirmedAt.Valid {
+		e.ConfirmedAt = &confirmedAt.Time
+	}
+	return &e, nil
+}
+
+func (s *pgTwoFactorStore) SavePending(ctx context.Context, userID int, secret string) error {
+	result, err := s.db.ExecContext(ctx,
+		`INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+         ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = 0, created_at = now()
+         WHERE user_totp.confirmed_at IS NULL`,
+		userID, secret,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (s *pgTwoFactorStore) Confirm(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // エラー時に自動ロールバック
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE user_totp SET confirmed_at = now(), last_used_step = $2
+         WHERE user_id = $1 AND confirmed_at IS NULL`,
+		userID, step,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *pgTwoFactorStore) UseStep(ctx context.Context, userID int, step int64) (bool, error) {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE user_totp SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2`,
+		userID, step,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+func (s *pgTwoFactorStore) ReplaceRecoveryCodes(ctx context.Context, userID int, hashes []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // エラー時に自動ロールバック
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, hashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int, hashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM totp_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO totp_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *pgTwoFactorStore) UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error) {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE totp_recovery_codes SET used_at = now()
+         WHERE id = (SELECT id FROM totp_recovery_codes
+                     WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+                     LIMIT 1 FOR UPDATE)`,
+		userID, hash,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+func (s *pgTwoFactorStore) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM totp_recovery_codes WHERE user_id = $1 AND used_at IS NULL`, userID,
+	).Scan(&n)
+	return n, err
+}
+
+func (s *pgTwoFactorStore) Delete(ctx context.Context, userID int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // エラー時に自動ロールバック
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM totp_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// recoveryCode はメモリストアのリカバリーコードです
+type recoveryCode struct {
+	hash string
+	used bool
+}
+
+type memTwoFactorStore struct {
+	m *memoryDB
+}
+
+func (s *memTwoFactorStore) Get(ctx context.Context, userID int) (*models.TOTPEnrollment, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	e, ok := s.m.totp[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &e, nil
+}
+
+func (s *memTwoFactorStore) SavePending(ctx context.Context, userID int, secret string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if e, ok := s.m.totp[userID]; ok && e.ConfirmedAt != nil {
+		return ErrConflict
+	}
+	s.m.totp[userID] = models.TOTPEnrollment{UserID: userID, Secret: secret, CreatedAt: time.Now()}
+	return nil
+}
+
+func (s *memTwoFactorStore) Confirm(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	e, ok := s.m.totp[userID]
+	if !ok || e.ConfirmedAt != nil {
+		return ErrNotFound
+	}
+	now := time.Now()
+	e.ConfirmedAt = &now
+	e.LastUsedStep = step
+	s.m.totp[userID] = e
+	s.m.setRecoveryCodes(userID, recoveryCodeHashes)
+	return nil
+}
+
+func (s *memTwoFactorStore) UseStep(ctx context.Context, userID int, step int64) (bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	e, ok := s.m.totp[userID]
+	if !ok || e.LastUsedStep >= step {
+		return false, nil
+	}
+	e.LastUsedStep = step
+	s.m.totp[userID] = e
+	return true, nil
+}
+
+func (s *memTwoFactorStore) ReplaceRecoveryCodes(ctx context.Context, userID int, hashes []string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.setRecoveryCodes(userID, hashes)
+	return nil
+}
+
+// setRecoveryCodes はリカバリーコードを置き換えます（呼び出し側でロックを取得していること）
+func (m *memoryDB) setRecoveryCodes(userID int, hashes []string) {
+	codes := make([]recoveryCode, len(hashes))
+	for i, hash := range hashes {
+		codes[i] = recoveryCode{hash: hash}
+	}
+	m.recoveryCodes[userID] = codes
+}
+
+func (s *memTwoFactorStore) UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	codes := s.m.recoveryCodes[userID]
+	for i := range codes {
+		if !codes[i].used && codes[i].hash == hash {
+			codes[i].used = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memTwoFactorStore) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	n := 0
+	for _, code := range s.m.recoveryCodes[userID] {
+		if !code.used {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *memTwoFactorStore) Delete(ctx context.Context, userID int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	delete(s.m.totp, userID)
+	delete(s.m.recoveryCodes, userID)
+	return nil
+}
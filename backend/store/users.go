@@ -0,0 +1,541 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/models"
+)
+
+// UserStore はユーザーの永続化を扱います
+type UserStore interface {
+	// Create はユーザーを登録し、採番したIDをuser.IDに設定します
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id int) (*models.User, error)
+	// GetByEmail はパスワードハッシュを含むユーザー情報を返します
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	Exists(ctx context.Context, id int) (bool, error)
+	List(ctx context.Context) ([]models.User, error)
+	// UpdatePassword はパスワードハッシュを更新します
+	UpdatePassword(ctx context.Context, id int, passwordHash string) error
+	// MarkEmailVerified はメールアドレスを確認済みにします（確認済みの場合は何もしません）
+	MarkEmailVerified(ctx context.Context, id int) error
+	// Search は条件に合うユーザーをID順に返します（totalはページングする前の件数）
+	Search(ctx context.Context, query UserSearch) (users []models.User, total int, err error)
+	UpdateRole(ctx context.Context, id int, role models.Role) error
+	// Suspend はアカウントを停止します（停止中の場合は理由だけを更新します）
+	Suspend(ctx context.Context, id int, reason string) error
+	Unsuspend(ctx context.Context, id int) error
+	// ScheduleDeletion はアカウントの削除をat以降に予約します
+	ScheduleDeletion(ctx context.Context, id int, at time.Time) error
+	CancelDeletion(ctx context.Context, id int) error
+	// ListDueForDeletion は削除予定日時がnow以前のユーザーのIDを返します
+	ListDueForDeletion(ctx context.Context, now time.Time) ([]int, error)
+	// Delete はユーザーとプロフィール（option_profiles・connections・linkを含む）などの関連データをまとめて削除します
+	Delete(ctx context.Context, id int) error
+}
+
+// UserSearch はユーザー検索の条件です
+type UserSearch struct {
+	Query     string      // 名前・メールアドレスの部分一致（大文字小文字を区別しない）
+	Role      models.Role // 空の場合はすべてのロール
+	Suspended *bool       // nilの場合は停止中かどうかを問わない
+	Limit     int
+	Offset    int
+}
+
+// userColumns はusersテーブルから取得する列です（scanUserと順序を合わせること）
+const userColumns = "id, name, email, password, email_verified_at, role, suspended_at, suspension_reason, deletion_scheduled_at"
+
+func scanUser(row rowScanner) (*models.User, error) {
+	var user models.User
+	var verifiedAt, suspendedAt, deletionAt sql.NullTime
+	if err := row.Scan(&user.ID, &user.Name, &user.Email, &user.PasswordHash, &verifiedAt,
+		&user.Role, &suspendedAt, &user.SuspensionReason, &deletionAt); err != nil {
+		return nil, err
+	}
+	if verifiedAt.Valid {
+		user.EmailVerifiedAt = &verifiedAt.Time
+	}
+	if suspendedAt.Valid {
+		user.SuspendedAt = &suspendedAt.Time
+	}
+	if deletionAt.Valid {
+		user.DeletionScheduledAt = &deletionAt.Time
+	}
+	return &user, nil
+}
+
+// defaultRole はロールが指定されていないユーザーを一般ユーザーにします
+func defaultRole(user *models.User) {
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+}
+
+type pgUserStore struct {
+	db *sql.DB
+}
+
+func (s *pgUserStore) Create(ctx context.Context, user *models.User) error {
+	defaultRole(user)
+	err := s.db.QueryRowContext(
+		ctx,
+		"INSERT INTO users (name, email, password, role) VALUES ($1, $2, $3, $4) RETURNING id",
+		user.Name, user.Email, user.PasswordHash, user.Role,
+	).Scan(&user.ID)
+	if isUniqueViolation(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+func (s *pgUserStore) GetByID(ctx context.Context, id int) (*models.User, error) {
+	user, err := scanUser(s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return user, nil
+}
+
+func (s *pgUserStore) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	user, err := scanUser(s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE email = $1", email))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return user, nil
+}
+
+func (s *pgUserStore) Exists(ctx context.Context, id int) (bool, error) {
+	var exists bool
+	err := s.db.QueryRowContext(
+		ctx,
+		"SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)",
+		id,
+	).Scan(&exists)
+	return exists, err
+}
+
+func (s *pgUserStore) List(ctx context.Context) ([]models.User, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+userColumns+" FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		user.PasswordHash = ""
+		users = append(users, *user)
+	}
+	return users, rows.Err()
+}
+
+func (s *pgUserStore) UpdatePassword(ctx context.Context, id int, passwordHash string) error {
+	result, err := s.db.ExecContext(ctx, "UPDATE users SET password = $1 WHERE id = $2", passwordHash, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *pgUserStore) MarkEmailVerified(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE users SET email_verified_at = COALESCE(email_verified_at, now()) WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *pgUserStore) Search(ctx context.Context, query UserSearch) ([]models.User, int, error) {
+	var conds []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if query.Query != "" {
+		// LIKEのワイルドカードは文字として扱う
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query.Query) + "%"
+		p := arg(pattern)
+		conds = append(conds, "(name ILIKE "+p+" OR email ILIKE "+p+")")
+	}
+	if query.Role != "" {
+		conds = append(conds, "role = "+arg(query.Role))
+	}
+	if query.Suspended != nil {
+		if *query.Suspended {
+			conds = append(conds, "suspended_at IS NOT NULL")
+		} else {
+			conds = append(conds, "suspended_at IS NULL")
+		}
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+userColumns+" FROM users"+where+" ORDER BY id LIMIT "+arg(query.Limit)+" OFFSET "+arg(query.Offset),
+		args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		user.PasswordHash = ""
+		users = append(users, *user)
+	}
+	return users, total, rows.Err()
+}
+
+func (s *pgUserStore) UpdateRole(ctx context.Context, id int, role models.Role) error {
+	return s.updateOne(ctx, "UPDATE users SET role = $2 WHERE id = $1", id, role)
+}
+
+func (s *pgUserStore) Suspend(ctx context.Context, id int, reason string) error {
+	return s.updateOne(ctx,
+		"UPDATE users SET suspended_at = COALESCE(suspended_at, now()), suspension_reason = $2 WHERE id = $1", id, reason)
+}
+
+func (s *pgUserStore) Unsuspend(ctx context.Context, id int) error {
+	return s.updateOne(ctx, "UPDATE users SET suspended_at = NULL, suspension_reason = '' WHERE id = $1", id)
+}
+
+func (s *pgUserStore) ScheduleDeletion(ctx context.Context, id int, at time.Time) error {
+	return s.updateOne(ctx, "UPDATE users SET deletion_scheduled_at = $2 WHERE id = $1", id, at)
+}
+
+func (s *pgUserStore) CancelDeletion(ctx context.Context, id int) error {
+	return s.updateOne(ctx, "UPDATE users SET deletion_scheduled_at = NULL WHERE id = $1", id)
+}
+
+func (s *pgUserStore) ListDueForDeletion(ctx context.Context, now time.Time) ([]int, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id FROM users WHERE deletion_scheduled_at <= $1 ORDER BY deletion_scheduled_at", now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (s *pgUserStore) Delete(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("トランザクションの開始に失敗しました: %v", err)
+	}
+	defer tx.Rollback() // エラー時に自動ロールバック
+
+	// プロフィールの関連データを先に削除（外部キー制約のため。DeleteProfileと同じテーブル）
+	// トークン・二要素認証・外部アカウントなどはON DELETE CASCADEで、監査ログはON DELETE SET NULLで処理される
+	const owned = "(SELECT id FROM profiles WHERE user_id = $1)"
+	steps := []struct{ table, query string }{
+		{"option_profiles", "DELETE FROM option_profiles WHERE profile_id IN " + owned},
+		{"connections", "DELETE FROM connections WHERE profile_id IN " + owned + " OR connect_user_profile_id IN " + owned},
+		{"link", "DELETE FROM link WHERE user_id = $1 OR profile_id IN " + owned},
+		{"profiles", "DELETE FROM profiles WHERE user_id = $1"},
+		{"login_attempts", "DELETE FROM login_attempts WHERE email = (SELECT email FROM users WHERE id = $1)"},
+	}
+	for _, step := range steps {
+		if _, err := tx.ExecContext(ctx, step.query, id); err != nil {
+			return fmt.Errorf("関連データの削除に失敗しました（%s）: %v", step.table, err)
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("ユーザーの削除に失敗しました: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+// updateOne はユーザー1件を更新します（存在しない場合はErrNotFound）
+func (s *pgUserStore) updateOne(ctx context.Context, query string, args ...interface{}) error {
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type memUserStore struct {
+	m *memoryDB
+}
+
+func (s *memUserStore) Create(ctx context.Context, user *models.User) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for _, u := range s.m.users {
+		if u.Email == user.Email {
+			return ErrConflict
+		}
+	}
+	defaultRole(user)
+	user.ID = s.m.nextID("users")
+	s.m.users[user.ID] = *user
+	return nil
+}
+
+func (s *memUserStore) GetByID(ctx context.Context, id int) (*models.User, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	user, ok := s.m.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (s *memUserStore) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for _, user := range s.m.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memUserStore) Exists(ctx context.Context, id int) (bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	_, ok := s.m.users[id]
+	return ok, nil
+}
+
+func (s *memUserStore) List(ctx context.Context) ([]models.User, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var users []models.User
+	for _, user := range s.m.users {
+		user.PasswordHash = ""
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+func (s *memUserStore) UpdatePassword(ctx context.Context, id int, passwordHash string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	user, ok := s.m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.PasswordHash = passwordHash
+	s.m.users[id] = user
+	return nil
+}
+
+func (s *memUserStore) MarkEmailVerified(ctx context.Context, id int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	user, ok := s.m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		s.m.users[id] = user
+	}
+	return nil
+}
+
+func (s *memUserStore) Search(ctx context.Context, query UserSearch) ([]models.User, int, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	q := strings.ToLower(query.Query)
+	var matched []models.User
+	for _, user := range s.m.users {
+		if q != "" && !strings.Contains(strings.ToLower(user.Name), q) && !strings.Contains(strings.ToLower(user.Email), q) {
+			continue
+		}
+		if query.Role != "" && user.Role != query.Role {
+			continue
+		}
+		if query.Suspended != nil && user.Suspended() != *query.Suspended {
+			continue
+		}
+		user.PasswordHash = ""
+		matched = append(matched, user)
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID < matched[j].ID })
+
+	users := []models.User{}
+	for i := query.Offset; i < len(matched) && len(users) < query.Limit; i++ {
+		users = append(users, matched[i])
+	}
+	return users, len(matched), nil
+}
+
+func (s *memUserStore) UpdateRole(ctx context.Context, id int, role models.Role) error {
+	return s.update(id, func(user *models.User) { user.Role = role })
+}
+
+func (s *memUserStore) Suspend(ctx context.Context, id int, reason string) error {
+	return s.update(id, func(user *models.User) {
+		if user.SuspendedAt == nil {
+			now := time.Now()
+			user.SuspendedAt = &now
+		}
+		user.SuspensionReason = reason
+	})
+}
+
+func (s *memUserStore) Unsuspend(ctx context.Context, id int) error {
+	return s.update(id, func(user *models.User) {
+		user.SuspendedAt = nil
+		user.SuspensionReason = ""
+	})
+}
+
+func (s *memUserStore) ScheduleDeletion(ctx context.Context, id int, at time.Time) error {
+	return s.update(id, func(user *models.User) { user.DeletionScheduledAt = &at })
+}
+
+func (s *memUserStore) CancelDeletion(ctx context.Context, id int) error {
+	return s.update(id, func(user *models.User) { user.DeletionScheduledAt = nil })
+}
+
+func (s *memUserStore) ListDueForDeletion(ctx context.Context, now time.Time) ([]int, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var ids []int
+	for _, user := range s.m.users {
+		if user.DeletionScheduledAt != nil && !user.DeletionScheduledAt.After(now) {
+			ids = append(ids, user.ID)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+func (s *memUserStore) Delete(ctx context.Context, id int) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	user, ok := s.m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	for profileID, profile := range s.m.profiles {
+		if profile.UserID == id {
+			s.m.deleteProfile(profileID)
+		}
+	}
+	for linkID, link := range s.m.links {
+		if link.UsersID == id {
+			delete(s.m.links, linkID)
+		}
+	}
+
+	// ON DELETE CASCADE・SET NULL の再現
+	for tokenID, token := range s.m.refreshTokens {
+		if token.UserID == id {
+			delete(s.m.refreshTokens, tokenID)
+		}
+	}
+	for sessionID, session := range s.m.sessions {
+		if session.UserID == id {
+			delete(s.m.sessions, sessionID)
+		}
+	}
+	for tokenID, token := range s.m.oneTimeTokens {
+		if token.UserID == id {
+			delete(s.m.oneTimeTokens, tokenID)
+		}
+	}
+	for identityID, identity := range s.m.identities {
+		if identity.UserID == id {
+			delete(s.m.identities, identityID)
+		}
+	}
+	for tokenID, token := range s.m.accessTokens {
+		if token.UserID == id {
+			delete(s.m.accessTokens, tokenID)
+		}
+	}
+	for logID, log := range s.m.auditLogs {
+		if log.ActorUserID != nil && *log.ActorUserID == id {
+			log.ActorUserID = nil
+		}
+		if log.TargetUserID != nil && *log.TargetUserID == id {
+			log.TargetUserID = nil
+		}
+		s.m.auditLogs[logID] = log
+	}
+	delete(s.m.userCutoffs, id)
+	delete(s.m.totp, id)
+	delete(s.m.recoveryCodes, id)
+	delete(s.m.loginAttempts, user.Email)
+	delete(s.m.users, id)
+	return nil
+}
+
+// update はユーザー1件を更新します（存在しない場合はErrNotFound）
+func (s *memUserStore) update(id int, apply func(user *models.User)) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	user, ok := s.m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	apply(&user)
+	s.m.users[id] = user
+	return nil
+}
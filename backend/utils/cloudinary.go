@@ -5,6 +5,9 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path"
+	"regexp"
+	"strings"
 
 	"github.com/cloudinary/cloudinary-go/v2"
 	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
@@ -83,3 +86,24 @@ func (c *CloudinaryClient) DeleteImage(ctx context.Context, publicID string) err
 	}
 	return nil
 }
+
+// cloudinaryVersion はURL中のバージョン（v1234567890）部分です
+var cloudinaryVersion = regexp.MustCompile(`^v[0-9]+$`)
+
+// CloudinaryPublicID はCloudinaryの画像URLからpublic ID（フォルダを含み拡張子を除いたもの）を取り出します
+// CloudinaryのURLでない場合は空文字を返します
+func CloudinaryPublicID(imageURL string) string {
+	if !strings.HasPrefix(imageURL, "https://res.cloudinary.com/") {
+		return ""
+	}
+	_, rest, ok := strings.Cut(imageURL, "/upload/")
+	if !ok {
+		return ""
+	}
+	parts := strings.Split(rest, "/")
+	if len(parts) > 1 && cloudinaryVersion.MatchString(parts[0]) {
+		parts = parts[1:]
+	}
+	publicID := strings.Join(parts, "/")
+	return strings.TrimSuffix(publicID, path.Ext(publicID))
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+)
+
+// ErrInvalidEmail はメールアドレスの形式が正しくない場合に返されます
+var ErrInvalidEmail = errors.New("メールアドレスの形式が正しくありません")
+
+// NormalizeEmail はメールアドレスを検証し、前後の空白を除いて小文字に揃えた形を返します
+// 表示名付き（"Alice <alice@example.com>"）やドメインにドットを含まないアドレスは受け付けません
+func NormalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" || len(email) > 254 {
+		return "", ErrInvalidEmail
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return "", ErrInvalidEmail
+	}
+
+	at := strings.LastIndex(email, "@")
+	local, domain := email[:at], email[at+1:]
+	if len(local) > 64 || !strings.Contains(domain, ".") ||
+		strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") || strings.Contains(domain, "..") {
+		return "", ErrInvalidEmail
+	}
+	return email, nil
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// 交換トークンの有効期間の範囲（リクエストで指定できる値）
+const (
+	MinExchangeTokenTTL = 30 * time.Second
+	MaxExchangeTokenTTL = 24 * time.Hour
+)
+
+// MaxMutualExchangeTokenTTL は相互交換（相手から自分へのコネクションも作成する交換）に使える交換トークンの有効期間の上限です
+// 相互交換には、この期間内の1回限りのトークンだけを所有者の同意として扱います
+const MaxMutualExchangeTokenTTL = 10 * time.Minute
+
+// AllowsMutualExchange は交換トークンが相互交換に使える（1回限りで有効期間が短い）かどうかを返します
+func (c *ExchangeClaims) AllowsMutualExchange() bool {
+	if !c.SingleUse || c.IssuedAt == nil || c.ExpiresAt == nil {
+		return false
+	}
+	return c.ExpiresAt.Sub(c.IssuedAt.Time) <= MaxMutualExchangeTokenTTL
+}
+
+// ExchangeClaims はQRコードに埋め込む交換トークンのクレームです
+// 連番のIDを漏らさないよう、プロフィールは公開IDで指定します
+type ExchangeClaims struct {
+	ProfilePublicID string `json:"pid"`
+	SingleUse       bool   `json:"single_use,omitempty"`
+	TokenUse        string `json:"token_use"`
+	jwt.RegisteredClaims
+}
+
+// ExchangeTokenTTL は交換トークンの既定の有効期間を返します（EXCHANGE_TOKEN_EXPIRES_SECONDS、デフォルト5分）
+func ExchangeTokenTTL() time.Duration {
+	expiresSeconds := 300
+	if s := os.Getenv("EXCHANGE_TOKEN_EXPIRES_SECONDS"); s != "" {
+		if seconds, err := strconv.Atoi(s); err == nil && seconds > 0 {
+			expiresSeconds = seconds
+		}
+	}
+	return time.Duration(expiresSeconds) * time.Second
+}
+
+// GenerateExchangeToken はプロフィールの交換トークンを発行します
+// singleUseの場合は1回使うと使用済みになります（使用済みのjtiはDBで管理します）
+func (km *KeyManager) GenerateExchangeToken(profilePublicID string, singleUse bool, ttl time.Duration) (string, *ExchangeClaims, error) {
+	now := time.Now()
+	claims := &ExchangeClaims{
+		ProfilePublicID: profilePublicID,
+		SingleUse:       singleUse,
+		TokenUse:        TokenUseExchange,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+	token, err := km.Sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, claims, nil
+}
+
+// ValidateExchangeToken は交換トークンの署名・有効期限・用途を検証してクレームを返します
+func (km *KeyManager) ValidateExchangeToken(tokenString string) (*ExchangeClaims, error) {
+	var claims ExchangeClaims
+	if err := km.Parse(tokenString, &claims); err != nil {
+		return nil, err
+	}
+	if claims.TokenUse != TokenUseExchange {
+		return nil, errors.New("unexpected token_use")
+	}
+	if claims.ID == "" || claims.ProfilePublicID == "" || claims.ExpiresAt == nil {
+		return nil, errors.New("exchange token is missing claims")
+	}
+	return &claims, nil
+}
@@ -7,58 +7,96 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+func init() {
+	// ユーザー単位の一括失効（パスワードリセットなど）の直後に発行したトークンと区別できるよう、iatなどをミリ秒精度にする
+	jwt.TimePrecision = time.Millisecond
+}
+
+// トークンの用途（token_use クレーム）。用途の違うトークンを取り違えて受け付けないようにします
+const (
+	TokenUseAccess             = "access"
+	TokenUseTwoFactorChallenge = "2fa_challenge"
+	TokenUseExchange           = "exchange"
+)
+
+// TwoFactorChallengeTTL は二要素認証のチャレンジトークンの有効期間です
+const TwoFactorChallengeTTL = 5 * time.Minute
+
 type Claims struct {
-	UserID int    `json:"user_id"`
-	Email  string `json:"email"`
+	UserID   int    `json:"user_id"`
+	Email    string `json:"email"`
+	TokenUse string `json:"token_use"`
+	// SessionID はアクセストークンを発行したセッション（sid）です。セッションを失効させるとトークンも使えなくなります
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(userID int, email string) (string, error) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		return "", errors.New("JWT_SECRET not set")
-	}
-
-	expiresHours := 24
-	if h := os.Getenv("JWT_EXPIRES_HOURS"); h != "" {
-		if hours, err := strconv.Atoi(h); err == nil {
-			expiresHours = hours
+// AccessTokenTTL はアクセストークンの有効期間を返します（JWT_ACCESS_EXPIRES_MINUTES、デフォルト15分）
+func AccessTokenTTL() time.Duration {
+	expiresMinutes := 15
+	if m := os.Getenv("JWT_ACCESS_EXPIRES_MINUTES"); m != "" {
+		if minutes, err := strconv.Atoi(m); err == nil && minutes > 0 {
+			expiresMinutes = minutes
 		}
 	}
+	return time.Duration(expiresMinutes) * time.Minute
+}
+
+// GenerateJWT は短命のアクセストークンを発行します
+// 失効リストで個別に無効化できるよう、トークンごとに一意なjtiを付与します
+func (km *KeyManager) GenerateJWT(userID int, email, sessionID string) (string, error) {
+	return km.generate(userID, email, sessionID, TokenUseAccess, AccessTokenTTL())
+}
+
+// ValidateJWT はアクセストークンを検証してクレームを返します
+func (km *KeyManager) ValidateJWT(tokenString string) (*Claims, error) {
+	return km.validate(tokenString, TokenUseAccess)
+}
 
+// GenerateChallengeToken はパスワード認証を通過したユーザーに、二要素認証の入力用トークンを発行します
+// アクセストークンとしては使えません
+func (km *KeyManager) GenerateChallengeToken(userID int, email string) (string, error) {
+	return km.generate(userID, email, "", TokenUseTwoFactorChallenge, TwoFactorChallengeTTL)
+}
+
+// ValidateChallengeToken は二要素認証のチャレンジトークンを検証してクレームを返します
+func (km *KeyManager) ValidateChallengeToken(tokenString string) (*Claims, error) {
+	return km.validate(tokenString, TokenUseTwoFactorChallenge)
+}
+
+func (km *KeyManager) generate(userID int, email, sessionID, use string, ttl time.Duration) (string, error) {
+	now := time.Now()
 	claims := Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:    userID,
+		Email:     email,
+		TokenUse:  use,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(expiresHours) * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ID:        uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return km.Sign(claims)
 }
 
-func ValidateJWT(tokenString string) (*Claims, error) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		return nil, errors.New("JWT_SECRET not set")
-	}
-
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
-
-	if err != nil {
+func (km *KeyManager) validate(tokenString, use string) (*Claims, error) {
+	var claims Claims
+	if err := km.Parse(tokenString, &claims); err != nil {
 		return nil, err
 	}
-
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	if claims.TokenUse != use {
+		return nil, errors.New("unexpected token_use")
 	}
-
-	return nil, errors.New("invalid token")
+	if claims.ID == "" {
+		return nil, errors.New("token has no jti")
+	}
+	if claims.ExpiresAt == nil {
+		return nil, errors.New("token has no exp")
+	}
+	return &claims, nil
 }
@@ -0,0 +1,270 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 鍵ファイルの拡張子（<kid>.pem は秘密鍵、<kid>.pub.pem は検証専用の公開鍵）
+const (
+	privateKeySuffix = ".pem"
+	publicKeySuffix  = ".pub.pem"
+)
+
+// signingKey は1つのkidに対応する鍵です（秘密鍵がない場合は検証専用）
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// KeyManager はJWTの署名鍵と検証鍵を管理します
+// 署名には1つのアクティブな鍵を使い、検証はkidヘッダーで選んだ鍵と、その鍵のアルゴリズムでのみ行います
+// ローテーション中は古い鍵も検証用として保持し、JWKSで公開します
+type KeyManager struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+// NewKeyManagerFromEnv は環境変数から鍵を読み込みます
+// JWT_KEYS_DIR が未設定の場合は一時的なEd25519鍵を生成します（再起動すると発行済みトークンは無効になります）
+func NewKeyManagerFromEnv() (*KeyManager, error) {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		fmt.Println("Warning: JWT_KEYS_DIR が設定されていないため、一時的な署名鍵を使用します")
+		return NewEphemeralKeyManager()
+	}
+	return LoadKeyManager(dir, os.Getenv("JWT_ACTIVE_KID"))
+}
+
+// NewEphemeralKeyManager はメモリ上で生成したEd25519鍵1つだけを持つKeyManagerを作成します（開発・テスト用）
+func NewEphemeralKeyManager() (*KeyManager, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("署名鍵の生成に失敗しました: %v", err)
+	}
+	key, err := newSigningKey("", private, private.Public())
+	if err != nil {
+		return nil, err
+	}
+	key.kid = thumbprint(key)
+	return &KeyManager{active: key, keys: map[string]*signingKey{key.kid: key}}, nil
+}
+
+// LoadKeyManager はディレクトリ内のPEMファイルから鍵を読み込みます
+// ファイル名（拡張子を除く）がkidになります。activeKIDが空の場合は、秘密鍵を持つ鍵のうちkidが辞書順で最後のものを署名に使います
+func LoadKeyManager(dir, activeKID string) (*KeyManager, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("鍵ディレクトリの読み込みに失敗しました: %v", err)
+	}
+
+	km := &KeyManager{keys: map[string]*signingKey{}}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, privateKeySuffix) {
+			continue
+		}
+		key, err := loadKeyFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		if _, exists := km.keys[key.kid]; exists {
+			return nil, fmt.Errorf("kid %q の鍵が重複しています", key.kid)
+		}
+		km.keys[key.kid] = key
+	}
+
+	if activeKID == "" {
+		var kids []string
+		for kid, key := range km.keys {
+			if key.private != nil {
+				kids = append(kids, kid)
+			}
+		}
+		if len(kids) == 0 {
+			return nil, errors.New("署名に使える秘密鍵がありません")
+		}
+		sort.Strings(kids)
+		activeKID = kids[len(kids)-1]
+	}
+
+	active, ok := km.keys[activeKID]
+	if !ok || active.private == nil {
+		return nil, fmt.Errorf("kid %q の秘密鍵が見つかりません", activeKID)
+	}
+	km.active = active
+	return km, nil
+}
+
+// loadKeyFile はPEMファイルを1つ読み込みます
+func loadKeyFile(path string) (*signingKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("PEM形式ではありません")
+	}
+
+	name := filepath.Base(path)
+	if strings.HasSuffix(name, publicKeySuffix) {
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("公開鍵の読み込みに失敗しました: %v", err)
+		}
+		return newSigningKey(strings.TrimSuffix(name, publicKeySuffix), nil, public)
+	}
+
+	var private interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("秘密鍵の読み込みに失敗しました: %v", err)
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("対応していない鍵の種類です")
+	}
+	return newSigningKey(strings.TrimSuffix(name, privateKeySuffix), signer, signer.Public())
+}
+
+// newSigningKey は鍵の種類から署名アルゴリズムを決定します（RSAはRS256、Ed25519はEdDSA）
+func newSigningKey(kid string, private crypto.Signer, public crypto.PublicKey) (*signingKey, error) {
+	key := &signingKey{kid: kid, private: private, public: public}
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 2048 {
+			return nil, errors.New("RSA鍵は2048ビット以上が必要です")
+		}
+		key.method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, errors.New("対応していない鍵の種類です（RSAまたはEd25519のみ）")
+	}
+	return key, nil
+}
+
+// ActiveKID は署名に使う鍵のkidを返します
+func (km *KeyManager) ActiveKID() string {
+	return km.active.kid
+}
+
+// Sign はアクティブな鍵でクレームに署名し、kidヘッダーを付けたトークンを返します
+func (km *KeyManager) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(km.active.method, claims)
+	token.Header["kid"] = km.active.kid
+	return token.SignedString(km.active.private)
+}
+
+// Parse はkidヘッダーで選んだ鍵でトークンを検証し、claimsにデコードします
+// 鍵に対応するアルゴリズム以外（HS256やnoneなど）で署名されたトークンと、有効期限（exp）のないトークンは拒否します
+func (km *KeyManager) Parse(tokenString string, claims jwt.Claims) error {
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := km.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown kid %q", kid)
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %q for kid %q", token.Method.Alg(), kid)
+		}
+		return key.public, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return errors.New("invalid token")
+	}
+	return nil
+}
+
+// JWK はJSON Web Key（RFC 7517）の公開鍵表現です
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 (OKP)
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+// JWKSet は /.well-known/jwks.json で返す鍵セットです
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS は検証に使うすべての公開鍵をkid順に返します
+func (km *KeyManager) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range km.keys {
+		set.Keys = append(set.Keys, key.jwk())
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
+
+func (key *signingKey) jwk() JWK {
+	jwk := JWK{KeyID: key.kid, Use: "sig", Algorithm: key.method.Alg()}
+	switch pub := key.public.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+	return jwk
+}
+
+// thumbprint はJWK Thumbprint（RFC 7638）を返します（生成した鍵のkidに使います）
+func thumbprint(key *signingKey) string {
+	jwk := key.jwk()
+	var members interface{}
+	if jwk.KeyType == "RSA" {
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N}
+	} else {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+	}
+	raw, _ := json.Marshal(members)
+	sum := sha256.Sum256(raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
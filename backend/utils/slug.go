@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// カスタムURL（slug）の長さの制限
+// 公開IDは22文字なので、最大長をそれより短くして取り違えが起きないようにしています
+const (
+	SlugMinLength = 3
+	SlugMaxLength = 20
+)
+
+var (
+	ErrSlugFormat    = errors.New("URLは3〜20文字の半角英小文字・数字・ハイフンで指定してください（先頭と末尾は英数字、数字だけは不可）")
+	ErrSlugReserved  = errors.New("このURLは予約されているため使用できません")
+	ErrSlugProfanity = errors.New("このURLには使用できない語句が含まれています")
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]*[a-z0-9])?$`)
+
+// reservedSlugs はページやAPIのパスと紛らわしいため使えない語です
+var reservedSlugs = map[string]bool{
+	"about": true, "account": true, "admin": true, "api": true, "auth": true, "edit": true,
+	"exchange": true, "forgot-password": true, "health": true, "help": true, "icon": true,
+	"listpage": true, "login": true, "logout": true, "me": true, "mypage": true, "new": true,
+	"null": true, "oauth": true, "privacy": true, "profile": true, "profiles": true, "qrpage": true,
+	"qrsona": true, "reset-password": true, "root": true, "settings": true, "signin": true,
+	"signout": true, "signup": true, "static": true, "support": true, "system": true, "terms": true,
+	"undefined": true, "uploads": true, "user": true, "users": true, "verify-email": true, "www": true,
+}
+
+// profaneWords はslugに含めることを禁止する語です（数字やハイフンによる言い換えも検出します）
+var profaneWords = []string{
+	"asshole", "bastard", "bitch", "chinko", "cunt", "faggot", "fuck", "manko",
+	"nazi", "nigga", "nigger", "porn", "shit", "slut", "whore",
+}
+
+// leetReplacer は1→i、0→oのような言い換えを元の文字に戻します
+var leetReplacer = strings.NewReplacer("-", "", "0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "8", "b")
+
+// NormalizeSlug はカスタムURLを小文字に揃えて検証します
+func NormalizeSlug(slug string) (string, error) {
+	slug = strings.ToLower(strings.TrimSpace(slug))
+	if len(slug) < SlugMinLength || len(slug) > SlugMaxLength || !slugPattern.MatchString(slug) ||
+		strings.Contains(slug, "--") || strings.Trim(slug, "0123456789") == "" {
+		return "", ErrSlugFormat
+	}
+	if reservedSlugs[slug] {
+		return "", ErrSlugReserved
+	}
+	plain := leetReplacer.Replace(slug)
+	for _, word := range profaneWords {
+		if strings.Contains(plain, word) {
+			return "", ErrSlugProfanity
+		}
+	}
+	return slug, nil
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GenerateOpaqueToken はURLセーフなランダムトークンと、保存用のハッシュを返します
+// 平文のトークンはクライアントにのみ渡し、DBにはハッシュだけを保存します
+func GenerateOpaqueToken() (token string, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("トークンの生成に失敗しました: %v", err)
+	}
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// HashToken はトークンのSHA-256ハッシュを16進文字列で返します
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RefreshTokenTTL はリフレッシュトークンの有効期間を返します（REFRESH_TOKEN_EXPIRES_HOURS、デフォルト30日）
+func RefreshTokenTTL() time.Duration {
+	expiresHours := 24 * 30
+	if h := os.Getenv("REFRESH_TOKEN_EXPIRES_HOURS"); h != "" {
+		if hours, err := strconv.Atoi(h); err == nil && hours > 0 {
+			expiresHours = hours
+		}
+	}
+	return time.Duration(expiresHours) * time.Hour
+}
+
+// PasswordResetTTL はパスワードリセットトークンの有効期間を返します（PASSWORD_RESET_EXPIRES_MINUTES、デフォルト60分）
+func PasswordResetTTL() time.Duration {
+	expiresMinutes := 60
+	if m := os.Getenv("PASSWORD_RESET_EXPIRES_MINUTES"); m != "" {
+		if minutes, err := strconv.Atoi(m); err == nil && minutes > 0 {
+			expiresMinutes = minutes
+		}
+	}
+	return time.Duration(expiresMinutes) * time.Minute
+}
+
+// ConnectionRequestTTL はコネクション申請の有効期間を返します（CONNECTION_REQUEST_EXPIRES_DAYS、デフォルト14日）
+func ConnectionRequestTTL() time.Duration {
+	expiresDays := 14
+	if d := os.Getenv("CONNECTION_REQUEST_EXPIRES_DAYS"); d != "" {
+		if days, err := strconv.Atoi(d); err == nil && days > 0 {
+			expiresDays = days
+		}
+	}
+	return time.Duration(expiresDays) * 24 * time.Hour
+}
+
+// FrontendURL はメール本文のリンクに使うフロントエンドのURLを返します（FRONTEND_URL、デフォルト http://localhost:3000）
+func FrontendURL() string {
+	if u := os.Getenv("FRONTEND_URL"); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	return "http://localhost:3000"
+}
+
+// PersonalAccessTokenPrefix はパーソナルアクセストークンの接頭辞です（JWTと区別し、漏えい検知のスキャンにも使えます）
+const PersonalAccessTokenPrefix = "qrs_pat_"
+
+// GeneratePersonalAccessToken はパーソナルアクセストークンと保存用のハッシュ、一覧表示用の先頭部分を返します
+func GeneratePersonalAccessToken() (token, hash, displayPrefix string, err error) {
+	raw, _, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", "", "", err
+	}
+	token = PersonalAccessTokenPrefix + raw
+	return token, HashToken(token), token[:len(PersonalAccessTokenPrefix)+6], nil
+}
+
+// IsPersonalAccessToken はBearerトークンがパーソナルアクセストークンの形式かどうかを返します
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+// GeneratePublicID は公開URLやQRコードに使う推測できないプロフィールIDを返します（URLセーフな22文字）
+func GeneratePublicID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("公開IDの生成に失敗しました: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
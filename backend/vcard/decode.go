@@ -0,0 +1,210 @@
+package vcard
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"mime/quotedprintable"
+	"strings"
+)
+
+// structuredProperties は値を ; で区切られた要素として扱うプロパティです
+var structuredProperties = map[string]bool{"N": true, "ADR": true, "ORG": true, "GENDER": true}
+
+// Decode はvCard 3.0・4.0形式のカードをすべて読み込みます
+// 折り返し行を戻し、グループ名（item1.URL の item1.）を取り除きます
+// 3.0のTYPEを省略したパラメーター（EMAIL;WORK:）とQUOTED-PRINTABLEの値にも対応します
+// BEGIN:VCARD〜END:VCARDの対応が取れていない場合や、プロパティの形式が不正な場合はエラーを返します（エラーには行番号を含めます）
+func Decode(r io.Reader) ([]*Card, error) {
+	lines, err := unfoldLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var cards []*Card
+	var card *Card
+	for _, line := range lines {
+		if strings.TrimSpace(line.text) == "" {
+			continue
+		}
+		prop, err := parseLine(line.text)
+		if err != nil {
+			return nil, fmt.Errorf("%d行目: %v", line.number, err)
+		}
+		switch {
+		case prop.Name == "BEGIN" && strings.EqualFold(prop.Value(), "VCARD"):
+			if card != nil {
+				return nil, fmt.Errorf("%d行目: END:VCARDがありません", line.number)
+			}
+			card = &Card{}
+		case prop.Name == "END" && strings.EqualFold(prop.Value(), "VCARD"):
+			if card == nil {
+				return nil, fmt.Errorf("%d行目: BEGIN:VCARDがありません", line.number)
+			}
+			cards = append(cards, card)
+			card = nil
+		case card == nil:
+			return nil, fmt.Errorf("%d行目: BEGIN:VCARDの外にプロパティがあります", line.number)
+		case prop.Name == "VERSION":
+			card.Version = prop.Value()
+		default:
+			card.Properties = append(card.Properties, prop)
+		}
+	}
+	if card != nil {
+		return nil, fmt.Errorf("END:VCARDがありません")
+	}
+	return cards, nil
+}
+
+type rawLine struct {
+	number int // 論理行の開始行番号
+	text   string
+}
+
+// unfoldLines は行を読み込み、空白・タブで始まる継続行とQUOTED-PRINTABLEのソフト改行（行末の=）を前の行に連結します
+func unfoldLines(r io.Reader) ([]rawLine, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	var lines []rawLine
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if number == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if len(lines) > 0 {
+			last := &lines[len(lines)-1]
+			if text != "" && (text[0] == ' ' || text[0] == '\t') {
+				last.text += text[1:]
+				continue
+			}
+			if isQuotedPrintable(last.text) && strings.HasSuffix(last.text, "=") {
+				last.text = last.text[:len(last.text)-1] + text
+				continue
+			}
+		}
+		lines = append(lines, rawLine{number: number, text: text})
+	}
+	return lines, scanner.Err()
+}
+
+func isQuotedPrintable(line string) bool {
+	colon := strings.IndexByte(line, ':')
+	return colon > 0 && strings.Contains(strings.ToUpper(line[:colon]), "QUOTED-PRINTABLE")
+}
+
+// parseLine は1行を名前・パラメーター・値に分解します
+func parseLine(line string) (Property, error) {
+	colon := -1
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		if line[i] == '"' {
+			quoted = !quoted
+		} else if line[i] == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon <= 0 {
+		return Property{}, fmt.Errorf("プロパティの形式が不正です")
+	}
+
+	parts := splitUnquoted(line[:colon], ';')
+	name := strings.ToUpper(parts[0])
+	if dot := strings.LastIndexByte(name, '.'); dot >= 0 {
+		name = name[dot+1:]
+	}
+	if name == "" {
+		return Property{}, fmt.Errorf("プロパティ名がありません")
+	}
+
+	prop := Property{Name: name}
+	for _, param := range parts[1:] {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok {
+			// vCard 2.1・3.0の EMAIL;WORK: のような省略形はTYPEとして扱う
+			key, value = "TYPE", param
+		}
+		prop.Params = append(prop.Params, Param{Name: strings.ToUpper(key), Value: decodeParamValue(value)})
+	}
+
+	value := line[colon+1:]
+	if strings.EqualFold(prop.Param("ENCODING"), "QUOTED-PRINTABLE") {
+		decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(value)))
+		if err != nil {
+			return Property{}, fmt.Errorf("QUOTED-PRINTABLEの値が不正です")
+		}
+		value = string(decoded)
+	}
+	if structuredProperties[name] {
+		for _, v := range splitEscaped(value, ';') {
+			prop.Values = append(prop.Values, unescapeText(v))
+		}
+	} else {
+		prop.Values = []string{unescapeText(value)}
+	}
+	return prop, nil
+}
+
+// splitUnquoted はダブルクォートの外にある区切り文字で分割します
+func splitUnquoted(s string, sep byte) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' {
+			quoted = !quoted
+		} else if s[i] == sep && !quoted {
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// splitEscaped はバックスラッシュでエスケープされていない区切り文字で分割します
+func splitEscaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+		} else if s[i] == sep {
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unescapeText は \n・\N を改行に戻し、それ以外の \x は x に戻します（3.0で使われる \: にも対応）
+func unescapeText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if s[i] == 'n' || s[i] == 'N' {
+			b.WriteByte('\n')
+		} else {
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// decodeParamValue はダブルクォートを外し、RFC 6868の^エスケープを戻します
+func decodeParamValue(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	return strings.NewReplacer("^^", "^", "^n", "\n", "^N", "\n", "^'", `"`).Replace(s)
+}
@@ -0,0 +1,166 @@
+package vcard
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxLineOctets はRFC 6350で推奨される1行の最大オクテット数です（改行を除く）
+const maxLineOctets = 75
+
+// Param はプロパティのパラメーター（TYPE=work など）を表します
+type Param struct {
+	Name  string
+	Value string
+}
+
+// Property はvCardの1プロパティを表します
+// Valuesは構造化された値（N・ADRなど）の各要素で、書き出し時に ; で連結します
+type Property struct {
+	Name   string
+	Params []Param
+	Values []string
+	// URIはURLやdata: URIなどエスケープせずにそのまま書き出す値の場合にtrueにします
+	URI bool
+}
+
+// Value は最初の要素の値を返します
+func (p Property) Value() string {
+	if len(p.Values) == 0 {
+		return ""
+	}
+	return p.Values[0]
+}
+
+// Param は指定した名前のパラメーターの値を返します（大文字小文字を区別しません）
+func (p Property) Param(name string) string {
+	for _, param := range p.Params {
+		if strings.EqualFold(param.Name, name) {
+			return param.Value
+		}
+	}
+	return ""
+}
+
+// Card は1件の連絡先（BEGIN:VCARD〜END:VCARD）を表します
+// BEGIN・VERSION・ENDは書き出し時に付けるためPropertiesには含めません
+type Card struct {
+	// Versionは読み込んだカードのVERSIONです（書き出し時は常に4.0）
+	Version    string
+	Properties []Property
+}
+
+// AddText はテキストのプロパティを追加します（空の値は追加しません）
+func (c *Card) AddText(name, value string, params ...Param) {
+	if value == "" {
+		return
+	}
+	c.Properties = append(c.Properties, Property{Name: name, Params: params, Values: []string{value}})
+}
+
+// AddURI はURIのプロパティを追加します（空の値は追加しません）
+func (c *Card) AddURI(name, value string, params ...Param) {
+	if value == "" {
+		return
+	}
+	c.Properties = append(c.Properties, Property{Name: name, Params: params, Values: []string{value}, URI: true})
+}
+
+// Get は指定した名前の最初のプロパティを返します
+func (c *Card) Get(name string) (Property, bool) {
+	for _, prop := range c.Properties {
+		if strings.EqualFold(prop.Name, name) {
+			return prop, true
+		}
+	}
+	return Property{}, false
+}
+
+// All は指定した名前のプロパティをすべて返します
+func (c *Card) All(name string) []Property {
+	var props []Property
+	for _, prop := range c.Properties {
+		if strings.EqualFold(prop.Name, name) {
+			props = append(props, prop)
+		}
+	}
+	return props
+}
+
+// Encode はvCard 4.0（RFC 6350）形式でカードを書き出します（複数のカードは続けて書き出します）
+func Encode(w io.Writer, cards ...*Card) error {
+	bw := bufio.NewWriter(w)
+	for _, card := range cards {
+		writeLine(bw, "BEGIN:VCARD")
+		writeLine(bw, "VERSION:4.0")
+		for _, prop := range card.Properties {
+			writeLine(bw, encodeProperty(prop))
+		}
+		writeLine(bw, "END:VCARD")
+	}
+	return bw.Flush()
+}
+
+func encodeProperty(prop Property) string {
+	var b strings.Builder
+	b.WriteString(strings.ToUpper(prop.Name))
+	for _, param := range prop.Params {
+		b.WriteString(";")
+		b.WriteString(strings.ToUpper(param.Name))
+		b.WriteString("=")
+		b.WriteString(encodeParamValue(param.Value))
+	}
+	b.WriteString(":")
+	for i, value := range prop.Values {
+		if i > 0 {
+			b.WriteString(";")
+		}
+		if prop.URI {
+			b.WriteString(value)
+		} else {
+			b.WriteString(escapeText(value))
+		}
+	}
+	return b.String()
+}
+
+// escapeText はテキストの値の \ , ; と改行をエスケープします
+func escapeText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		",", `\,`,
+		";", `\;`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
+
+// encodeParamValue は : ; , を含むパラメーターの値をダブルクォートで囲みます（RFC 6868の^エスケープも行います）
+func encodeParamValue(s string) string {
+	s = strings.NewReplacer("^", "^^", "\n", "^n", `"`, "^'").Replace(s)
+	if strings.ContainsAny(s, ":;,") {
+		return `"` + s + `"`
+	}
+	return s
+}
+
+// writeLine は75オクテットを超える行をUTF-8の文字の途中で切らないように折り返し、CRLFで書き出します
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		// 継続行は先頭の空白を含めて75オクテットに収める
+		limit = maxLineOctets - 1
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
@@ -0,0 +1,73 @@
+'use client';
+
+import { Suspense, useEffect, useRef, useState } from 'react';
+import { useParams, useRouter, useSearchParams } from 'next/navigation';
+import { setUser, setToken, setRefreshToken } from '@/utils/auth';
+import { getApiBaseUrl } from '@/utils/config';
+import styles from '../../../login/page.module.css';
+import Link from 'next/link';
+
+function OAuthCallback() {
+  const router = useRouter();
+  const { provider } = useParams<{ provider: string }>();
+  const searchParams = useSearchParams();
+  const [error, setError] = useState('');
+  // 認可コードは一度しか使えないため、開発時の二重実行でも送信は1回にする
+  const sent = useRef(false);
+
+  useEffect(() => {
+    if (sent.current) return;
+    sent.current = true;
+
+    const code = searchParams.get('code');
+    const state = searchParams.get('state');
+    if (!code || !state) {
+      setError(searchParams.get('error_description') || 'ログインがキャンセルされました');
+      return;
+    }
+
+    (async () => {
+      try {
+        const res = await fetch(`${getApiBaseUrl()}/api/oauth/${provider}/callback`, {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({ code, state }),
+        });
+        const data = await res.json();
+        if (!res.ok) throw new Error(data.error || 'ログインに失敗しました');
+
+        // 二要素認証が必要な場合はログインページで認証コードを入力する
+        if (data.two_factor_required) {
+          sessionStorage.setItem('two_factor_challenge', data.challenge_token);
+          router.replace('/login');
+          return;
+        }
+        setUser(data.user);
+        setToken(data.token);
+        if (data.refresh_token) setRefreshToken(data.refresh_token);
+        router.replace('/');
+      } catch (err: unknown) {
+        setError(err instanceof Error ? err.message : '予期しないエラーが発生しました');
+      }
+    })();
+  }, [provider, router, searchParams]);
+
+  return error ? <p className={styles.error}>{error}</p> : <p>ログインしています...</p>;
+}
+
+export default function OAuthCallbackPage() {
+  return (
+    <div className={styles.container}>
+      <Link href="/login" className={styles.backLink}>
+        &lt; Back Page
+      </Link>
+
+      <div className={styles.overlay}>
+        <h1 className={styles.title}>Login</h1>
+        <Suspense>
+          <OAuthCallback />
+        </Suspense>
+      </div>
+    </div>
+  );
+}
@@ -9,6 +9,15 @@ import { getUser, authenticatedFetch } from "@/utils/auth";
 type Profile = {
   id: number;
   user_id: number;
+  public_id: string;
+  display_name: string;
+  title: string;
+  description?: string;
+};
+
+// 相手のプロフィール（連番のID・ユーザーIDは返されないため公開IDで扱う）
+type ScannedProfile = {
+  public_id: string;
   display_name: string;
   title: string;
   description?: string;
@@ -18,9 +27,13 @@ function ExchangePageContent() {
   const router = useRouter();
   const searchParams = useSearchParams();
   const scannedProfileId = searchParams.get("profileId");
+  // 交換用QRコードから遷移した場合の交換トークン
+  const exchangeToken = searchParams.get("token");
 
   const [myProfiles, setMyProfiles] = useState<Profile[]>([]);
-  const [scannedProfile, setScannedProfile] = useState<Profile | null>(null);
+  const [scannedProfile, setScannedProfile] = useState<ScannedProfile | null>(null);
+  // 1回限りの交換トークンなら相互交換、繰り返し使えるトークンなら自分の一覧への登録のみ
+  const [mutualExchange, setMutualExchange] = useState(false);
   const [selectedProfileId, setSelectedProfileId] = useState<number | null>(
     null
   );
@@ -39,7 +52,7 @@ function ExchangePageContent() {
         return;
       }
 
-      if (!scannedProfileId) {
+      if (!scannedProfileId && !exchangeToken) {
         setError("プロフィールIDが指定されていません");
         setLoading(false);
         return;
@@ -57,15 +70,24 @@ function ExchangePageContent() {
         const profiles = myProfilesData.profiles || [];
         setMyProfiles(profiles);
 
-        // 相手のプロフィール情報を取得
-        const scannedProfileResponse = await authenticatedFetch(
-          `/api/profiles/${scannedProfileId}`
-        );
+        // 相手のプロフィール情報を取得（交換トークンの場合はトークンを検証して取得）
+        const scannedProfileResponse = exchangeToken
+          ? await authenticatedFetch("/api/exchange-tokens/preview", {
+              method: "POST",
+              headers: {
+                "Content-Type": "application/json",
+              },
+              body: JSON.stringify({ token: exchangeToken }),
+            })
+          : await authenticatedFetch(`/api/profiles/${scannedProfileId}`);
         if (!scannedProfileResponse.ok) {
-          throw new Error("相手のプロフィール情報の取得に失敗しました");
+          const data = await scannedProfileResponse.json().catch(() => null);
+          throw new Error(data?.error || "相手のプロフィール情報の取得に失敗しました");
         }
-        const scannedProfileData = await scannedProfileResponse.json();
+        const scannedProfileJson = await scannedProfileResponse.json();
+        const scannedProfileData = exchangeToken ? scannedProfileJson.profile : scannedProfileJson;
         setScannedProfile(scannedProfileData);
+        setMutualExchange(!!exchangeToken && !!scannedProfileJson.single_use);
 
         // 最初のプロフィールをデフォルトで選択
         if (profiles.length > 0) {
@@ -73,7 +95,7 @@ function ExchangePageContent() {
           setSelectedProfileId(firstProfileId);
           
           // 既存のコネクション情報を確認
-          if (scannedProfileData.id) {
+          if (scannedProfileData.public_id) {
             try {
               const connectionsResponse = await authenticatedFetch(
                 `/api/connections?profile_id=${firstProfileId}`
@@ -82,7 +104,7 @@ function ExchangePageContent() {
               if (connectionsResponse.ok) {
                 const connectionsData = await connectionsResponse.json();              // 表示中のプロフィールとのコネクションを検索
               const existingConnection = connectionsData.connections?.find(
-                (conn: { connect_user_profile_id: number }) => conn.connect_user_profile_id === scannedProfileData.id
+                (conn: { connect_user_profile_public_id?: string }) => conn.connect_user_profile_public_id === scannedProfileData.public_id
               );
                 
                 if (existingConnection) {
@@ -108,7 +130,7 @@ function ExchangePageContent() {
     };
 
     fetchData();
-  }, [router, scannedProfileId]);
+  }, [router, scannedProfileId, exchangeToken]);
 
   // イベント情報の状態
   const [eventInfo, setEventInfo] = useState({
@@ -145,37 +167,42 @@ function ExchangePageContent() {
           throw new Error('フレンド情報の更新に失敗しました');
         }
       } else {
-        // 新規作成の場合は両方向のコネクションを作成
-        const response1 = await authenticatedFetch('/api/connections', {
-          method: 'POST',
-          headers: {
-            'Content-Type': 'application/json',
-          },
-          body: JSON.stringify({
-            profile_id: selectedProfileId,
-            connect_user_profile_id: scannedProfile.id,
-            event_name: eventInfo.eventName,
-            event_date: eventInfo.eventDate,
-            memo: eventInfo.memo
-          }),
-        });
-
-        const response2 = await authenticatedFetch('/api/connections', {
-          method: 'POST',
-          headers: {
-            'Content-Type': 'application/json',
-          },
-          body: JSON.stringify({
-            profile_id: scannedProfile.id,
-            connect_user_profile_id: selectedProfileId,
-            event_name: eventInfo.eventName,
-            event_date: eventInfo.eventDate,
-            memo: eventInfo.memo
-          }),
-        });
-
-        if (!response1.ok || !response2.ok) {
-          throw new Error('プロフィール交換に失敗しました');
+        // 新規作成の場合は自分のプロフィールからのコネクションを作成
+        // （相手側のコネクションは相手のプロフィールでのみ作成できる）
+        // 交換用QRコードから遷移した場合はトークンを使って交換する
+        // 1回限りのトークンは双方向のコネクションをまとめて作成し（再送しても重複しない）、
+        // 繰り返し使えるトークンは自分から相手へのコネクションだけを作成する
+        const response1 = exchangeToken
+          ? await authenticatedFetch(mutualExchange ? '/api/exchanges' : '/api/exchange-tokens/redeem', {
+              method: 'POST',
+              headers: {
+                'Content-Type': 'application/json',
+              },
+              body: JSON.stringify({
+                token: exchangeToken,
+                profile_id: selectedProfileId,
+                event_name: eventInfo.eventName,
+                event_date: eventInfo.eventDate,
+                memo: eventInfo.memo
+              }),
+            })
+          : await authenticatedFetch('/api/connections', {
+              method: 'POST',
+              headers: {
+                'Content-Type': 'application/json',
+              },
+              body: JSON.stringify({
+                profile_id: selectedProfileId,
+                connect_user_profile_public_id: scannedProfile.public_id,
+                event_name: eventInfo.eventName,
+                event_date: eventInfo.eventDate,
+                memo: eventInfo.memo
+              }),
+            });
+
+        if (!response1.ok) {
+          const data = await response1.json().catch(() => null);
+          throw new Error(data?.error || 'プロフィール交換に失敗しました');
         }
         
         // 新規作成後に接続IDを取得して状態を更新
@@ -211,7 +238,7 @@ function ExchangePageContent() {
         const connectionsData = await connectionsResponse.json();
         // 表示中のプロフィールとのコネクションを検索
         const existingConnection = connectionsData.connections?.find(
-          (conn: { connect_user_profile_id: number }) => conn.connect_user_profile_id === scannedProfile.id
+          (conn: { connect_user_profile_public_id?: string }) => conn.connect_user_profile_public_id === scannedProfile.public_id
         );
         
         if (existingConnection) {
@@ -0,0 +1,63 @@
+'use client';
+
+import { useState } from 'react';
+import { getApiBaseUrl } from '@/utils/config';
+import styles from '../login/page.module.css';
+import Link from 'next/link';
+
+export default function ForgotPasswordPage() {
+  const [email, setEmail] = useState('');
+  const [message, setMessage] = useState('');
+  const [error, setError] = useState('');
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError('');
+    setMessage('');
+
+    try {
+      const res = await fetch(`${getApiBaseUrl()}/api/password/forgot`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ email }),
+      });
+      const data = await res.json();
+      if (!res.ok) throw new Error(data.error || '送信に失敗しました');
+      setMessage(data.message);
+    } catch (err: unknown) {
+      setError(err instanceof Error ? err.message : '予期しないエラーが発生しました');
+    }
+  };
+
+  return (
+    <div className={styles.container}>
+      <Link href="/login" className={styles.backLink}>
+        &lt; Back Page
+      </Link>
+
+      <div className={styles.overlay}>
+        <h1 className={styles.title}>Forgot Password</h1>
+
+        <form onSubmit={handleSubmit} className={styles.form}>
+          <label>
+            メールアドレス
+            <input
+              type="email"
+              name="email"
+              required
+              value={email}
+              onChange={(e) => setEmail(e.target.value)}
+            />
+          </label>
+
+          {message && <p>{message}</p>}
+          {error && <p className={styles.error}>{error}</p>}
+
+          <button type="submit" className={styles.submitButton}>
+            再設定メールを送信
+          </button>
+        </form>
+      </div>
+    </div>
+  );
+}
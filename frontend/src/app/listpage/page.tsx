@@ -11,7 +11,7 @@ import styles from './page.module.css';
 import { getUser, authenticatedFetch } from '@/utils/auth';
 
 type Contact = {
-  id: number;
+  publicId: string;
   name: string;
   profileTitle: string;
   exchangeDate: string;
@@ -57,7 +57,7 @@ export default function ListPage() {
         
         // APIレスポンスをContact型に変換
         const contactList: Contact[] = connections.map((conn: {
-          connected_profile_id: number;
+          connected_profile_public_id: string;
           connected_user_name: string;
           connected_profile_title: string;
           connected_at: string;
@@ -65,7 +65,7 @@ export default function ListPage() {
           event_date?: string;
           memo?: string;
         }) => ({
-          id: conn.connected_profile_id,
+          publicId: conn.connected_profile_public_id,
           name: conn.connected_user_name,
           profileTitle: conn.connected_profile_title,
           exchangeDate: conn.connected_at.split('T')[0], // 日付部分のみ抽出
@@ -85,6 +85,27 @@ export default function ListPage() {
     fetchConnections();
   }, [router]);
 
+  /* 交換済みのプロフィールをまとめて.vcfでダウンロード */
+  const exportVCard = async () => {
+    const user = getUser();
+    if (!user) return;
+    try {
+      const response = await authenticatedFetch(`/api/users/${user.id}/connections/vcard`);
+      if (!response.ok) {
+        throw new Error('vCardのエクスポートに失敗しました');
+      }
+      const blob = await response.blob();
+      const url = URL.createObjectURL(blob);
+      const a = document.createElement('a');
+      a.href = url;
+      a.download = 'qrsona-connections.vcf';
+      a.click();
+      URL.revokeObjectURL(url);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'エラーが発生しました');
+    }
+  };
+
   /* 年 → 日付 → Contact[] にまとめる */
   const grouped = contacts.reduce<Record<string, Record<string, Contact[]>>>(
     (acc, c) => {
@@ -134,6 +155,12 @@ export default function ListPage() {
       <div className={styles.overlay}>
         <h1 className={styles.title}>Exchange List</h1>
 
+        {contacts.length > 0 && (
+          <button className={styles.exportButton} onClick={exportVCard}>
+            vCardでエクスポート
+          </button>
+        )}
+
         {/* タブ切り替えUI */}
         <div className={styles.tabContainer}>
           <div className={styles.tabButtons}>
@@ -202,8 +229,8 @@ export default function ListPage() {
                     {openDate[key] &&
                       grouped[year][md].map(p => (
                         <Link
-                          key={p.id}
-                          href={`/profile/${p.id}`}
+                          key={p.publicId}
+                          href={`/profile/${p.publicId}`}
                           className={styles.nameLink}
                           onClick={() => sessionStorage.setItem('referrer', 'listpage')}
                         >
@@ -251,8 +278,8 @@ export default function ListPage() {
               .sort((a, b) => new Date(b.exchangeDate).getTime() - new Date(a.exchangeDate).getTime())
               .map(contact => (
                 <Link 
-                  key={contact.id}
-                  href={`/profile/${contact.id}`}
+                  key={contact.publicId}
+                  href={`/profile/${contact.publicId}`}
                   className={styles.followingItem}
                   onClick={() => sessionStorage.setItem('referrer', 'listpage')}
                 >
@@ -1,8 +1,8 @@
 'use client';
 
-import { useState } from 'react';
+import { useEffect, useState } from 'react';
 import { useRouter } from 'next/navigation';
-import { setUser, setToken } from '@/utils/auth';
+import { setUser, setToken, setRefreshToken, type User } from '@/utils/auth';
 import { getApiBaseUrl } from '@/utils/config';
 import styles from './page.module.css';
 import Link from 'next/link';
@@ -11,6 +11,36 @@ export default function LoginPage() {
   const router = useRouter();
   const [formData, setFormData] = useState({ email: '', password: '' });
   const [error, setError] = useState('');
+  // 二要素認証が有効なアカウントはパスワードの後に認証コードを入力する
+  const [challengeToken, setChallengeToken] = useState('');
+  const [code, setCode] = useState('');
+  const [providers, setProviders] = useState<string[]>([]);
+
+  useEffect(() => {
+    // 外部ログインのコールバックで二要素認証が必要になった場合は認証コードの入力から始める
+    const challenge = sessionStorage.getItem('two_factor_challenge');
+    if (challenge) {
+      sessionStorage.removeItem('two_factor_challenge');
+      setChallengeToken(challenge);
+    }
+
+    fetch(`${getApiBaseUrl()}/api/oauth/providers`)
+      .then((res) => (res.ok ? res.json() : { providers: [] }))
+      .then((data) => setProviders(data.providers ?? []))
+      .catch(() => setProviders([]));
+  }, []);
+
+  const handleOAuth = async (provider: string) => {
+    setError('');
+    try {
+      const res = await fetch(`${getApiBaseUrl()}/api/oauth/${provider}/start`, { method: 'POST' });
+      const data = await res.json();
+      if (!res.ok) throw new Error(data.error || 'ログインを開始できませんでした');
+      window.location.href = data.authorization_url;
+    } catch (err: unknown) {
+      setError(err instanceof Error ? err.message : '予期しないエラーが発生しました');
+    }
+  };
 
   const handleChange = (e: React.ChangeEvent<HTMLInputElement>) =>
     setFormData({ ...formData, [e.target.name]: e.target.value });
@@ -29,18 +59,54 @@ export default function LoginPage() {
       if (!res.ok) throw new Error('ログインに失敗しました');
 
       const data = await res.json();
-      console.log('Login response:', data);
-      setUser(data.user);
-      if (data.token) {
-        setToken(data.token);
-        console.log('Token saved successfully');
+      if (data.two_factor_required) {
+        setChallengeToken(data.challenge_token);
+        return;
+      }
+      completeLogin(data);
+    } catch (err: unknown) {
+      setError(err instanceof Error ? err.message : '予期しないエラーが発生しました');
+    }
+  };
+
+  const handleCodeSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError('');
+
+    try {
+      const res = await fetch(`${getApiBaseUrl()}/api/signin/2fa`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ challenge_token: challengeToken, code }),
+      });
+
+      if (!res.ok) {
+        const data = await res.json().catch(() => ({}));
+        // コードの誤り以外（チャレンジトークンの期限切れ・ロック等）はパスワード入力からやり直す
+        if (data.error !== '認証コードが正しくありません') {
+          setChallengeToken('');
+          setCode('');
+        }
+        throw new Error(data.error || 'ログインに失敗しました');
       }
-      router.replace('/');
+
+      completeLogin(await res.json());
     } catch (err: unknown) {
       setError(err instanceof Error ? err.message : '予期しないエラーが発生しました');
     }
   };
 
+  const completeLogin = (data: { user: User; token?: string; refresh_token?: string }) => {
+    console.log('Login response:', data);
+    setUser(data.user);
+    if (data.token) {
+      setToken(data.token);
+      if (data.refresh_token) setRefreshToken(data.refresh_token);
+      console.log('Token saved successfully');
+    }
+    router.replace('/');
+  };
+
   return (
     <div className={styles.container}>
       <Link href="/auth" className={styles.backLink}>
@@ -50,35 +116,71 @@ export default function LoginPage() {
       <div className={styles.overlay}>
         <h1 className={styles.title}>LoginPage</h1>
 
-        <form onSubmit={handleSubmit} className={styles.form}>
-          <label>
-            メールアドレス
-            <input
-              type="email"
-              name="email"
-              required
-              value={formData.email}
-              onChange={handleChange}
-            />
-          </label>
-
-          <label>
-            パスワード
-            <input
-              type="password"
-              name="password"
-              required
-              value={formData.password}
-              onChange={handleChange}
-            />
-          </label>
-
-          {error && <p className={styles.error}>{error}</p>}
-
-          <button type="submit" className={styles.submitButton}>
-            Login
-          </button>
-        </form>
+        {challengeToken ? (
+          <form onSubmit={handleCodeSubmit} className={styles.form}>
+            <label>
+              認証コード（認証アプリの6桁のコード、またはリカバリーコード）
+              <input
+                type="text"
+                name="code"
+                required
+                autoComplete="one-time-code"
+                value={code}
+                onChange={(e) => setCode(e.target.value)}
+              />
+            </label>
+
+            {error && <p className={styles.error}>{error}</p>}
+
+            <button type="submit" className={styles.submitButton}>
+              Verify
+            </button>
+          </form>
+        ) : (
+          <form onSubmit={handleSubmit} className={styles.form}>
+            <label>
+              メールアドレス
+              <input
+                type="email"
+                name="email"
+                required
+                value={formData.email}
+                onChange={handleChange}
+              />
+            </label>
+
+            <label>
+              パスワード
+              <input
+                type="password"
+                name="password"
+                required
+                value={formData.password}
+                onChange={handleChange}
+              />
+            </label>
+
+            {error && <p className={styles.error}>{error}</p>}
+
+            <button type="submit" className={styles.submitButton}>
+              Login
+            </button>
+          </form>
+        )}
+
+        {!challengeToken &&
+          providers.map((provider) => (
+            <button
+              key={provider}
+              type="button"
+              className={styles.submitButton}
+              onClick={() => handleOAuth(provider)}
+            >
+              {provider === 'github' ? 'GitHub' : provider === 'google' ? 'Google' : provider}でログイン
+            </button>
+          ))}
+
+        <Link href="/forgot-password">パスワードをお忘れの方</Link>
       </div>
     </div>
   );
@@ -0,0 +1,133 @@
+"use client";
+
+import React, { useCallback, useEffect, useState } from "react";
+import Link from "next/link";
+import styles from "./ConnectionRequestInbox.module.css";
+import { authenticatedFetch } from "@/utils/auth";
+
+// 自分のプロフィールに届いた承認待ちのコネクション申請
+type ConnectionRequest = {
+  id: number;
+  event_name?: string;
+  event_date?: string;
+  created_at: string;
+  expires_at: string;
+  // 申請者のプロフィール（削除済みの場合は省略される）
+  profile?: {
+    public_id: string;
+    slug?: string;
+    display_name: string;
+    title?: string;
+  };
+};
+
+const ConnectionRequestInbox: React.FC = () => {
+  const [requests, setRequests] = useState<ConnectionRequest[]>([]);
+  const [loading, setLoading] = useState(true);
+  const [error, setError] = useState<string | null>(null);
+  // 承認・拒否の処理中の申請ID
+  const [respondingId, setRespondingId] = useState<number | null>(null);
+
+  const fetchRequests = useCallback(async () => {
+    try {
+      const response = await authenticatedFetch(
+        "/api/connection-requests/incoming?status=pending"
+      );
+      const data = await response.json();
+      if (!response.ok) {
+        throw new Error(data.error || "申請の取得に失敗しました");
+      }
+      setRequests(data.requests || []);
+      setError(null);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "申請の取得に失敗しました");
+    } finally {
+      setLoading(false);
+    }
+  }, []);
+
+  useEffect(() => {
+    fetchRequests();
+  }, [fetchRequests]);
+
+  // 申請を承認・拒否し、一覧から取り除く（対応済み・期限切れの場合も一覧を更新する）
+  const respond = async (id: number, action: "accept" | "decline") => {
+    setRespondingId(id);
+    try {
+      const response = await authenticatedFetch(
+        `/api/connection-requests/${id}/${action}`,
+        { method: "POST" }
+      );
+      if (!response.ok) {
+        const data = await response.json().catch(() => null);
+        setError(data?.error || "申請への対応に失敗しました");
+        await fetchRequests();
+        return;
+      }
+      setError(null);
+      setRequests((prev) => prev.filter((request) => request.id !== id));
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "申請への対応に失敗しました");
+    } finally {
+      setRespondingId(null);
+    }
+  };
+
+  if (loading || (requests.length === 0 && !error)) {
+    return null;
+  }
+
+  return (
+    <div className={styles.inbox}>
+      <h2 className={styles.title}>届いているコネクション申請</h2>
+      {error && <p className={styles.error}>{error}</p>}
+      <ul className={styles.list}>
+        {requests.map((request) => (
+          <li key={request.id} className={styles.item}>
+            <div className={styles.info}>
+              {request.profile ? (
+                <Link
+                  href={`/profile/${request.profile.slug || request.profile.public_id}`}
+                  className={styles.name}
+                >
+                  {request.profile.display_name}
+                </Link>
+              ) : (
+                <span className={styles.name}>削除されたプロフィール</span>
+              )}
+              {request.profile?.title && (
+                <span className={styles.detail}>{request.profile.title}</span>
+              )}
+              {(request.event_name || request.event_date) && (
+                <span className={styles.detail}>
+                  {[request.event_name, request.event_date].filter(Boolean).join(" / ")}
+                </span>
+              )}
+              <span className={styles.detail}>
+                {new Date(request.expires_at).toLocaleDateString("ja-JP")}まで
+              </span>
+            </div>
+            <div className={styles.actions}>
+              <button
+                className={styles.acceptButton}
+                onClick={() => respond(request.id, "accept")}
+                disabled={respondingId === request.id}
+              >
+                承認
+              </button>
+              <button
+                className={styles.declineButton}
+                onClick={() => respond(request.id, "decline")}
+                disabled={respondingId === request.id}
+              >
+                拒否
+              </button>
+            </div>
+          </li>
+        ))}
+      </ul>
+    </div>
+  );
+};
+
+export default ConnectionRequestInbox;
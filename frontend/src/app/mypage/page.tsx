@@ -5,10 +5,13 @@ import { useEffect, useState } from "react";
 import styles from "./page.module.css";
 import Link from "next/link";
 import { getUser, User, authenticatedFetch, getToken } from "../../utils/auth";
+import ConnectionRequestInbox from "./components/ConnectionRequestInbox";
 
 type Profile = {
   id: number;
   user_id: number;
+  public_id: string;
+  slug?: string;
   display_name: string;
   icon_path?: string;
   icon_url?: string;
@@ -261,6 +264,9 @@ export default function ProfilePage() {
           NewProfile ＋
         </button>
 
+        {/* 自分のプロフィールに届いたコネクション申請（承認待ちがある場合のみ表示） */}
+        {!loading && !error && profiles.length > 0 && <ConnectionRequestInbox />}
+
         <div className={styles.profileList}>
           {loading ? (
             <p className={styles.message}>読み込み中...</p>
@@ -290,7 +296,7 @@ export default function ProfilePage() {
                     className={styles.profileCardInfo}
                     onClick={() => {
                       sessionStorage.setItem('referrer', 'mypage');
-                      router.push(`/profile/${profile.id}`);
+                      router.push(`/profile/${profile.slug || profile.public_id}`);
                     }}
                   >
                     <h2 className={styles.cardTitle}>{profile.title}</h2>
@@ -19,6 +19,7 @@ type Profile = {
   comment?: string;
   title?: string;
   description?: string;
+  slug?: string;
 };
 
 type OptionProfile = {
@@ -238,6 +239,7 @@ export default function ProfileEditPage() {
     birthday: '',
     birthplace: '',
     hobby: '',
+    slug: '',
     sns: {
       twitter: '',
       instagram: '',
@@ -314,6 +316,7 @@ export default function ProfileEditPage() {
           birthday: profileData.birthdate ? profileData.birthdate.split('T')[0] : '',
           birthplace: profileData.hometown || '',
           hobby: profileData.hobby || '',
+          slug: profileData.slug || '',
           sns: {
             twitter: snsLinks.find(link => link.title === 'Twitter')?.url || '',
             instagram: snsLinks.find(link => link.title === 'Instagram')?.url || '',
@@ -500,6 +503,7 @@ export default function ProfileEditPage() {
         comment: formData.bio,
         title: formData.profileTitle,
         description: formData.profileDescription,
+        slug: formData.slug.trim(),
       };
 
       const response = await authenticatedFetch(`/api/profiles/${params.id}`, {
@@ -508,7 +512,9 @@ export default function ProfileEditPage() {
       });
 
       if (!response.ok) {
-        throw new Error('プロフィールの更新に失敗しました');
+        // カスタムURLの重複・使用できない語句などはサーバーのメッセージを表示する
+        const errorData = await response.json().catch(() => null);
+        throw new Error(errorData?.error || 'プロフィールの更新に失敗しました');
       }
 
       // 任意項目の更新
@@ -787,6 +793,17 @@ export default function ProfileEditPage() {
             />
           </label>
 
+          <label>
+            カスタムURL（任意・3〜20文字の英小文字・数字・ハイフン）：
+            <input
+              type="text"
+              name="slug"
+              value={formData.slug}
+              onChange={handleChange}
+              placeholder="例: taro-dev"
+            />
+          </label>
+
           <label>
             アイコン画像をアップロード：
             <input type="file" accept="image/*" onChange={handleFileChange} />
@@ -21,8 +21,9 @@ import { authenticatedFetch, getUser } from "@/utils/auth";
 import { getApiBaseUrl } from "@/utils/config";
 
 type Profile = {
-  id: number;
-  user_id: number;
+  id?: number; // 連番のID・ユーザーIDは所有者本人にだけ返される
+  user_id?: number;
+  public_id: string;
   display_name: string;
   icon_url?: string;
   aka?: string;
@@ -118,7 +119,7 @@ export default function ProfileDetail() {
           } else if (!profileData.icon_url.startsWith("http")) {
             // URLがhttpで始まらない場合もAPIベースURLを追加
             profileData.icon_url = `${getApiBaseUrl()}/api/profiles/${
-              profileData.id
+              profileData.public_id
             }/icon`;
           }
           console.log("アイコンURL:", profileData.icon_url);
@@ -142,7 +143,7 @@ export default function ProfileDetail() {
         } else {
           setIsOwner(false);
           // 所有者でない場合、交換済みかどうかをチェック
-          await checkExchangeRelation(user, profileData.id);
+          await checkExchangeRelation(user, profileData.public_id);
         }
         if (user) {
           try {
@@ -161,7 +162,7 @@ export default function ProfileDetail() {
                 setSelectedProfileId(firstProfileId);
 
                 // 既存のコネクション情報を取得 (自分のプロフィールでない場合のみ)
-                if (!isOwner && profileData.id) {
+                if (!isOwner && profileData.public_id) {
                   try {
                     // セッションストレージから参照元を取得
                     const referrer = sessionStorage.getItem("referrer");
@@ -182,11 +183,12 @@ export default function ProfileDetail() {
 
                     if (connectionsResponse.ok) {
                       const connectionsData = await connectionsResponse.json();
-                      // 表示中のプロフィールとのコネクションを検索
+                      // 表示中のプロフィールとのコネクションを検索（他人のプロフィールは公開IDで照合する）
                       const existingConnection =
                         connectionsData.connections?.find(
-                          (conn: { connect_user_profile_id: number }) =>
-                            conn.connect_user_profile_id === profileData.id
+                          (conn: { connect_user_profile_public_id?: string }) =>
+                            conn.connect_user_profile_public_id ===
+                            profileData.public_id
                         );
 
                       if (existingConnection) {
@@ -198,46 +200,18 @@ export default function ProfileDetail() {
                           memo: existingConnection.memo || "",
                           existingConnectionId: existingConnection.id,
                         }));
-                      } else {
-                        // 相手のプロフィールから自分のプロフィールへのコネクションも確認
-                        // (QRコード交換時に相手側が作成した可能性がある)
-                        const reverseConnectionsResponse =
-                          await authenticatedFetch(
-                            `/api/connections?profile_id=${profileData.id}`
-                          );
-
-                        if (reverseConnectionsResponse.ok) {
-                          const reverseConnectionsData =
-                            await reverseConnectionsResponse.json();
-                          const reverseConnection =
-                            reverseConnectionsData.connections?.find(
-                              (conn: { connect_user_profile_id: number }) =>
-                                conn.connect_user_profile_id === firstProfileId
-                            );
-
-                          if (reverseConnection) {
-                            // 既存のコネクション情報をフォームに設定 (双方向のコネクションを考慮)
-                            setFriendForm((prev) => ({
-                              ...prev,
-                              eventName: reverseConnection.event_name || "",
-                              eventDate: reverseConnection.event_date || "",
-                              memo: reverseConnection.memo || "",
-                              existingConnectionId: reverseConnection.id,
-                            }));
-                          } else if (referrer === "listpage") {
-                            // リストページからの遷移なのにコネクションが見つからない場合
-                            // フレンド情報編集ボタンを表示するため、ダミーのIDを残す
-                            console.warn("リストからの遷移だがコネクション情報が見つかりませんでした");
-                            // 空のデータでも編集可能にする
-                            setFriendForm((prev) => ({
-                              ...prev,
-                              eventName: "",
-                              eventDate: "",
-                              memo: "",
-                              existingConnectionId: -1, // 仮のID
-                            }));
-                          }
-                        }
+                      } else if (referrer === "listpage") {
+                        // リストページからの遷移なのにコネクションが見つからない場合
+                        // フレンド情報編集ボタンを表示するため、ダミーのIDを残す
+                        console.warn("リストからの遷移だがコネクション情報が見つかりませんでした");
+                        // 空のデータでも編集可能にする
+                        setFriendForm((prev) => ({
+                          ...prev,
+                          eventName: "",
+                          eventDate: "",
+                          memo: "",
+                          existingConnectionId: -1, // 仮のID
+                        }));
                       }
                     }
                   } catch (err) {
@@ -282,7 +256,7 @@ export default function ProfileDetail() {
   // 交換関係をチェックする関数
   const checkExchangeRelation = async (
     user: { id: number },
-    targetProfileId: number
+    targetPublicId: string
   ) => {
     if (!user) {
       setHasAccess(false);
@@ -313,8 +287,8 @@ export default function ProfileDetail() {
         if (connectionsResponse.ok) {
           const connectionsData = await connectionsResponse.json();
           const hasConnection = connectionsData.connections?.some(
-            (conn: { connect_user_profile_id: number }) =>
-              conn.connect_user_profile_id === targetProfileId
+            (conn: { connect_user_profile_public_id?: string }) =>
+              conn.connect_user_profile_public_id === targetPublicId
           );
 
           if (hasConnection) {
@@ -322,25 +296,6 @@ export default function ProfileDetail() {
             return;
           }
         }
-
-        // 相手のプロフィールから自分への接続もチェック
-        const reverseConnectionsResponse = await authenticatedFetch(
-          `/api/connections?profile_id=${targetProfileId}`
-        );
-
-        if (reverseConnectionsResponse.ok) {
-          const reverseConnectionsData =
-            await reverseConnectionsResponse.json();
-          const hasReverseConnection = reverseConnectionsData.connections?.some(
-            (conn: { connect_user_profile_id: number }) =>
-              conn.connect_user_profile_id === userProfile.id
-          );
-
-          if (hasReverseConnection) {
-            setHasAccess(true);
-            return;
-          }
-        }
       }
 
       // どのプロフィールでも交換関係が見つからない場合
@@ -351,6 +306,27 @@ export default function ProfileDetail() {
     }
   };
 
+  // vCard（.vcf）をダウンロードして連絡先アプリに追加できるようにする
+  const downloadVCard = async () => {
+    try {
+      const response = await authenticatedFetch(
+        `/api/profiles/${params.id}/vcard`
+      );
+      if (!response.ok) {
+        throw new Error("vCardの取得に失敗しました");
+      }
+      const blob = await response.blob();
+      const url = URL.createObjectURL(blob);
+      const a = document.createElement("a");
+      a.href = url;
+      a.download = `${params.id}.vcf`;
+      a.click();
+      URL.revokeObjectURL(url);
+    } catch (err) {
+      console.error("vCardダウンロードエラー:", err);
+    }
+  };
+
   // フレンド追加・編集フォームの表示・非表示切り替え
   const toggleFriendForm = () => {
     setFriendForm((prev) => ({
@@ -382,6 +358,7 @@ export default function ProfileDetail() {
     try {
       let response;
       let isUpdate = true;
+      let requested = false;
       
       // セッションストレージから参照元を取得
       const referrer = sessionStorage.getItem("referrer");
@@ -419,8 +396,6 @@ export default function ProfileDetail() {
         } else {
           // 仮ID(-1)が設定されている場合、リストページから来たが実際のコネクションが見つからない
           // この場合は新規作成する
-          console.log("リストページからの遷移ですが、コネクションIDが見つからないため新規作成します");
-          
           const myToFriendResponse = await authenticatedFetch(
             "/api/connections",
             {
@@ -430,7 +405,7 @@ export default function ProfileDetail() {
               },
               body: JSON.stringify({
                 profile_id: selectedProfileId,
-                connect_user_profile_id: profile.id,
+                connect_user_profile_public_id: profile.public_id,
                 event_name: friendForm.eventName,
                 event_date: friendForm.eventDate,
                 memo: friendForm.memo,
@@ -441,6 +416,8 @@ export default function ProfileDetail() {
           if (!myToFriendResponse.ok) {
             throw new Error("フレンド情報の更新に失敗しました");
           }
+          // 他のユーザーのプロフィールへは申請になり、相手が承認するまでコネクションは作成されない
+          requested = myToFriendResponse.status === 202;
           
           // IDを取得して状態を更新
           const data = await myToFriendResponse.json();
@@ -451,37 +428,10 @@ export default function ProfileDetail() {
             }));
           }
         }
-        
-        // 相互のコネクションの存在確認のみ行い、更新はしない
-        try {
-          // 相手のプロフィールからのコネクションを検索（存在確認のみ）
-          const connectionsResponse = await authenticatedFetch(
-            `/api/connections?profile_id=${profile.id}`
-          );
-          
-          if (connectionsResponse.ok) {
-            const connectionsData = await connectionsResponse.json();
-            const reverseConnection = connectionsData.connections?.find(
-              (conn: { connect_user_profile_id: number }) =>
-                conn.connect_user_profile_id === selectedProfileId
-            );
-            
-            if (reverseConnection && reverseConnection.id) {
-              // 相手側のコネクションの存在を確認するだけで、更新は行わない
-              console.log("相互コネクションの存在を確認しました。相手側の情報は更新しません。");
-            }
-          }
-        } catch (err) {
-          console.error("相互コネクション確認エラー:", err);
-          // エラーがあっても処理は継続
-        }
       } else {
         // 新規作成処理
-        // QRコード交換のケースかどうかを判断（セッションストレージから参照元を取得）
-        const referrer = sessionStorage.getItem("referrer");
-        const isQRExchange = referrer === "qrpage" || referrer === "exchange";
-        
-        // 自分から相手へのコネクションを作成
+        // 自分から相手へのコネクションを作成（他のユーザーのプロフィールへは申請になる）
+        // QRコードでの相互交換は交換トークンを使う交換ページ（/exchange）で行う
         const myToFriendResponse = await authenticatedFetch(
           "/api/connections",
           {
@@ -491,7 +441,7 @@ export default function ProfileDetail() {
             },
             body: JSON.stringify({
               profile_id: selectedProfileId,
-              connect_user_profile_id: profile.id,
+              connect_user_profile_public_id: profile.public_id,
               event_name: friendForm.eventName,
               event_date: friendForm.eventDate,
               memo: friendForm.memo,
@@ -502,50 +452,23 @@ export default function ProfileDetail() {
         if (!myToFriendResponse.ok) {
           throw new Error("フレンド追加に失敗しました");
         }
-        
-        // QRコード交換のケースのみ、相手から自分へのコネクションも作成（相互関係）
-        if (isQRExchange) {
-          console.log("QRコード交換のケースなので、相互コネクションを作成します");
-          const friendToMyResponse = await authenticatedFetch(
-            "/api/connections",
-            {
-              method: "POST",
-              headers: {
-                "Content-Type": "application/json",
-              },
-              body: JSON.stringify({
-                profile_id: profile.id,
-                connect_user_profile_id: selectedProfileId,
-                // QRコード交換時は相手側も同じイベント情報を持つ
-                event_name: friendForm.eventName,
-                event_date: friendForm.eventDate,
-                memo: friendForm.memo,
-              }),
-            }
-          );
-          
-          if (!friendToMyResponse.ok) {
-            console.warn("相互コネクション作成に失敗しましたが、処理を継続します");
-          }
-        } else {
-          console.log("通常のフレンド追加のため、自分側のコネクションのみを作成します");
-        }
+        requested = myToFriendResponse.status === 202;
 
         // 新規作成後、IDを取得して状態を更新
-        if (myToFriendResponse.ok) {
-          const data = await myToFriendResponse.json();
-          if (data?.connection?.id) {
-            setFriendForm((prev) => ({
-              ...prev,
-              existingConnectionId: data.connection.id,
-            }));
-          }
+        const data = await myToFriendResponse.json();
+        if (data?.connection?.id) {
+          setFriendForm((prev) => ({
+            ...prev,
+            existingConnectionId: data.connection.id,
+          }));
         }
       }
 
       setAddFriendResult({
         success: true,
-        message: "フレンド情報を更新しました！",
+        message: requested
+          ? "コネクションを申請しました。相手が承認すると交換済みになります"
+          : "フレンド情報を更新しました！",
       });
 
       // フォームを閉じる
@@ -658,7 +581,7 @@ export default function ProfileDetail() {
       {isOwner && (
         <button
           className={styles.editButton}
-          onClick={() => router.push(`/profile/${params.id}/edit`)}
+          onClick={() => router.push(`/profile/${profile.id}/edit`)}
         >
           ✎ プロフィールを編集
         </button>
@@ -800,6 +723,12 @@ export default function ProfileDetail() {
           </div>
         )}
 
+        <div className={styles.friendSection}>
+          <button className={styles.addFriendButton} onClick={downloadVCard}>
+            連絡先に追加
+          </button>
+        </div>
+
         {/* フレンド情報編集ボタン - 自分自身のプロフィールではない場合のみ表示 */}
         {userProfiles.length > 0 && !isOwner && (
           <div className={styles.friendSection}>
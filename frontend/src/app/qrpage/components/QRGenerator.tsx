@@ -1,104 +1,93 @@
 "use client";
 
-import React, { useState, useRef, useEffect } from "react";
-import axios from "axios";
+import React, { useState, useRef, useEffect, useCallback } from "react";
 import Image from "next/image";
 import styles from "./QRGenerator.module.css";
+import { authenticatedFetch } from "@/utils/auth";
 
 interface QRGeneratorProps {
+  // QRコードで交換するプロフィールのID
   profileId: number;
 }
 
+type ExchangeToken = {
+  token: string;
+  url: string;
+  qr_data: string;
+  expires_at: string;
+  single_use: boolean;
+};
+
+// 有効期限のこの時間前に自動で再発行する
+const REFRESH_MARGIN_MS = 10 * 1000;
+
 const QRGenerator: React.FC<QRGeneratorProps> = ({ profileId }) => {
-  const [url, setUrl] = useState("");
-  const [qrData, setQrData] = useState("");
+  const [exchangeToken, setExchangeToken] = useState<ExchangeToken | null>(null);
+  const [singleUse, setSingleUse] = useState(false);
+  const [remaining, setRemaining] = useState<number | null>(null);
   const [isLoading, setIsLoading] = useState(false);
   const [error, setError] = useState<string | null>(null);
   const qrRef = useRef<HTMLImageElement>(null);
 
-  // プロフィールIDが変更されたときにQRコードを生成
-  useEffect(() => {
-    const generateProfileQR = async () => {
-      if (!profileId) return;
-
-      setIsLoading(true);
-      setError(null);
-      try {
-        // 環境に応じたベースURLの決定
-        const getBaseUrl = () => {
-          if (process.env.NODE_ENV === "development") {
-            return "http://localhost:3000";
-          }
-
-          // 本番環境での分岐
-          if (typeof window !== "undefined") {
-            const hostname = window.location.hostname;
-            if (hostname === "qrsona-dev.vercel.app") {
-              return "https://qrsona-dev.vercel.app";
-            }
-          }
-
-          // デフォルトは本番環境
-          return "https://qrsona.vercel.app";
-        };
-
-        const baseUrl = getBaseUrl();
-        const profileUrl = `${baseUrl}/profile/${profileId}`;
-        const urlWithTimestamp = `${profileUrl}?t=${Date.now()}`;
-        const response = await axios.post(`/api/generate-qr`, {
-          url: urlWithTimestamp,
-        });
-
-        if (response.data && response.data.qr_data) {
-          setQrData(response.data.qr_data);
-          setUrl(profileUrl);
-        } else {
-          throw new Error("QRコードデータが見つかりません");
-        }
-      } catch (error) {
-        console.error("Error generating profile QR code:", error);
-        setError("QRコードの生成に失敗しました。再試行してください。");
-      } finally {
-        setIsLoading(false);
-      }
-    };
-
-    generateProfileQR();
-  }, [profileId]);
-
-  const handleReload = async () => {
-    if (!url) return;
+  // 交換トークンを発行してQRコードを取得
+  const generate = useCallback(async () => {
+    if (!profileId) return;
 
     setIsLoading(true);
     setError(null);
     try {
-      const urlWithTimestamp = `${url}?t=${Date.now()}`;
-      const response = await axios.post(`/api/generate-qr`, {
-        url: urlWithTimestamp,
+      const response = await authenticatedFetch("/api/exchange-tokens", {
+        method: "POST",
+        headers: {
+          "Content-Type": "application/json",
+        },
+        body: JSON.stringify({ profile_id: profileId, single_use: singleUse }),
       });
-
-      if (response.data && response.data.qr_data) {
-        setQrData(response.data.qr_data);
-      } else {
+      const data = await response.json();
+      if (!response.ok) {
+        throw new Error(data.error || "QRコードの生成に失敗しました");
+      }
+      if (!data.qr_data) {
         throw new Error("QRコードデータが見つかりません");
       }
+      setExchangeToken(data);
     } catch (error) {
-      console.error("Error regenerating QR code:", error);
-      setError("QRコードの再生成に失敗しました。再試行してください。");
+      console.error("Error generating exchange QR code:", error);
+      setError("QRコードの生成に失敗しました。再試行してください。");
     } finally {
       setIsLoading(false);
     }
-  };
+  }, [profileId, singleUse]);
+
+  // プロフィールが変更されたときにQRコードを生成
+  useEffect(() => {
+    generate();
+  }, [generate]);
+
+  // 有効期限が近づいたら自動で再発行し、残り時間を表示する
+  useEffect(() => {
+    if (!exchangeToken) return;
+
+    const expiresAt = new Date(exchangeToken.expires_at).getTime();
+    const timer = setTimeout(generate, Math.max(expiresAt - Date.now() - REFRESH_MARGIN_MS, 0));
+    const tick = () => setRemaining(Math.max(Math.ceil((expiresAt - Date.now()) / 1000), 0));
+    tick();
+    const interval = setInterval(tick, 1000);
+    return () => {
+      clearTimeout(timer);
+      clearInterval(interval);
+    };
+  }, [exchangeToken, generate]);
 
   const handleDownload = () => {
-    if (!qrData) {
+    if (!exchangeToken) {
       setError("ダウンロードするQRコードがありません");
       return;
     }
 
     try {
       const link = document.createElement("a");
-      link.href = qrData;
+      link.href = exchangeToken.qr_data;
       link.download = "qrcode.png";
       document.body.appendChild(link);
       link.click();
@@ -112,7 +101,14 @@ const QRGenerator: React.FC<QRGeneratorProps> = ({ profileId }) => {
   return (
     <div className={styles.qrGenerator}>
       <h1>QRコードの作成</h1>
-      <p className={styles.urlDisplay}>URL: {url}</p>
+      <label className={styles.urlDisplay}>
+        <input
+          type='checkbox'
+          checked={singleUse}
+          onChange={(e) => setSingleUse(e.target.checked)}
+        />{" "}
+        1回だけ使えるQRコードにする（読み取った相手とお互いの一覧に登録する相互交換になります）
+      </label>
 
       {/* エラーメッセージ表示 */}
       {error && (
@@ -121,7 +117,7 @@ const QRGenerator: React.FC<QRGeneratorProps> = ({ profileId }) => {
           <button
             onClick={() => {
               setError(null);
-              handleReload();
+              generate();
             }}
             className={styles.retryButton}
           >
@@ -134,20 +130,25 @@ const QRGenerator: React.FC<QRGeneratorProps> = ({ profileId }) => {
       {isLoading && <div className={styles.loading}>QRコードを生成中...</div>}
 
       {/* QRコード表示 */}
-      {qrData && !isLoading && (
+      {exchangeToken && !isLoading && (
         <div className={styles.qrResult}>
           <Image
             ref={qrRef}
-            src={qrData}
+            src={exchangeToken.qr_data}
             alt='QR Code'
             className={styles.qrImage}
             width={256}
             height={256}
             unoptimized
           />
+          {remaining !== null && (
+            <p className={styles.urlDisplay}>
+              有効期限まで残り{remaining}秒（自動で更新されます）
+            </p>
+          )}
           <div className={styles.qrActions}>
             <button
-              onClick={handleReload}
+              onClick={generate}
               className={styles.qrActionButton}
               disabled={isLoading}
             >
@@ -11,6 +11,8 @@ import { useRouter } from 'next/navigation';
 type Profile = {
   id: number;
   user_id: number;
+  public_id: string;
+  slug?: string;
   display_name: string;
   title: string;
   description?: string;
@@ -24,6 +26,7 @@ export default function QRPage() {
   const [error, setError] = useState<string | null>(null);
   const [isScannerOpen, setIsScannerOpen] = useState(false);
   const [exchangedMessage, setExchangedMessage] = useState<string | null>(null);
+  const [decoding, setDecoding] = useState(false);
 
   useEffect(() => {
     const fetchProfiles = async () => {
@@ -78,8 +81,14 @@ export default function QRPage() {
     try {
       const url = new URL(result);
       if (url.origin === window.location.origin) {
+        // 交換用QRコードのURLかチェック
+        const exchangeToken = url.pathname === '/exchange' ? url.searchParams.get('token') : null;
+        if (exchangeToken) {
+          router.push(`/exchange?token=${encodeURIComponent(exchangeToken)}`);
+          return;
+        }
         // プロフィールページのURLかチェック
-        const profileMatch = url.pathname.match(/^\/profile\/(\d+)$/);
+        const profileMatch = url.pathname.match(/^\/profile\/([A-Za-z0-9_-]+)$/);
         if (profileMatch) {
           const profileId = profileMatch[1];
           // 交換ページに遷移
@@ -96,10 +105,45 @@ export default function QRPage() {
     }
   };
 
+  // カメラで読み取れない場合は、撮影した画像をサーバーで読み取る
+  const handleImageUpload = async (e: React.ChangeEvent<HTMLInputElement>) => {
+    const file = e.target.files?.[0];
+    e.target.value = '';
+    if (!file) return;
+    setDecoding(true);
+    try {
+      const body = new FormData();
+      body.append('file', file);
+      const response = await authenticatedFetch('/api/decode-qr', { method: 'POST', body });
+      const data = await response.json();
+      if (!response.ok) {
+        alert(data.error || 'QRコードを読み取れませんでした');
+        return;
+      }
+      const result = data.results[0];
+      if (result.type === 'exchange' && result.token) {
+        router.push(`/exchange?token=${encodeURIComponent(result.token)}`);
+      } else if (result.type === 'profile' && result.profile) {
+        router.push(`/exchange?profileId=${result.profile.public_id}`);
+      } else if (result.error) {
+        alert(result.error);
+      } else {
+        handleScanResult(result.content);
+      }
+    } catch (err) {
+      console.error('Error decoding QR code image:', err);
+      alert('QRコードを読み取れませんでした');
+    } finally {
+      setDecoding(false);
+    }
+  };
+
   const handleScannerClose = () => {
     setIsScannerOpen(false);
   };
 
+  const selected = profiles.find((profile) => profile.id === selectedProfile);
+
   return (
     <div className={styles.container}>
       <main className={styles.main}>
@@ -143,15 +187,25 @@ export default function QRPage() {
                 ))}
               </select>
 
-              {selectedProfile && <QRGenerator profileId={selectedProfile} />}
+              {selected && <QRGenerator profileId={selected.id} />}
 
-              <p className={styles.paragraph}>選択中のプロフィールID: {selectedProfile}</p>
+              <p className={styles.paragraph}>選択中のプロフィール: {selected?.title}</p>
             </>
           )}
 
           <button className={styles.scanButton} onClick={handleScanClick}>
             QRコードを読み取る
           </button>
+          <label className={styles.scanButton}>
+            {decoding ? '読み取り中...' : '画像から読み取る'}
+            <input
+              type="file"
+              accept="image/png,image/jpeg,image/gif"
+              onChange={handleImageUpload}
+              disabled={decoding}
+              hidden
+            />
+          </label>
         </div>
       </main>
 
@@ -0,0 +1,96 @@
+'use client';
+
+import { Suspense, useState } from 'react';
+import { useRouter, useSearchParams } from 'next/navigation';
+import { getApiBaseUrl } from '@/utils/config';
+import styles from '../login/page.module.css';
+import Link from 'next/link';
+
+function ResetPasswordForm() {
+  const router = useRouter();
+  const token = useSearchParams().get('token') ?? '';
+  const [formData, setFormData] = useState({ password: '', confirm: '' });
+  const [error, setError] = useState('');
+
+  const handleChange = (e: React.ChangeEvent<HTMLInputElement>) =>
+    setFormData({ ...formData, [e.target.name]: e.target.value });
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError('');
+
+    if (formData.password !== formData.confirm) {
+      setError('パスワードが一致しません');
+      return;
+    }
+
+    try {
+      const res = await fetch(`${getApiBaseUrl()}/api/password/reset`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ token, password: formData.password }),
+      });
+      const data = await res.json();
+      if (!res.ok) throw new Error(data.error || 'パスワードの再設定に失敗しました');
+      alert(data.message);
+      router.replace('/login');
+    } catch (err: unknown) {
+      setError(err instanceof Error ? err.message : '予期しないエラーが発生しました');
+    }
+  };
+
+  if (!token) {
+    return <p className={styles.error}>リセット用のリンクが正しくありません</p>;
+  }
+
+  return (
+    <form onSubmit={handleSubmit} className={styles.form}>
+      <label>
+        新しいパスワード（8文字以上）
+        <input
+          type="password"
+          name="password"
+          required
+          minLength={8}
+          value={formData.password}
+          onChange={handleChange}
+        />
+      </label>
+
+      <label>
+        新しいパスワード（確認）
+        <input
+          type="password"
+          name="confirm"
+          required
+          minLength={8}
+          value={formData.confirm}
+          onChange={handleChange}
+        />
+      </label>
+
+      {error && <p className={styles.error}>{error}</p>}
+
+      <button type="submit" className={styles.submitButton}>
+        Reset Password
+      </button>
+    </form>
+  );
+}
+
+export default function ResetPasswordPage() {
+  return (
+    <div className={styles.container}>
+      <Link href="/login" className={styles.backLink}>
+        &lt; Back Page
+      </Link>
+
+      <div className={styles.overlay}>
+        <h1 className={styles.title}>Reset Password</h1>
+        <Suspense>
+          <ResetPasswordForm />
+        </Suspense>
+      </div>
+    </div>
+  );
+}
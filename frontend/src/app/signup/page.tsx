@@ -2,7 +2,7 @@
 
 import { useState } from 'react';
 import { useRouter } from 'next/navigation';
-import { setUser, setToken } from '@/utils/auth';
+import { setUser, setToken, setRefreshToken } from '@/utils/auth';
 import styles from './page.module.css';
 import Link from 'next/link';
 
@@ -38,6 +38,7 @@ export default function SignupPage() {
       setUser(data.user);
       if (data.token) {
         setToken(data.token);
+        if (data.refresh_token) setRefreshToken(data.refresh_token);
         console.log('Token saved successfully');
       }
       router.replace('/');
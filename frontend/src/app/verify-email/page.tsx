@@ -0,0 +1,79 @@
+'use client';
+
+import { Suspense, useEffect, useState } from 'react';
+import { useSearchParams } from 'next/navigation';
+import { authenticatedFetch, getToken, getUser, setUser } from '@/utils/auth';
+import { getApiBaseUrl } from '@/utils/config';
+import styles from '../login/page.module.css';
+import Link from 'next/link';
+
+function VerifyEmail() {
+  const token = useSearchParams().get('token') ?? '';
+  const [message, setMessage] = useState('');
+  const [error, setError] = useState('');
+
+  useEffect(() => {
+    if (!token) {
+      setError('確認用のリンクが正しくありません');
+      return;
+    }
+
+    (async () => {
+      try {
+        const res = await fetch(`${getApiBaseUrl()}/api/email/verify`, {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({ token }),
+        });
+        const data = await res.json();
+        if (!res.ok) throw new Error(data.error || 'メールアドレスの確認に失敗しました');
+        // ログイン中のユーザーと同じなら確認状態を反映する
+        if (getUser()?.id === data.user?.id) setUser(data.user);
+        setMessage(data.message);
+      } catch (err: unknown) {
+        setError(err instanceof Error ? err.message : '予期しないエラーが発生しました');
+      }
+    })();
+  }, [token]);
+
+  const handleResend = async () => {
+    setError('');
+    try {
+      const res = await authenticatedFetch('/api/email/verification', { method: 'POST' });
+      const data = await res.json();
+      if (!res.ok) throw new Error(data.error || '確認メールの送信に失敗しました');
+      setMessage(data.message);
+    } catch (err: unknown) {
+      setError(err instanceof Error ? err.message : '予期しないエラーが発生しました');
+    }
+  };
+
+  return (
+    <>
+      {message && <p>{message}</p>}
+      {error && <p className={styles.error}>{error}</p>}
+      {error && getToken() && (
+        <button type="button" className={styles.submitButton} onClick={handleResend}>
+          確認メールを再送する
+        </button>
+      )}
+    </>
+  );
+}
+
+export default function VerifyEmailPage() {
+  return (
+    <div className={styles.container}>
+      <Link href="/" className={styles.backLink}>
+        &lt; Back Page
+      </Link>
+
+      <div className={styles.overlay}>
+        <h1 className={styles.title}>Verify Email</h1>
+        <Suspense>
+          <VerifyEmail />
+        </Suspense>
+      </div>
+    </div>
+  );
+}
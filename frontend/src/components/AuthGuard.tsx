@@ -8,6 +8,8 @@ import { getUser, getToken } from '@/utils/auth';
  * 認証ガード  
  * - ログインしていなければ /auth へ  
  * - ログイン済みで /auth|/login|/signup に来たら /mypage へ  
+ * - メール確認ページはログイン状態に関わらず表示する  
+ * - 外部ログインのコールバック（/auth/callback/*）は認証不要  
  */
 export default function AuthGuard({
   children,
@@ -18,12 +20,19 @@ export default function AuthGuard({
   const pathname = usePathname();
 
   // 認証不要ページ（useMemoで固定）
-  const publicPaths = useMemo(() => ['/auth', '/login', '/signup'], []);
+  const publicPaths = useMemo(
+    () => ['/auth', '/login', '/signup', '/forgot-password', '/reset-password'],
+    []
+  );
+  // ログイン状態に関わらず表示するページ
+  const openPaths = useMemo(() => ['/verify-email'], []);
 
   useEffect(() => {
+    if (openPaths.includes(pathname)) return;
+
     const user = getUser();
     const token = getToken();
-    const isPublic = publicPaths.includes(pathname);
+    const isPublic = publicPaths.includes(pathname) || pathname.startsWith('/auth/callback/');
 
     console.log('AuthGuard - Path:', pathname);
     console.log('AuthGuard - User:', user);
@@ -37,7 +46,7 @@ export default function AuthGuard({
       console.log('AuthGuard - User exists on public page, redirecting to home');
       router.replace('/');
     }
-  }, [pathname, router, publicPaths]);
+  }, [pathname, router, publicPaths, openPaths]);
 
   return <>{children}</>;
 }
@@ -6,8 +6,8 @@ import { useRouter } from 'next/navigation';
 export default function LogoutButton() {
   const router = useRouter();
 
-  const handleLogout = () => {
-    logout();        // localStorage から user と token を削除
+  const handleLogout = async () => {
+    await logout();  // サーバー側でトークンを失効させ、localStorage から削除
     router.replace('/auth'); // 認証ガードが働くので /auth に遷移
   };
 
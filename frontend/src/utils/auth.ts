@@ -2,12 +2,14 @@ export type User = {
   id: number;
   name: string;
   email: string;
+  email_verified_at?: string | null;
 };
 
 import { getApiBaseUrl } from './config';
 
 const USER_KEY = 'user';
 const TOKEN_KEY = 'token';
+const REFRESH_TOKEN_KEY = 'refresh_token';
 
 export const getUser = (): User | null => {
   if (typeof window === 'undefined') return null;
@@ -36,9 +38,72 @@ export const clearToken = () => {
   if (typeof window !== 'undefined') localStorage.removeItem(TOKEN_KEY);
 };
 
-export const logout = () => {
+export const getRefreshToken = (): string | null => {
+  if (typeof window === 'undefined') return null;
+  return localStorage.getItem(REFRESH_TOKEN_KEY);
+};
+
+export const setRefreshToken = (token: string) => {
+  if (typeof window !== 'undefined') localStorage.setItem(REFRESH_TOKEN_KEY, token);
+};
+
+export const clearRefreshToken = () => {
+  if (typeof window !== 'undefined') localStorage.removeItem(REFRESH_TOKEN_KEY);
+};
+
+// サーバー側でトークンを失効させてからローカルの情報を削除する
+export const logout = async () => {
+  const token = getToken();
+  const refreshToken = getRefreshToken();
+  if (token) {
+    try {
+      await fetch(`${getApiBaseUrl()}/api/signout`, {
+        method: 'POST',
+        headers: {
+          Authorization: `Bearer ${token}`,
+          'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({ refresh_token: refreshToken ?? '' }),
+      });
+    } catch (err) {
+      console.error('Sign out request failed:', err);
+    }
+  }
   clearUser();
   clearToken();
+  clearRefreshToken();
+};
+
+// リフレッシュトークンで新しいトークンを取得する（同時に呼ばれても1回だけリクエストする）
+let refreshPromise: Promise<string | null> | null = null;
+
+export const refreshAccessToken = (): Promise<string | null> => {
+  if (!refreshPromise) {
+    refreshPromise = (async () => {
+      const refreshToken = getRefreshToken();
+      if (!refreshToken) return null;
+
+      const res = await fetch(`${getApiBaseUrl()}/api/token/refresh`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ refresh_token: refreshToken }),
+      });
+      if (!res.ok) {
+        clearToken();
+        clearRefreshToken();
+        return null;
+      }
+
+      const data = await res.json();
+      setToken(data.token);
+      setRefreshToken(data.refresh_token);
+      if (data.user) setUser(data.user);
+      return data.token as string;
+    })().finally(() => {
+      refreshPromise = null;
+    });
+  }
+  return refreshPromise;
 };
 
 // 認証されたAPIリクエストを行うためのヘルパー関数
@@ -51,7 +116,10 @@ export const authenticatedFetch = async (url: string, options: RequestInit = {})
 
   const headers = new Headers(options.headers);
   headers.set('Authorization', `Bearer ${token}`);
-  headers.set('Content-Type', 'application/json');
+  // FormDataはブラウザがboundary付きのContent-Typeを設定する
+  if (!(options.body instanceof FormData)) {
+    headers.set('Content-Type', 'application/json');
+  }
 
   console.log('Making authenticated request to:', url);
   console.log('With token:', token ? 'Present' : 'Missing');
@@ -63,6 +131,16 @@ export const authenticatedFetch = async (url: string, options: RequestInit = {})
     
   console.log('Final API URL:', apiUrl);
     
+  const res = await fetch(apiUrl, {
+    ...options,
+    headers,
+  });
+  if (res.status !== 401) return res;
+
+  // アクセストークンの期限切れならリフレッシュして1回だけ再試行する
+  const newToken = await refreshAccessToken();
+  if (!newToken) return res;
+  headers.set('Authorization', `Bearer ${newToken}`);
   return fetch(apiUrl, {
     ...options,
     headers,